@@ -1,9 +1,12 @@
 package cli
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/term"
 	"github.com/TakahashiShuuhei/gmacs/domain"
@@ -11,9 +14,21 @@ import (
 	"github.com/TakahashiShuuhei/gmacs/util"
 )
 
+// Display draws the editor on the terminal. Each frame is drawn into a
+// back buffer of cells, and only the cells that differ from the previous
+// frame are sent to the terminal, in one write.
 type Display struct {
-	width  int
-	height int
+	width     int
+	height    int
+	out       *bufio.Writer
+	front     *screen // Frame shown on the terminal, nil to repaint everything
+	back      *screen // Frame being drawn
+	penRow    int     // Where the next text is drawn
+	penCol    int
+	cursorRow int // Where the terminal cursor is left after the frame
+	cursorCol int
+	profile   ColorProfile
+	styles    map[string]string // Escape sequence of each face in this frame
 }
 
 func NewDisplay() *Display {
@@ -26,34 +41,71 @@ func NewDisplay() *Display {
 	
 	log.Info("Initial terminal size: %dx%d", width, height)
 	
+	display := NewDisplayWithWriter(os.Stdout, width, height)
+	display.profile = DetectColorProfile(os.Getenv)
+	return display
+}
+
+// NewDisplayWithWriter creates a display of the given size that writes its
+// escape sequences to w, with the 16 colors of the terminal palette
+func NewDisplayWithWriter(w io.Writer, width, height int) *Display {
 	return &Display{
-		width:  width,
-		height: height,
+		width:   width,
+		height:  height,
+		out:     bufio.NewWriterSize(w, 64*1024),
+		profile: Color16,
 	}
 }
 
+// SetColorProfile sets the colors faces are drawn with
+func (d *Display) SetColorProfile(profile ColorProfile) {
+	d.profile = profile
+}
+
+// Clear makes the next Render clear the terminal and repaint every cell
 func (d *Display) Clear() {
-	fmt.Print("\033[2J\033[H")
+	d.front = nil
 }
 
 // ClearAndExit clears the screen and prepares for clean exit
 func (d *Display) ClearAndExit() {
 	// Clear entire screen
-	fmt.Print("\033[2J")
+	d.out.WriteString("\033[2J")
 	// Move cursor to top-left
-	fmt.Print("\033[H")
+	d.out.WriteString("\033[H")
 	// Show cursor (in case it was hidden)
-	fmt.Print("\033[?25h")
+	d.out.WriteString("\033[?25h")
 	// Reset terminal attributes
-	fmt.Print("\033[0m")
+	d.out.WriteString("\033[0m")
+	d.out.Flush()
+	d.front = nil
 }
 
+// MoveCursor sets where the terminal cursor is left when the frame has been
+// drawn
 func (d *Display) MoveCursor(row, col int) {
-	fmt.Printf("\033[%d;%dH", row+1, col+1)
+	d.cursorRow, d.cursorCol = row, col
+}
+
+// moveTo moves the position where the next text is drawn
+func (d *Display) moveTo(row, col int) {
+	d.penRow, d.penCol = row, col
+}
+
+// draw draws s in face at the drawing position and moves past it
+func (d *Display) draw(s, face string) {
+	style, ok := d.styles[face]
+	if !ok {
+		style = d.styles[domain.FaceDefault]
+	}
+	for _, r := range s {
+		d.penCol += d.back.set(d.penRow, d.penCol, r, style)
+	}
 }
 
 func (d *Display) Render(editor *domain.Editor) {
-	d.Clear()
+	d.beginFrame(editor)
+	defer d.flush()
 	
 	layout := editor.Layout()
 	if layout == nil {
@@ -67,20 +119,23 @@ func (d *Display) Render(editor *domain.Editor) {
 	// Render all windows with their individual mode lines
 	for _, node := range windowNodes {
 		if node.Window != nil {
-			d.renderWindow(node)
-			d.renderWindowModeLine(node)
+			d.renderWindow(node, editor)
+			d.renderWindowModeLine(node, editor)
 		}
 	}
 	
 	// Render window borders for split windows
 	d.renderWindowBorders(layout)
 	
+	// Render the completion candidates above the minibuffer
+	d.renderCompletionList(editor)
+	
 	// Render minibuffer at bottom
 	d.renderMinibuffer(editor)
 	
 	// Position cursor based on whether minibuffer is active
 	minibuffer := editor.Minibuffer()
-	if minibuffer.IsActive() && (minibuffer.Mode() == domain.MinibufferCommand || minibuffer.Mode() == domain.MinibufferFile) {
+	if minibuffer.IsEditing() {
 		// Position cursor in minibuffer (last line)
 		promptLen := util.StringWidth(minibuffer.Prompt())
 		
@@ -106,11 +161,37 @@ func (d *Display) Render(editor *domain.Editor) {
 //   This function is no longer used in the multi-window layout system
 // }
 
+// beginFrame starts drawing a frame on cells blank in the default face
+func (d *Display) beginFrame(editor *domain.Editor) {
+	d.styles = faceStyles(editor.Faces(), d.profile)
+	if d.back == nil || d.back.width != d.width || d.back.height != d.height {
+		d.back = newScreen(d.width, d.height)
+	}
+	d.back.blank = cell{r: ' ', width: 1, style: d.styles[domain.FaceDefault]}
+	d.back.clear()
+}
+
+// flush sends the changes of the frame to the terminal in one write, with
+// the cursor hidden while they are drawn
+func (d *Display) flush() {
+	d.out.WriteString("\033[?25l")
+	if d.front == nil || d.front.width != d.width || d.front.height != d.height {
+		d.out.WriteString("\033[0m\033[2J")
+		d.front = newScreen(d.width, d.height)
+	}
+	writeDiff(d.out, d.front, d.back)
+	fmt.Fprintf(d.out, "\033[%d;%dH\033[?25h", d.cursorRow+1, d.cursorCol+1)
+	if err := d.out.Flush(); err != nil {
+		log.Error("Failed to write to terminal: %v", err)
+	}
+	d.front, d.back = d.back, d.front
+}
+
 func (d *Display) renderMinibuffer(editor *domain.Editor) {
 	minibuffer := editor.Minibuffer()
 	
-	// Position cursor at the minibuffer line (last line of terminal)
-	d.MoveCursor(d.height-1, 0)
+	// Draw on the minibuffer line (last line of terminal)
+	d.moveTo(d.height-1, 0)
 	
 	var content string
 	
@@ -133,28 +214,74 @@ func (d *Display) renderMinibuffer(editor *domain.Editor) {
 			content = truncateToWidth(content, d.width)
 		}
 		
-		fmt.Print(content)
-		
-		// Pad with spaces to clear any remaining text
-		paddingLength := d.width - util.StringWidth(content)
-		if paddingLength > 0 {
-			padding := strings.Repeat(" ", paddingLength)
-			fmt.Print(padding)
+		prompt := minibuffer.Prompt()
+		if minibuffer.Mode() != domain.MinibufferMessage && prompt != "" && strings.HasPrefix(content, prompt) {
+			d.draw(prompt, domain.FaceMinibufferPrompt)
+			content = content[len(prompt):]
 		}
-	} else {
-		// Empty minibuffer line
-		padding := strings.Repeat(" ", d.width)
-		fmt.Print(padding)
+		d.draw(content, domain.FaceDefault)
+	}
+}
+
+// renderCompletionList draws the candidates of the minibuffer prompt on
+// the lines above it, covering the windows. Annotations are aligned after
+// the longest candidate shown.
+func (d *Display) renderCompletionList(editor *domain.Editor) {
+	height := editor.CompletionListHeight()
+	if height > d.height-1 {
+		height = d.height - 1
+	}
+	candidates, selected, ok := editor.Minibuffer().CompletionList(height)
+	if !ok {
+		return
+	}
+	if len(candidates) == 0 {
+		d.moveTo(d.height-2, 0)
+		d.draw(padToWidth("[No match]", d.width), domain.FaceDefault)
+		return
 	}
+	
+	column := 0
+	for _, candidate := range candidates {
+		if width := util.StringWidth(candidate.Text); width > column {
+			column = width
+		}
+	}
+	column += 2
+	
+	top := d.height - 1 - len(candidates)
+	for i, candidate := range candidates {
+		face := domain.FaceDefault
+		if i == selected {
+			face = domain.FaceCompletionSelected
+		}
+		d.moveTo(top+i, 0)
+		d.draw(padToWidth(truncateToWidth(candidate.Text, d.width), d.width), face)
+		if candidate.Annotation != "" && column < d.width {
+			d.moveTo(top+i, column)
+			d.draw(truncateToWidth(candidate.Annotation, d.width-column), domain.FaceCompletionAnnotation)
+		}
+	}
+}
+
+// padToWidth pads s with spaces to the given display width
+func padToWidth(s string, width int) string {
+	if padding := width - util.StringWidth(s); padding > 0 {
+		return s + strings.Repeat(" ", padding)
+	}
+	return s
 }
 
 func (d *Display) Size() (int, int) {
 	return d.width, d.height
 }
 
+// Resize changes the size of the display. The next Render repaints every
+// cell.
 func (d *Display) Resize(width, height int) {
 	d.width = width
 	d.height = height
+	d.front = nil
 }
 
 func (d *Display) ShowMessage(msg string) {
@@ -182,45 +309,139 @@ func truncateToWidth(s string, maxWidth int) string {
 }
 
 // renderWindow renders a single window at its designated position
-func (d *Display) renderWindow(node *domain.WindowLayoutNode) {
+func (d *Display) renderWindow(node *domain.WindowLayoutNode, editor *domain.Editor) {
 	if node.Window == nil {
 		return
 	}
 	
 	window := node.Window
-	lines := window.VisibleLines()
+	lines := window.DisplayLines()
 	_, windowContentHeight := window.Size()
+	highlighter := newLineHighlighter(window.Buffer(), editor.SearchHighlight())
 	
 	// Render each line of the window content
-	for i := 0; i < windowContentHeight; i++ {
-		// Position cursor at the start of this line
-		d.MoveCursor(node.Y+i, node.X)
-		
-		if i < len(lines) {
-			line := lines[i]
-			
-			// Truncate line to fit window width
-			if util.StringWidth(line) > node.Width {
-				line = truncateToWidth(line, node.Width)
-			}
-			
-			// Pad line to window width to clear any previous content
-			lineWidth := util.StringWidth(line)
-			if lineWidth < node.Width {
-				padding := strings.Repeat(" ", node.Width-lineWidth)
-				line = line + padding
+	for i := 0; i < windowContentHeight && i < len(lines); i++ {
+		d.moveTo(node.Y+i, node.X)
+		d.renderDisplayLine(lines[i], node.Width, highlighter)
+	}
+}
+
+// lineHighlighter decides the face of each character shown in a window
+type lineHighlighter struct {
+	buffer      *domain.Buffer
+	region      bool
+	regionStart domain.Position
+	regionEnd   domain.Position
+	search      domain.SearchHighlight
+	matches     map[int][][]int // Search matches per buffer row
+	syntaxRow   int
+	syntax      []domain.HighlightSegment // Syntax highlighting of syntaxRow
+}
+
+func newLineHighlighter(buffer *domain.Buffer, search domain.SearchHighlight) *lineHighlighter {
+	h := &lineHighlighter{syntaxRow: -1}
+	if buffer == nil {
+		return h
+	}
+	h.buffer = buffer
+	if buffer.MarkActive() {
+		h.regionStart, h.regionEnd, h.region = buffer.Region()
+	}
+	if search != nil && search.Buffer() == buffer {
+		h.search = search
+		h.matches = make(map[int][][]int)
+	}
+	return h
+}
+
+// faceAt returns the face of the character at pos
+func (h *lineHighlighter) faceAt(pos domain.Position) string {
+	if h.search != nil {
+		if start, end, ok := h.search.CurrentMatch(); ok && !pos.Before(start) && pos.Before(end) {
+			return domain.FaceISearch
+		}
+		matches, cached := h.matches[pos.Row]
+		if !cached {
+			matches = h.search.LineMatches(h.buffer.Line(pos.Row))
+			h.matches[pos.Row] = matches
+		}
+		for _, m := range matches {
+			if pos.Col >= m[0] && pos.Col < m[1] {
+				return domain.FaceLazyHighlight
 			}
-			
-			fmt.Print(line)
-		} else {
-			// Empty line - fill with spaces
-			fmt.Print(strings.Repeat(" ", node.Width))
 		}
 	}
+	if h.inRegion(pos) {
+		return domain.FaceRegion
+	}
+	return h.syntaxFaceAt(pos)
+}
+
+// syntaxFaceAt returns the syntax face at pos
+func (h *lineHighlighter) syntaxFaceAt(pos domain.Position) string {
+	if h.buffer == nil {
+		return domain.FaceDefault
+	}
+	if pos.Row != h.syntaxRow {
+		h.syntaxRow = pos.Row
+		h.syntax = h.buffer.Highlights(pos.Row)
+	}
+	for _, s := range h.syntax {
+		if pos.Col >= s.Start && pos.Col < s.End {
+			return s.Style
+		}
+	}
+	return domain.FaceDefault
+}
+
+func (h *lineHighlighter) inRegion(pos domain.Position) bool {
+	return h.region && !pos.Before(h.regionStart) && pos.Before(h.regionEnd)
+}
+
+// renderDisplayLine draws one screen line with its highlighting and returns
+// the number of columns used
+func (d *Display) renderDisplayLine(line domain.DisplayLine, maxWidth int, h *lineHighlighter) int {
+	width := 0
+	
+	if line.LeftIndicator {
+		d.draw("\\", domain.FaceDefault)
+		width++
+	}
+	
+	text := line.Text
+	if line.LeftIndicator {
+		text = text[1:]
+	}
+	if line.RightIndicator {
+		text = text[:len(text)-1]
+	}
+	
+	col := line.StartCol
+	for _, r := range text {
+		charWidth := util.RuneWidth(r)
+		if width+charWidth > maxWidth {
+			break
+		}
+		d.draw(string(r), h.faceAt(domain.Position{Row: line.Row, Col: col}))
+		width += charWidth
+		col += utf8.RuneLen(r)
+	}
+	
+	atLineEnd := h.buffer != nil && line.EndCol == len(h.buffer.Line(line.Row))
+	if line.RightIndicator && width < maxWidth {
+		d.draw("\\", domain.FaceDefault)
+		width++
+	} else if atLineEnd && col == line.EndCol && width < maxWidth && h.inRegion(domain.Position{Row: line.Row, Col: col}) {
+		// The newline at the end of the line is part of the region
+		d.draw(" ", domain.FaceRegion)
+		width++
+	}
+	
+	return width
 }
 
 // renderWindowModeLine renders the mode line for a specific window
-func (d *Display) renderWindowModeLine(node *domain.WindowLayoutNode) {
+func (d *Display) renderWindowModeLine(node *domain.WindowLayoutNode, editor *domain.Editor) {
 	if node.Window == nil || node.Window.Buffer() == nil {
 		return
 	}
@@ -231,8 +452,8 @@ func (d *Display) renderWindowModeLine(node *domain.WindowLayoutNode) {
 	// Mode line appears right after the window content
 	modeLineRow := node.Y + windowContentHeight
 	
-	// Position cursor at the mode line position for this window
-	d.MoveCursor(modeLineRow, node.X)
+	// Draw at the mode line position for this window
+	d.moveTo(modeLineRow, node.X)
 	
 	// Create mode line content with major mode and minor modes
 	majorModeName := "Fundamental"
@@ -242,14 +463,23 @@ func (d *Display) renderWindowModeLine(node *domain.WindowLayoutNode) {
 	
 	// Add minor modes
 	minorModeNames := ""
-	minorModes := buffer.MinorModes()
-	if len(minorModes) > 0 {
-		for i, mode := range minorModes {
-			if i > 0 {
-				minorModeNames += " "
-			}
-			minorModeNames += mode.Name()
+	for _, mode := range buffer.MinorModes() {
+		lighter := mode.Lighter()
+		if lighter == "" {
+			continue
 		}
+		if minorModeNames != "" {
+			minorModeNames += " "
+		}
+		minorModeNames += lighter
+	}
+	if editor.DefiningKbdMacro() {
+		if minorModeNames != "" {
+			minorModeNames += " "
+		}
+		minorModeNames += "Def"
+	}
+	if minorModeNames != "" {
 		minorModeNames = " [" + minorModeNames + "]"
 	}
 	
@@ -266,8 +496,11 @@ func (d *Display) renderWindowModeLine(node *domain.WindowLayoutNode) {
 	
 	padding := strings.Repeat("-", paddingLength)
 	
-	// Apply reverse video (black text on white background) for mode line
-	fmt.Printf("\033[7m%s%s\033[0m", modeLine, padding)
+	face := domain.FaceModeLineInactive
+	if node.Window == editor.CurrentWindow() {
+		face = domain.FaceModeLine
+	}
+	d.draw(modeLine+padding, face)
 }
 
 // positionCursorInWindow positions the cursor in the current window
@@ -331,8 +564,8 @@ func (d *Display) renderBordersForNode(node *domain.WindowLayoutNode) {
 		// Vertical split: draw vertical line between left and right
 		borderX := node.Left.X + node.Left.Width
 		for y := node.Y; y < node.Y+node.Height-1; y++ { // -1 to avoid overwriting mode line
-			d.MoveCursor(y, borderX)
-			fmt.Print("│")
+			d.moveTo(y, borderX)
+			d.draw("│", domain.FaceDefault)
 		}
 	} else if node.SplitType == domain.SplitHorizontal && node.Left != nil && node.Right != nil {
 		// Horizontal split: the mode line already serves as the border
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+// ColorProfile is the set of colors a terminal can show. Faces are drawn
+// with the closest colors the profile has.
+type ColorProfile int
+
+const (
+	ColorNone      ColorProfile = iota // Attributes only, as on a dumb terminal
+	Color16                            // The terminal palette
+	Color256                           // The xterm 256 color palette
+	ColorTrueColor                     // 24-bit RGB
+)
+
+// DetectColorProfile guesses the colors of the terminal from the COLORTERM
+// and TERM environment variables
+func DetectColorProfile(getenv func(string) string) ColorProfile {
+	colorterm := strings.ToLower(getenv("COLORTERM"))
+	term := strings.ToLower(getenv("TERM"))
+	switch {
+	case colorterm == "truecolor" || colorterm == "24bit" || strings.HasSuffix(term, "-direct"):
+		return ColorTrueColor
+	case term == "dumb":
+		return ColorNone
+	case strings.Contains(term, "256color"):
+		return Color256
+	}
+	return Color16
+}
+
+// paletteRGB are the usual RGB values of the 16 palette colors, used to
+// find the closest one to an RGB color
+var paletteRGB = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// faceStyles returns the escape sequence of each face. Colors a face leaves
+// unset are those of the default face.
+func faceStyles(faces *domain.Faces, profile ColorProfile) map[string]string {
+	base, _ := faces.Get(domain.FaceDefault)
+	styles := make(map[string]string)
+	for _, name := range faces.Names() {
+		face, _ := faces.Get(name)
+		if face.Foreground == "" {
+			face.Foreground = base.Foreground
+		}
+		if face.Background == "" {
+			face.Background = base.Background
+		}
+		face.Bold = face.Bold || base.Bold
+		face.Italic = face.Italic || base.Italic
+		face.Underline = face.Underline || base.Underline
+		face.Reverse = face.Reverse || base.Reverse
+		styles[name] = faceStyle(face, profile)
+	}
+	return styles
+}
+
+// faceStyle returns the SGR escape sequence drawing text in face, "" for
+// the terminal default
+func faceStyle(face domain.Face, profile ColorProfile) string {
+	var params []string
+	for _, attr := range []struct {
+		on   bool
+		code string
+	}{{face.Bold, "1"}, {face.Italic, "3"}, {face.Underline, "4"}, {face.Reverse, "7"}} {
+		if attr.on {
+			params = append(params, attr.code)
+		}
+	}
+	if fg := colorParam(face.Foreground, false, profile); fg != "" {
+		params = append(params, fg)
+	}
+	if bg := colorParam(face.Background, true, profile); bg != "" {
+		params = append(params, bg)
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "\033[" + strings.Join(params, ";") + "m"
+}
+
+// colorParam returns the SGR parameter setting color as the foreground or
+// the background
+func colorParam(color string, background bool, profile ColorProfile) string {
+	if color == "" || profile == ColorNone {
+		return ""
+	}
+	for i, name := range domain.ColorNames {
+		if color == name {
+			return paletteParam(i, background)
+		}
+	}
+
+	rgb, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+	if err != nil {
+		return ""
+	}
+	r, g, b := int(rgb>>16&0xff), int(rgb>>8&0xff), int(rgb&0xff)
+	prefix := "38"
+	if background {
+		prefix = "48"
+	}
+	switch profile {
+	case ColorTrueColor:
+		return prefix + ";2;" + strconv.Itoa(r) + ";" + strconv.Itoa(g) + ";" + strconv.Itoa(b)
+	case Color256:
+		return prefix + ";5;" + strconv.Itoa(xterm256(r, g, b))
+	}
+	return paletteParam(nearestPalette(r, g, b), background)
+}
+
+// paletteParam returns the SGR parameter for palette color i
+func paletteParam(i int, background bool) string {
+	code := 30 + i
+	if i >= 8 {
+		code = 90 + i - 8
+	}
+	if background {
+		code += 10
+	}
+	return strconv.Itoa(code)
+}
+
+// nearestPalette returns the palette color closest to r, g, b
+func nearestPalette(r, g, b int) int {
+	best, bestDistance := 0, -1
+	for i, c := range paletteRGB {
+		if d := distance(r, g, b, c[0], c[1], c[2]); bestDistance < 0 || d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+	return best
+}
+
+// cubeLevels are the component values of the 6x6x6 color cube of the xterm
+// 256 color palette
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// xterm256 returns the color of the 256 color palette closest to r, g, b,
+// from the color cube or the gray ramp
+func xterm256(r, g, b int) int {
+	nearestLevel := func(v int) int {
+		best := 0
+		for i, level := range cubeLevels {
+			if abs(v-level) < abs(v-cubeLevels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	ri, gi, bi := nearestLevel(r), nearestLevel(g), nearestLevel(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDistance := distance(r, g, b, cubeLevels[ri], cubeLevels[gi], cubeLevels[bi])
+
+	gray := (r + g + b) / 3
+	grayIndex := (gray - 3) / 10
+	if grayIndex < 0 {
+		grayIndex = 0
+	} else if grayIndex > 23 {
+		grayIndex = 23
+	}
+	level := 8 + 10*grayIndex
+	if distance(r, g, b, level, level, level) < cubeDistance {
+		return 232 + grayIndex
+	}
+	return cube
+}
+
+func distance(r1, g1, b1, r2, g2, b2 int) int {
+	return (r1-r2)*(r1-r2) + (g1-g2)*(g1-g2) + (b1-b2)*(b1-b2)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/TakahashiShuuhei/gmacs/util"
+)
+
+// A frame is drawn into a screen of cells and then compared with the
+// screen last sent to the terminal, so that only the cells that changed
+// are written.
+
+// cell is one column of the terminal. A wide character fills two cells; the
+// second has width 0 and is not written itself.
+type cell struct {
+	r     rune
+	width int
+	style string // Escape sequence setting the style, "" for the default
+}
+
+// blankCell is an empty column in the default style
+var blankCell = cell{r: ' ', width: 1}
+
+// screen is a grid of cells
+type screen struct {
+	width  int
+	height int
+	cells  [][]cell
+	blank  cell // Cell an empty column is filled with
+}
+
+func newScreen(width, height int) *screen {
+	s := &screen{width: width, height: height, cells: make([][]cell, height), blank: blankCell}
+	for row := range s.cells {
+		s.cells[row] = make([]cell, width)
+	}
+	s.clear()
+	return s
+}
+
+// clear fills the screen with blank cells
+func (s *screen) clear() {
+	for _, line := range s.cells {
+		for col := range line {
+			line[col] = s.blank
+		}
+	}
+}
+
+// set puts r at row, col and returns the number of columns it takes.
+// Characters outside the screen or without width are dropped.
+func (s *screen) set(row, col int, r rune, style string) int {
+	width := util.RuneWidth(r)
+	if width == 0 || row < 0 || row >= s.height || col < 0 || col+width > s.width {
+		return 0
+	}
+
+	s.clearWide(row, col)
+	if width == 2 {
+		s.clearWide(row, col+1)
+	}
+	s.cells[row][col] = cell{r: r, width: width, style: style}
+	if width == 2 {
+		s.cells[row][col+1] = cell{style: style}
+	}
+	return width
+}
+
+// clearWide blanks the other half of a wide character at row, col before
+// the cell is overwritten
+func (s *screen) clearWide(row, col int) {
+	line := s.cells[row]
+	switch {
+	case line[col].width == 0 && col > 0:
+		line[col-1] = s.blank
+	case line[col].width == 2 && col+1 < s.width:
+		line[col+1] = s.blank
+	}
+}
+
+// maxSpanGap is the number of unchanged cells written rather than skipped
+// with a cursor movement, which takes about as many bytes
+const maxSpanGap = 6
+
+// writeDiff writes to w the escape sequences that turn the terminal showing
+// front into back
+func writeDiff(w *bufio.Writer, front, back *screen) {
+	for row := range back.cells {
+		writeRowDiff(w, row, front.cells[row], back.cells[row])
+	}
+}
+
+// writeRowDiff writes the spans of one row that differ
+func writeRowDiff(w *bufio.Writer, row int, front, back []cell) {
+	col := 0
+	for col < len(back) {
+		if back[col] == front[col] {
+			col++
+			continue
+		}
+
+		// Start at the first half of a wide character
+		start := col
+		for start > 0 && (back[start].width == 0 || front[start].width == 0) {
+			start--
+		}
+
+		// Extend the span over short runs of unchanged cells
+		end, gap := col, 0
+		for end < len(back) && gap <= maxSpanGap {
+			if back[end] == front[end] {
+				gap++
+			} else {
+				gap = 0
+			}
+			end++
+		}
+		end -= gap
+		for end < len(back) && back[end].width == 0 {
+			end++
+		}
+
+		writeSpan(w, row, start, back[start:end])
+		col = end
+	}
+}
+
+// writeSpan writes cells starting at row, col
+func writeSpan(w *bufio.Writer, row, col int, cells []cell) {
+	fmt.Fprintf(w, "\033[%d;%dH", row+1, col+1)
+	style := ""
+	for _, c := range cells {
+		if c.width == 0 {
+			continue
+		}
+		if c.style != style {
+			if style != "" {
+				w.WriteString("\033[0m")
+			}
+			w.WriteString(c.style)
+			style = c.style
+		}
+		w.WriteRune(c.r)
+	}
+	if style != "" {
+		w.WriteString("\033[0m")
+	}
+}
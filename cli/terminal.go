@@ -135,7 +135,13 @@ func (t *Terminal) parseInput(data []byte) {
 		log.Debug("UTF-8 parsing: %d runes from %d bytes: %+q", len(runes), len(data), runes)
 		
 		for _, r := range runes {
-			if r != '\ufffd' { // Valid UTF-8 character
+			if r < 0x20 || r == 0x7f {
+				// A control key after ESC, as in C-M-s, is decoded as
+				// when it comes alone
+				event := keyEventForByte(byte(r))
+				log.Debug("Sending event: key=%s, ctrl=%t, raw=%v", event.Key, event.Ctrl, event.Raw)
+				t.eventChan <- event
+			} else if r != '\ufffd' { // Valid UTF-8 character
 				event := events.KeyEventData{
 					Raw:  data,
 					Rune: r,
@@ -150,71 +156,87 @@ func (t *Terminal) parseInput(data []byte) {
 	
 	// Single byte processing
 	for i, b := range data {
-		event := events.KeyEventData{
-			Raw: []byte{b},
-		}
-		
 		log.Debug("Processing byte %d: 0x%02x (%d)", i, b, b)
-		
-		switch b {
-		case 1: // Ctrl+A
-			event.Key = "a"
-			event.Ctrl = true
-			log.Debug("Recognized Ctrl+A")
-		case 2: // Ctrl+B
-			event.Key = "b"
-			event.Ctrl = true
-			log.Debug("Recognized Ctrl+B")
-		case 3: // Ctrl+C
-			event.Key = "c"
-			event.Ctrl = true
-			log.Debug("Recognized Ctrl+C")
-		case 5: // Ctrl+E
-			event.Key = "e"
-			event.Ctrl = true
-			log.Debug("Recognized Ctrl+E")
-		case 6: // Ctrl+F
-			event.Key = "f"
-			event.Ctrl = true
-			log.Debug("Recognized Ctrl+F")
-		case 14: // Ctrl+N
-			event.Key = "n"
-			event.Ctrl = true
-			log.Debug("Recognized Ctrl+N")
-		case 16: // Ctrl+P
-			event.Key = "p"
-			event.Ctrl = true
-			log.Debug("Recognized Ctrl+P")
-		case 13: // Enter
-			event.Key = "Enter"
-			event.Rune = '\n'
-			log.Debug("Recognized Enter")
-		case 27: // ESC
-			event.Key = "\x1b"
-			log.Debug("Recognized Escape")
-		case 127: // Backspace
-			event.Key = "Backspace"
-			log.Debug("Recognized Backspace")
-		default:
-			if b >= 32 && b <= 126 {
-				event.Rune = rune(b)
-				event.Key = string(rune(b))
-				log.Debug("ASCII character: %c", b)
-			} else if b >= 1 && b <= 26 {
-				// Other Ctrl combinations
-				event.Key = string(rune('a' + b - 1))
-				event.Ctrl = true
-				log.Debug("Recognized Ctrl+%c", 'A'+b-1)
-			} else {
-				log.Debug("Non-printable byte: 0x%02x", b)
-			}
-		}
-		
+		event := keyEventForByte(b)
 		log.Debug("Sending event: key=%s, rune=%c, ctrl=%t, raw=%v", event.Key, event.Rune, event.Ctrl, event.Raw)
 		t.eventChan <- event
 	}
 }
 
+// keyEventForByte decodes a key that the terminal sends as one byte
+func keyEventForByte(b byte) events.KeyEventData {
+	event := events.KeyEventData{
+		Raw: []byte{b},
+	}
+
+	switch b {
+	case 0: // Ctrl+Space
+		event.Key = "SPC"
+		event.Ctrl = true
+		log.Debug("Recognized Ctrl+Space")
+	case 1: // Ctrl+A
+		event.Key = "a"
+		event.Ctrl = true
+		log.Debug("Recognized Ctrl+A")
+	case 2: // Ctrl+B
+		event.Key = "b"
+		event.Ctrl = true
+		log.Debug("Recognized Ctrl+B")
+	case 3: // Ctrl+C
+		event.Key = "c"
+		event.Ctrl = true
+		log.Debug("Recognized Ctrl+C")
+	case 5: // Ctrl+E
+		event.Key = "e"
+		event.Ctrl = true
+		log.Debug("Recognized Ctrl+E")
+	case 6: // Ctrl+F
+		event.Key = "f"
+		event.Ctrl = true
+		log.Debug("Recognized Ctrl+F")
+	case 14: // Ctrl+N
+		event.Key = "n"
+		event.Ctrl = true
+		log.Debug("Recognized Ctrl+N")
+	case 16: // Ctrl+P
+		event.Key = "p"
+		event.Ctrl = true
+		log.Debug("Recognized Ctrl+P")
+	case 13: // Enter
+		event.Key = "Enter"
+		event.Rune = '\n'
+		log.Debug("Recognized Enter")
+	case 27: // ESC
+		event.Key = "\x1b"
+		log.Debug("Recognized Escape")
+	case 28: // Ctrl+\
+		event.Key = "\\"
+		event.Ctrl = true
+		log.Debug("Recognized Ctrl+\\")
+	case 31: // Ctrl+/ (also Ctrl+_)
+		event.Key = "/"
+		event.Ctrl = true
+		log.Debug("Recognized Ctrl+/")
+	case 127: // Backspace
+		event.Key = "Backspace"
+		log.Debug("Recognized Backspace")
+	default:
+		if b >= 32 && b <= 126 {
+			event.Rune = rune(b)
+			event.Key = string(rune(b))
+			log.Debug("ASCII character: %c", b)
+		} else if b >= 1 && b <= 26 {
+			// Other Ctrl combinations
+			event.Key = string(rune('a' + b - 1))
+			event.Ctrl = true
+			log.Debug("Recognized Ctrl+%c", 'A'+b-1)
+		} else {
+			log.Debug("Non-printable byte: 0x%02x", b)
+		}
+	}
+	return event
+}
+
 func (t *Terminal) Close() {
 	close(t.eventChan)
 	signal.Stop(t.sigChan)
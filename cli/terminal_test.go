@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// parseKeys runs parseInput on one read of the terminal and returns the
+// keys it sends
+func parseKeys(t *testing.T, data string) []events.KeyEventData {
+	t.Helper()
+	terminal := &Terminal{eventChan: make(chan events.Event, len(data))}
+	terminal.parseInput([]byte(data))
+	close(terminal.eventChan)
+
+	var keys []events.KeyEventData
+	for event := range terminal.eventChan {
+		key, ok := event.(events.KeyEventData)
+		if !ok {
+			t.Fatalf("Expected key events from %q, got %T", data, event)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// TestParseInputMetaControl checks that a control key read together with
+// the ESC before it, as the terminal sends C-M- keys, keeps its Ctrl flag
+func TestParseInputMetaControl(t *testing.T) {
+	tests := []struct {
+		data string
+		key  string
+		ctrl bool
+	}{
+		{"\x1b\x13", "s", true},          // C-M-s
+		{"\x1b\x12", "r", true},          // C-M-r
+		{"\x1b\x1c", "\\", true},         // C-M-\ (indent-region)
+		{"\x1b\x7f", "Backspace", false}, // M-Backspace (backward-kill-word)
+		{"\x1b\x1f", "/", true},          // C-M-/ (redo)
+		{"\x1bx", "x", false},            // M-x
+	}
+	for _, test := range tests {
+		keys := parseKeys(t, test.data)
+		if len(keys) != 2 {
+			t.Errorf("Expected 2 keys from %q, got %+v", test.data, keys)
+			continue
+		}
+		if keys[0].Key != "\x1b" {
+			t.Errorf("Expected ESC first from %q, got %q", test.data, keys[0].Key)
+		}
+		if keys[1].Key != test.key || keys[1].Ctrl != test.ctrl {
+			t.Errorf("Expected key %q (ctrl %v) from %q, got %q (ctrl %v)",
+				test.key, test.ctrl, test.data, keys[1].Key, keys[1].Ctrl)
+		}
+	}
+}
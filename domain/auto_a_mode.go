@@ -76,6 +76,11 @@ func (am *AutoAMode) Priority() int {
 	return am.priority
 }
 
+// Lighter returns the mode line text
+func (am *AutoAMode) Lighter() string {
+	return am.name
+}
+
 // setupHooks sets up the auto-'a' insertion logic
 func (am *AutoAMode) setupHooks(buffer *Buffer) {
 	// In a real implementation, we'd use a proper hook system
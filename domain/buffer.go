@@ -1,20 +1,30 @@
 package domain
 
 import (
-	"bufio"
 	"os"
+	"path/filepath"
 	"strings"
 	"unicode/utf8"
 )
 
 type Buffer struct {
 	name       string
-	content    []string
+	lines      *lineRope
 	cursor     Position
 	modified   bool
 	filepath   string // File path if buffer is associated with a file
+	stamp      *fileStamp // File as last visited, saved or reverted, nil if unknown
+	warned     *fileStamp // Change on disk the user was last warned about
+	format     *lineFormat // How the visited file ends lines, nil for a new file
 	majorMode  MajorMode
 	minorModes []MinorMode
+	undo       undoLog
+	mark       Position
+	markSet    bool // Mark has been set at least once
+	markActive bool // Region is active (highlighted)
+	onChange   func(b *Buffer, start, end Position, text string, inserted bool)
+	syntax     syntaxCache
+	tabWidth   int // Columns between tab stops, 0 for DefaultTabWidth
 }
 
 type Position struct {
@@ -22,10 +32,15 @@ type Position struct {
 	Col int
 }
 
+// Before reports whether p comes before other in the buffer
+func (p Position) Before(other Position) bool {
+	return p.Row < other.Row || (p.Row == other.Row && p.Col < other.Col)
+}
+
 func NewBuffer(name string) *Buffer {
 	return &Buffer{
 		name:       name,
-		content:    []string{""},
+		lines:      newLineRope([]string{""}),
 		cursor:     Position{Row: 0, Col: 0},
 		filepath:   "",
 		majorMode:  nil, // Will be set by mode manager
@@ -35,26 +50,10 @@ func NewBuffer(name string) *Buffer {
 
 // NewBufferFromFile creates a new buffer and loads content from a file
 func NewBufferFromFile(filepath string) (*Buffer, error) {
-	file, err := os.Open(filepath)
+	lines, format, stamp, err := readFile(filepath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-	
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-	
-	// If file is empty, ensure at least one empty line
-	if len(lines) == 0 {
-		lines = []string{""}
-	}
 	
 	// Extract filename from path for buffer name
 	name := filepath
@@ -64,15 +63,27 @@ func NewBufferFromFile(filepath string) (*Buffer, error) {
 	
 	return &Buffer{
 		name:       name,
-		content:    lines,
+		lines:      newLineRope(lines),
 		cursor:     Position{Row: 0, Col: 0},
 		modified:   false,
 		filepath:   filepath,
+		stamp:      &stamp,
+		format:     &format,
 		majorMode:  nil, // Will be set by mode manager
 		minorModes: make([]MinorMode, 0),
 	}, nil
 }
 
+// NewBufferForFile creates an empty buffer visiting path, a file that does
+// not exist yet
+func NewBufferForFile(path string) *Buffer {
+	buffer := NewBuffer(filepath.Base(path))
+	buffer.filepath = path
+	stamp := missingFile
+	buffer.stamp = &stamp
+	return buffer
+}
+
 func (b *Buffer) Name() string {
 	return b.name
 }
@@ -81,8 +92,32 @@ func (b *Buffer) Filepath() string {
 	return b.filepath
 }
 
+// Content returns all lines of the buffer. The slice is built lazily and
+// shared until the next change, so callers must not modify it. Code that
+// only needs a few lines should use Line and LineCount instead.
 func (b *Buffer) Content() []string {
-	return b.content
+	return b.lines.Lines()
+}
+
+// LineCount returns the number of lines in the buffer
+func (b *Buffer) LineCount() int {
+	return b.lines.Len()
+}
+
+// Line returns line row of the buffer
+func (b *Buffer) Line(row int) string {
+	return b.lines.Line(row)
+}
+
+// Lines returns lines start through end-1 of the buffer
+func (b *Buffer) Lines(start, end int) []string {
+	if start < 0 {
+		start = 0
+	}
+	if end > b.lines.Len() {
+		end = b.lines.Len()
+	}
+	return b.lines.Slice(start, end)
 }
 
 func (b *Buffer) Cursor() Position {
@@ -94,84 +129,22 @@ func (b *Buffer) IsModified() bool {
 }
 
 func (b *Buffer) SetCursor(pos Position) {
-	if pos.Row < 0 {
-		pos.Row = 0
-	}
-	if pos.Row >= len(b.content) {
-		pos.Row = len(b.content) - 1
-	}
-	if pos.Col < 0 {
-		pos.Col = 0
-	}
-	if pos.Col > len(b.content[pos.Row]) {
-		pos.Col = len(b.content[pos.Row])
-	}
-	b.cursor = pos
+	b.cursor = b.clampPosition(pos)
 }
 
 func (b *Buffer) InsertChar(ch rune) {
-	if ch == '\n' {
-		b.insertNewline()
-		return
-	}
-	
-	line := b.content[b.cursor.Row]
-	
-	// Insert at byte position (cursor.Col is in bytes)
-	newLine := line[:b.cursor.Col] + string(ch) + line[b.cursor.Col:]
-	b.content[b.cursor.Row] = newLine
-	
-	// Move cursor by the byte length of the inserted character
-	b.cursor.Col += utf8.RuneLen(ch)
-	b.modified = true
-	
-}
-
-func (b *Buffer) insertNewline() {
-	line := b.content[b.cursor.Row]
-	beforeCursor := line[:b.cursor.Col]
-	afterCursor := line[b.cursor.Col:]
-	
-	b.content[b.cursor.Row] = beforeCursor
-	
-	newContent := make([]string, 0, len(b.content)+1)
-	newContent = append(newContent, b.content[:b.cursor.Row+1]...)
-	newContent = append(newContent, afterCursor)
-	newContent = append(newContent, b.content[b.cursor.Row+1:]...)
-	
-	b.content = newContent
-	b.cursor.Row++
-	b.cursor.Col = 0
-	b.modified = true
+	b.cursor = b.insertText(b.cursor, string(ch))
 }
 
 func (b *Buffer) InsertString(s string) {
-	lines := strings.Split(s, "\n")
-	if len(lines) == 1 {
-		b.InsertChar(rune(s[0]))
+	if s == "" {
 		return
 	}
-	
-	currentLine := b.content[b.cursor.Row]
-	beforeCursor := currentLine[:b.cursor.Col]
-	afterCursor := currentLine[b.cursor.Col:]
-	
-	lines[0] = beforeCursor + lines[0]
-	lines[len(lines)-1] = lines[len(lines)-1] + afterCursor
-	
-	newContent := make([]string, 0, len(b.content)+len(lines)-1)
-	newContent = append(newContent, b.content[:b.cursor.Row]...)
-	newContent = append(newContent, lines...)
-	newContent = append(newContent, b.content[b.cursor.Row+1:]...)
-	
-	b.content = newContent
-	b.cursor.Row += len(lines) - 1
-	b.cursor.Col = len(lines[len(lines)-1]) - len(afterCursor)
-	b.modified = true
+	b.cursor = b.insertText(b.cursor, s)
 }
 
 func (b *Buffer) Clear() {
-	b.content = []string{""}
+	b.deleteText(Position{Row: 0, Col: 0}, b.endPosition())
 	b.cursor = Position{Row: 0, Col: 0}
 	b.modified = true
 }
@@ -183,96 +156,251 @@ func (b *Buffer) DeleteBackward() {
 		return
 	}
 	
-	line := b.content[b.cursor.Row]
-	
+	start := b.cursor
 	if b.cursor.Col == 0 {
 		// At beginning of line, join with previous line
-		if b.cursor.Row > 0 {
-			prevLine := b.content[b.cursor.Row-1]
-			
-			// Join lines
-			b.content[b.cursor.Row-1] = prevLine + line
-			
-			// Remove current line
-			newContent := make([]string, 0, len(b.content)-1)
-			newContent = append(newContent, b.content[:b.cursor.Row]...)
-			newContent = append(newContent, b.content[b.cursor.Row+1:]...)
-			b.content = newContent
-			
-			// Move cursor to end of previous line
-			b.cursor.Row--
-			b.cursor.Col = len(prevLine) // Use byte position for cursor
-			b.modified = true
-		}
+		start = Position{Row: b.cursor.Row - 1, Col: len(b.Line(b.cursor.Row - 1))}
 	} else {
-		// Delete character before cursor
-		runes := []rune(line)
-		if len(runes) > 0 {
-			// Find the rune position before cursor
-			bytePos := 0
-			runeIndex := 0
-			for bytePos < b.cursor.Col && runeIndex < len(runes) {
-				bytePos += utf8.RuneLen(runes[runeIndex])
-				runeIndex++
-			}
-			
-			if runeIndex > 0 {
-				// Remove the previous rune
-				newRunes := append(runes[:runeIndex-1], runes[runeIndex:]...)
-				b.content[b.cursor.Row] = string(newRunes)
-				
-				// Move cursor back by the byte length of the deleted rune
-				deletedRune := runes[runeIndex-1]
-				b.cursor.Col -= utf8.RuneLen(deletedRune)
-				b.modified = true
-			}
-		}
+		// Delete the rune before the cursor
+		_, size := utf8.DecodeLastRuneInString(b.Line(b.cursor.Row)[:b.cursor.Col])
+		start.Col -= size
 	}
+	
+	b.deleteText(start, b.cursor)
+	b.cursor = start
 }
 
 // DeleteForward deletes the character at the cursor position (delete)
 func (b *Buffer) DeleteForward() {
-	if b.cursor.Row >= len(b.content) {
+	if b.cursor.Row >= b.LineCount() {
 		return
 	}
 	
-	line := b.content[b.cursor.Row]
-	
+	line := b.Line(b.cursor.Row)
+	end := b.cursor
 	if b.cursor.Col >= len(line) {
 		// At end of line, join with next line
-		if b.cursor.Row < len(b.content)-1 {
-			nextLine := b.content[b.cursor.Row+1]
-			
-			// Join lines
-			b.content[b.cursor.Row] = line + nextLine
-			
-			// Remove next line
-			newContent := make([]string, 0, len(b.content)-1)
-			newContent = append(newContent, b.content[:b.cursor.Row+1]...)
-			newContent = append(newContent, b.content[b.cursor.Row+2:]...)
-			b.content = newContent
-			b.modified = true
+		if b.cursor.Row >= b.LineCount()-1 {
+			return
 		}
+		end = Position{Row: b.cursor.Row + 1, Col: 0}
 	} else {
-		// Delete character at cursor
-		runes := []rune(line)
-		if len(runes) > 0 {
-			// Find the rune position at cursor
-			bytePos := 0
-			runeIndex := 0
-			for bytePos < b.cursor.Col && runeIndex < len(runes) {
-				bytePos += utf8.RuneLen(runes[runeIndex])
-				runeIndex++
-			}
-			
-			if runeIndex < len(runes) {
-				// Remove the rune at cursor position
-				newRunes := append(runes[:runeIndex], runes[runeIndex+1:]...)
-				b.content[b.cursor.Row] = string(newRunes)
-				b.modified = true
-			}
-		}
+		// Delete the rune at the cursor
+		_, size := utf8.DecodeRuneInString(line[b.cursor.Col:])
+		end.Col += size
+	}
+	
+	b.deleteText(b.cursor, end)
+}
+
+// InsertText inserts text at pos and returns the position just after it.
+// pos is clamped to the buffer; a cursor at pos ends up after the text.
+func (b *Buffer) InsertText(pos Position, text string) Position {
+	pos = b.clampPosition(pos)
+	if text == "" {
+		return pos
+	}
+	return b.insertText(pos, text)
+}
+
+// DeleteRegion deletes the text between start and end, in either order,
+// and returns it
+func (b *Buffer) DeleteRegion(start, end Position) string {
+	return b.deleteText(b.clampPosition(start), b.clampPosition(end))
+}
+
+// ReplaceRegion replaces the text between start and end, in either order,
+// with text and returns the position just after the new text. The buffer
+// is left untouched if the text is the same.
+func (b *Buffer) ReplaceRegion(start, end Position, text string) Position {
+	start, end = b.clampPosition(start), b.clampPosition(end)
+	if end.Before(start) {
+		start, end = end, start
 	}
+	if b.textInRange(start, end) == text {
+		return end
+	}
+	
+	b.deleteText(start, end)
+	if text == "" {
+		return start
+	}
+	return b.insertText(start, text)
+}
+
+// TransposeRegions swaps the text between aStart and aEnd with the text
+// between bStart and bEnd. The first region must end before the second
+// starts. It returns the end of the text that now ends at bEnd.
+func (b *Buffer) TransposeRegions(aStart, aEnd, bStart, bEnd Position) Position {
+	first := b.textInRange(aStart, aEnd)
+	middle := b.textInRange(aEnd, bStart)
+	second := b.textInRange(bStart, bEnd)
+	return b.ReplaceRegion(aStart, bEnd, second+middle+first)
+}
+
+// Mark returns the mark position and whether the mark has been set
+func (b *Buffer) Mark() (Position, bool) {
+	return b.mark, b.markSet
+}
+
+// SetMark sets the mark at pos and activates the region
+func (b *Buffer) SetMark(pos Position) {
+	b.mark = b.clampPosition(pos)
+	b.markSet = true
+	b.markActive = true
+}
+
+// MarkActive returns true if the region between mark and cursor is active
+func (b *Buffer) MarkActive() bool {
+	return b.markSet && b.markActive
+}
+
+// DeactivateMark deactivates the region but keeps the mark position
+func (b *Buffer) DeactivateMark() {
+	b.markActive = false
+}
+
+// Region returns the region between the mark and the cursor in buffer order.
+// ok is false if the mark has not been set.
+func (b *Buffer) Region() (start, end Position, ok bool) {
+	if !b.markSet {
+		return Position{}, Position{}, false
+	}
+	if b.mark.Before(b.cursor) {
+		return b.mark, b.cursor, true
+	}
+	return b.cursor, b.mark, true
+}
+
+// insertText inserts text (which may contain newlines) at pos and returns
+// the position just after the inserted text. All insertions go through here
+// so that they are recorded in the undo log and reported to after-change.
+// The cursor and the mark keep their place relative to the surrounding text.
+func (b *Buffer) insertText(pos Position, text string) Position {
+	line := b.Line(pos.Row)
+	beforeCursor := line[:pos.Col]
+	afterCursor := line[pos.Col:]
+	
+	var end Position
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 {
+		b.lines.SetLine(pos.Row, beforeCursor+text+afterCursor)
+		end = Position{Row: pos.Row, Col: pos.Col + len(text)}
+	} else {
+		lastLen := len(lines[len(lines)-1])
+		lines[0] = beforeCursor + lines[0]
+		lines[len(lines)-1] = lines[len(lines)-1] + afterCursor
+		
+		b.lines.SetLine(pos.Row, lines[0])
+		b.lines.Insert(pos.Row+1, lines[1:]...)
+		end = Position{Row: pos.Row + len(lines) - 1, Col: lastLen}
+	}
+	
+	b.recordChange(undoInsert, pos, end, text)
+	b.syntax.invalidate(pos.Row, pos.Row, end.Row)
+	b.mark = shiftForInsert(b.mark, pos, end)
+	if b.cursor == pos {
+		// Text inserted at the cursor goes before it, as with typing
+		b.cursor = end
+	} else {
+		b.cursor = shiftForInsert(b.cursor, pos, end)
+	}
+	b.markActive = false
+	b.modified = true
+	if b.onChange != nil {
+		b.onChange(b, pos, end, text, true)
+	}
+	return end
+}
+
+// deleteText removes the text between start and end and returns it.
+// All deletions go through here so that they are recorded in the undo log
+// and reported to after-change.
+func (b *Buffer) deleteText(start, end Position) string {
+	if end.Before(start) {
+		start, end = end, start
+	}
+	if start == end {
+		return ""
+	}
+	
+	text := b.textInRange(start, end)
+	
+	b.lines.SetLine(start.Row, b.Line(start.Row)[:start.Col]+b.Line(end.Row)[end.Col:])
+	b.lines.Delete(start.Row+1, end.Row+1)
+	
+	b.recordChange(undoDelete, start, end, text)
+	b.syntax.invalidate(start.Row, end.Row, start.Row)
+	b.mark = shiftForDelete(b.mark, start, end)
+	b.cursor = shiftForDelete(b.cursor, start, end)
+	b.markActive = false
+	b.modified = true
+	if b.onChange != nil {
+		b.onChange(b, start, end, text, false)
+	}
+	return text
+}
+
+// textInRange returns the text between start and end, joining lines with newlines
+func (b *Buffer) textInRange(start, end Position) string {
+	if start.Row == end.Row {
+		return b.Line(start.Row)[start.Col:end.Col]
+	}
+	
+	parts := make([]string, 0, end.Row-start.Row+1)
+	parts = append(parts, b.Line(start.Row)[start.Col:])
+	parts = append(parts, b.lines.Slice(start.Row+1, end.Row)...)
+	parts = append(parts, b.Line(end.Row)[:end.Col])
+	return strings.Join(parts, "\n")
+}
+
+// clampPosition limits pos to a valid position in the buffer
+func (b *Buffer) clampPosition(pos Position) Position {
+	if pos.Row < 0 {
+		pos.Row = 0
+	}
+	if pos.Row >= b.LineCount() {
+		pos.Row = b.LineCount() - 1
+	}
+	if pos.Col < 0 {
+		pos.Col = 0
+	}
+	if pos.Col > len(b.Line(pos.Row)) {
+		pos.Col = len(b.Line(pos.Row))
+	}
+	return pos
+}
+
+// shiftForInsert adjusts p for text inserted between start and end.
+// A position at the insertion point stays before the inserted text.
+func shiftForInsert(p, start, end Position) Position {
+	if !start.Before(p) {
+		return p
+	}
+	if p.Row == start.Row {
+		return Position{Row: end.Row, Col: end.Col + p.Col - start.Col}
+	}
+	return Position{Row: p.Row + end.Row - start.Row, Col: p.Col}
+}
+
+// shiftForDelete adjusts p for the deletion of the text between start and end
+func shiftForDelete(p, start, end Position) Position {
+	if !start.Before(p) {
+		return p
+	}
+	if !end.Before(p) {
+		return start
+	}
+	if p.Row == end.Row {
+		return Position{Row: start.Row, Col: start.Col + p.Col - end.Col}
+	}
+	return Position{Row: p.Row - (end.Row - start.Row), Col: p.Col}
+}
+
+// endPosition returns the position at the very end of the buffer
+func (b *Buffer) endPosition() Position {
+	last := b.LineCount() - 1
+	return Position{Row: last, Col: len(b.Line(last))}
 }
 
 // MajorMode returns the current major mode
@@ -331,4 +459,143 @@ func (b *Buffer) getEnabledMinorModes() []MinorMode {
 // SetFilepath sets the file path for the buffer (for testing)
 func (b *Buffer) SetFilepath(filepath string) {
 	b.filepath = filepath
-}
\ No newline at end of file
+}
+
+// Save writes the buffer content back to its associated file
+func (b *Buffer) Save() error {
+	if b.filepath == "" {
+		return &FileError{Message: "Buffer " + b.name + " is not visiting a file"}
+	}
+	return b.SaveAs(b.filepath)
+}
+
+// SaveAs writes the buffer content to path and makes the buffer visit it.
+// The buffer is renamed after the new file when the path changes.
+func (b *Buffer) SaveAs(path string) error {
+	data := []byte(b.fileContent())
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	b.recordWrite(path, data)
+	b.warned = nil
+	
+	if path != b.filepath {
+		b.filepath = path
+		b.name = filepath.Base(path)
+	}
+	b.modified = false
+	b.markUndoSavePoint()
+	return nil
+}
+
+// lineFormat is how a file ends its lines. Files are written back the way
+// they were read.
+type lineFormat struct {
+	crlf         bool // Lines end with "\r\n" rather than "\n"
+	finalNewline bool // The last line ends with a line ending too
+}
+
+// splitFileLines splits the contents of a file into the lines of a buffer.
+// The line ending of the first line decides the format; in a CRLF file
+// every "\r" before a "\n" is dropped.
+func splitFileLines(data string) ([]string, lineFormat) {
+	var format lineFormat
+	if i := strings.IndexByte(data, '\n'); i > 0 && data[i-1] == '\r' {
+		format.crlf = true
+	}
+	if strings.HasSuffix(data, "\n") {
+		format.finalNewline = true
+		data = data[:len(data)-1]
+	}
+
+	lines := strings.Split(data, "\n")
+	if format.crlf {
+		for i, line := range lines {
+			if i < len(lines)-1 || format.finalNewline {
+				lines[i] = strings.TrimSuffix(line, "\r")
+			}
+		}
+	}
+	return lines, format
+}
+
+// fileContent returns the buffer text as it should be written to disk: with
+// the line endings of the file it was read from, or for a new file with
+// every line newline-terminated unless the buffer is empty.
+func (b *Buffer) fileContent() string {
+	if b.format == nil {
+		text := strings.Join(b.Content(), "\n")
+		if text == "" {
+			return ""
+		}
+		return text + "\n"
+	}
+
+	eol := "\n"
+	if b.format.crlf {
+		eol = "\r\n"
+	}
+	text := strings.Join(b.Content(), eol)
+	if b.format.finalNewline {
+		text += eol
+	}
+	return text
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a partially written file. The mode of
+// an existing file is preserved, and symlinks are written through.
+func writeFileAtomic(path string, data []byte) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".gmacs-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	
+	// Remove the temporary file unless it was renamed into place
+	committed := false
+	defer func() {
+		if !committed {
+			os.Remove(tmpPath)
+		}
+	}()
+	
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	
+	committed = true
+	return nil
+}
+
+// FileError represents an error in file operations
+type FileError struct {
+	Message string
+}
+
+func (e *FileError) Error() string {
+	return e.Message
+}
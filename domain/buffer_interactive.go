@@ -8,9 +8,6 @@ import (
 	"github.com/TakahashiShuuhei/gmacs/log"
 )
 
-// MinibufferBufferSelection represents buffer selection mode
-const MinibufferBufferSelection MinibufferMode = 4
-
 // Interactive buffer functions
 
 // SwitchToBufferInteractive implements C-x b (switch-to-buffer)
@@ -21,6 +18,8 @@ func SwitchToBufferInteractive(e *Editor) error {
 	e.minibuffer.prompt = "Switch to buffer: "
 	e.minibuffer.message = ""
 	e.minibuffer.cursor = 0
+	e.minibuffer.startHistory(HistoryBuffer)
+	e.minibuffer.SetCompletionTable(bufferTable{editor: e})
 	
 	log.Info("Started interactive buffer switch")
 	return nil
@@ -77,9 +76,11 @@ func KillBufferInteractive(e *Editor) error {
 	for i, buffer := range e.buffers {
 		if buffer == currentBuffer {
 			e.buffers = append(e.buffers[:i], e.buffers[i+1:]...)
+			delete(e.keySequences, buffer)
 			break
 		}
 	}
+	e.uniquifyBufferNames()
 	
 	// Switch to the first remaining buffer
 	if len(e.buffers) > 0 {
@@ -107,6 +108,11 @@ func (e *Editor) GetOrCreateBuffer(name string) *Buffer {
 	return buffer
 }
 
+// Buffers returns all buffers in the order they were created
+func (e *Editor) Buffers() []*Buffer {
+	return append([]*Buffer(nil), e.buffers...)
+}
+
 // GetBufferNames returns a list of all buffer names
 func (e *Editor) GetBufferNames() []string {
 	names := make([]string, len(e.buffers))
@@ -131,9 +137,8 @@ func (e *Editor) HandleBufferSelectionInput(event events.KeyEventData) {
 		
 		// Find or create buffer
 		buffer := e.GetOrCreateBuffer(bufferName)
-		e.SwitchToBuffer(buffer)
-		
 		e.minibuffer.SetMessage("Switched to buffer: " + bufferName)
+		e.SwitchToBuffer(buffer) // May report a change of its file instead
 		return
 	}
 	
@@ -143,9 +148,8 @@ func (e *Editor) HandleBufferSelectionInput(event events.KeyEventData) {
 		return
 	}
 	
-	// Handle Tab - buffer name completion
-	if event.Key == "Tab" || event.Key == "\t" {
-		e.handleBufferCompletion()
+	// TAB is handled by the completion of the minibuffer
+	if isTabKey(event.Key, event.Ctrl, event.Meta) {
 		return
 	}
 	
@@ -185,60 +189,6 @@ func (e *Editor) HandleBufferSelectionInput(event events.KeyEventData) {
 	}
 }
 
-// handleBufferCompletion implements buffer name completion
-func (e *Editor) handleBufferCompletion() {
-	input := e.minibuffer.Content()
-	matches := []string{}
-	
-	// Find matching buffer names
-	for _, name := range e.GetBufferNames() {
-		if strings.HasPrefix(name, input) {
-			matches = append(matches, name)
-		}
-	}
-	
-	if len(matches) == 0 {
-		// No matches
-		return
-	} else if len(matches) == 1 {
-		// Single match - complete it
-		e.minibuffer.content = matches[0]
-		e.minibuffer.cursor = len([]rune(matches[0]))
-	} else {
-		// Multiple matches - find common prefix
-		commonPrefix := findCommonPrefix(matches)
-		if len(commonPrefix) > len(input) {
-			e.minibuffer.content = commonPrefix
-			e.minibuffer.cursor = len([]rune(commonPrefix))
-		} else {
-			// Show all matches in message
-			e.minibuffer.SetMessage("Matches: " + strings.Join(matches, ", "))
-		}
-	}
-}
-
-// findCommonPrefix finds the common prefix of a list of strings
-func findCommonPrefix(strs []string) string {
-	if len(strs) == 0 {
-		return ""
-	}
-	
-	prefix := strs[0]
-	for _, str := range strs[1:] {
-		for i := 0; i < len(prefix) && i < len(str); i++ {
-			if prefix[i] != str[i] {
-				prefix = prefix[:i]
-				break
-			}
-		}
-		if len(str) < len(prefix) {
-			prefix = str
-		}
-	}
-	
-	return prefix
-}
-
 // formatBufferList creates buffer list content in Emacs format
 func (e *Editor) formatBufferList() []string {
 	var lines []string
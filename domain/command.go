@@ -77,8 +77,20 @@ func Quit(editor *Editor) error {
 	return nil
 }
 
-// DeleteBackwardChar command for C-h (backspace)
+// DeleteBackwardChar command for C-h (backspace), deleting as many
+// characters as the prefix count
 func DeleteBackwardChar(editor *Editor) error {
+	return repeatCommand(editor, deleteBackwardChar, deleteChar)
+}
+
+// DeleteChar command for C-d (delete-char), deleting as many characters as
+// the prefix count
+func DeleteChar(editor *Editor) error {
+	return repeatCommand(editor, deleteChar, deleteBackwardChar)
+}
+
+// deleteBackwardChar deletes the character before the cursor
+func deleteBackwardChar(editor *Editor) error {
 	buffer := editor.CurrentBuffer()
 	if buffer != nil {
 		buffer.DeleteBackward()
@@ -87,8 +99,8 @@ func DeleteBackwardChar(editor *Editor) error {
 	return nil
 }
 
-// DeleteChar command for C-d (delete-char)
-func DeleteChar(editor *Editor) error {
+// deleteChar deletes the character at the cursor
+func deleteChar(editor *Editor) error {
 	buffer := editor.CurrentBuffer()
 	if buffer != nil {
 		buffer.DeleteForward()
@@ -99,20 +111,45 @@ func DeleteChar(editor *Editor) error {
 
 // FindFile command for C-x C-f (find-file)
 func FindFile(editor *Editor) error {
-	editor.minibuffer.StartFileInput()
+	editor.minibuffer.StartFileInput(editor.defaultDirectory())
+	editor.minibuffer.SetCompletionTable(fileTable{})
 	log.Info("Find file command started")
 	return nil
 }
 
 // KeyboardQuit command for C-g (keyboard-quit)
 func KeyboardQuit(editor *Editor) error {
+	// Abort an incremental search, returning to where it started
+	if editor.isearch != nil {
+		editor.abortISearch()
+		log.Info("Keyboard quit: aborted isearch")
+		return nil
+	}
+	
+	// Stop query-replace, keeping the replacements made so far
+	if editor.queryReplace != nil {
+		editor.finishQueryReplace()
+		log.Info("Keyboard quit: stopped query-replace")
+		return nil
+	}
+	
+	// Stop defining a keyboard macro
+	if editor.cancelKbdMacro() {
+		editor.SetMinibufferMessage("Keyboard macro definition cancelled")
+		log.Info("Keyboard quit: cancelled keyboard macro")
+		return nil
+	}
+	
 	// Clear minibuffer if active
 	if editor.minibuffer.IsActive() {
 		editor.minibuffer.Clear()
 		log.Info("Keyboard quit: cleared minibuffer")
 	} else {
 		// Reset any partial key sequences
-		editor.keyBindings.ResetSequence()
+		editor.resetKeySequence()
+		if buffer := editor.CurrentBuffer(); buffer != nil {
+			buffer.DeactivateMark()
+		}
 		log.Info("Keyboard quit: reset key sequences")
 	}
 	return nil
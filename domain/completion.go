@@ -0,0 +1,384 @@
+package domain
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// Prompts that read a command, file or buffer name complete the input
+// against a CompletionTable. TAB completes as far as the matches agree and
+// shows the candidate list above the minibuffer; C-n and C-p select a
+// candidate, which TAB inserts and Enter submits. The list follows the
+// input while it is shown.
+
+// CompletionTable supplies the candidates of a minibuffer prompt
+type CompletionTable interface {
+	// Candidates returns the candidates for input. They complete the text
+	// of input after base, which is the part they have in common, such as
+	// the directory of a file name.
+	Candidates(input string) (base string, candidates []string)
+	// Annotation returns text shown after a candidate in the list, "" for
+	// none
+	Annotation(candidate string) string
+}
+
+// CompletionStyle is a way of matching the typed text against candidates
+type CompletionStyle string
+
+const (
+	CompletionPrefix    CompletionStyle = "prefix"    // Candidates starting with the text
+	CompletionSubstring CompletionStyle = "substring" // Candidates containing the text
+	CompletionFlex      CompletionStyle = "flex"      // Candidates containing its characters in order
+)
+
+// DefaultCompletionStyles is the completion-styles option when it is not
+// set. The first style that matches any candidate is used.
+const DefaultCompletionStyles = "prefix substring flex"
+
+// DefaultCompletionListHeight is the number of candidates shown when the
+// completion-list-height option is not set
+const DefaultCompletionListHeight = 10
+
+// CompletionCandidate is a line of the candidate list
+type CompletionCandidate struct {
+	Text       string
+	Annotation string
+}
+
+// completion is the completion state of the prompt being read
+type completion struct {
+	table    CompletionTable
+	base     string   // Input the matches do not cover
+	matches  []string // Candidates matching the rest of the input
+	selected int      // Selected match, -1 for none
+	shown    bool     // Whether the candidate list is shown
+}
+
+// SetCompletionTable makes the prompt being read complete against table
+func (mb *Minibuffer) SetCompletionTable(table CompletionTable) {
+	mb.completion = &completion{table: table, selected: -1}
+}
+
+// CompletionList returns at most height candidates of the list around the
+// selected one, and the index of the selected one among them, -1 for none.
+// It returns false when no list is shown.
+func (mb *Minibuffer) CompletionList(height int) ([]CompletionCandidate, int, bool) {
+	c := mb.completion
+	if c == nil || !c.shown || !mb.IsEditing() || height <= 0 {
+		return nil, -1, false
+	}
+	start := 0
+	if c.selected >= height {
+		start = c.selected - height + 1
+	}
+	end := start + height
+	if end > len(c.matches) {
+		end = len(c.matches)
+	}
+	list := make([]CompletionCandidate, 0, end-start)
+	for _, match := range c.matches[start:end] {
+		list = append(list, CompletionCandidate{Text: match, Annotation: c.table.Annotation(match)})
+	}
+	selected := -1
+	if c.selected >= 0 {
+		selected = c.selected - start
+	}
+	return list, selected, true
+}
+
+// handleCompletionKey completes the input with TAB and selects candidates
+// with C-n and C-p
+func (mb *Minibuffer) handleCompletionKey(event events.KeyEventData, editor *Editor) bool {
+	c := mb.completion
+	if c == nil {
+		return false
+	}
+	switch {
+	case isTabKey(event.Key, event.Ctrl, event.Meta):
+		mb.complete(editor)
+	case event.Ctrl && !event.Meta && event.Key == "n":
+		mb.selectCompletion(editor, 1)
+	case event.Ctrl && !event.Meta && event.Key == "p":
+		mb.selectCompletion(editor, -1)
+	default:
+		return false
+	}
+	return true
+}
+
+// complete inserts the selected candidate, or completes the input as far
+// as the matches agree and shows the list when more than one is left
+func (mb *Minibuffer) complete(editor *Editor) {
+	c := mb.completion
+	if c.shown && c.selected >= 0 {
+		mb.setInput(c.base + c.matches[c.selected])
+		mb.updateCompletion(editor)
+		return
+	}
+
+	mb.updateCompletion(editor)
+	pattern := strings.TrimPrefix(mb.content, c.base)
+	switch len(c.matches) {
+	case 0:
+		c.shown = true // The list says there is no match
+	case 1:
+		mb.setInput(c.base + c.matches[0])
+		c.shown = false
+	default:
+		if prefix := findCommonPrefix(c.matches); len(prefix) > len(pattern) && strings.HasPrefix(prefix, pattern) {
+			mb.setInput(c.base + prefix)
+			mb.updateCompletion(editor)
+		}
+		c.shown = true
+	}
+}
+
+// selectCompletion moves the selection by delta candidates, wrapping
+// around, and shows the list if it was hidden
+func (mb *Minibuffer) selectCompletion(editor *Editor, delta int) {
+	c := mb.completion
+	if !c.shown {
+		mb.updateCompletion(editor)
+		c.shown = true
+	}
+	if len(c.matches) == 0 {
+		return
+	}
+	switch {
+	case c.selected < 0 && delta < 0:
+		c.selected = len(c.matches) - 1
+	case c.selected < 0:
+		c.selected = 0
+	default:
+		c.selected = (c.selected + delta + len(c.matches)) % len(c.matches)
+	}
+}
+
+// acceptCompletion puts the selected candidate in the input before it is
+// submitted
+func (mb *Minibuffer) acceptCompletion() {
+	if c := mb.completion; c != nil && c.shown && c.selected >= 0 {
+		mb.setInput(c.base + c.matches[c.selected])
+	}
+}
+
+// updateCompletion matches the input against the table again, dropping
+// the selection
+func (mb *Minibuffer) updateCompletion(editor *Editor) {
+	c := mb.completion
+	base, candidates := c.table.Candidates(mb.content)
+	c.base = base
+	c.matches = matchCompletions(candidates, strings.TrimPrefix(mb.content, base), editor.completionStyles())
+	c.selected = -1
+}
+
+// setInput replaces the input and moves the cursor to its end
+func (mb *Minibuffer) setInput(input string) {
+	mb.content = input
+	mb.cursor = len([]rune(input))
+}
+
+// completionStyles returns the styles of the completion-styles option
+func (e *Editor) completionStyles() []CompletionStyle {
+	value, ok := e.options["completion-styles"].(string)
+	if !ok {
+		value = DefaultCompletionStyles
+	}
+	var styles []CompletionStyle
+	for _, name := range strings.Fields(value) {
+		styles = append(styles, CompletionStyle(name))
+	}
+	return styles
+}
+
+// CompletionListHeight returns the number of candidates the list shows
+func (e *Editor) CompletionListHeight() int {
+	return e.intOption("completion-list-height", DefaultCompletionListHeight)
+}
+
+// matchCompletions returns the candidates matching pattern in the first
+// of styles that matches any. Flex matches come closest first.
+func matchCompletions(candidates []string, pattern string, styles []CompletionStyle) []string {
+	for _, style := range styles {
+		var matches []string
+		var spans []int
+		for _, candidate := range candidates {
+			switch style {
+			case CompletionPrefix:
+				if strings.HasPrefix(candidate, pattern) {
+					matches = append(matches, candidate)
+				}
+			case CompletionSubstring:
+				if strings.Contains(candidate, pattern) {
+					matches = append(matches, candidate)
+				}
+			case CompletionFlex:
+				if span, ok := flexSpan(candidate, pattern); ok {
+					matches = append(matches, candidate)
+					spans = append(spans, span)
+				}
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		if style == CompletionFlex {
+			sort.Stable(flexMatches{matches, spans})
+		}
+		return matches
+	}
+	return nil
+}
+
+// flexSpan reports whether the characters of pattern appear in candidate
+// in order, and the length of the shortest text holding them from the
+// first match
+func flexSpan(candidate, pattern string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	first := strings.IndexRune(candidate, []rune(pattern)[0])
+	if first < 0 {
+		return 0, false
+	}
+	pos := first
+	for _, r := range pattern {
+		i := strings.IndexRune(candidate[pos:], r)
+		if i < 0 {
+			return 0, false
+		}
+		pos += i + len(string(r))
+	}
+	return pos - first, true
+}
+
+// flexMatches sorts flex matches by the length of their span
+type flexMatches struct {
+	matches []string
+	spans   []int
+}
+
+func (f flexMatches) Len() int           { return len(f.matches) }
+func (f flexMatches) Less(i, j int) bool { return f.spans[i] < f.spans[j] }
+func (f flexMatches) Swap(i, j int) {
+	f.matches[i], f.matches[j] = f.matches[j], f.matches[i]
+	f.spans[i], f.spans[j] = f.spans[j], f.spans[i]
+}
+
+// findCommonPrefix finds the common prefix of a list of strings, which
+// ends at a rune boundary
+func findCommonPrefix(strs []string) string {
+	if len(strs) == 0 {
+		return ""
+	}
+
+	prefix := strs[0]
+	for _, str := range strs[1:] {
+		for i := 0; i < len(prefix) && i < len(str); i++ {
+			if prefix[i] != str[i] {
+				// Cut at the start of the rune that differs
+				for i > 0 && !utf8.RuneStart(prefix[i]) {
+					i--
+				}
+				prefix = prefix[:i]
+				break
+			}
+		}
+		if len(str) < len(prefix) {
+			prefix = str
+		}
+	}
+
+	return prefix
+}
+
+// commandTable completes command names, annotated with their key bindings
+type commandTable struct {
+	editor *Editor
+}
+
+func (t commandTable) Candidates(input string) (string, []string) {
+	return "", t.editor.commandRegistry.List()
+}
+
+func (t commandTable) Annotation(candidate string) string {
+	return t.editor.WhereIs(candidate)
+}
+
+// bufferTable completes buffer names, annotated with the file they visit
+type bufferTable struct {
+	editor *Editor
+}
+
+func (t bufferTable) Candidates(input string) (string, []string) {
+	return "", t.editor.GetBufferNames()
+}
+
+func (t bufferTable) Annotation(candidate string) string {
+	if buffer := t.editor.FindBuffer(candidate); buffer != nil {
+		return buffer.Filepath()
+	}
+	return ""
+}
+
+// fileTable completes file names in the directory typed so far, with the
+// shortcuts of expandFileName. Directories end with a slash.
+type fileTable struct{}
+
+func (fileTable) Candidates(input string) (string, []string) {
+	base := input[:strings.LastIndex(input, "/")+1]
+	dir := expandFileName(base)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return base, nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return base, names
+}
+
+func (fileTable) Annotation(candidate string) string {
+	return ""
+}
+
+// WhereIs returns the first key sequence that runs the named command in
+// the current buffer, "" for none
+func (e *Editor) WhereIs(name string) string {
+	for _, layer := range e.keymapLayers(e.CurrentBuffer()) {
+		if keys, ok := layer.keysFor(name); ok {
+			return keys
+		}
+	}
+	return ""
+}
+
+// keysFor returns the first key sequence bound to the named command and
+// not shadowed by an earlier binding
+func (kbm *KeyBindingMap) keysFor(name string) (string, bool) {
+	for _, binding := range kbm.sequenceBindings {
+		if binding.Name != name {
+			continue
+		}
+		if found, exact, _ := kbm.lookupKeys(binding.Sequence); exact && found.Name == name {
+			parts := make([]string, len(binding.Sequence))
+			for i, press := range binding.Sequence {
+				parts[i] = formatKeyPress(press)
+			}
+			return strings.Join(parts, " "), true
+		}
+	}
+	return "", false
+}
@@ -1,25 +1,40 @@
 package domain
 
 import (
+	"strconv"
+	"strings"
+	"unicode"
 	"unicode/utf8"
 	"github.com/TakahashiShuuhei/gmacs/util"
 )
 
 // Cursor movement interactive functions following Emacs conventions
 
-// ForwardChar moves cursor forward by one character (C-f)
+// ForwardChar moves cursor forward by one character (C-f), or by the
+// prefix count
 func ForwardChar(editor *Editor) error {
+	return repeatCommand(editor, forwardChar, backwardChar)
+}
+
+// BackwardChar moves cursor backward by one character (C-b), or by the
+// prefix count
+func BackwardChar(editor *Editor) error {
+	return repeatCommand(editor, backwardChar, forwardChar)
+}
+
+// forwardChar moves cursor forward by one character
+func forwardChar(editor *Editor) error {
 	buffer := editor.CurrentBuffer()
 	if buffer == nil {
 		return nil
 	}
 	
 	cursor := buffer.Cursor()
-	content := buffer.Content()
+	lineCount := buffer.LineCount()
 	
 	// Check if we're at the end of current line
-	if cursor.Row < len(content) {
-		line := content[cursor.Row]
+	if cursor.Row < lineCount {
+		line := buffer.Line(cursor.Row)
 		if cursor.Col < len(line) {
 			// Move within current line
 			runes := []rune(line[cursor.Col:])
@@ -28,7 +43,7 @@ func ForwardChar(editor *Editor) error {
 				buffer.SetCursor(Position{Row: cursor.Row, Col: newCol})
 				EnsureCursorVisible(editor)
 			}
-		} else if cursor.Row < len(content)-1 {
+		} else if cursor.Row < lineCount-1 {
 			// Move to beginning of next line
 			buffer.SetCursor(Position{Row: cursor.Row + 1, Col: 0})
 			EnsureCursorVisible(editor)
@@ -38,19 +53,18 @@ func ForwardChar(editor *Editor) error {
 	return nil
 }
 
-// BackwardChar moves cursor backward by one character (C-b)
-func BackwardChar(editor *Editor) error {
+// backwardChar moves cursor backward by one character
+func backwardChar(editor *Editor) error {
 	buffer := editor.CurrentBuffer()
 	if buffer == nil {
 		return nil
 	}
 	
 	cursor := buffer.Cursor()
-	content := buffer.Content()
 	
 	if cursor.Col > 0 {
 		// Move within current line
-		line := content[cursor.Row]
+		line := buffer.Line(cursor.Row)
 		beforeCursor := line[:cursor.Col]
 		runes := []rune(beforeCursor)
 		if len(runes) > 0 {
@@ -61,7 +75,7 @@ func BackwardChar(editor *Editor) error {
 		}
 	} else if cursor.Row > 0 {
 		// Move to end of previous line
-		prevLine := content[cursor.Row-1]
+		prevLine := buffer.Line(cursor.Row - 1)
 		buffer.SetCursor(Position{Row: cursor.Row - 1, Col: len(prevLine)})
 		EnsureCursorVisible(editor)
 	}
@@ -69,55 +83,62 @@ func BackwardChar(editor *Editor) error {
 	return nil
 }
 
-// NextLine moves cursor to next line (C-n)
+// NextLine moves cursor to next line (C-n), or down by the prefix count
 func NextLine(editor *Editor) error {
+	return moveLines(editor, editor.PrefixCount())
+}
+
+// PreviousLine moves cursor to previous line (C-p), or up by the prefix count
+func PreviousLine(editor *Editor) error {
+	return moveLines(editor, -editor.PrefixCount())
+}
+
+// moveLines moves cursor down by n lines (up if n is negative), keeping
+// its display column where the target line is long enough
+func moveLines(editor *Editor, n int) error {
 	buffer := editor.CurrentBuffer()
 	if buffer == nil {
 		return nil
 	}
 	
 	cursor := buffer.Cursor()
-	content := buffer.Content()
-	
-	if cursor.Row < len(content)-1 {
-		// Calculate target column in display width
-		currentLine := content[cursor.Row]
-		targetDisplayCol := calculateDisplayColumn(currentLine, cursor.Col)
-		
-		// Move to next line and find corresponding byte position
-		nextLine := content[cursor.Row+1]
-		newCol := findBytePositionFromDisplay(nextLine, targetDisplayCol)
-		
-		buffer.SetCursor(Position{Row: cursor.Row + 1, Col: newCol})
-		EnsureCursorVisible(editor)
+	row := cursor.Row + n
+	if row < 0 {
+		row = 0
+	}
+	if row > buffer.LineCount()-1 {
+		row = buffer.LineCount() - 1
 	}
+	if row == cursor.Row {
+		return nil
+	}
+	
+	// Calculate target column in display width
+	currentLine := buffer.Line(cursor.Row)
+	targetDisplayCol := calculateDisplayColumn(currentLine, cursor.Col)
+	
+	// Move to the target line and find corresponding byte position
+	newCol := findBytePositionFromDisplay(buffer.Line(row), targetDisplayCol)
+	
+	buffer.SetCursor(Position{Row: row, Col: newCol})
+	EnsureCursorVisible(editor)
 	
 	return nil
 }
 
-// PreviousLine moves cursor to previous line (C-p)
-func PreviousLine(editor *Editor) error {
-	buffer := editor.CurrentBuffer()
-	if buffer == nil {
-		return nil
+// repeatCommand runs forward as many times as the prefix count, or
+// backward for a negative count
+func repeatCommand(editor *Editor, forward, backward CommandFunc) error {
+	count := editor.PrefixCount()
+	step := forward
+	if count < 0 {
+		count, step = -count, backward
 	}
-	
-	cursor := buffer.Cursor()
-	content := buffer.Content()
-	
-	if cursor.Row > 0 {
-		// Calculate target column in display width
-		currentLine := content[cursor.Row]
-		targetDisplayCol := calculateDisplayColumn(currentLine, cursor.Col)
-		
-		// Move to previous line and find corresponding byte position
-		prevLine := content[cursor.Row-1]
-		newCol := findBytePositionFromDisplay(prevLine, targetDisplayCol)
-		
-		buffer.SetCursor(Position{Row: cursor.Row - 1, Col: newCol})
-		EnsureCursorVisible(editor)
+	for i := 0; i < count; i++ {
+		if err := step(editor); err != nil {
+			return err
+		}
 	}
-	
 	return nil
 }
 
@@ -143,10 +164,9 @@ func EndOfLine(editor *Editor) error {
 	}
 	
 	cursor := buffer.Cursor()
-	content := buffer.Content()
 	
-	if cursor.Row < len(content) {
-		line := content[cursor.Row]
+	if cursor.Row < buffer.LineCount() {
+		line := buffer.Line(cursor.Row)
 		buffer.SetCursor(Position{Row: cursor.Row, Col: len(line)})
 		EnsureCursorVisible(editor)
 	}
@@ -154,6 +174,202 @@ func EndOfLine(editor *Editor) error {
 	return nil
 }
 
+// ForwardWord moves cursor to the end of the next word (M-f), or over as
+// many words as the prefix count
+func ForwardWord(editor *Editor) error {
+	return repeatCommand(editor, forwardWord, backwardWord)
+}
+
+// BackwardWord moves cursor to the start of the previous word (M-b), or
+// over as many words as the prefix count
+func BackwardWord(editor *Editor) error {
+	return repeatCommand(editor, backwardWord, forwardWord)
+}
+
+// forwardWord moves cursor to the end of the next word
+func forwardWord(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+	
+	buffer.SetCursor(wordEnd(buffer, buffer.Cursor()))
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// backwardWord moves cursor to the start of the previous word
+func backwardWord(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+	
+	buffer.SetCursor(wordStart(buffer, buffer.Cursor()))
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// BeginningOfBuffer moves cursor to the start of the buffer (M-<), leaving
+// the mark at the previous position
+func BeginningOfBuffer(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+	
+	pushMark(editor, buffer)
+	buffer.SetCursor(Position{Row: 0, Col: 0})
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// EndOfBuffer moves cursor to the end of the buffer (M->), leaving the
+// mark at the previous position
+func EndOfBuffer(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+	
+	pushMark(editor, buffer)
+	buffer.SetCursor(buffer.endPosition())
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// pushMark sets the mark, without activating the region, at the cursor
+// before a jump so that the previous position is not lost
+func pushMark(editor *Editor, buffer *Buffer) {
+	buffer.SetMark(buffer.Cursor())
+	buffer.DeactivateMark()
+	editor.SetMinibufferMessage("Mark set")
+}
+
+// ForwardParagraph moves cursor to the end of the paragraph (M-}), or over
+// as many paragraphs as the prefix count. Paragraphs are separated by
+// blank lines.
+func ForwardParagraph(editor *Editor) error {
+	return repeatCommand(editor, forwardParagraph, backwardParagraph)
+}
+
+// BackwardParagraph moves cursor to the start of the paragraph (M-{), or
+// over as many paragraphs as the prefix count
+func BackwardParagraph(editor *Editor) error {
+	return repeatCommand(editor, backwardParagraph, forwardParagraph)
+}
+
+// forwardParagraph moves cursor to the blank line after the paragraph, or
+// to the end of the buffer if there is none
+func forwardParagraph(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+	
+	row := buffer.Cursor().Row
+	lineCount := buffer.LineCount()
+	
+	// Skip the blank lines before the paragraph, then the paragraph itself
+	for row < lineCount && isBlank(buffer.Line(row)) {
+		row++
+	}
+	for row < lineCount && !isBlank(buffer.Line(row)) {
+		row++
+	}
+	
+	if row >= lineCount {
+		buffer.SetCursor(buffer.endPosition())
+	} else {
+		buffer.SetCursor(Position{Row: row, Col: 0})
+	}
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// backwardParagraph moves cursor to the blank line before the paragraph,
+// or to the start of the buffer if there is none
+func backwardParagraph(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+	
+	row := buffer.Cursor().Row
+	
+	// Skip the blank lines after the paragraph, then the paragraph itself
+	for row >= 0 && isBlank(buffer.Line(row)) {
+		row--
+	}
+	for row >= 0 && !isBlank(buffer.Line(row)) {
+		row--
+	}
+	
+	if row < 0 {
+		buffer.SetCursor(Position{Row: 0, Col: 0})
+	} else {
+		buffer.SetCursor(Position{Row: row, Col: 0})
+	}
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// GotoLine implements goto-line (M-g g). The line number is the numeric
+// prefix argument if given, otherwise it is read from the minibuffer.
+func GotoLine(editor *Editor) error {
+	if arg := editor.PrefixArg(); arg != nil && !arg.Universal {
+		gotoLine(editor, arg.Value)
+		return nil
+	}
+	
+	editor.minibuffer.StartInput("Goto line: ", func(editor *Editor, input string) {
+		line, err := strconv.Atoi(strings.TrimSpace(input))
+		if err != nil {
+			editor.SetMinibufferMessage("Please enter a number")
+			return
+		}
+		gotoLine(editor, line)
+	})
+	return nil
+}
+
+// gotoLine moves cursor to the start of the 1-based line, clamped to the
+// lines of the buffer
+func gotoLine(editor *Editor, line int) {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return
+	}
+	
+	row := line - 1
+	if row < 0 {
+		row = 0
+	}
+	if row > buffer.LineCount()-1 {
+		row = buffer.LineCount() - 1
+	}
+	
+	pushMark(editor, buffer)
+	buffer.SetCursor(Position{Row: row, Col: 0})
+	EnsureCursorVisible(editor)
+}
+
+// BackToIndentation moves cursor to the first non-whitespace character of
+// the current line (M-m)
+func BackToIndentation(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+	
+	cursor := buffer.Cursor()
+	line := buffer.Line(cursor.Row)
+	col := len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+	buffer.SetCursor(Position{Row: cursor.Row, Col: col})
+	EnsureCursorVisible(editor)
+	return nil
+}
+
 // Helper functions
 
 // calculateDisplayColumn calculates the display width from byte position
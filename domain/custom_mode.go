@@ -0,0 +1,200 @@
+package domain
+
+import (
+	"regexp"
+)
+
+// ModeKey binds a key sequence in a mode defined at runtime, either to a
+// registered command or directly to a function
+type ModeKey struct {
+	Sequence string
+	Command  string      // Name of a registered command
+	Func     CommandFunc // Called when Command is empty
+}
+
+// MajorModeConfig describes a major mode defined at runtime, e.g. with
+// gmacs.major_mode from Lua
+type MajorModeConfig struct {
+	FilePattern  *regexp.Regexp // Files that are visited in this mode, nil for none
+	Keymap       []ModeKey
+	Highlighter  SyntaxHighlighter // nil for no highlighting
+	Indent       IndentFunc        // nil to indent like the previous line
+	OnActivate   func(buffer *Buffer) error
+	OnDeactivate func(buffer *Buffer) error
+}
+
+// CustomMajorMode is a major mode built from a MajorModeConfig
+type CustomMajorMode struct {
+	name        string
+	config      MajorModeConfig
+	keyBindings *KeyBindingMap
+	commands    map[string]*Command
+}
+
+// Name returns the mode name
+func (cm *CustomMajorMode) Name() string {
+	return cm.name
+}
+
+// FilePattern returns the pattern of files visited in this mode
+func (cm *CustomMajorMode) FilePattern() *regexp.Regexp {
+	return cm.config.FilePattern
+}
+
+// KeyBindings returns the mode keymap
+func (cm *CustomMajorMode) KeyBindings() *KeyBindingMap {
+	return cm.keyBindings
+}
+
+// Commands returns the commands for this mode
+func (cm *CustomMajorMode) Commands() map[string]*Command {
+	return cm.commands
+}
+
+// IndentFunction returns the indentation function of the mode, nil for none
+func (cm *CustomMajorMode) IndentFunction() IndentFunc {
+	return cm.config.Indent
+}
+
+// SyntaxHighlighting returns the highlighter of the mode, nil for none
+func (cm *CustomMajorMode) SyntaxHighlighting() SyntaxHighlighter {
+	return cm.config.Highlighter
+}
+
+// Initialize initializes the mode for a buffer
+func (cm *CustomMajorMode) Initialize(buffer *Buffer) error {
+	return nil
+}
+
+// OnActivate runs the on_activate hook of the mode
+func (cm *CustomMajorMode) OnActivate(buffer *Buffer) error {
+	if cm.config.OnActivate != nil {
+		return cm.config.OnActivate(buffer)
+	}
+	return nil
+}
+
+// OnDeactivate runs the on_deactivate hook of the mode
+func (cm *CustomMajorMode) OnDeactivate(buffer *Buffer) error {
+	if cm.config.OnDeactivate != nil {
+		return cm.config.OnDeactivate(buffer)
+	}
+	return nil
+}
+
+// buildModeKeymap creates the keymap of a runtime-defined mode, resolving
+// command names in the command registry
+func (e *Editor) buildModeKeymap(keys []ModeKey) (*KeyBindingMap, error) {
+	keyBindings := NewEmptyKeyBindingMap()
+	for _, key := range keys {
+		if key.Command == "" {
+			keyBindings.BindCommand(key.Sequence, anonymousCommand, key.Func)
+			continue
+		}
+		cmd, exists := e.commandRegistry.Get(key.Command)
+		if !exists {
+			return nil, &ConfigError{Message: "Unknown command: " + key.Command}
+		}
+		keyBindings.BindCommand(key.Sequence, key.Command, cmd.Execute)
+	}
+	return keyBindings, nil
+}
+
+// MinorModeConfig describes a minor mode defined at runtime, e.g. with
+// gmacs.minor_mode from Lua
+type MinorModeConfig struct {
+	Priority  int
+	Keymap    []ModeKey
+	Lighter   string // Mode line text, empty to show nothing
+	OnEnable  func(buffer *Buffer) error
+	OnDisable func(buffer *Buffer) error
+
+	// Predicate makes the mode global: it is enabled in every buffer for
+	// which Predicate returns true whenever the buffer gets a major mode
+	Predicate func(buffer *Buffer) bool
+}
+
+// CustomMinorMode is a minor mode built from a MinorModeConfig
+type CustomMinorMode struct {
+	name        string
+	config      MinorModeConfig
+	keyBindings *KeyBindingMap
+}
+
+// Name returns the mode name
+func (cm *CustomMinorMode) Name() string {
+	return cm.name
+}
+
+// KeyBindings returns the mode keymap
+func (cm *CustomMinorMode) KeyBindings() *KeyBindingMap {
+	return cm.keyBindings
+}
+
+// Commands returns the toggle command of the mode
+func (cm *CustomMinorMode) Commands() map[string]*Command {
+	return map[string]*Command{
+		cm.name: NewCommand(cm.name, toggleMinorModeCommand(cm.name)),
+	}
+}
+
+// Enable enables the mode for a buffer and runs its on_enable hook
+func (cm *CustomMinorMode) Enable(buffer *Buffer) error {
+	buffer.EnableMinorMode(cm)
+	if cm.config.OnEnable != nil {
+		return cm.config.OnEnable(buffer)
+	}
+	return nil
+}
+
+// Disable disables the mode for a buffer and runs its on_disable hook
+func (cm *CustomMinorMode) Disable(buffer *Buffer) error {
+	buffer.DisableMinorMode(cm.name)
+	if cm.config.OnDisable != nil {
+		return cm.config.OnDisable(buffer)
+	}
+	return nil
+}
+
+// IsEnabled checks if the mode is enabled for a buffer
+func (cm *CustomMinorMode) IsEnabled(buffer *Buffer) bool {
+	for _, mode := range buffer.MinorModes() {
+		if mode.Name() == cm.name {
+			return true
+		}
+	}
+	return false
+}
+
+// Priority returns the mode priority
+func (cm *CustomMinorMode) Priority() int {
+	return cm.config.Priority
+}
+
+// Lighter returns the mode line text
+func (cm *CustomMinorMode) Lighter() string {
+	return cm.config.Lighter
+}
+
+// IsGlobal reports whether the mode is enabled automatically by a predicate
+func (cm *CustomMinorMode) IsGlobal() bool {
+	return cm.config.Predicate != nil
+}
+
+// appliesTo reports whether a global mode should be enabled in buffer
+func (cm *CustomMinorMode) appliesTo(buffer *Buffer) bool {
+	return cm.config.Predicate != nil && cm.config.Predicate(buffer)
+}
+
+// toggleMinorModeCommand returns a command toggling a minor mode in the
+// current buffer
+func toggleMinorModeCommand(name string) CommandFunc {
+	return func(editor *Editor) error {
+		buffer := editor.CurrentBuffer()
+		if buffer == nil {
+			return &ModeError{Message: "No current buffer"}
+		}
+
+		return editor.ModeManager().ToggleMinorMode(buffer, name)
+	}
+}
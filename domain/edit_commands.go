@@ -0,0 +1,337 @@
+package domain
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Word and line editing commands. Words are those of forward-word and
+// backward-word (see word.go), so they follow the same rules for Japanese
+// text.
+
+// KillWord implements kill-word (M-d), killing to the end of the word, or
+// of as many words as the prefix count
+func KillWord(editor *Editor) error {
+	return killWords(editor, editor.PrefixCount())
+}
+
+// BackwardKillWord implements backward-kill-word (M-DEL), killing to the
+// start of the word, or of as many words as the prefix count
+func BackwardKillWord(editor *Editor) error {
+	return killWords(editor, -editor.PrefixCount())
+}
+
+// killWords kills count words after the cursor, or before it if count is
+// negative
+func killWords(editor *Editor, count int) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	cursor := buffer.Cursor()
+	to := moveWords(buffer, cursor, count)
+	if to == cursor {
+		return nil
+	}
+	killText(editor, buffer, cursor, to)
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// moveWords returns the position count words after pos, or before it if
+// count is negative
+func moveWords(buffer *Buffer, pos Position, count int) Position {
+	for ; count > 0; count-- {
+		pos = wordEnd(buffer, pos)
+	}
+	for ; count < 0; count++ {
+		pos = wordStart(buffer, pos)
+	}
+	return pos
+}
+
+// TransposeChars implements transpose-chars (C-t). It swaps the characters
+// before and after the cursor and moves forward, so that repeating it drags
+// a character along; at the end of a line it swaps the two characters
+// before the cursor.
+func TransposeChars(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	for i := 0; i < editor.PrefixCount(); i++ {
+		pos := buffer.Cursor()
+		if pos.Col > 0 && pos.Col == len(buffer.Line(pos.Row)) {
+			_, pos, _ = runeBefore(buffer, pos)
+		}
+
+		_, start, ok := runeBefore(buffer, pos)
+		if !ok {
+			editor.SetMinibufferMessage("Beginning of buffer")
+			break
+		}
+		_, end, ok := runeAfter(buffer, pos)
+		if !ok {
+			editor.SetMinibufferMessage("End of buffer")
+			break
+		}
+		buffer.SetCursor(buffer.TransposeRegions(start, pos, pos, end))
+	}
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// TransposeWords implements transpose-words (M-t). It swaps the word at
+// or before the cursor with the word after it and moves past both.
+func TransposeWords(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	for i := 0; i < editor.PrefixCount(); i++ {
+		firstStart := wordStart(buffer, buffer.Cursor())
+		firstEnd := wordEnd(buffer, firstStart)
+		secondEnd := wordEnd(buffer, firstEnd)
+		secondStart := wordStart(buffer, secondEnd)
+		if secondEnd == firstEnd || secondStart.Before(firstEnd) {
+			editor.SetMinibufferMessage("Don't have two things to transpose")
+			break
+		}
+		buffer.SetCursor(buffer.TransposeRegions(firstStart, firstEnd, secondStart, secondEnd))
+	}
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// TransposeLines implements transpose-lines (C-x C-t). It swaps the
+// current line with the one above and moves to the start of the next line,
+// so that repeating it drags a line down.
+func TransposeLines(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	for i := 0; i < editor.PrefixCount(); i++ {
+		row := buffer.Cursor().Row
+		if row == 0 || row >= buffer.LineCount() {
+			editor.SetMinibufferMessage("Don't have two things to transpose")
+			break
+		}
+
+		buffer.TransposeRegions(
+			Position{Row: row - 1, Col: 0}, Position{Row: row - 1, Col: len(buffer.Line(row - 1))},
+			Position{Row: row, Col: 0}, Position{Row: row, Col: len(buffer.Line(row))})
+		if row == buffer.LineCount()-1 {
+			buffer.InsertText(buffer.endPosition(), "\n")
+		}
+		buffer.SetCursor(Position{Row: row + 1, Col: 0})
+	}
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// UpcaseWord implements upcase-word (M-u)
+func UpcaseWord(editor *Editor) error {
+	return convertWords(editor, strings.ToUpper)
+}
+
+// DowncaseWord implements downcase-word (M-l)
+func DowncaseWord(editor *Editor) error {
+	return convertWords(editor, strings.ToLower)
+}
+
+// CapitalizeWord implements capitalize-word (M-c)
+func CapitalizeWord(editor *Editor) error {
+	return convertWords(editor, capitalize)
+}
+
+// convertWords converts the text from the cursor to the end of the word,
+// or of as many words as the prefix count, and moves past it. With a
+// negative count the words before the cursor are converted and the cursor
+// stays.
+func convertWords(editor *Editor, convert func(string) string) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	cursor := buffer.Cursor()
+	count := editor.PrefixCount()
+	other := moveWords(buffer, cursor, count)
+	if count < 0 {
+		buffer.ReplaceRegion(other, cursor, convert(buffer.textInRange(other, cursor)))
+	} else {
+		buffer.SetCursor(buffer.ReplaceRegion(cursor, other, convert(buffer.textInRange(cursor, other))))
+	}
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// capitalize upcases the first letter of each word in s and downcases the
+// rest. s is taken to start a word, as capitalize-word starts at the cursor.
+func capitalize(s string) string {
+	var sb strings.Builder
+	inWord := false
+	for _, r := range s {
+		switch {
+		case classOfRune(r) == wordNone:
+			inWord = false
+		case inWord:
+			r = unicode.ToLower(r)
+		default:
+			r = unicode.ToTitle(r)
+			inWord = true
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// UpcaseRegion implements upcase-region (C-x C-u)
+func UpcaseRegion(editor *Editor) error {
+	return convertRegion(editor, strings.ToUpper)
+}
+
+// DowncaseRegion implements downcase-region (C-x C-l)
+func DowncaseRegion(editor *Editor) error {
+	return convertRegion(editor, strings.ToLower)
+}
+
+// convertRegion converts the text of the region, keeping the cursor and the
+// mark at its ends
+func convertRegion(editor *Editor, convert func(string) string) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	start, end, ok := buffer.Region()
+	if !ok {
+		editor.SetMinibufferMessage("The mark is not set now, so there is no region")
+		return nil
+	}
+
+	cursorAtStart := buffer.Cursor() == start
+	end = buffer.ReplaceRegion(start, end, convert(buffer.textInRange(start, end)))
+	if cursorAtStart {
+		buffer.SetMark(end)
+		buffer.SetCursor(start)
+	} else {
+		buffer.SetMark(start)
+		buffer.SetCursor(end)
+	}
+	buffer.DeactivateMark()
+	return nil
+}
+
+// JoinLine implements join-line (M-^). It joins the current line to the
+// previous one, or the next line to the current one with a prefix argument,
+// replacing the line break and surrounding whitespace with a single space.
+func JoinLine(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	row := buffer.Cursor().Row
+	if editor.PrefixArg() != nil {
+		row++
+	}
+	if row <= 0 || row >= buffer.LineCount() {
+		return nil
+	}
+
+	prev := buffer.Line(row - 1)
+	line := buffer.Line(row)
+	start := Position{Row: row - 1, Col: len(strings.TrimRightFunc(prev, unicode.IsSpace))}
+	end := Position{Row: row, Col: len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))}
+
+	buffer.ReplaceRegion(start, end, joinSeparator(prev[:start.Col], line[end.Col:]))
+	buffer.SetCursor(start)
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// joinSeparator returns the text join-line puts between before and after:
+// a space, except at the start or end of a line, inside parentheses, and
+// next to Japanese or Chinese text, which is written without spaces
+func joinSeparator(before, after string) string {
+	if before == "" || after == "" {
+		return ""
+	}
+	last, _ := utf8.DecodeLastRuneInString(before)
+	first, _ := utf8.DecodeRuneInString(after)
+	if last == '(' || first == ')' || isCJK(last) || isCJK(first) {
+		return ""
+	}
+	return " "
+}
+
+// OpenLine implements open-line (C-o), inserting a newline, or as many as
+// the prefix count, after the cursor
+func OpenLine(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	count := editor.PrefixCount()
+	if count <= 0 {
+		return nil
+	}
+	cursor := buffer.Cursor()
+	buffer.InsertText(cursor, strings.Repeat("\n", count))
+	buffer.SetCursor(cursor)
+	return nil
+}
+
+// DeleteBlankLines implements delete-blank-lines (C-x C-o). On a blank
+// line it deletes the blank lines around it but one, or the line itself if
+// it is the only one; on a non-blank line it deletes the blank lines that
+// follow.
+func DeleteBlankLines(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	row := buffer.Cursor().Row
+	lastRow := buffer.LineCount() - 1
+	last := row
+	for last < lastRow && isBlank(buffer.Line(last+1)) {
+		last++
+	}
+	lastEnd := Position{Row: last, Col: len(buffer.Line(last))}
+
+	if !isBlank(buffer.Line(row)) {
+		if last > row {
+			buffer.DeleteRegion(Position{Row: row, Col: len(buffer.Line(row))}, lastEnd)
+		}
+		return nil
+	}
+
+	first := row
+	for first > 0 && isBlank(buffer.Line(first-1)) {
+		first--
+	}
+
+	switch {
+	case first < last:
+		// Leave one empty line
+		buffer.DeleteRegion(Position{Row: first, Col: 0}, lastEnd)
+	case row < lastRow:
+		buffer.DeleteRegion(Position{Row: row, Col: 0}, Position{Row: row + 1, Col: 0})
+	case row > 0:
+		buffer.DeleteRegion(Position{Row: row - 1, Col: len(buffer.Line(row - 1))}, lastEnd)
+	default:
+		buffer.DeleteRegion(Position{Row: row, Col: 0}, lastEnd)
+	}
+	buffer.SetCursor(Position{Row: first, Col: 0})
+	EnsureCursorVisible(editor)
+	return nil
+}
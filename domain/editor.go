@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"strings"
+
 	"github.com/TakahashiShuuhei/gmacs/events"
 )
 
@@ -29,6 +31,22 @@ type Editor struct {
 	configLoader    ConfigLoader
 	hookManager     HookManager
 	options         map[string]interface{}
+	killRing        *KillRing
+	isearch         *ISearch                      // Incremental search in progress, nil otherwise
+	lastSearch      string                        // Last isearch string
+	lastRegexp      string                        // Last regexp isearch string
+	queryReplace    *QueryReplace                 // query-replace in progress, nil otherwise
+	thisCommand     string                        // Name of the command being executed
+	lastCommand     string                        // Name of the previously executed command
+	selfInsertRun   int                           // Number of consecutive self-inserts sharing an undo group
+	runningHooks    map[string]bool               // Events whose hooks are running, to stop recursion
+	keySequences    map[*Buffer]*keySequenceState // Multi-key sequences being typed, per buffer
+	lastEvent       events.KeyEventData           // Key event being handled
+	argState        *prefixArgState               // Prefix argument typed for the next command
+	prefixArg       *PrefixArg                    // Prefix argument of the running command
+	macro           kbdMacroState                 // Keyboard macros
+	recenterCycle   int                           // Place of the cursor line after the last recenter-top-bottom
+	faces           *Faces                        // Named text styles of the display
 }
 
 // EditorConfig holds configuration options for editor initialization
@@ -74,7 +92,13 @@ func newEditorWithConfig(config EditorConfig) *Editor {
 		configLoader:    config.ConfigLoader,
 		hookManager:     config.HookManager,
 		options:         make(map[string]interface{}),
+		killRing:        NewKillRing(),
+		runningHooks:    make(map[string]bool),
+		keySequences:    make(map[*Buffer]*keySequenceState),
+		faces:           NewFaces(),
 	}
+	editor.watchBuffer(buffer)
+	editor.modeManager.onMajorModeChange = editor.majorModeChanged
 
 	// Built-in commands are now registered via Lua configuration
 
@@ -90,6 +114,9 @@ func newEditorWithConfig(config EditorConfig) *Editor {
 // RegisterBuiltinCommands registers all built-in editor commands for backward compatibility
 func (e *Editor) RegisterBuiltinCommands() {
 	e.registerCoreCommands()
+	e.registerFileCommands()
+	e.registerEditCommands()
+	e.registerSearchCommands()
 	e.registerCursorCommands()
 	e.registerScrollCommands()
 	e.registerBufferCommands()
@@ -104,6 +131,61 @@ func (e *Editor) registerCoreCommands() {
 	e.commandRegistry.RegisterFunc("find-file", FindFile)
 	e.commandRegistry.RegisterFunc("delete-backward-char", DeleteBackwardChar)
 	e.commandRegistry.RegisterFunc("delete-char", DeleteChar)
+	e.commandRegistry.RegisterFunc("universal-argument", UniversalArgument)
+	e.commandRegistry.RegisterFunc("digit-argument", DigitArgument)
+	e.commandRegistry.RegisterFunc("negative-argument", NegativeArgument)
+	e.commandRegistry.RegisterFunc("start-kbd-macro", StartKbdMacro)
+	e.commandRegistry.RegisterFunc("end-kbd-macro", EndKbdMacro)
+	e.commandRegistry.RegisterFunc("call-last-kbd-macro", CallLastKbdMacro)
+	e.commandRegistry.RegisterFunc("name-last-kbd-macro", NameLastKbdMacro)
+	e.commandRegistry.RegisterFunc("load-theme", LoadTheme)
+}
+
+func (e *Editor) registerFileCommands() {
+	// Register file commands as M-x interactive functions
+	e.commandRegistry.RegisterFunc("save-buffer", SaveBuffer)
+	e.commandRegistry.RegisterFunc("write-file", WriteFile)
+	e.commandRegistry.RegisterFunc("revert-buffer", RevertBuffer)
+	e.commandRegistry.RegisterFunc("gofmt-buffer", GofmtBuffer)
+}
+
+func (e *Editor) registerEditCommands() {
+	// Register editing commands as M-x interactive functions
+	e.commandRegistry.RegisterFunc("undo", Undo)
+	e.commandRegistry.RegisterFunc("redo", Redo)
+	e.commandRegistry.RegisterFunc("set-mark-command", SetMarkCommand)
+	e.commandRegistry.RegisterFunc("kill-region", KillRegion)
+	e.commandRegistry.RegisterFunc("kill-ring-save", KillRingSave)
+	e.commandRegistry.RegisterFunc("kill-line", KillLine)
+	e.commandRegistry.RegisterFunc("yank", Yank)
+	e.commandRegistry.RegisterFunc("yank-pop", YankPop)
+	e.commandRegistry.RegisterFunc("kill-word", KillWord)
+	e.commandRegistry.RegisterFunc("backward-kill-word", BackwardKillWord)
+	e.commandRegistry.RegisterFunc("transpose-chars", TransposeChars)
+	e.commandRegistry.RegisterFunc("transpose-words", TransposeWords)
+	e.commandRegistry.RegisterFunc("transpose-lines", TransposeLines)
+	e.commandRegistry.RegisterFunc("upcase-word", UpcaseWord)
+	e.commandRegistry.RegisterFunc("downcase-word", DowncaseWord)
+	e.commandRegistry.RegisterFunc("capitalize-word", CapitalizeWord)
+	e.commandRegistry.RegisterFunc("upcase-region", UpcaseRegion)
+	e.commandRegistry.RegisterFunc("downcase-region", DowncaseRegion)
+	e.commandRegistry.RegisterFunc("join-line", JoinLine)
+	e.commandRegistry.RegisterFunc("open-line", OpenLine)
+	e.commandRegistry.RegisterFunc("delete-blank-lines", DeleteBlankLines)
+	e.commandRegistry.RegisterFunc("indent-for-tab-command", IndentForTabCommand)
+	e.commandRegistry.RegisterFunc("newline-and-indent", NewlineAndIndent)
+	e.commandRegistry.RegisterFunc("indent-region", IndentRegion)
+	e.commandRegistry.RegisterFunc("indent-rigidly", IndentRigidly)
+}
+
+func (e *Editor) registerSearchCommands() {
+	// Register search commands as M-x interactive functions
+	e.commandRegistry.RegisterFunc("isearch-forward", ISearchForward)
+	e.commandRegistry.RegisterFunc("isearch-backward", ISearchBackward)
+	e.commandRegistry.RegisterFunc("isearch-forward-regexp", ISearchForwardRegexp)
+	e.commandRegistry.RegisterFunc("isearch-backward-regexp", ISearchBackwardRegexp)
+	e.commandRegistry.RegisterFunc("query-replace", QueryReplaceCommand)
+	e.commandRegistry.RegisterFunc("replace-regexp", ReplaceRegexp)
 }
 
 func (e *Editor) registerCursorCommands() {
@@ -114,6 +196,14 @@ func (e *Editor) registerCursorCommands() {
 	e.commandRegistry.RegisterFunc("previous-line", PreviousLine)
 	e.commandRegistry.RegisterFunc("beginning-of-line", BeginningOfLine)
 	e.commandRegistry.RegisterFunc("end-of-line", EndOfLine)
+	e.commandRegistry.RegisterFunc("forward-word", ForwardWord)
+	e.commandRegistry.RegisterFunc("backward-word", BackwardWord)
+	e.commandRegistry.RegisterFunc("beginning-of-buffer", BeginningOfBuffer)
+	e.commandRegistry.RegisterFunc("end-of-buffer", EndOfBuffer)
+	e.commandRegistry.RegisterFunc("forward-paragraph", ForwardParagraph)
+	e.commandRegistry.RegisterFunc("backward-paragraph", BackwardParagraph)
+	e.commandRegistry.RegisterFunc("goto-line", GotoLine)
+	e.commandRegistry.RegisterFunc("back-to-indentation", BackToIndentation)
 }
 
 func (e *Editor) registerScrollCommands() {
@@ -122,6 +212,7 @@ func (e *Editor) registerScrollCommands() {
 	e.commandRegistry.RegisterFunc("scroll-down", ScrollDown)
 	e.commandRegistry.RegisterFunc("page-up", PageUp)
 	e.commandRegistry.RegisterFunc("page-down", PageDown)
+	e.commandRegistry.RegisterFunc("recenter-top-bottom", RecenterTopBottom)
 	e.commandRegistry.RegisterFunc("toggle-truncate-lines", ToggleLineWrap)
 	e.commandRegistry.RegisterFunc("debug-info", ShowDebugInfo)
 }
@@ -144,6 +235,11 @@ func (e *Editor) registerWindowCommands() {
 
 // Cleanup closes any resources when the editor is shutting down
 func (e *Editor) Cleanup() {
+	// Exit hooks need the Lua VM, so they run before it is closed
+	if e.hookManager != nil {
+		e.TriggerHook(HookEditorExit)
+		e.hookManager = nil
+	}
 	if e.configLoader != nil {
 		e.configLoader.Close()
 		e.configLoader = nil
@@ -159,12 +255,7 @@ func (e *Editor) BindKey(sequence, command string) error {
 		return &ConfigError{Message: "Unknown command: " + command}
 	}
 
-	// Convert command to function
-	cmdFunc := func(editor *Editor) error {
-		return cmd.Execute(editor)
-	}
-
-	e.keyBindings.BindKeySequence(sequence, cmdFunc)
+	e.keyBindings.BindCommand(sequence, command, cmd.Execute)
 	return nil
 }
 
@@ -176,16 +267,11 @@ func (e *Editor) LocalBindKey(modeName, sequence, command string) error {
 		return &ConfigError{Message: "Unknown command: " + command}
 	}
 
-	// Convert command to function
-	cmdFunc := func(editor *Editor) error {
-		return cmd.Execute(editor)
-	}
-
 	// Try to find major mode first
 	if majorMode, exists := e.modeManager.GetMajorModeByName(modeName); exists {
 		keyBindings := majorMode.KeyBindings()
 		if keyBindings != nil {
-			keyBindings.BindKeySequence(sequence, cmdFunc)
+			keyBindings.BindCommand(sequence, command, cmd.Execute)
 			return nil
 		}
 	}
@@ -194,7 +280,7 @@ func (e *Editor) LocalBindKey(modeName, sequence, command string) error {
 	if minorMode, exists := e.modeManager.GetMinorModeByName(modeName); exists {
 		keyBindings := minorMode.KeyBindings()
 		if keyBindings != nil {
-			keyBindings.BindKeySequence(sequence, cmdFunc)
+			keyBindings.BindCommand(sequence, command, cmd.Execute)
 			return nil
 		}
 	}
@@ -216,6 +302,11 @@ func (e *Editor) RegisterCommand(name string, fn func() error) error {
 // SetOption implements option setting
 func (e *Editor) SetOption(name string, value interface{}) error {
 	e.options[name] = value
+	if name == "tab-width" {
+		for _, buffer := range e.buffers {
+			buffer.tabWidth = e.intOption("tab-width", DefaultTabWidth)
+		}
+	}
 	return nil
 }
 
@@ -228,15 +319,48 @@ func (e *Editor) GetOption(name string) (interface{}, error) {
 	return value, nil
 }
 
-// RegisterMajorMode implements major mode registration
-func (e *Editor) RegisterMajorMode(name string, config map[string]interface{}) error {
-	// TODO: Implement dynamic major mode registration
+// RegisterMajorMode implements major mode registration. The mode is used
+// for files matching its pattern from then on, in preference to the modes
+// registered before it.
+func (e *Editor) RegisterMajorMode(name string, config MajorModeConfig) error {
+	keyBindings, err := e.buildModeKeymap(config.Keymap)
+	if err != nil {
+		return err
+	}
+	
+	e.modeManager.RegisterMajorMode(&CustomMajorMode{
+		name:        name,
+		config:      config,
+		keyBindings: keyBindings,
+		commands:    make(map[string]*Command),
+	})
 	return nil
 }
 
-// RegisterMinorMode implements minor mode registration
-func (e *Editor) RegisterMinorMode(name string, config map[string]interface{}) error {
-	// TODO: Implement dynamic minor mode registration
+// RegisterMinorMode implements minor mode registration. It also defines a
+// command of the same name that toggles the mode in the current buffer, and
+// enables a global mode in the existing buffers its predicate accepts.
+func (e *Editor) RegisterMinorMode(name string, config MinorModeConfig) error {
+	keyBindings, err := e.buildModeKeymap(config.Keymap)
+	if err != nil {
+		return err
+	}
+	
+	mode := &CustomMinorMode{
+		name:        name,
+		config:      config,
+		keyBindings: keyBindings,
+	}
+	e.modeManager.RegisterMinorMode(mode)
+	e.commandRegistry.RegisterFunc(name, toggleMinorModeCommand(name))
+	
+	for _, buffer := range e.buffers {
+		if !mode.IsEnabled(buffer) && mode.appliesTo(buffer) {
+			if err := mode.Enable(buffer); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -248,11 +372,15 @@ func (e *Editor) AddHook(event string, fn func(...interface{}) error) error {
 	return nil
 }
 
-// TriggerHook triggers hooks for an event
+// TriggerHook triggers hooks for an event. Changes made by a hook do not
+// trigger the same event again.
 func (e *Editor) TriggerHook(event string, args ...interface{}) {
-	if e.hookManager != nil {
-		e.hookManager.TriggerHook(event, args...)
+	if e.hookManager == nil || e.runningHooks[event] {
+		return
 	}
+	e.runningHooks[event] = true
+	defer delete(e.runningHooks, event)
+	e.hookManager.TriggerHook(event, args...)
 }
 
 // ConfigError represents a configuration error
@@ -290,6 +418,7 @@ func (e *Editor) EventQueue() *events.EventQueue {
 func (e *Editor) HandleEvent(event events.Event) {
 	switch ev := event.(type) {
 	case events.KeyEventData:
+		e.recordKey(ev)
 		e.handleKeyEvent(ev)
 	case events.ResizeEventData:
 		e.handleResizeEvent(ev)
@@ -301,9 +430,29 @@ func (e *Editor) HandleEvent(event events.Event) {
 }
 
 func (e *Editor) handleKeyEvent(event events.KeyEventData) {
+	// An Escape prefix turns the following key into a Meta key
+	if e.metaPressed && event.Key != "\x1b" && event.Key != "Escape" {
+		event.Meta = true
+		e.metaPressed = false
+	}
+	e.lastEvent = event
+	
+	// Digits, "-" and C-u right after C-u extend the prefix argument
+	if e.continuePrefixArg(event) {
+		return
+	}
+	
+	// "e" right after C-x e calls the macro again
+	if e.macro.repeatKey {
+		e.macro.repeatKey = false
+		if event.Key == "e" && !event.Ctrl && !event.Meta {
+			e.executeCommand("call-last-kbd-macro", CallLastKbdMacro)
+			return
+		}
+	}
 
 	// Always process key sequences first to handle multi-key sequences correctly
-	cmd, matched, continuing := e.keyBindings.ProcessKeyPress(event.Key, event.Ctrl, event.Meta)
+	binding, matched, continuing := e.processKey(event.Key, event.Ctrl, event.Meta)
 
 	// If we have a continuing sequence, always handle it first
 	if continuing {
@@ -315,7 +464,7 @@ func (e *Editor) handleKeyEvent(event events.KeyEventData) {
 		// Special commands that should always execute immediately
 		if event.Ctrl && event.Key == "g" { // C-g (KeyboardQuit)
 			if matched {
-				cmd(e)
+				e.executeCommand("keyboard-quit", binding.Command)
 			}
 			return
 		}
@@ -329,7 +478,7 @@ func (e *Editor) handleKeyEvent(event events.KeyEventData) {
 
 	// If not handled by minibuffer, check for matched global commands
 	if matched {
-		cmd(e)
+		e.executeCommand(binding.Name, binding.Command)
 		return
 	}
 
@@ -337,33 +486,21 @@ func (e *Editor) handleKeyEvent(event events.KeyEventData) {
 	if event.Key == "\x1b" || event.Key == "Escape" {
 		e.metaPressed = true
 		// Reset key sequence on Escape
-		e.keyBindings.ResetSequence()
+		e.resetKeySequence()
 		return
 	}
 
 	// Handle M-x command
-	if e.metaPressed && event.Key == "x" {
+	if event.Meta && event.Key == "x" {
 		e.minibuffer.StartCommandInput()
-		e.metaPressed = false
+		e.minibuffer.SetCompletionTable(commandTable{editor: e})
 		return
 	}
 
-	// Handle other Meta key combinations
-	if e.metaPressed {
-		metaSequence := "M-" + event.Key
-		if cmd, found := e.keyBindings.LookupSequence(metaSequence); found {
-			cmd(e)
-			e.metaPressed = false
-			return
-		}
-		// Reset meta state for unbound keys
-		e.metaPressed = false
-	}
-
 	// Check for any remaining key bindings through the unified system
 	// (single keys and raw sequences that weren't caught by sequence processing)
-	if cmd, found := e.keyBindings.LookupSequence(event.Key); found {
-		cmd(e)
+	if name, cmd, found := e.lookupKey(event.Key); found {
+		e.executeCommand(name, cmd)
 		return
 	}
 
@@ -375,19 +512,101 @@ func (e *Editor) handleKeyEvent(event events.KeyEventData) {
 
 	if event.Rune != 0 && !event.Ctrl && !event.Meta {
 		if event.Key == "Enter" || event.Key == "Return" {
-			buffer.InsertChar('\n')
+			e.executeCommand("newline", func(editor *Editor) error {
+				for i := 0; i < editor.PrefixCount(); i++ {
+					buffer.InsertChar('\n')
 
-			// Check for auto-a-mode and add 'a' if enabled
-			e.processMinorModeHooks(buffer, "newline")
+					// Check for auto-a-mode and add 'a' if enabled
+					editor.processMinorModeHooks(buffer, "newline")
+				}
 
-			EnsureCursorVisible(e)
+				EnsureCursorVisible(editor)
+				return nil
+			})
 		} else {
-			buffer.InsertChar(event.Rune)
-			EnsureCursorVisible(e)
+			e.executeCommand("self-insert-command", func(editor *Editor) error {
+				if count := editor.PrefixCount(); count > 0 {
+					buffer.InsertString(strings.Repeat(string(event.Rune), count))
+				}
+				EnsureCursorVisible(editor)
+				return nil
+			})
 		}
 	}
 }
 
+// executeCommand runs fn as one interactive command, between the
+// pre-command and post-command hooks. Every command starts a new undo
+// group, except that runs of self-inserts are grouped together.
+func (e *Editor) executeCommand(name string, fn CommandFunc) error {
+	if name == "self-insert-command" && e.lastCommand == "self-insert-command" && e.selfInsertRun < maxSelfInsertRun {
+		e.selfInsertRun++
+	} else {
+		e.undoBoundary()
+		e.selfInsertRun = 1
+	}
+
+	// The prefix argument typed so far belongs to this command
+	e.prefixArg = nil
+	if e.argState != nil {
+		e.prefixArg = e.argState.arg()
+		e.argState = nil
+	}
+	
+	e.thisCommand = name
+	e.TriggerHook(HookPreCommand, name)
+	err := fn(e)
+	e.lastCommand = e.thisCommand
+	e.TriggerHook(HookPostCommand, name)
+	e.prefixArg = nil
+	return err
+}
+
+// maxSelfInsertRun is the number of self-inserted characters undone at once
+const maxSelfInsertRun = 20
+
+// undoBoundary closes the open undo group of every buffer
+func (e *Editor) undoBoundary() {
+	limit := e.intOption("undo-limit", DefaultUndoLimit)
+	for _, buffer := range e.buffers {
+		buffer.UndoBoundary(limit)
+	}
+}
+
+// intOption returns a numeric option as an int, or def if it is not set
+func (e *Editor) intOption(name string, def int) int {
+	switch v := e.options[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return def
+}
+
+// boolOption returns a boolean option, or def if it is not set
+func (e *Editor) boolOption(name string, def bool) bool {
+	if v, ok := e.options[name].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// KillRing returns the kill ring shared by all buffers
+func (e *Editor) KillRing() *KillRing {
+	return e.killRing
+}
+
+// Faces returns the named text styles used by the display
+func (e *Editor) Faces() *Faces {
+	return e.faces
+}
+
+// LastCommand returns the name of the previously executed command
+func (e *Editor) LastCommand() string {
+	return e.lastCommand
+}
+
 func (e *Editor) handleResizeEvent(event events.ResizeEventData) {
 	if e.layout != nil {
 		e.layout.Resize(event.Width, event.Height)
@@ -412,14 +631,20 @@ func (e *Editor) SetMinibufferMessage(message string) {
 
 // GetKeySequenceInProgress returns the current key sequence in progress, if any
 func (e *Editor) GetKeySequenceInProgress() string {
-	sequence := e.keyBindings.GetCurrentSequence()
-	return FormatSequence(sequence)
+	state := e.keySequences[e.CurrentBuffer()]
+	if state == nil {
+		return e.prefixArgInProgress()
+	}
+	return FormatSequence(state.keys)
 }
 
 
 // AddBuffer adds a new buffer to the editor
 func (e *Editor) AddBuffer(buffer *Buffer) {
 	e.buffers = append(e.buffers, buffer)
+	e.uniquifyBufferNames()
+	e.watchBuffer(buffer)
+	buffer.tabWidth = e.intOption("tab-width", DefaultTabWidth)
 
 	// Auto-detect and set major mode for new buffer
 	if buffer.MajorMode() == nil {
@@ -432,9 +657,21 @@ func (e *Editor) AddBuffer(buffer *Buffer) {
 
 // SwitchToBuffer switches the current window to the specified buffer
 func (e *Editor) SwitchToBuffer(buffer *Buffer) {
-	window := e.CurrentWindow()
-	if window != nil {
-		window.SetBuffer(buffer)
+	e.SetWindowBuffer(e.CurrentWindow(), buffer)
+}
+
+// SetWindowBuffer shows buffer in window. Changing the buffer of the
+// selected window checks whether its file changed on disk and fires
+// buffer-switch.
+func (e *Editor) SetWindowBuffer(window *Window, buffer *Buffer) {
+	if window == nil || window.Buffer() == buffer {
+		return
+	}
+	previous := window.Buffer()
+	window.SetBuffer(buffer)
+	if window == e.CurrentWindow() {
+		e.checkFileChange(buffer, true)
+		e.TriggerHook(HookBufferSwitch, buffer, previous)
 	}
 }
 
@@ -459,14 +696,8 @@ func (e *Editor) registerMinorModeCommands() {
 	e.modeManager.RegisterMinorMode(autoAMode)
 	
 	// Register the command
-	e.commandRegistry.RegisterFunc("auto-a-mode", func(editor *Editor) error {
-		buffer := editor.CurrentBuffer()
-		if buffer == nil {
-			return &ModeError{Message: "No current buffer"}
-		}
-		
-		return editor.ModeManager().ToggleMinorMode(buffer, "auto-a-mode")
-	})
+	e.commandRegistry.RegisterFunc("auto-a-mode", toggleMinorModeCommand("auto-a-mode"))
+	e.commandRegistry.RegisterFunc("auto-revert-mode", AutoRevertModeCommand)
 }
 
 func (e *Editor) processMinorModeHooks(buffer *Buffer, event string) {
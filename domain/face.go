@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"regexp"
+	"sort"
+)
+
+// Face is a named text style. A color is "" for the terminal default, one
+// of ColorNames, or "#rrggbb". The display takes the colors a face leaves
+// unset from the default face.
+type Face struct {
+	Foreground string
+	Background string
+	Bold       bool
+	Italic     bool
+	Underline  bool
+	Reverse    bool
+}
+
+// Faces used by the display. Syntax highlighters name their own faces, such
+// as "keyword", "comment" or "string".
+const (
+	FaceDefault              = "default"
+	FaceModeLine             = "mode-line"
+	FaceModeLineInactive     = "mode-line-inactive"
+	FaceRegion               = "region"
+	FaceISearch              = "isearch"
+	FaceLazyHighlight        = "lazy-highlight"
+	FaceMinibufferPrompt     = "minibuffer-prompt"
+	FaceCompletionSelected   = "completion-selected"
+	FaceCompletionAnnotation = "completion-annotation"
+)
+
+// ColorNames are the 16 colors of the terminal palette in SGR order
+var ColorNames = []string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+	"bright-black", "bright-red", "bright-green", "bright-yellow",
+	"bright-blue", "bright-magenta", "bright-cyan", "bright-white",
+}
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// IsValidColor reports whether color can be used in a face
+func IsValidColor(color string) bool {
+	if color == "" || hexColorPattern.MatchString(color) {
+		return true
+	}
+	for _, name := range ColorNames {
+		if color == name {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultFaces are the faces before any theme is loaded, drawn with the
+// terminal palette
+func defaultFaces() map[string]Face {
+	return map[string]Face{
+		FaceDefault:              {},
+		FaceModeLine:             {Reverse: true},
+		FaceModeLineInactive:     {Reverse: true},
+		FaceRegion:               {Reverse: true},
+		FaceISearch:              {Foreground: "black", Background: "magenta"},
+		FaceLazyHighlight:        {Foreground: "black", Background: "cyan"},
+		FaceMinibufferPrompt:     {Foreground: "cyan"},
+		FaceCompletionSelected:   {Reverse: true},
+		FaceCompletionAnnotation: {Foreground: "bright-black"},
+		"keyword":                {Foreground: "magenta"},
+		"builtin":                {Foreground: "bright-magenta"},
+		"comment":                {Foreground: "bright-black"},
+		"string":                 {Foreground: "green"},
+		"type":                   {Foreground: "cyan"},
+		"function-name":          {Foreground: "blue"},
+		"variable-name":          {Foreground: "yellow"},
+		"constant":               {Foreground: "bright-cyan"},
+		"preprocessor":           {Foreground: "bright-blue"},
+		"warning":                {Foreground: "red", Bold: true},
+	}
+}
+
+// Faces holds the faces by name
+type Faces struct {
+	faces map[string]Face
+	theme string // Last loaded theme, "" for none
+}
+
+func NewFaces() *Faces {
+	return &Faces{faces: defaultFaces()}
+}
+
+// Get returns the face called name
+func (f *Faces) Get(name string) (Face, bool) {
+	face, ok := f.faces[name]
+	return face, ok
+}
+
+// Set defines or replaces the face called name
+func (f *Faces) Set(name string, face Face) error {
+	for _, color := range []string{face.Foreground, face.Background} {
+		if !IsValidColor(color) {
+			return &ConfigError{Message: "Invalid color: " + color}
+		}
+	}
+	f.faces[name] = face
+	return nil
+}
+
+// Names returns the names of all faces in sorted order
+func (f *Faces) Names() []string {
+	names := make([]string, 0, len(f.faces))
+	for name := range f.faces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Theme returns the name of the last loaded theme, "" for none
+func (f *Faces) Theme() string {
+	return f.theme
+}
+
+// LoadTheme resets the faces to their defaults and applies the named theme.
+// Faces defined by the user that the theme does not mention are kept.
+func (f *Faces) LoadTheme(name string) error {
+	theme, ok := themes[name]
+	if !ok {
+		return &ConfigError{Message: "Unknown theme: " + name}
+	}
+	for faceName, face := range defaultFaces() {
+		f.faces[faceName] = face
+	}
+	for faceName, face := range theme {
+		f.faces[faceName] = face
+	}
+	f.theme = name
+	return nil
+}
+
+// LoadTheme asks for a theme name and loads it
+func LoadTheme(editor *Editor) error {
+	editor.minibuffer.StartInput("Load custom theme: ", func(editor *Editor, input string) {
+		if err := editor.faces.LoadTheme(input); err != nil {
+			editor.SetMinibufferMessage(err.Error())
+		}
+	})
+	return nil
+}
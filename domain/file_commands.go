@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"errors"
+
+	"github.com/TakahashiShuuhei/gmacs/log"
+)
+
+// SaveBuffer implements the save-buffer command (C-x C-s)
+func SaveBuffer(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	// A buffer that is not visiting a file has to be given a name first
+	if buffer.Filepath() == "" {
+		return WriteFile(editor)
+	}
+
+	if !buffer.IsModified() {
+		editor.SetMinibufferMessage("(No changes need to be saved)")
+		return nil
+	}
+
+	editor.saveBufferAs(buffer, buffer.Filepath())
+	return nil
+}
+
+// saveBufferAs writes buffer to path, asking first if that would overwrite
+// what another program wrote to the file. C-x C-s and C-x C-w save through
+// it.
+func (e *Editor) saveBufferAs(buffer *Buffer, path string) {
+	if path == buffer.Filepath() && buffer.ChangedOnDisk() {
+		e.minibuffer.StartYesOrNo(buffer.Name()+" changed on disk; really save? ", func(editor *Editor, yes bool) {
+			if yes {
+				editor.saveBuffer(buffer, path)
+			} else {
+				editor.SetMinibufferMessage("Save cancelled")
+			}
+		})
+		return
+	}
+	e.saveBuffer(buffer, path)
+}
+
+// saveBuffer writes buffer to path and reports the outcome
+func (e *Editor) saveBuffer(buffer *Buffer, path string) {
+	err := e.writeBufferAs(buffer, path)
+	var warning *SaveWarning
+	if errors.As(err, &warning) {
+		e.SetMinibufferMessage("Wrote " + path + " (" + warning.Message + ")")
+		return
+	}
+	if err != nil {
+		log.Error("Failed to save buffer %s: %v", buffer.Name(), err)
+		e.SetMinibufferMessage("Cannot write file: " + path)
+		return
+	}
+
+	log.Info("Saved buffer %s to %s", buffer.Name(), path)
+	e.SetMinibufferMessage("Wrote " + path)
+}
+
+// SaveWarning is returned by WriteBuffer when the buffer was saved but the
+// preparation of its major mode failed, e.g. formatting it
+type SaveWarning struct {
+	Message string
+}
+
+func (e *SaveWarning) Error() string {
+	return e.Message
+}
+
+// WriteBuffer saves buffer to the file it visits, running the before-save
+// and after-save hooks
+func (e *Editor) WriteBuffer(buffer *Buffer) error {
+	if buffer.Filepath() == "" {
+		return &FileError{Message: "Buffer " + buffer.Name() + " is not visiting a file"}
+	}
+	return e.writeBufferAs(buffer, buffer.Filepath())
+}
+
+// writeBufferAs saves buffer to path and makes it visit path, running the
+// BeforeSave of its major mode and the before-save and after-save hooks
+func (e *Editor) writeBufferAs(buffer *Buffer, path string) error {
+	var warning error
+	if saver, ok := buffer.MajorMode().(BeforeSaver); ok {
+		if err := saver.BeforeSave(e, buffer); err != nil {
+			log.Warn("Preparing %s for saving failed: %v", buffer.Name(), err)
+			warning = &SaveWarning{Message: err.Error()}
+		}
+	}
+	e.TriggerHook(HookBeforeSave, buffer)
+	oldPath := buffer.Filepath()
+	if err := buffer.SaveAs(path); err != nil {
+		return err
+	}
+
+	// A new file name may call for a different major mode and buffer name
+	if path != oldPath {
+		e.uniquifyBufferNames()
+		if mode, err := e.modeManager.AutoDetectMajorMode(buffer); err == nil && mode != buffer.MajorMode() {
+			e.modeManager.SetMajorMode(buffer, mode.Name())
+		}
+	}
+
+	e.TriggerHook(HookAfterSave, buffer)
+	return warning
+}
+
+// WriteFile implements the write-file command (C-x C-w)
+func WriteFile(editor *Editor) error {
+	editor.minibuffer.StartWriteFileInput(editor.defaultDirectory())
+	editor.minibuffer.SetCompletionTable(fileTable{})
+	log.Info("Write file command started")
+	return nil
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File name prompts start in the directory of the current buffer's file.
+// A path can be typed after it without erasing it: "//" starts over at the
+// root and "/~/" at the home directory, so "~/src//etc/hosts" reads
+// /etc/hosts. Environment variables such as $HOME or ${HOME} are expanded
+// and a leading "~" stands for the home directory.
+
+// expandFileName returns the file a typed file name refers to, cleaned of
+// "." and ".." elements
+func expandFileName(input string) string {
+	if i := strings.LastIndex(input, "//"); i >= 0 {
+		input = input[i+1:]
+	}
+	if i := lastHomeReset(input); i >= 0 {
+		input = input[i+1:]
+	}
+	input = os.Expand(input, func(name string) string {
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return "$" + name
+	})
+	if input == "~" || strings.HasPrefix(input, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			input = home + input[1:]
+		}
+	}
+	if input == "" {
+		return ""
+	}
+	return filepath.Clean(input)
+}
+
+// lastHomeReset returns the index of the last "/~" that starts a path over
+// at the home directory, -1 for none
+func lastHomeReset(input string) int {
+	for i := strings.LastIndex(input, "/~"); i >= 0; i = strings.LastIndex(input[:i], "/~") {
+		if rest := input[i+2:]; rest == "" || rest[0] == '/' {
+			return i
+		}
+	}
+	return -1
+}
+
+// abbreviateHome writes a path in the home directory with a leading "~"
+func abbreviateHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || home == "/" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+"/") {
+		return "~" + path[len(home):]
+	}
+	return path
+}
+
+// defaultDirectory returns the directory file name prompts start in: that
+// of the file the current buffer visits, ending with a slash, or "" to
+// start from the working directory
+func (e *Editor) defaultDirectory() string {
+	buffer := e.CurrentBuffer()
+	if buffer == nil || buffer.Filepath() == "" {
+		return ""
+	}
+	path, err := filepath.Abs(buffer.Filepath())
+	if err != nil {
+		return ""
+	}
+	dir := abbreviateHome(filepath.Dir(path))
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	return dir
+}
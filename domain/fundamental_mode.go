@@ -48,9 +48,10 @@ func (fm *FundamentalMode) Commands() map[string]*Command {
 	return fm.commands
 }
 
-// IndentFunction returns the indentation function
+// IndentFunction returns the indentation function, which follows the
+// previous line
 func (fm *FundamentalMode) IndentFunction() IndentFunc {
-	return fm.basicIndent
+	return previousLineIndent
 }
 
 // SyntaxHighlighting returns the syntax highlighter (nil for fundamental mode)
@@ -86,9 +87,4 @@ func (fm *FundamentalMode) registerCommands() {
 func (fm *FundamentalMode) setupKeyBindings() {
 	// Fundamental mode uses only global key bindings
 	// This can be extended later
-}
-
-// basicIndent provides basic indentation (no indentation)
-func (fm *FundamentalMode) basicIndent(buffer *Buffer, line int) int {
-	return 0 // No automatic indentation
 }
\ No newline at end of file
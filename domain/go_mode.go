@@ -0,0 +1,383 @@
+package domain
+
+import (
+	"go/format"
+	"go/scanner"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GoMode is the major mode for Go source files. It highlights with the
+// tokens of go/scanner, indents with tabs by brace depth and formats with
+// go/format.
+type GoMode struct {
+	keyBindings *KeyBindingMap
+	highlighter *goHighlighter
+	filePattern *regexp.Regexp
+}
+
+// NewGoMode creates a new go mode instance
+func NewGoMode() *GoMode {
+	mode := &GoMode{
+		keyBindings: NewEmptyKeyBindingMap(),
+		highlighter: &goHighlighter{},
+		filePattern: regexp.MustCompile(`\.go$`),
+	}
+	mode.keyBindings.BindCommand("C-c C-f", "gofmt-buffer", GofmtBuffer)
+	return mode
+}
+
+// Name returns the mode name
+func (gm *GoMode) Name() string {
+	return "go-mode"
+}
+
+// FilePattern returns the file pattern for Go files
+func (gm *GoMode) FilePattern() *regexp.Regexp {
+	return gm.filePattern
+}
+
+// KeyBindings returns the key bindings for this mode
+func (gm *GoMode) KeyBindings() *KeyBindingMap {
+	return gm.keyBindings
+}
+
+// Commands returns the commands for this mode
+func (gm *GoMode) Commands() map[string]*Command {
+	return nil
+}
+
+// IndentFunction returns the indentation function for Go
+func (gm *GoMode) IndentFunction() IndentFunc {
+	return goIndent
+}
+
+// SyntaxHighlighting returns the Go highlighter
+func (gm *GoMode) SyntaxHighlighting() SyntaxHighlighter {
+	return gm.highlighter
+}
+
+// Initialize initializes the mode for a buffer
+func (gm *GoMode) Initialize(buffer *Buffer) error {
+	return nil
+}
+
+// OnActivate is called when the mode is activated
+func (gm *GoMode) OnActivate(buffer *Buffer) error {
+	return nil
+}
+
+// OnDeactivate is called when the mode is deactivated
+func (gm *GoMode) OnDeactivate(buffer *Buffer) error {
+	return nil
+}
+
+// BeforeSave formats the buffer when the "gofmt-before-save" option is set
+func (gm *GoMode) BeforeSave(editor *Editor, buffer *Buffer) error {
+	if !editor.boolOption("gofmt-before-save", false) {
+		return nil
+	}
+	_, err := gofmt(buffer)
+	return err
+}
+
+// goHighlighter highlights Go with the tokens of go/scanner. Each line is
+// scanned on its own; the state carried to the next line says whether it
+// ends inside a block comment or a raw string.
+type goHighlighter struct{}
+
+// States at the end of a line
+const (
+	goStateComment   = "comment"
+	goStateRawString = "raw-string"
+)
+
+// Identifiers that are predeclared in the universe block
+var (
+	goPredeclaredTypes = map[string]bool{
+		"any": true, "bool": true, "byte": true, "comparable": true,
+		"complex64": true, "complex128": true, "error": true,
+		"float32": true, "float64": true, "int": true, "int8": true,
+		"int16": true, "int32": true, "int64": true, "rune": true,
+		"string": true, "uint": true, "uint8": true, "uint16": true,
+		"uint32": true, "uint64": true, "uintptr": true,
+	}
+	goBuiltins = map[string]bool{
+		"append": true, "cap": true, "clear": true, "close": true,
+		"complex": true, "copy": true, "delete": true, "imag": true,
+		"len": true, "make": true, "max": true, "min": true, "new": true,
+		"panic": true, "print": true, "println": true, "real": true,
+		"recover": true,
+	}
+	goConstants = map[string]bool{"true": true, "false": true, "nil": true, "iota": true}
+)
+
+// HighlightLine highlights a line on its own
+func (h *goHighlighter) HighlightLine(line string) []HighlightSegment {
+	segments, _ := h.HighlightLineFrom(line, "")
+	return segments
+}
+
+// GetTokens returns the highlighted parts of content as tokens typed by
+// their face
+func (h *goHighlighter) GetTokens(content []string) []Token {
+	return lineStateTokens(h, content)
+}
+
+// HighlightLineFrom highlights line starting in state and returns the
+// state at its end
+func (h *goHighlighter) HighlightLineFrom(line string, state string) ([]HighlightSegment, string) {
+	var segments []HighlightSegment
+	start := 0
+
+	// Finish a block comment or a raw string from the previous line
+	closing := map[string]string{goStateComment: "*/", goStateRawString: "`"}[state]
+	if closing != "" {
+		face := "comment"
+		if state == goStateRawString {
+			face = "string"
+		}
+		end := strings.Index(line, closing)
+		if end < 0 {
+			if line != "" {
+				segments = append(segments, HighlightSegment{Start: 0, End: len(line), Style: face})
+			}
+			return segments, state
+		}
+		start = end + len(closing)
+		segments = append(segments, HighlightSegment{Start: 0, End: start, Style: face})
+	}
+
+	src := []byte(line[start:])
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, func(token.Position, string) {}, scanner.ScanComments)
+
+	type goToken struct {
+		offset int
+		tok    token.Token
+		lit    string
+	}
+	var tokens []goToken
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit != ";" {
+			continue // Inserted at the end of the line
+		}
+		if lit == "" {
+			lit = tok.String()
+		}
+		tokens = append(tokens, goToken{offset: start + file.Offset(pos), tok: tok, lit: lit})
+	}
+	next := func(i int) token.Token {
+		if i < len(tokens) {
+			return tokens[i].tok
+		}
+		return token.EOF
+	}
+
+	// Function names follow func, or the receiver of a method
+	names := make(map[int]bool)
+	for i, t := range tokens {
+		if t.tok != token.FUNC {
+			continue
+		}
+		if next(i+1) == token.IDENT {
+			names[i+1] = true
+			continue
+		}
+		if next(i+1) != token.LPAREN {
+			continue
+		}
+		depth := 0
+		for j := i + 1; j < len(tokens); j++ {
+			switch tokens[j].tok {
+			case token.LPAREN:
+				depth++
+			case token.RPAREN:
+				depth--
+			}
+			if depth == 0 {
+				if next(j+1) == token.IDENT && (next(j+2) == token.LPAREN || next(j+2) == token.LBRACK) {
+					names[j+1] = true
+				}
+				break
+			}
+		}
+	}
+
+	state = ""
+	for i, t := range tokens {
+		face := ""
+		switch {
+		case t.tok == token.COMMENT:
+			face = "comment"
+			if strings.HasPrefix(t.lit, "/*") && (len(t.lit) < 4 || !strings.HasSuffix(t.lit, "*/")) {
+				state = goStateComment
+			}
+		case t.tok == token.STRING || t.tok == token.CHAR:
+			face = "string"
+			if strings.HasPrefix(t.lit, "`") && (len(t.lit) < 2 || !strings.HasSuffix(t.lit, "`")) {
+				state = goStateRawString
+			}
+		case t.tok == token.INT || t.tok == token.FLOAT || t.tok == token.IMAG:
+			face = "constant"
+		case t.tok.IsKeyword():
+			face = "keyword"
+		case t.tok == token.IDENT:
+			switch {
+			case names[i]:
+				face = "function-name"
+			case (i > 0 && tokens[i-1].tok == token.TYPE) || goPredeclaredTypes[t.lit]:
+				face = "type"
+			case goBuiltins[t.lit]:
+				face = "builtin"
+			case goConstants[t.lit]:
+				face = "constant"
+			}
+		}
+		if face != "" {
+			segments = append(segments, HighlightSegment{Start: t.offset, End: t.offset + len(t.lit), Style: face})
+		}
+	}
+	return segments, state
+}
+
+// goIndent returns the indentation of line: a tab stop for each enclosing
+// brace, parenthesis or bracket, one less for a line that closes one or
+// starts a case clause. Lines inside a raw string or a block comment keep
+// theirs.
+func goIndent(buffer *Buffer, line int) int {
+	lines := buffer.Lines(0, line+1)
+	lineStart := len(strings.Join(lines[:line], "\n"))
+	if line > 0 {
+		lineStart++
+	}
+
+	src := []byte(strings.Join(lines, "\n"))
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, func(token.Position, string) {}, scanner.ScanComments)
+
+	depth := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		offset := file.Offset(pos)
+		if offset >= lineStart {
+			switch tok {
+			case token.RBRACE, token.RPAREN, token.RBRACK, token.CASE, token.DEFAULT:
+				depth--
+			}
+			break
+		}
+		switch tok {
+		case token.LBRACE, token.LPAREN, token.LBRACK:
+			depth++
+		case token.RBRACE, token.RPAREN, token.RBRACK:
+			depth--
+		case token.COMMENT, token.STRING:
+			if offset+len(lit) > lineStart {
+				return buffer.Indentation(line)
+			}
+		}
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	return depth * buffer.TabWidth()
+}
+
+// GofmtBuffer formats the current buffer with go/format
+func GofmtBuffer(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+	changed, err := gofmt(buffer)
+	switch {
+	case err != nil:
+		editor.SetMinibufferMessage(err.Error())
+	case changed:
+		editor.SetMinibufferMessage("Applied gofmt")
+	default:
+		editor.SetMinibufferMessage("Buffer is already gofmted")
+	}
+	return nil
+}
+
+// gofmt formats buffer and reports whether it changed. Only the lines that
+// differ are replaced, and the cursor stays where it was.
+func gofmt(buffer *Buffer) (bool, error) {
+	old := buffer.Content()
+	source := strings.Join(old, "\n")
+	formatted, err := format.Source([]byte(source + "\n"))
+	if err != nil {
+		return false, gofmtError(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(formatted), "\n"), "\n")
+	if strings.Join(lines, "\n") == source {
+		return false, nil
+	}
+
+	// Skip the lines that are the same at both ends
+	prefix := 0
+	for prefix < len(old) && prefix < len(lines) && old[prefix] == lines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(lines)-prefix && old[len(old)-1-suffix] == lines[len(lines)-1-suffix] {
+		suffix++
+	}
+	oldEnd, newEnd := len(old)-suffix, len(lines)-suffix
+	changed := lines[prefix:newEnd]
+
+	// Replace the differing lines with their newlines
+	start := Position{Row: prefix, Col: 0}
+	var end Position
+	var text string
+	switch {
+	case oldEnd < len(old):
+		end = Position{Row: oldEnd, Col: 0}
+		if len(changed) > 0 {
+			text = strings.Join(changed, "\n") + "\n"
+		}
+	case prefix > 0 && (len(changed) == 0 || prefix == len(old)):
+		// Lines removed from or added at the end of the buffer
+		start = Position{Row: prefix - 1, Col: len(old[prefix-1])}
+		end = buffer.endPosition()
+		if len(changed) > 0 {
+			text = "\n" + strings.Join(changed, "\n")
+		}
+	default:
+		end = buffer.endPosition()
+		text = strings.Join(changed, "\n")
+	}
+
+	cursor := buffer.Cursor()
+	buffer.ReplaceRegion(start, end, text)
+	buffer.SetCursor(cursor)
+	return true, nil
+}
+
+// gofmtError describes a syntax error found by go/format with its line
+func gofmtError(err error) error {
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) == 0 {
+		return &ModeError{Message: "gofmt: " + err.Error()}
+	}
+	message := "Syntax error at line " + strconv.Itoa(list[0].Pos.Line) + ": " + list[0].Msg
+	if len(list) > 1 {
+		message += " (and " + strconv.Itoa(len(list)-1) + " more)"
+	}
+	return &ModeError{Message: message}
+}
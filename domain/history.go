@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// HistoryKind names the history of one kind of minibuffer prompt. Each
+// kind keeps its own entries, so M-p at a file prompt only recalls files.
+type HistoryKind string
+
+const (
+	HistoryCommand HistoryKind = "command" // M-x command names
+	HistoryFile    HistoryKind = "file"    // Paths read by find-file and write-file
+	HistoryBuffer  HistoryKind = "buffer"  // Buffer names read by switch-to-buffer
+	HistorySearch  HistoryKind = "search"  // Search strings, also the text to replace
+	HistoryReplace HistoryKind = "replace" // Replacement strings
+)
+
+// DefaultHistoryLength is the number of entries kept for each kind
+const DefaultHistoryLength = 100
+
+// History holds the input given to minibuffer prompts, newest first
+type History struct {
+	entries map[HistoryKind][]string
+}
+
+// NewHistory creates an empty history
+func NewHistory() *History {
+	return &History{entries: make(map[HistoryKind][]string)}
+}
+
+// Add records input for a kind of prompt. An entry that is already in the
+// history moves to the front.
+func (h *History) Add(kind HistoryKind, input string) {
+	if kind == "" || input == "" {
+		return
+	}
+	entries := []string{input}
+	for _, entry := range h.entries[kind] {
+		if entry != input {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) > DefaultHistoryLength {
+		entries = entries[:DefaultHistoryLength]
+	}
+	h.entries[kind] = entries
+}
+
+// Entries returns the history of a kind of prompt, newest first
+func (h *History) Entries(kind HistoryKind) []string {
+	return h.entries[kind]
+}
+
+// Save writes the history to path as JSON, creating its directory
+func (h *History) Save(path string) error {
+	data, err := json.MarshalIndent(h.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// Load replaces the history with the one saved in path. A missing file
+// leaves the history empty.
+func (h *History) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entries := make(map[HistoryKind][]string)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return &ConfigError{Message: "Invalid history file " + path + ": " + err.Error()}
+	}
+	for kind, list := range entries {
+		if len(list) > DefaultHistoryLength {
+			entries[kind] = list[:DefaultHistoryLength]
+		}
+	}
+	h.entries = entries
+	return nil
+}
+
+// startHistory sets the history used by the prompt being started
+func (mb *Minibuffer) startHistory(kind HistoryKind) {
+	mb.historyKind = kind
+	mb.historyPos = 0
+	mb.historyInput = ""
+}
+
+// History returns the input history of all prompts
+func (mb *Minibuffer) History() *History {
+	return mb.history
+}
+
+// handleHistoryKey recalls earlier input of the prompt with M-p (older),
+// M-n (newer) and M-r (older entry containing the typed text)
+func (mb *Minibuffer) handleHistoryKey(event events.KeyEventData) bool {
+	if !event.Meta || event.Ctrl || mb.historyKind == "" {
+		return false
+	}
+	switch event.Key {
+	case "p":
+		mb.recallHistory(mb.historyPos + 1)
+	case "n":
+		mb.recallHistory(mb.historyPos - 1)
+	case "r":
+		mb.searchHistory()
+	default:
+		return false
+	}
+	return true
+}
+
+// recallHistory shows history entry pos, counting from 1 for the newest.
+// Position 0 is the text typed before moving through the history.
+func (mb *Minibuffer) recallHistory(pos int) {
+	entries := mb.history.Entries(mb.historyKind)
+	if pos < 0 || pos > len(entries) {
+		return
+	}
+	if mb.historyPos == 0 {
+		mb.historyInput = mb.content
+	}
+	mb.historyPos = pos
+	if pos == 0 {
+		mb.content = mb.historyInput
+	} else {
+		mb.content = entries[pos-1]
+	}
+	mb.cursor = len([]rune(mb.content))
+}
+
+// searchHistory shows the next older entry that contains the text typed
+// before moving through the history
+func (mb *Minibuffer) searchHistory() {
+	pattern := mb.content
+	if mb.historyPos > 0 {
+		pattern = mb.historyInput
+	}
+	entries := mb.history.Entries(mb.historyKind)
+	for pos := mb.historyPos + 1; pos <= len(entries); pos++ {
+		if strings.Contains(entries[pos-1], pattern) {
+			mb.recallHistory(pos)
+			return
+		}
+	}
+}
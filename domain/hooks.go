@@ -0,0 +1,61 @@
+package domain
+
+// Hook events. Handlers are registered with AddHook (gmacs.add_hook in
+// Lua) and receive the arguments listed next to each event. Buffers and
+// windows are passed as objects, never as bare names.
+const (
+	HookAfterChange     = "after-change"      // buffer, start, end, text, "insert" or "delete"
+	HookBeforeSave      = "before-save"       // buffer
+	HookAfterSave       = "after-save"        // buffer
+	HookFindFile        = "find-file"         // buffer
+	HookBufferSwitch    = "buffer-switch"     // buffer, previous buffer
+	HookPreCommand      = "pre-command"       // command name
+	HookPostCommand     = "post-command"      // command name
+	HookWindowSplit     = "window-split"      // new window, "right" or "below"
+	HookMajorModeChange = "major-mode-change" // buffer, mode name, previous mode name
+	HookEditorStartup   = "editor-startup"    // no arguments
+	HookEditorExit      = "editor-exit"       // no arguments
+)
+
+// HookEvents lists every event fired by the editor
+var HookEvents = []string{
+	HookAfterChange,
+	HookBeforeSave,
+	HookAfterSave,
+	HookFindFile,
+	HookBufferSwitch,
+	HookPreCommand,
+	HookPostCommand,
+	HookWindowSplit,
+	HookMajorModeChange,
+	HookEditorStartup,
+	HookEditorExit,
+}
+
+// RunStartupHooks fires editor-startup. It is called once the default and
+// user configurations have been loaded.
+func (e *Editor) RunStartupHooks() {
+	e.TriggerHook(HookEditorStartup)
+}
+
+// watchBuffer makes changes to buffer fire after-change. For an insertion
+// start and end span the new text; for a deletion they span the removed
+// text as it was before the change.
+func (e *Editor) watchBuffer(buffer *Buffer) {
+	buffer.onChange = func(b *Buffer, start, end Position, text string, inserted bool) {
+		kind := "delete"
+		if inserted {
+			kind = "insert"
+		}
+		e.TriggerHook(HookAfterChange, b, start, end, text, kind)
+	}
+}
+
+// majorModeChanged fires major-mode-change after a buffer switched modes
+func (e *Editor) majorModeChanged(buffer *Buffer, mode, previous MajorMode) {
+	var previousName interface{}
+	if previous != nil {
+		previousName = previous.Name()
+	}
+	e.TriggerHook(HookMajorModeChange, buffer, mode.Name(), previousName)
+}
@@ -0,0 +1,233 @@
+package domain
+
+import (
+	"strings"
+)
+
+// Indentation is measured in columns, with a tab reaching the next multiple
+// of the tab-width option. Indentation commands ask the indent function of
+// the major mode, or one set with SetIndentFunction, for the column of a
+// line and rewrite its leading whitespace with tabs and spaces, as Emacs
+// and gofmt do, or spaces only when the indent-tabs-mode option is false.
+
+// DefaultTabWidth is the tab width when the tab-width option is not set
+const DefaultTabWidth = 8
+
+// TabWidth returns the number of columns between tab stops in the buffer
+func (b *Buffer) TabWidth() int {
+	if b.tabWidth <= 0 {
+		return DefaultTabWidth
+	}
+	return b.tabWidth
+}
+
+// Indentation returns the indentation of a line in columns
+func (b *Buffer) Indentation(row int) int {
+	return indentationWidth(b.Line(row), b.TabWidth())
+}
+
+// indentationWidth returns the width of the leading whitespace of line
+func indentationWidth(line string, tabWidth int) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += tabWidth - width%tabWidth
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// indentationEnd returns the byte index of the first character of line
+// after its leading whitespace
+func indentationEnd(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// previousLineIndent indents a line like the nearest non-blank line above
+// it. It is the indentation of modes without rules of their own.
+func previousLineIndent(buffer *Buffer, line int) int {
+	for row := line - 1; row >= 0; row-- {
+		if strings.TrimLeft(buffer.Line(row), " \t") != "" {
+			return buffer.Indentation(row)
+		}
+	}
+	return 0
+}
+
+// SetIndentFunction makes fn the indent function of a major mode in place
+// of the one the mode provides
+func (mm *ModeManager) SetIndentFunction(modeName string, fn IndentFunc) error {
+	if _, exists := mm.majorModes[modeName]; !exists {
+		return &ModeError{Message: "Unknown major mode: " + modeName}
+	}
+	mm.indentFunctions[modeName] = fn
+	return nil
+}
+
+// IndentFunction returns the indent function used in buffer
+func (mm *ModeManager) IndentFunction(buffer *Buffer) IndentFunc {
+	mode := buffer.MajorMode()
+	if mode == nil {
+		return previousLineIndent
+	}
+	if fn := mm.indentFunctions[mode.Name()]; fn != nil {
+		return fn
+	}
+	if fn := mode.IndentFunction(); fn != nil {
+		return fn
+	}
+	return previousLineIndent
+}
+
+// indentString returns the whitespace that indents to column width
+func (e *Editor) indentString(buffer *Buffer, width int) string {
+	if !e.boolOption("indent-tabs-mode", true) {
+		return strings.Repeat(" ", width)
+	}
+	tabWidth := buffer.TabWidth()
+	return strings.Repeat("\t", width/tabWidth) + strings.Repeat(" ", width%tabWidth)
+}
+
+// setIndentation replaces the leading whitespace of a line so that it is
+// indented to column width. A cursor in the whitespace moves to its end
+// and one after it stays on the same character.
+func (e *Editor) setIndentation(buffer *Buffer, row, width int) {
+	if width < 0 {
+		width = 0
+	}
+	end := indentationEnd(buffer.Line(row))
+	cursor := buffer.Cursor()
+	buffer.ReplaceRegion(Position{Row: row, Col: 0}, Position{Row: row, Col: end}, e.indentString(buffer, width))
+	if cursor.Row != row {
+		return
+	}
+	newEnd := indentationEnd(buffer.Line(row))
+	if cursor.Col < end {
+		buffer.SetCursor(Position{Row: row, Col: newEnd})
+	} else {
+		buffer.SetCursor(Position{Row: row, Col: newEnd + cursor.Col - end})
+	}
+}
+
+// indentLine indents a line as its indent function says. A negative
+// indentation leaves the line as it is.
+func (e *Editor) indentLine(buffer *Buffer, row int) {
+	width := e.modeManager.IndentFunction(buffer)(buffer, row)
+	if width < 0 {
+		return
+	}
+	e.setIndentation(buffer, row, width)
+}
+
+// regionLines returns the first and last line of the region, leaving out
+// the last line when the region ends at its start
+func regionLines(buffer *Buffer) (first, last int, ok bool) {
+	start, end, ok := buffer.Region()
+	if !ok {
+		return 0, 0, false
+	}
+	last = end.Row
+	if end.Col == 0 && end.Row > start.Row {
+		last--
+	}
+	return start.Row, last, true
+}
+
+// IndentForTabCommand implements indent-for-tab-command (TAB), indenting
+// the current line, or the lines of the region when it is active
+func IndentForTabCommand(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+	if buffer.MarkActive() {
+		return IndentRegion(editor)
+	}
+
+	editor.indentLine(buffer, buffer.Cursor().Row)
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// NewlineAndIndent implements newline-and-indent (C-j), inserting a
+// newline, or as many as the prefix count, and indenting the new line
+func NewlineAndIndent(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	count := editor.PrefixCount()
+	if count <= 0 {
+		return nil
+	}
+	// Whitespace before the cursor would be left at the end of the line
+	cursor := buffer.Cursor()
+	line := buffer.Line(cursor.Row)
+	start := len(strings.TrimRight(line[:cursor.Col], " \t"))
+	buffer.ReplaceRegion(Position{Row: cursor.Row, Col: start}, cursor, strings.Repeat("\n", count))
+	editor.indentLine(buffer, buffer.Cursor().Row)
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// IndentRegion implements indent-region (C-M-\), indenting each non-empty
+// line of the region, or indenting them to the column given as a numeric
+// prefix argument
+func IndentRegion(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	first, last, ok := regionLines(buffer)
+	if !ok {
+		editor.SetMinibufferMessage("The mark is not set now, so there is no region")
+		return nil
+	}
+	arg := editor.PrefixArg()
+	for row := first; row <= last; row++ {
+		if buffer.Line(row) == "" {
+			continue
+		}
+		if arg != nil && !arg.Universal {
+			editor.setIndentation(buffer, row, arg.Value)
+		} else {
+			editor.indentLine(buffer, row)
+		}
+	}
+	buffer.DeactivateMark()
+	return nil
+}
+
+// IndentRigidly implements indent-rigidly (C-x TAB), shifting the lines of
+// the region right by the prefix count, or left for a negative count.
+// Lines holding only whitespace are emptied.
+func IndentRigidly(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	first, last, ok := regionLines(buffer)
+	if !ok {
+		editor.SetMinibufferMessage("The mark is not set now, so there is no region")
+		return nil
+	}
+	count := editor.PrefixCount()
+	for row := first; row <= last; row++ {
+		line := buffer.Line(row)
+		if strings.TrimLeft(line, " \t") == "" {
+			buffer.DeleteRegion(Position{Row: row, Col: 0}, Position{Row: row, Col: len(line)})
+			continue
+		}
+		editor.setIndentation(buffer, row, buffer.Indentation(row)+count)
+	}
+	buffer.DeactivateMark()
+	return nil
+}
@@ -0,0 +1,485 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/TakahashiShuuhei/gmacs/events"
+	"github.com/TakahashiShuuhei/gmacs/log"
+)
+
+// ISearch holds the state of an incremental search
+type ISearch struct {
+	buffer     *Buffer
+	forward    bool
+	regexp     bool
+	query      string
+	pattern    *regexp.Regexp // nil while the query is empty or not a valid regexp
+	start      Position       // Cursor position when the search started
+	startTop   int            // Window scroll position when the search started
+	matchStart Position
+	matchEnd   Position
+	found      bool
+	wrapped    bool
+	steps      []isearchStep // Earlier states, restored one by one with backspace
+
+	historyPos   int    // Search history entry recalled with M-p, 0 for none
+	historyInput string // Query typed before recalling history entries
+}
+
+// isearchStep is a snapshot of the search state
+type isearchStep struct {
+	query      string
+	forward    bool
+	matchStart Position
+	matchEnd   Position
+	found      bool
+	wrapped    bool
+}
+
+// ISearch returns the incremental search in progress, or nil
+func (e *Editor) ISearch() *ISearch {
+	return e.isearch
+}
+
+// CurrentMatch returns the match the cursor is on
+func (s *ISearch) CurrentMatch() (start, end Position, ok bool) {
+	if !s.found || s.pattern == nil {
+		return Position{}, Position{}, false
+	}
+	return s.matchStart, s.matchEnd, true
+}
+
+// Buffer returns the buffer being searched
+func (s *ISearch) Buffer() *Buffer {
+	return s.buffer
+}
+
+// LineMatches returns the byte ranges of all non-empty matches in line
+func (s *ISearch) LineMatches(line string) [][]int {
+	if s.pattern == nil {
+		return nil
+	}
+	var result [][]int
+	for _, m := range s.pattern.FindAllStringIndex(line, -1) {
+		if m[1] > m[0] {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// ISearchForward implements isearch-forward (C-s)
+func ISearchForward(editor *Editor) error {
+	return startISearch(editor, true, false)
+}
+
+// ISearchBackward implements isearch-backward (C-r)
+func ISearchBackward(editor *Editor) error {
+	return startISearch(editor, false, false)
+}
+
+// ISearchForwardRegexp implements isearch-forward-regexp (C-M-s)
+func ISearchForwardRegexp(editor *Editor) error {
+	return startISearch(editor, true, true)
+}
+
+// ISearchBackwardRegexp implements isearch-backward-regexp (C-M-r)
+func ISearchBackwardRegexp(editor *Editor) error {
+	return startISearch(editor, false, true)
+}
+
+func startISearch(editor *Editor, forward, isRegexp bool) error {
+	buffer := editor.CurrentBuffer()
+	window := editor.CurrentWindow()
+	if buffer == nil || window == nil {
+		return nil
+	}
+
+	cursor := buffer.Cursor()
+	editor.isearch = &ISearch{
+		buffer:     buffer,
+		forward:    forward,
+		regexp:     isRegexp,
+		start:      cursor,
+		startTop:   window.ScrollTop(),
+		matchStart: cursor,
+		matchEnd:   cursor,
+		found:      true,
+	}
+	editor.minibuffer.StartISearch()
+	editor.isearch.updatePrompt(editor)
+	log.Debug("I-search started at (%d,%d)", cursor.Row, cursor.Col)
+	return nil
+}
+
+// handleISearchInput processes a key while an incremental search is active.
+// Keys that have no meaning in isearch end the search and return false so
+// that they run as ordinary commands.
+func (e *Editor) handleISearchInput(event events.KeyEventData) bool {
+	s := e.isearch
+	if s == nil {
+		e.minibuffer.Clear()
+		return false
+	}
+
+	switch {
+	case event.Key == "Enter" || event.Key == "Return":
+		e.exitISearch()
+		return true
+	case event.Key == "Backspace" || event.Key == "\x7f" || (event.Ctrl && event.Key == "h"):
+		s.rubout(e)
+		return true
+	case event.Ctrl && event.Key == "s":
+		s.repeat(e, true)
+		return true
+	case event.Ctrl && event.Key == "r":
+		s.repeat(e, false)
+		return true
+	case event.Meta && !event.Ctrl && (event.Key == "p" || event.Key == "n"):
+		if event.Key == "p" {
+			s.recallHistory(e, s.historyPos+1)
+		} else {
+			s.recallHistory(e, s.historyPos-1)
+		}
+		return true
+	case event.Rune != 0 && !event.Ctrl && !event.Meta:
+		s.pushStep()
+		s.query += string(event.Rune)
+		s.compile()
+		s.search(e, s.matchStart, true)
+		return true
+	}
+
+	e.exitISearch()
+	return false
+}
+
+// exitISearch ends the search, leaving the cursor at the current match
+func (e *Editor) exitISearch() {
+	s := e.isearch
+	e.isearch = nil
+	e.rememberSearch(s)
+
+	if s.buffer.Cursor() != s.start {
+		s.buffer.SetMark(s.start)
+		s.buffer.DeactivateMark()
+		e.minibuffer.SetMessage("Mark saved where search started")
+	} else {
+		e.minibuffer.Clear()
+	}
+}
+
+// abortISearch ends the search and returns to where it started (C-g)
+func (e *Editor) abortISearch() {
+	s := e.isearch
+	e.isearch = nil
+	e.rememberSearch(s)
+
+	s.buffer.SetCursor(s.start)
+	if window := e.CurrentWindow(); window != nil && window.Buffer() == s.buffer {
+		window.SetScrollTop(s.startTop)
+	}
+	e.minibuffer.SetMessage("Quit")
+}
+
+// rememberSearch keeps the query for the next search of the same kind
+// and in the search history
+func (e *Editor) rememberSearch(s *ISearch) {
+	if s.query == "" {
+		return
+	}
+	e.minibuffer.history.Add(HistorySearch, s.query)
+	if s.regexp {
+		e.lastRegexp = s.query
+	} else {
+		e.lastSearch = s.query
+	}
+}
+
+// repeat moves to the next match in the given direction, wrapping around
+// the buffer after a failed search
+func (s *ISearch) repeat(e *Editor, forward bool) {
+	if s.query == "" {
+		// Search again for the previous search string
+		last := e.lastSearch
+		if s.regexp {
+			last = e.lastRegexp
+		}
+		if last == "" {
+			s.forward = forward
+			s.updatePrompt(e)
+			return
+		}
+		s.pushStep()
+		s.forward = forward
+		s.query = last
+		s.compile()
+		s.search(e, s.matchStart, true)
+		return
+	}
+
+	s.pushStep()
+	s.forward = forward
+
+	if !s.found {
+		s.wrapped = true
+		if forward {
+			s.search(e, Position{}, true)
+		} else {
+			s.search(e, s.buffer.endPosition(), true)
+		}
+		return
+	}
+
+	if forward {
+		from := s.matchEnd
+		if s.matchEnd == s.matchStart {
+			from = nextPosition(s.buffer, from)
+		}
+		s.search(e, from, true)
+	} else {
+		s.search(e, s.matchStart, false)
+	}
+}
+
+// recallHistory searches for search history entry pos (M-p, M-n) from
+// where the search started. Position 0 is the query typed before.
+func (s *ISearch) recallHistory(e *Editor, pos int) {
+	entries := e.minibuffer.history.Entries(HistorySearch)
+	if pos < 0 || pos > len(entries) {
+		return
+	}
+	if s.historyPos == 0 {
+		s.historyInput = s.query
+	}
+	s.historyPos = pos
+	s.pushStep()
+	if pos == 0 {
+		s.query = s.historyInput
+	} else {
+		s.query = entries[pos-1]
+	}
+	s.compile()
+	s.search(e, s.start, true)
+}
+
+// rubout undoes the last character typed or the last repeat
+func (s *ISearch) rubout(e *Editor) {
+	if len(s.steps) == 0 {
+		return
+	}
+
+	step := s.steps[len(s.steps)-1]
+	s.steps = s.steps[:len(s.steps)-1]
+	s.query = step.query
+	s.forward = step.forward
+	s.matchStart = step.matchStart
+	s.matchEnd = step.matchEnd
+	s.found = step.found
+	s.wrapped = step.wrapped
+	s.compile()
+
+	if s.forward {
+		s.buffer.SetCursor(s.matchEnd)
+	} else {
+		s.buffer.SetCursor(s.matchStart)
+	}
+	EnsureCursorVisible(e)
+	s.updatePrompt(e)
+}
+
+func (s *ISearch) pushStep() {
+	s.steps = append(s.steps, isearchStep{
+		query:      s.query,
+		forward:    s.forward,
+		matchStart: s.matchStart,
+		matchEnd:   s.matchEnd,
+		found:      s.found,
+		wrapped:    s.wrapped,
+	})
+}
+
+// compile builds the search pattern from the query
+func (s *ISearch) compile() {
+	s.pattern = nil
+	if s.query == "" {
+		return
+	}
+
+	pattern, err := compileSearchPattern(s.query, s.regexp)
+	if err != nil {
+		log.Debug("Incomplete regexp %q: %v", s.query, err)
+		return
+	}
+	s.pattern = pattern
+}
+
+// compileSearchPattern turns a search string into a regexp. The search
+// ignores case unless the string contains an upper case letter.
+func compileSearchPattern(query string, isRegexp bool) (*regexp.Regexp, error) {
+	expr := query
+	if !isRegexp {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if !strings.ContainsFunc(query, unicode.IsUpper) {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// search looks for the query from the given position in the current
+// direction and moves the cursor to the match
+func (s *ISearch) search(e *Editor, from Position, inclusive bool) {
+	if s.query == "" {
+		s.matchStart, s.matchEnd, s.found = s.start, s.start, true
+		s.buffer.SetCursor(s.start)
+		EnsureCursorVisible(e)
+		s.updatePrompt(e)
+		return
+	}
+
+	var start, end Position
+	found := false
+	if s.pattern != nil {
+		if s.forward {
+			start, end, found = searchForward(s.buffer, s.pattern, from)
+		} else {
+			start, end, found = searchBackward(s.buffer, s.pattern, from, inclusive)
+		}
+	}
+
+	s.found = found
+	if found {
+		s.matchStart, s.matchEnd = start, end
+		if s.forward {
+			s.buffer.SetCursor(end)
+		} else {
+			s.buffer.SetCursor(start)
+		}
+		EnsureCursorVisible(e)
+	}
+	s.updatePrompt(e)
+}
+
+// updatePrompt shows the search state in the minibuffer
+func (s *ISearch) updatePrompt(e *Editor) {
+	prompt := "I-search"
+	if s.regexp {
+		prompt = "Regexp I-search"
+	}
+	if s.wrapped {
+		prompt = "Wrapped " + prompt
+	}
+	if !s.found {
+		prompt = "Failing " + prompt
+	}
+	if !s.forward {
+		prompt += " backward"
+	}
+	e.minibuffer.SetISearchPrompt(prompt+": ", s.query)
+}
+
+// searchForward returns the first match of re that starts at or after from
+func searchForward(buffer *Buffer, re *regexp.Regexp, from Position) (Position, Position, bool) {
+	row, loc, ok := findSubmatchForward(buffer, re, from)
+	if !ok {
+		return Position{}, Position{}, false
+	}
+	return Position{Row: row, Col: loc[0]}, Position{Row: row, Col: loc[1]}, true
+}
+
+// findSubmatchForward is like searchForward but returns the row of the match
+// and its submatch byte offsets within that row
+func findSubmatchForward(buffer *Buffer, re *regexp.Regexp, from Position) (int, []int, bool) {
+	for row := from.Row; row < buffer.LineCount(); row++ {
+		col := 0
+		if row == from.Row {
+			col = from.Col
+		}
+		if loc, ok := findSubmatchFrom(re, buffer.Line(row), col); ok {
+			return row, loc, true
+		}
+	}
+	return 0, nil, false
+}
+
+// findSubmatchFrom returns the first match of re in line that starts at or
+// after col. The match is found as in the whole line: ^ only matches at its
+// start and \b sees the character before col.
+func findSubmatchFrom(re *regexp.Regexp, line string, col int) ([]int, bool) {
+	if col > len(line) {
+		return nil, false
+	}
+	for _, loc := range re.FindAllStringSubmatchIndex(line, -1) {
+		if loc[0] >= col {
+			return loc, true
+		}
+		if loc[1] > col {
+			// A match running past col hides the matches after it
+			return findSubmatchInside(re, line, col)
+		}
+	}
+	return nil, false
+}
+
+// findSubmatchInside is findSubmatchFrom for a col inside an earlier match.
+// Assertions look at one character on each side, so the line is searched
+// from the character before col with a pattern that must first consume that
+// character: \b still sees it and ^ cannot match.
+func findSubmatchInside(re *regexp.Regexp, line string, col int) ([]int, bool) {
+	shifted, err := regexp.Compile(`(?s:.)(?:` + re.String() + `)`)
+	if err != nil {
+		return nil, false
+	}
+	_, size := utf8.DecodeLastRuneInString(line[:col])
+	context := col - size
+	loc := shifted.FindStringSubmatchIndex(line[context:])
+	if loc == nil {
+		return nil, false
+	}
+	_, size = utf8.DecodeRuneInString(line[context+loc[0]:])
+	loc[0] += size
+	return shiftMatch(loc, context), true
+}
+
+// shiftMatch adds offset to the submatch offsets of loc that are set
+func shiftMatch(loc []int, offset int) []int {
+	for i := range loc {
+		if loc[i] >= 0 {
+			loc[i] += offset
+		}
+	}
+	return loc
+}
+
+// nextPosition returns the position after the character at pos, from
+// which to search again after an empty match at pos. It is past the end of
+// the buffer when pos is at the end.
+func nextPosition(buffer *Buffer, pos Position) Position {
+	if _, next, ok := runeAfter(buffer, pos); ok {
+		return next
+	}
+	return Position{Row: buffer.LineCount(), Col: 0}
+}
+
+// searchBackward returns the last match of re that starts before from, or
+// at from if inclusive is true
+func searchBackward(buffer *Buffer, re *regexp.Regexp, from Position, inclusive bool) (Position, Position, bool) {
+	if from.Row >= buffer.LineCount() {
+		from = buffer.endPosition()
+	}
+	for row := from.Row; row >= 0; row-- {
+		matches := re.FindAllStringIndex(buffer.Line(row), -1)
+		for i := len(matches) - 1; i >= 0; i-- {
+			m := matches[i]
+			if row == from.Row && (m[0] > from.Col || (m[0] == from.Col && !inclusive)) {
+				continue
+			}
+			return Position{Row: row, Col: m[0]}, Position{Row: row, Col: m[1]}, true
+		}
+	}
+	return Position{}, Position{}, false
+}
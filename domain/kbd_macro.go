@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"github.com/TakahashiShuuhei/gmacs/events"
+	"github.com/TakahashiShuuhei/gmacs/log"
+)
+
+// A keyboard macro is the stream of key events fed to HandleEvent between
+// start-kbd-macro and end-kbd-macro. Calling it feeds the same events back
+// through HandleEvent, so it replays minibuffer input and prefix arguments
+// exactly as they were typed.
+
+// maxMacroDepth limits macros that call themselves
+const maxMacroDepth = 64
+
+// kbdMacroState holds the macro being recorded and the macros defined so far
+type kbdMacroState struct {
+	defining      bool
+	keys          []events.KeyEventData            // Keys recorded so far
+	sequenceStart int                              // Index in keys of the key sequence being typed
+	last          []events.KeyEventData            // Last macro defined
+	named         map[string][]events.KeyEventData // Macros defined as commands
+	depth         int                              // Nesting depth of macro execution
+	aborted       bool                             // Execution stopped, e.g. by runaway recursion
+	repeatKey     bool                             // A plain "e" calls the last macro again
+}
+
+// recordKey adds a key event to the macro being defined
+func (e *Editor) recordKey(event events.KeyEventData) {
+	if !e.macro.defining || e.macro.depth > 0 {
+		return
+	}
+	// Remember where the key sequence that will run the next command
+	// starts, so that end-kbd-macro can drop its own keys. A message shown
+	// in the minibuffer does not make the key part of minibuffer input.
+	if e.keySequences[e.CurrentBuffer()] == nil && !e.metaPressed && !e.minibuffer.IsEditing() {
+		e.macro.sequenceStart = len(e.macro.keys)
+	}
+	e.macro.keys = append(e.macro.keys, event)
+}
+
+// DefiningKbdMacro returns true while a keyboard macro is being recorded
+func (e *Editor) DefiningKbdMacro() bool {
+	return e.macro.defining
+}
+
+// LastKbdMacro returns the keys of the last keyboard macro defined
+func (e *Editor) LastKbdMacro() []events.KeyEventData {
+	return e.macro.last
+}
+
+// KbdMacro returns the keys of the macro defined as the command name
+func (e *Editor) KbdMacro(name string) ([]events.KeyEventData, bool) {
+	keys, exists := e.macro.named[name]
+	return keys, exists
+}
+
+// DefineKbdMacro makes a keyboard macro callable as the command name
+func (e *Editor) DefineKbdMacro(name string, keys []events.KeyEventData) {
+	if e.macro.named == nil {
+		e.macro.named = make(map[string][]events.KeyEventData)
+	}
+	keys = append([]events.KeyEventData(nil), keys...)
+	e.macro.named[name] = keys
+	e.commandRegistry.RegisterFunc(name, func(editor *Editor) error {
+		return editor.ExecuteKbdMacro(keys, editor.PrefixCount())
+	})
+}
+
+// ExecuteKbdMacro feeds keys to the editor count times
+func (e *Editor) ExecuteKbdMacro(keys []events.KeyEventData, count int) error {
+	if e.macro.depth >= maxMacroDepth {
+		e.macro.aborted = true
+		return &ModeError{Message: "Keyboard macro nested too deeply"}
+	}
+	e.macro.depth++
+	defer func() {
+		e.macro.depth--
+		if e.macro.depth == 0 {
+			e.macro.aborted = false
+		}
+	}()
+
+	for i := 0; i < count && !e.macro.aborted; i++ {
+		for _, key := range keys {
+			if e.macro.aborted {
+				break
+			}
+			e.HandleEvent(key)
+		}
+	}
+	if e.macro.aborted {
+		e.SetMinibufferMessage("Keyboard macro nested too deeply")
+	}
+	return nil
+}
+
+// StartKbdMacro implements start-kbd-macro (C-x ()
+func StartKbdMacro(editor *Editor) error {
+	if editor.macro.defining {
+		editor.SetMinibufferMessage("Already defining keyboard macro")
+		return nil
+	}
+	editor.macro.defining = true
+	editor.macro.keys = nil
+	editor.macro.sequenceStart = 0
+	editor.SetMinibufferMessage("Defining kbd macro...")
+	log.Info("Started defining keyboard macro")
+	return nil
+}
+
+// EndKbdMacro implements end-kbd-macro (C-x ))
+func EndKbdMacro(editor *Editor) error {
+	if !editor.macro.defining {
+		editor.SetMinibufferMessage("Not defining kbd macro")
+		return nil
+	}
+	editor.macro.defining = false
+	end := editor.macro.sequenceStart
+	if end > len(editor.macro.keys) {
+		end = len(editor.macro.keys)
+	}
+	editor.macro.last = editor.macro.keys[:end]
+	editor.macro.keys = nil
+	editor.SetMinibufferMessage("Keyboard macro defined")
+	log.Info("Defined keyboard macro of %d keys", len(editor.macro.last))
+	return nil
+}
+
+// CallLastKbdMacro implements call-last-kbd-macro (C-x e). It first ends
+// a macro being defined, and runs the macro as many times as the prefix
+// count. A plain "e" right after it calls the macro again.
+func CallLastKbdMacro(editor *Editor) error {
+	if editor.macro.defining {
+		EndKbdMacro(editor)
+	}
+	if len(editor.macro.last) == 0 {
+		editor.SetMinibufferMessage("No kbd macro has been defined")
+		return nil
+	}
+
+	count := editor.PrefixCount()
+	if err := editor.ExecuteKbdMacro(editor.macro.last, count); err != nil {
+		return err
+	}
+	editor.macro.repeatKey = true
+	return nil
+}
+
+// NameLastKbdMacro implements name-last-kbd-macro, defining the last macro
+// as a command with the name read from the minibuffer
+func NameLastKbdMacro(editor *Editor) error {
+	if len(editor.macro.last) == 0 {
+		editor.SetMinibufferMessage("No kbd macro has been defined")
+		return nil
+	}
+	editor.minibuffer.StartInput("Name for last kbd macro: ", func(editor *Editor, name string) {
+		if name == "" {
+			return
+		}
+		editor.DefineKbdMacro(name, editor.macro.last)
+		editor.SetMinibufferMessage("Keyboard macro named " + name)
+	})
+	return nil
+}
+
+// cancelKbdMacro abandons the macro being defined
+func (e *Editor) cancelKbdMacro() bool {
+	if !e.macro.defining {
+		return false
+	}
+	e.macro.defining = false
+	e.macro.keys = nil
+	return true
+}
@@ -7,6 +7,7 @@ import (
 // KeySequenceBinding represents a multi-key sequence binding
 type KeySequenceBinding struct {
 	Sequence []KeyPress
+	Name     string // Command name, anonymousCommand for a bare function
 	Command  CommandFunc
 }
 
@@ -20,6 +21,7 @@ type KeyPress struct {
 // RawSequenceBinding represents raw escape sequences (like arrow keys)
 type RawSequenceBinding struct {
 	Sequence string
+	Name     string // Command name, anonymousCommand for a bare function
 	Command  CommandFunc
 }
 
@@ -54,40 +56,47 @@ func NewEmptyKeyBindingMap() *KeyBindingMap {
 
 func (kbm *KeyBindingMap) registerDefaultBindings() {
 	// Cursor movement
-	kbm.BindKeySequence("C-f", ForwardChar)      // C-f
-	kbm.BindKeySequence("C-b", BackwardChar)     // C-b
-	kbm.BindKeySequence("C-n", NextLine)        // C-n
-	kbm.BindKeySequence("C-p", PreviousLine)    // C-p
-	kbm.BindKeySequence("C-a", BeginningOfLine) // C-a
-	kbm.BindKeySequence("C-e", EndOfLine)       // C-e
+	kbm.BindCommand("C-f", "forward-char", ForwardChar)
+	kbm.BindCommand("C-b", "backward-char", BackwardChar)
+	kbm.BindCommand("C-n", "next-line", NextLine)
+	kbm.BindCommand("C-p", "previous-line", PreviousLine)
+	kbm.BindCommand("C-a", "beginning-of-line", BeginningOfLine)
+	kbm.BindCommand("C-e", "end-of-line", EndOfLine)
 	
 	// Deletion
-	kbm.BindKeySequence("C-h", DeleteBackwardChar) // C-h: backspace
-	kbm.BindKeySequence("C-d", DeleteChar)         // C-d: delete-char
+	kbm.BindCommand("C-h", "delete-backward-char", DeleteBackwardChar) // C-h: backspace
+	kbm.BindCommand("C-d", "delete-char", DeleteChar)
 	
 	// Cancel/Quit
-	kbm.BindKeySequence("C-g", KeyboardQuit)       // C-g: keyboard-quit
+	kbm.BindCommand("C-g", "keyboard-quit", KeyboardQuit)
 	
 	// Scrolling
-	kbm.BindKeySequence("C-v", PageDown)        // C-v (page down)
-	kbm.BindRawSequence("\x1b[6~", PageDown)    // Page Down key
-	kbm.BindRawSequence("\x1b[5~", PageUp)      // Page Up key
+	kbm.BindCommand("C-v", "page-down", PageDown)
+	kbm.BindRawCommand("\x1b[6~", "page-down", PageDown) // Page Down key
+	kbm.BindRawCommand("\x1b[5~", "page-up", PageUp)     // Page Up key
 	
 	// Arrow keys (ANSI escape sequences)
-	kbm.BindRawSequence("\x1b[C", ForwardChar)  // Right arrow
-	kbm.BindRawSequence("\x1b[D", BackwardChar) // Left arrow
-	kbm.BindRawSequence("\x1b[B", NextLine)     // Down arrow
-	kbm.BindRawSequence("\x1b[A", PreviousLine) // Up arrow
+	kbm.BindRawCommand("\x1b[C", "forward-char", ForwardChar)   // Right arrow
+	kbm.BindRawCommand("\x1b[D", "backward-char", BackwardChar) // Left arrow
+	kbm.BindRawCommand("\x1b[B", "next-line", NextLine)         // Down arrow
+	kbm.BindRawCommand("\x1b[A", "previous-line", PreviousLine) // Up arrow
 	
 	// Multi-key sequences
-	kbm.BindKeySequence("C-x C-c", Quit)        // C-x C-c: quit
-	kbm.BindKeySequence("C-x C-f", FindFile)    // C-x C-f: find-file
+	kbm.BindCommand("C-x C-c", "quit", Quit)
+	kbm.BindCommand("C-x C-f", "find-file", FindFile)
 }
 
-// BindRawSequence adds a raw key sequence binding (like arrow keys)
+// BindRawSequence adds a raw key sequence binding (like arrow keys) to a
+// function without a command name
 func (kbm *KeyBindingMap) BindRawSequence(sequence string, command CommandFunc) {
+	kbm.BindRawCommand(sequence, anonymousCommand, command)
+}
+
+// BindRawCommand adds a raw key sequence binding to the named command
+func (kbm *KeyBindingMap) BindRawCommand(sequence, name string, command CommandFunc) {
 	binding := RawSequenceBinding{
 		Sequence: sequence,
+		Name:     name,
 		Command:  command,
 	}
 	kbm.rawSequenceBindings = append(kbm.rawSequenceBindings, binding)
@@ -95,10 +104,16 @@ func (kbm *KeyBindingMap) BindRawSequence(sequence string, command CommandFunc)
 
 // LookupSequence finds a command for the given key sequence (both raw and parsed)
 func (kbm *KeyBindingMap) LookupSequence(sequence string) (CommandFunc, bool) {
+	_, command, found := kbm.lookupNamed(sequence)
+	return command, found
+}
+
+// lookupNamed is LookupSequence that also returns the command name
+func (kbm *KeyBindingMap) lookupNamed(sequence string) (string, CommandFunc, bool) {
 	// First check raw sequence bindings (escape sequences)
 	for _, binding := range kbm.rawSequenceBindings {
 		if binding.Sequence == sequence {
-			return binding.Command, true
+			return binding.Name, binding.Command, true
 		}
 	}
 	
@@ -106,18 +121,29 @@ func (kbm *KeyBindingMap) LookupSequence(sequence string) (CommandFunc, bool) {
 	parsedSequence := parseKeySequence(sequence)
 	for _, binding := range kbm.sequenceBindings {
 		if kbm.sequencesEqual(binding.Sequence, parsedSequence) {
-			return binding.Command, true
+			return binding.Name, binding.Command, true
 		}
 	}
 	
-	return nil, false
+	return "", nil, false
 }
 
-// BindKeySequence adds a multi-key sequence binding like "C-x C-c"
+// anonymousCommand is the name reported to pre-command and post-command
+// hooks for a key bound to a function rather than a named command
+const anonymousCommand = "lambda"
+
+// BindKeySequence adds a multi-key sequence binding like "C-x C-c" to a
+// function without a command name
 func (kbm *KeyBindingMap) BindKeySequence(keySequence string, command CommandFunc) {
-	sequence := parseKeySequence(keySequence)
+	kbm.BindCommand(keySequence, anonymousCommand, command)
+}
+
+// BindCommand binds a key sequence like "C-x C-s" to the named command.
+// The name is reported to pre-command and post-command hooks.
+func (kbm *KeyBindingMap) BindCommand(keySequence, name string, command CommandFunc) {
 	binding := KeySequenceBinding{
-		Sequence: sequence,
+		Sequence: parseKeySequence(keySequence),
+		Name:     name,
 		Command:  command,
 	}
 	kbm.sequenceBindings = append(kbm.sequenceBindings, binding)
@@ -136,8 +162,20 @@ func parseKeySequence(keySequence string) []KeyPress {
 	return sequence
 }
 
-// parseKeyPress parses a string like "C-x" or "M-x" into KeyPress
+// parseKeyPress parses a string like "C-x" or "M-x" into KeyPress. "TAB"
+// is C-i, the key a terminal sends for it.
 func parseKeyPress(keyStr string) KeyPress {
+	if keyStr == "TAB" {
+		return KeyPress{Key: "i", Ctrl: true}
+	}
+
+	// A trailing "-" is the minus key itself, as in "M--"
+	if strings.HasSuffix(keyStr, "--") {
+		keyPress := parseKeyPress(keyStr[:len(keyStr)-1] + "x")
+		keyPress.Key = "-"
+		return keyPress
+	}
+
 	parts := strings.Split(keyStr, "-")
 	
 	keyPress := KeyPress{
@@ -170,18 +208,15 @@ func (kbm *KeyBindingMap) ProcessKeyPress(key string, ctrl, meta bool) (CommandF
 	// Add to current sequence
 	kbm.currentSequence = append(kbm.currentSequence, currentPress)
 	
-	// Check if current sequence matches any binding
-	for _, binding := range kbm.sequenceBindings {
-		if kbm.matchesSequence(binding.Sequence) {
-			// Complete match - reset sequence and return command
-			kbm.currentSequence = make([]KeyPress, 0)
-			return binding.Command, true, false
-		}
-		
-		if kbm.isPrefixOf(binding.Sequence) {
-			// Partial match - continue sequence
-			return nil, false, true
-		}
+	binding, exact, prefix := kbm.lookupKeys(kbm.currentSequence)
+	if exact {
+		// Complete match - reset sequence and return command
+		kbm.currentSequence = make([]KeyPress, 0)
+		return binding.Command, true, false
+	}
+	if prefix {
+		// Partial match - continue sequence
+		return nil, false, true
 	}
 	
 	// No match - reset sequence
@@ -189,37 +224,30 @@ func (kbm *KeyBindingMap) ProcessKeyPress(key string, ctrl, meta bool) (CommandF
 	return nil, false, false
 }
 
-// matchesSequence checks if current sequence exactly matches the given sequence
-func (kbm *KeyBindingMap) matchesSequence(sequence []KeyPress) bool {
-	if len(kbm.currentSequence) != len(sequence) {
-		return false
-	}
-	
-	for i, press := range kbm.currentSequence {
-		if press.Key != sequence[i].Key || 
-		   press.Ctrl != sequence[i].Ctrl || 
-		   press.Meta != sequence[i].Meta {
-			return false
+// lookupKeys finds the first binding that keys either completes (exact) or
+// starts (prefix). Bindings are tried in the order they were added.
+func (kbm *KeyBindingMap) lookupKeys(keys []KeyPress) (KeySequenceBinding, bool, bool) {
+	for _, binding := range kbm.sequenceBindings {
+		if kbm.sequencesEqual(binding.Sequence, keys) {
+			return binding, true, false
+		}
+		if isKeyPrefix(keys, binding.Sequence) {
+			return KeySequenceBinding{}, false, true
 		}
 	}
-	
-	return true
+	return KeySequenceBinding{}, false, false
 }
 
-// isPrefixOf checks if current sequence is a prefix of the given sequence
-func (kbm *KeyBindingMap) isPrefixOf(sequence []KeyPress) bool {
-	if len(kbm.currentSequence) >= len(sequence) {
+// isKeyPrefix checks if keys is a proper prefix of sequence
+func isKeyPrefix(keys, sequence []KeyPress) bool {
+	if len(keys) >= len(sequence) {
 		return false
 	}
-	
-	for i, press := range kbm.currentSequence {
-		if press.Key != sequence[i].Key || 
-		   press.Ctrl != sequence[i].Ctrl || 
-		   press.Meta != sequence[i].Meta {
+	for i, press := range keys {
+		if press != sequence[i] {
 			return false
 		}
 	}
-	
 	return true
 }
 
@@ -269,18 +297,28 @@ func FormatSequence(sequence []KeyPress) string {
 	
 	parts := make([]string, len(sequence))
 	for i, press := range sequence {
-		var keyStr string
-		if press.Ctrl && press.Meta {
-			keyStr = "C-M-" + press.Key
-		} else if press.Ctrl {
-			keyStr = "C-" + press.Key
-		} else if press.Meta {
-			keyStr = "M-" + press.Key
-		} else {
-			keyStr = press.Key
-		}
-		parts[i] = keyStr
+		parts[i] = formatKeyPress(press)
 	}
 	
 	return strings.Join(parts, " ") + " -"
-}
\ No newline at end of file
+}
+
+// formatKeyPress formats a single key press, e.g. "C-x" or "M-5"
+func formatKeyPress(press KeyPress) string {
+	if isTabKey(press.Key, press.Ctrl, press.Meta) {
+		return "TAB"
+	}
+	if press.Ctrl && press.Meta {
+		return "C-M-" + press.Key
+	} else if press.Ctrl {
+		return "C-" + press.Key
+	} else if press.Meta {
+		return "M-" + press.Key
+	}
+	return press.Key
+}
+
+// isTabKey reports whether a key is TAB, which arrives as C-i
+func isTabKey(key string, ctrl, meta bool) bool {
+	return !meta && ((ctrl && key == "i") || key == "Tab" || key == "\t")
+}
@@ -0,0 +1,71 @@
+package domain
+
+// Keys are looked up in layers: the keymaps of the buffer's enabled minor
+// modes in priority order, then its major mode keymap, then the global
+// keymap. The first layer that binds the keys typed so far, either to a
+// command or as a prefix, decides what happens. A prefix stays alive in
+// every layer that has it, so "C-x" bound as a prefix in a minor mode does
+// not hide the global "C-x C-f".
+
+// keySequenceState is the multi-key sequence being typed in one buffer
+type keySequenceState struct {
+	keys   []KeyPress
+	layers []*KeyBindingMap // Layers in which keys is still a prefix
+}
+
+// keymapLayers returns the keymaps consulted for keys typed in buffer,
+// highest precedence first
+func (e *Editor) keymapLayers(buffer *Buffer) []*KeyBindingMap {
+	var layers []*KeyBindingMap
+	if buffer != nil {
+		layers = e.modeManager.modeKeymaps(buffer)
+	}
+	return append(layers, e.keyBindings)
+}
+
+// processKey adds a key press to the current buffer's key sequence and
+// looks the sequence up in the layers it is still alive in. It returns the
+// binding of a completed sequence, and whether the sequence matched a
+// command or continues as a prefix.
+func (e *Editor) processKey(key string, ctrl, meta bool) (KeySequenceBinding, bool, bool) {
+	buffer := e.CurrentBuffer()
+	state := e.keySequences[buffer]
+	if state == nil {
+		state = &keySequenceState{layers: e.keymapLayers(buffer)}
+	}
+	keys := append(state.keys, KeyPress{Key: key, Ctrl: ctrl, Meta: meta})
+
+	var alive []*KeyBindingMap
+	for _, layer := range state.layers {
+		binding, exact, prefix := layer.lookupKeys(keys)
+		if exact && len(alive) == 0 {
+			delete(e.keySequences, buffer)
+			return binding, true, false
+		}
+		if prefix {
+			alive = append(alive, layer)
+		}
+	}
+
+	if len(alive) == 0 {
+		delete(e.keySequences, buffer)
+		return KeySequenceBinding{}, false, false
+	}
+	e.keySequences[buffer] = &keySequenceState{keys: keys, layers: alive}
+	return KeySequenceBinding{}, false, true
+}
+
+// lookupKey finds a single key or raw escape sequence in the keymap layers
+func (e *Editor) lookupKey(sequence string) (string, CommandFunc, bool) {
+	for _, layer := range e.keymapLayers(e.CurrentBuffer()) {
+		if name, cmd, found := layer.lookupNamed(sequence); found {
+			return name, cmd, true
+		}
+	}
+	return "", nil, false
+}
+
+// resetKeySequence abandons the key sequence typed in the current buffer
+func (e *Editor) resetKeySequence() {
+	delete(e.keySequences, e.CurrentBuffer())
+}
@@ -0,0 +1,228 @@
+package domain
+
+import (
+	"github.com/TakahashiShuuhei/gmacs/log"
+)
+
+// KillRingMax is the maximum number of entries kept in the kill ring
+const KillRingMax = 60
+
+// KillRing stores killed text shared by all buffers
+type KillRing struct {
+	entries []string // Most recent kill first
+	yank    int      // Index of the entry most recently yanked
+}
+
+// NewKillRing creates an empty kill ring
+func NewKillRing() *KillRing {
+	return &KillRing{
+		entries: make([]string, 0),
+	}
+}
+
+// Push adds a new entry to the front of the kill ring
+func (kr *KillRing) Push(text string) {
+	kr.entries = append([]string{text}, kr.entries...)
+	if len(kr.entries) > KillRingMax {
+		kr.entries = kr.entries[:KillRingMax]
+	}
+	kr.yank = 0
+}
+
+// Append adds text to the most recent entry, before it if prepend is true
+func (kr *KillRing) Append(text string, prepend bool) {
+	if len(kr.entries) == 0 {
+		kr.Push(text)
+		return
+	}
+	if prepend {
+		kr.entries[0] = text + kr.entries[0]
+	} else {
+		kr.entries[0] = kr.entries[0] + text
+	}
+	kr.yank = 0
+}
+
+// Current returns the entry that the next yank inserts
+func (kr *KillRing) Current() (string, bool) {
+	if len(kr.entries) == 0 {
+		return "", false
+	}
+	return kr.entries[kr.yank], true
+}
+
+// Rotate moves the yank pointer to the next older entry, wrapping around
+func (kr *KillRing) Rotate() (string, bool) {
+	if len(kr.entries) == 0 {
+		return "", false
+	}
+	kr.yank = (kr.yank + 1) % len(kr.entries)
+	return kr.entries[kr.yank], true
+}
+
+// Len returns the number of entries in the kill ring
+func (kr *KillRing) Len() int {
+	return len(kr.entries)
+}
+
+// killText deletes the text between from and to and saves it in the kill ring.
+// Consecutive kills are merged into one entry; text killed backwards (to
+// before from) is prepended to it.
+func killText(editor *Editor, buffer *Buffer, from, to Position) {
+	text := buffer.deleteText(from, to)
+	if editor.lastCommand == "kill-region" {
+		editor.killRing.Append(text, to.Before(from))
+	} else {
+		editor.killRing.Push(text)
+	}
+	if to.Before(from) {
+		buffer.SetCursor(to)
+	} else {
+		buffer.SetCursor(from)
+	}
+	editor.thisCommand = "kill-region"
+}
+
+// SetMarkCommand implements set-mark-command (C-SPC)
+func SetMarkCommand(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	buffer.SetMark(buffer.Cursor())
+	editor.SetMinibufferMessage("Mark set")
+	log.Debug("Mark set at (%d,%d)", buffer.Cursor().Row, buffer.Cursor().Col)
+	return nil
+}
+
+// KillRegion implements kill-region (C-w)
+func KillRegion(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	start, end, ok := buffer.Region()
+	if !ok {
+		editor.SetMinibufferMessage("The mark is not set now, so there is no region")
+		return nil
+	}
+
+	killText(editor, buffer, start, end)
+	buffer.DeactivateMark()
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// KillRingSave implements kill-ring-save (M-w)
+func KillRingSave(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	start, end, ok := buffer.Region()
+	if !ok {
+		editor.SetMinibufferMessage("The mark is not set now, so there is no region")
+		return nil
+	}
+
+	editor.killRing.Push(buffer.textInRange(start, end))
+	buffer.DeactivateMark()
+	return nil
+}
+
+// KillLine implements kill-line (C-k). It kills the rest of the line, or the
+// newline itself when only whitespace follows the cursor.
+func KillLine(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	cursor := buffer.Cursor()
+	line := buffer.Line(cursor.Row)
+	end := Position{Row: cursor.Row, Col: len(line)}
+
+	if isBlank(line[cursor.Col:]) {
+		if cursor.Row >= buffer.LineCount()-1 {
+			if cursor.Col == len(line) {
+				editor.SetMinibufferMessage("End of buffer")
+				return nil
+			}
+		} else {
+			end = Position{Row: cursor.Row + 1, Col: 0}
+		}
+	}
+
+	killText(editor, buffer, cursor, end)
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// Yank implements yank (C-y), inserting the most recent kill at the cursor
+func Yank(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	text, ok := editor.killRing.Current()
+	if !ok {
+		editor.SetMinibufferMessage("Kill ring is empty")
+		return nil
+	}
+
+	insertYank(buffer, text)
+	editor.thisCommand = "yank"
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// YankPop implements yank-pop (M-y), replacing the text just yanked with
+// the next older kill
+func YankPop(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	if editor.lastCommand != "yank" {
+		editor.SetMinibufferMessage("Previous command was not a yank")
+		return nil
+	}
+
+	text, ok := editor.killRing.Rotate()
+	if !ok {
+		editor.SetMinibufferMessage("Kill ring is empty")
+		return nil
+	}
+
+	start, end, _ := buffer.Region()
+	buffer.deleteText(start, end)
+	buffer.SetCursor(start)
+	insertYank(buffer, text)
+	editor.thisCommand = "yank"
+	EnsureCursorVisible(editor)
+	return nil
+}
+
+// insertYank inserts text at the cursor, leaving the (inactive) mark at the
+// start of the inserted text
+func insertYank(buffer *Buffer, text string) {
+	start := buffer.Cursor()
+	buffer.InsertString(text)
+	buffer.SetMark(start)
+	buffer.DeactivateMark()
+}
+
+// isBlank returns true if s contains only spaces and tabs
+func isBlank(s string) bool {
+	for _, r := range s {
+		if r != ' ' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
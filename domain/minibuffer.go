@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"os"
+	"path"
+
 	"github.com/TakahashiShuuhei/gmacs/events"
 )
 
@@ -13,6 +16,12 @@ const (
 	MinibufferCommand                 // M-x command input
 	MinibufferMessage                 // Displaying a message
 	MinibufferFile                    // File path input (C-x C-f)
+	MinibufferBufferSelection         // Buffer name input (C-x b)
+	MinibufferWriteFile               // File path input for write-file (C-x C-w)
+	MinibufferISearch                 // Incremental search (C-s, C-r)
+	MinibufferInput                   // Generic string input passed to a callback
+	MinibufferQueryReplace            // Waiting for y/n/!/q during query-replace
+	MinibufferYesOrNo                 // Waiting for the y or n answer to a question
 )
 
 // Minibuffer manages the minibuffer state
@@ -22,6 +31,15 @@ type Minibuffer struct {
 	prompt   string
 	message  string
 	cursor   int
+	onSubmit func(editor *Editor, input string) // Callback for MinibufferInput
+	onAnswer func(editor *Editor, yes bool)     // Callback for MinibufferYesOrNo
+
+	history      *History
+	historyKind  HistoryKind // History of the current prompt, "" for none
+	historyPos   int         // Entry shown, 0 for the typed text
+	historyInput string      // Text typed before moving through the history
+
+	completion *completion // Completion of the current prompt, nil for none
 }
 
 func NewMinibuffer() *Minibuffer {
@@ -31,6 +49,7 @@ func NewMinibuffer() *Minibuffer {
 		prompt:  "",
 		message: "",
 		cursor:  0,
+		history: NewHistory(),
 	}
 }
 
@@ -58,6 +77,15 @@ func (mb *Minibuffer) CursorPosition() int {
 	return mb.cursor
 }
 
+// IsEditing returns true if the minibuffer is reading user input
+func (mb *Minibuffer) IsEditing() bool {
+	switch mb.mode {
+	case MinibufferCommand, MinibufferFile, MinibufferBufferSelection, MinibufferWriteFile, MinibufferInput:
+		return true
+	}
+	return false
+}
+
 // StartCommandInput starts M-x command input mode
 func (mb *Minibuffer) StartCommandInput() {
 	mb.mode = MinibufferCommand
@@ -65,15 +93,94 @@ func (mb *Minibuffer) StartCommandInput() {
 	mb.prompt = "M-x "
 	mb.message = ""
 	mb.cursor = 0
+	mb.completion = nil
+	mb.startHistory(HistoryCommand)
 }
 
-// StartFileInput starts file path input mode (C-x C-f)
-func (mb *Minibuffer) StartFileInput() {
+// StartFileInput starts file path input mode (C-x C-f) with the input
+// set to dir
+func (mb *Minibuffer) StartFileInput(dir string) {
 	mb.mode = MinibufferFile
-	mb.content = ""
+	mb.content = dir
 	mb.prompt = "Find file: "
 	mb.message = ""
+	mb.cursor = len([]rune(dir))
+	mb.completion = nil
+	mb.startHistory(HistoryFile)
+}
+
+// StartWriteFileInput starts file path input mode for write-file (C-x C-w)
+// with the input set to dir
+func (mb *Minibuffer) StartWriteFileInput(dir string) {
+	mb.mode = MinibufferWriteFile
+	mb.content = dir
+	mb.prompt = "Write file: "
+	mb.message = ""
+	mb.cursor = len([]rune(dir))
+	mb.completion = nil
+	mb.startHistory(HistoryFile)
+}
+
+// StartISearch starts incremental search mode (C-s, C-r)
+func (mb *Minibuffer) StartISearch() {
+	mb.mode = MinibufferISearch
+	mb.content = ""
+	mb.prompt = "I-search: "
+	mb.message = ""
+	mb.cursor = 0
+	mb.completion = nil
+}
+
+// SetISearchPrompt updates the prompt and search string shown during isearch
+func (mb *Minibuffer) SetISearchPrompt(prompt, query string) {
+	if mb.mode != MinibufferISearch {
+		return
+	}
+	mb.prompt = prompt
+	mb.content = query
+	mb.cursor = len([]rune(query))
+}
+
+// StartInput reads a string with the given prompt and passes it to onSubmit
+// when Enter is pressed
+func (mb *Minibuffer) StartInput(prompt string, onSubmit func(editor *Editor, input string)) {
+	mb.mode = MinibufferInput
+	mb.content = ""
+	mb.prompt = prompt
+	mb.message = ""
+	mb.cursor = 0
+	mb.completion = nil
+	mb.onSubmit = onSubmit
+	mb.startHistory("")
+}
+
+// StartInputWithHistory reads a string like StartInput, recording it in
+// and recalling it from the history of kind
+func (mb *Minibuffer) StartInputWithHistory(prompt string, kind HistoryKind, onSubmit func(editor *Editor, input string)) {
+	mb.StartInput(prompt, onSubmit)
+	mb.startHistory(kind)
+}
+
+// StartQueryReplace shows the query-replace question for the current match
+func (mb *Minibuffer) StartQueryReplace(prompt string) {
+	mb.mode = MinibufferQueryReplace
+	mb.content = ""
+	mb.prompt = prompt
+	mb.message = ""
 	mb.cursor = 0
+	mb.completion = nil
+}
+
+// StartYesOrNo asks a question answered with y or n and passes the answer
+// to onAnswer. C-g cancels the question without calling it.
+func (mb *Minibuffer) StartYesOrNo(prompt string, onAnswer func(editor *Editor, yes bool)) {
+	mb.mode = MinibufferYesOrNo
+	mb.content = ""
+	mb.prompt = prompt + "(y or n) "
+	mb.message = ""
+	mb.cursor = 0
+	mb.completion = nil
+	mb.onAnswer = onAnswer
 }
 
 // SetMessage displays a message in the minibuffer
@@ -83,6 +190,9 @@ func (mb *Minibuffer) SetMessage(message string) {
 	mb.prompt = ""
 	mb.message = message
 	mb.cursor = 0
+	mb.completion = nil
+	mb.onSubmit = nil
+	mb.onAnswer = nil
 }
 
 // Clear clears the minibuffer
@@ -92,11 +202,14 @@ func (mb *Minibuffer) Clear() {
 	mb.prompt = ""
 	mb.message = ""
 	mb.cursor = 0
+	mb.completion = nil
+	mb.onSubmit = nil
+	mb.onAnswer = nil
 }
 
 // InsertChar inserts a character at the cursor position
 func (mb *Minibuffer) InsertChar(ch rune) {
-	if mb.mode != MinibufferCommand && mb.mode != MinibufferFile && mb.mode != MinibufferBufferSelection {
+	if !mb.IsEditing() {
 		return
 	}
 	
@@ -114,7 +227,7 @@ func (mb *Minibuffer) InsertChar(ch rune) {
 
 // DeleteBackward deletes the character before the cursor
 func (mb *Minibuffer) DeleteBackward() {
-	if (mb.mode != MinibufferCommand && mb.mode != MinibufferFile && mb.mode != MinibufferBufferSelection) || mb.cursor == 0 {
+	if !mb.IsEditing() || mb.cursor == 0 {
 		return
 	}
 	
@@ -128,7 +241,7 @@ func (mb *Minibuffer) DeleteBackward() {
 
 // DeleteForward deletes the character at the cursor position
 func (mb *Minibuffer) DeleteForward() {
-	if mb.mode != MinibufferCommand && mb.mode != MinibufferFile && mb.mode != MinibufferBufferSelection {
+	if !mb.IsEditing() {
 		return
 	}
 	
@@ -146,7 +259,7 @@ func (mb *Minibuffer) DeleteForward() {
 
 // MoveCursorForward moves cursor one position to the right
 func (mb *Minibuffer) MoveCursorForward() {
-	if mb.mode != MinibufferCommand && mb.mode != MinibufferFile && mb.mode != MinibufferBufferSelection {
+	if !mb.IsEditing() {
 		return
 	}
 	
@@ -158,7 +271,7 @@ func (mb *Minibuffer) MoveCursorForward() {
 
 // MoveCursorBackward moves cursor one position to the left
 func (mb *Minibuffer) MoveCursorBackward() {
-	if mb.mode != MinibufferCommand && mb.mode != MinibufferFile && mb.mode != MinibufferBufferSelection {
+	if !mb.IsEditing() {
 		return
 	}
 	
@@ -169,7 +282,7 @@ func (mb *Minibuffer) MoveCursorBackward() {
 
 // MoveCursorToBeginning moves cursor to the beginning of the line
 func (mb *Minibuffer) MoveCursorToBeginning() {
-	if mb.mode != MinibufferCommand && mb.mode != MinibufferFile && mb.mode != MinibufferBufferSelection {
+	if !mb.IsEditing() {
 		return
 	}
 	
@@ -178,7 +291,7 @@ func (mb *Minibuffer) MoveCursorToBeginning() {
 
 // MoveCursorToEnd moves cursor to the end of the line
 func (mb *Minibuffer) MoveCursorToEnd() {
-	if mb.mode != MinibufferCommand && mb.mode != MinibufferFile && mb.mode != MinibufferBufferSelection {
+	if !mb.IsEditing() {
 		return
 	}
 	
@@ -195,6 +308,16 @@ func (mb *Minibuffer) GetDisplayText() string {
 		return mb.prompt + mb.content
 	case MinibufferBufferSelection:
 		return mb.prompt + mb.content
+	case MinibufferWriteFile:
+		return mb.prompt + mb.content
+	case MinibufferISearch:
+		return mb.prompt + mb.content
+	case MinibufferInput:
+		return mb.prompt + mb.content
+	case MinibufferQueryReplace:
+		return mb.prompt
+	case MinibufferYesOrNo:
+		return mb.prompt
 	case MinibufferMessage:
 		return mb.message
 	default:
@@ -204,6 +327,31 @@ func (mb *Minibuffer) GetDisplayText() string {
 
 // HandleInput handles key input for the minibuffer
 func (mb *Minibuffer) HandleInput(event events.KeyEventData, editor *Editor) bool {
+	if mb.IsEditing() {
+		if mb.handleCompletionKey(event, editor) {
+			return true
+		}
+		if event.Key == "Enter" || event.Key == "Return" {
+			mb.acceptCompletion()
+			mb.history.Add(mb.historyKind, mb.content)
+		}
+	}
+
+	content := mb.content
+	handled := mb.handleModeInput(event, editor)
+	// A shown candidate list follows the input
+	if c := mb.completion; c != nil && c.shown && mb.content != content {
+		mb.updateCompletion(editor)
+	}
+	return handled
+}
+
+// handleModeInput handles a key as the current mode of the minibuffer says
+func (mb *Minibuffer) handleModeInput(event events.KeyEventData, editor *Editor) bool {
+	if mb.IsEditing() && mb.handleHistoryKey(event) {
+		return true
+	}
+
 	switch mb.mode {
 	case MinibufferCommand:
 		return mb.handleAsBuffer(event, func() { mb.executeCommand(editor) })
@@ -212,6 +360,16 @@ func (mb *Minibuffer) HandleInput(event events.KeyEventData, editor *Editor) boo
 	case MinibufferBufferSelection:
 		editor.HandleBufferSelectionInput(event)
 		return true
+	case MinibufferWriteFile:
+		return mb.handleAsBuffer(event, func() { mb.executeWriteFile(editor) })
+	case MinibufferISearch:
+		return editor.handleISearchInput(event)
+	case MinibufferInput:
+		return mb.handleAsBuffer(event, func() { mb.executeInput(editor) })
+	case MinibufferQueryReplace:
+		return editor.handleQueryReplaceInput(event)
+	case MinibufferYesOrNo:
+		return mb.handleYesOrNo(event, editor)
 	case MinibufferMessage:
 		// Any key clears the message, but allow the key to continue being processed
 		mb.Clear()
@@ -220,6 +378,30 @@ func (mb *Minibuffer) HandleInput(event events.KeyEventData, editor *Editor) boo
 	return false
 }
 
+// handleYesOrNo answers the question with y or n. Other keys are ignored
+// so that a stray key does not answer it.
+func (mb *Minibuffer) handleYesOrNo(event events.KeyEventData, editor *Editor) bool {
+	if event.Ctrl || event.Meta {
+		return true
+	}
+
+	var yes bool
+	switch event.Key {
+	case "y", "Y":
+		yes = true
+	case "n", "N":
+		yes = false
+	default:
+		return true
+	}
+	onAnswer := mb.onAnswer
+	mb.Clear()
+	if onAnswer != nil {
+		onAnswer(editor, yes)
+	}
+	return true
+}
+
 // handleAsBuffer treats minibuffer like a regular buffer, using unified commands
 func (mb *Minibuffer) handleAsBuffer(event events.KeyEventData, onEnter func()) bool {
 	// Handle Enter - execute the completion action
@@ -233,6 +415,11 @@ func (mb *Minibuffer) handleAsBuffer(event events.KeyEventData, onEnter func())
 		mb.Clear()
 		return true
 	}
+
+	// TAB does not indent the buffer behind the minibuffer
+	if isTabKey(event.Key, event.Ctrl, event.Meta) {
+		return true
+	}
 	
 	// Handle Backspace as delete-backward-char
 	if event.Key == "Backspace" || event.Key == "\x7f" {
@@ -305,7 +492,7 @@ func (mb *Minibuffer) executeCommand(editor *Editor) {
 		mb.Clear()
 		
 		// Execute command (command can set its own message)
-		err := cmd.Execute(editor)
+		err := editor.executeCommand(cmd.Name(), cmd.Execute)
 		if err != nil {
 			mb.SetMessage("Command failed: " + err.Error())
 		}
@@ -314,18 +501,75 @@ func (mb *Minibuffer) executeCommand(editor *Editor) {
 	}
 }
 
-// executeFileOpen handles C-x C-f file opening
+// executeInput passes the string read in MinibufferInput mode to its callback.
+// The minibuffer is cleared first so that the callback can start a new prompt.
+func (mb *Minibuffer) executeInput(editor *Editor) {
+	input := mb.content
+	onSubmit := mb.onSubmit
+	mb.Clear()
+
+	if onSubmit != nil {
+		onSubmit(editor, input)
+	}
+}
+
+// executeFileOpen handles C-x C-f file opening. A file that does not exist
+// is visited in a new empty buffer and created when it is saved.
 func (mb *Minibuffer) executeFileOpen(editor *Editor) {
-	filepath := mb.content
+	filepath := expandFileName(mb.content)
+	if filepath == "" {
+		mb.SetMessage("No file name given")
+		return
+	}
+
+	// A file that is already visited is not read again
+	if buffer := editor.FindFileBuffer(filepath); buffer != nil {
+		mb.Clear()
+		editor.SwitchToBuffer(buffer)
+		return
+	}
 	
 	// Try to load the file
+	message := "Opened: " + filepath
 	buffer, err := NewBufferFromFile(filepath)
-	if err != nil {
+	if os.IsNotExist(err) {
+		buffer = NewBufferForFile(filepath)
+		message = "(New file)"
+	} else if err != nil {
 		mb.SetMessage("Cannot open file: " + filepath)
-	} else {
-		// Add buffer to editor and switch to it
-		editor.AddBuffer(buffer)
-		editor.SwitchToBuffer(buffer)
-		mb.SetMessage("Opened: " + filepath)
+		return
 	}
-}
\ No newline at end of file
+
+	// Add buffer to editor and switch to it
+	editor.AddBuffer(buffer)
+	editor.SwitchToBuffer(buffer)
+	mb.SetMessage(message)
+	editor.TriggerHook(HookFindFile, buffer)
+}
+
+// executeWriteFile handles C-x C-w, saving the current buffer under a new path
+func (mb *Minibuffer) executeWriteFile(editor *Editor) {
+	filepath := expandFileName(mb.content)
+	if filepath == "" {
+		mb.SetMessage("No file name given")
+		return
+	}
+
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		mb.Clear()
+		return
+	}
+
+	// A directory is written to under the name of the buffer's file, or of
+	// the buffer if it visits none
+	if info, err := os.Stat(filepath); err == nil && info.IsDir() {
+		name := buffer.Name()
+		if buffer.Filepath() != "" {
+			name = path.Base(buffer.Filepath())
+		}
+		filepath = path.Join(filepath, name)
+	}
+
+	editor.saveBufferAs(buffer, filepath)
+}
@@ -4,7 +4,9 @@ import (
 	"regexp"
 )
 
-// IndentFunc defines a function type for indentation logic
+// IndentFunc defines a function type for indentation logic. It returns the
+// column a line should be indented to, or a negative number to leave the
+// line as it is.
 type IndentFunc func(buffer *Buffer, line int) int
 
 // SyntaxHighlighter defines interface for syntax highlighting
@@ -40,6 +42,12 @@ type MajorMode interface {
 	OnDeactivate(buffer *Buffer) error
 }
 
+// BeforeSaver is implemented by major modes that prepare a buffer before
+// it is written to its file
+type BeforeSaver interface {
+	BeforeSave(editor *Editor, buffer *Buffer) error
+}
+
 // MinorMode represents a minor editing mode
 type MinorMode interface {
 	Name() string
@@ -48,13 +56,22 @@ type MinorMode interface {
 	Enable(buffer *Buffer) error
 	Disable(buffer *Buffer) error
 	IsEnabled(buffer *Buffer) bool
-	Priority() int // Higher priority modes override lower priority ones
+	Priority() int   // Higher priority modes override lower priority ones
+	Lighter() string // Mode line text, empty to show nothing
 }
 
 // ModeManager manages major and minor modes
 type ModeManager struct {
 	majorModes map[string]MajorMode
 	minorModes map[string]MinorMode
+	majorOrder []string // Major mode names in registration order
+	minorOrder []string // Minor mode names in registration order
+	
+	// indentFunctions replace the indent functions of modes by mode name
+	indentFunctions map[string]IndentFunc
+	
+	// onMajorModeChange is called after SetMajorMode activated a mode
+	onMajorModeChange func(buffer *Buffer, mode, previous MajorMode)
 }
 
 // NewModeManager creates a new mode manager
@@ -62,6 +79,7 @@ func NewModeManager() *ModeManager {
 	mm := &ModeManager{
 		majorModes: make(map[string]MajorMode),
 		minorModes: make(map[string]MinorMode),
+		indentFunctions: make(map[string]IndentFunc),
 	}
 	
 	// Register default modes
@@ -72,12 +90,32 @@ func NewModeManager() *ModeManager {
 
 // RegisterMajorMode registers a major mode
 func (mm *ModeManager) RegisterMajorMode(mode MajorMode) {
-	mm.majorModes[mode.Name()] = mode
+	name := mode.Name()
+	if _, exists := mm.majorModes[name]; exists {
+		for i, n := range mm.majorOrder {
+			if n == name {
+				mm.majorOrder = append(mm.majorOrder[:i], mm.majorOrder[i+1:]...)
+				break
+			}
+		}
+	}
+	mm.majorModes[name] = mode
+	mm.majorOrder = append(mm.majorOrder, name)
 }
 
 // RegisterMinorMode registers a minor mode
 func (mm *ModeManager) RegisterMinorMode(mode MinorMode) {
-	mm.minorModes[mode.Name()] = mode
+	name := mode.Name()
+	if _, exists := mm.minorModes[name]; exists {
+		for i, n := range mm.minorOrder {
+			if n == name {
+				mm.minorOrder = append(mm.minorOrder[:i], mm.minorOrder[i+1:]...)
+				break
+			}
+		}
+	}
+	mm.minorModes[name] = mode
+	mm.minorOrder = append(mm.minorOrder, name)
 }
 
 // GetMajorModeByName returns a major mode by name
@@ -100,8 +138,9 @@ func (mm *ModeManager) SetMajorMode(buffer *Buffer, modeName string) error {
 	}
 	
 	// Deactivate current major mode if any
-	if buffer.majorMode != nil {
-		buffer.majorMode.OnDeactivate(buffer)
+	previous := buffer.majorMode
+	if previous != nil {
+		previous.OnDeactivate(buffer)
 	}
 	
 	// Set new major mode
@@ -112,7 +151,18 @@ func (mm *ModeManager) SetMajorMode(buffer *Buffer, modeName string) error {
 		return err
 	}
 	
-	return mode.OnActivate(buffer)
+	if err := mode.OnActivate(buffer); err != nil {
+		return err
+	}
+	
+	if err := mm.enableGlobalMinorModes(buffer); err != nil {
+		return err
+	}
+	
+	if mm.onMajorModeChange != nil {
+		mm.onMajorModeChange(buffer, mode, previous)
+	}
+	return nil
 }
 
 // ToggleMinorMode toggles a minor mode for a buffer
@@ -129,28 +179,52 @@ func (mm *ModeManager) ToggleMinorMode(buffer *Buffer, modeName string) error {
 	}
 }
 
-// GetEffectiveKeyBindings returns the effective key bindings for a buffer
+// enableGlobalMinorModes enables the global minor modes whose predicate
+// accepts buffer, in the order they were registered
+func (mm *ModeManager) enableGlobalMinorModes(buffer *Buffer) error {
+	for _, name := range mm.minorOrder {
+		custom, ok := mm.minorModes[name].(*CustomMinorMode)
+		if !ok || custom.IsEnabled(buffer) || !custom.appliesTo(buffer) {
+			continue
+		}
+		if err := custom.Enable(buffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetEffectiveKeyBindings returns the effective key bindings for a buffer:
+// its minor mode and major mode bindings layered over the default global
+// bindings
 func (mm *ModeManager) GetEffectiveKeyBindings(buffer *Buffer) *KeyBindingMap {
 	// Start with global bindings
 	effective := NewKeyBindingMap()
 	
-	// Add major mode bindings
-	if buffer.majorMode != nil {
-		majorBindings := buffer.majorMode.KeyBindings()
-		if majorBindings != nil {
-			effective = mm.mergeKeyBindings(effective, majorBindings)
-		}
+	// Layer the mode keymaps over it, lowest precedence first
+	layers := mm.modeKeymaps(buffer)
+	for i := len(layers) - 1; i >= 0; i-- {
+		effective = mm.mergeKeyBindings(effective, layers[i])
 	}
 	
-	// Add minor mode bindings in priority order
+	return effective
+}
+
+// modeKeymaps returns the keymaps of the buffer's enabled minor modes in
+// priority order followed by its major mode keymap
+func (mm *ModeManager) modeKeymaps(buffer *Buffer) []*KeyBindingMap {
+	var layers []*KeyBindingMap
 	for _, mode := range buffer.getEnabledMinorModes() {
-		minorBindings := mode.KeyBindings()
-		if minorBindings != nil {
-			effective = mm.mergeKeyBindings(effective, minorBindings)
+		if keyBindings := mode.KeyBindings(); keyBindings != nil {
+			layers = append(layers, keyBindings)
 		}
 	}
-	
-	return effective
+	if buffer.majorMode != nil {
+		if keyBindings := buffer.majorMode.KeyBindings(); keyBindings != nil {
+			layers = append(layers, keyBindings)
+		}
+	}
+	return layers
 }
 
 // GetMajorMode returns the major mode for a buffer
@@ -171,8 +245,9 @@ func (mm *ModeManager) AutoDetectMajorMode(buffer *Buffer) (MajorMode, error) {
 		return mm.majorModes["fundamental-mode"], nil
 	}
 	
-	// Try to match file patterns
-	for _, mode := range mm.majorModes {
+	// Try to match file patterns, most recently registered mode first
+	for i := len(mm.majorOrder) - 1; i >= 0; i-- {
+		mode := mm.majorModes[mm.majorOrder[i]]
 		if pattern := mode.FilePattern(); pattern != nil {
 			if pattern.MatchString(filepath) {
 				return mode, nil
@@ -184,10 +259,15 @@ func (mm *ModeManager) AutoDetectMajorMode(buffer *Buffer) (MajorMode, error) {
 	return mm.majorModes["fundamental-mode"], nil
 }
 
-// mergeKeyBindings merges two key binding maps, with the second taking precedence
+// mergeKeyBindings merges two key binding maps, with the second taking precedence.
+// Lookups try bindings in order, so the override bindings simply come first.
 func (mm *ModeManager) mergeKeyBindings(base, override *KeyBindingMap) *KeyBindingMap {
-	// For now, return override (later we can implement proper merging)
-	return override
+	merged := NewEmptyKeyBindingMap()
+	merged.sequenceBindings = append(merged.sequenceBindings, override.sequenceBindings...)
+	merged.sequenceBindings = append(merged.sequenceBindings, base.sequenceBindings...)
+	merged.rawSequenceBindings = append(merged.rawSequenceBindings, override.rawSequenceBindings...)
+	merged.rawSequenceBindings = append(merged.rawSequenceBindings, base.rawSequenceBindings...)
+	return merged
 }
 
 // registerDefaultModes registers the default modes
@@ -198,8 +278,12 @@ func (mm *ModeManager) registerDefaultModes() {
 	// Register text mode
 	mm.RegisterMajorMode(NewTextMode())
 	
+	// Register go mode
+	mm.RegisterMajorMode(NewGoMode())
+	
 	// Register minor modes
 	mm.RegisterMinorMode(NewAutoAMode())
+	mm.RegisterMinorMode(NewAutoRevertMode())
 }
 
 // ModeError represents an error in mode operations
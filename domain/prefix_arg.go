@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"strings"
+
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// A prefix argument is typed before a command. C-u alone gives 4 and each
+// further C-u multiplies it by 4; digits typed after C-u, or with Meta as
+// in M-5, give the value directly, and a minus sign negates it. The
+// argument applies to the next command that runs, which reads it with
+// PrefixArg or PrefixCount.
+
+// PrefixArg is the prefix argument of a command
+type PrefixArg struct {
+	Value     int  // Numeric value
+	Universal bool // Given with C-u alone, without digits or a minus sign
+}
+
+// prefixArgState is a prefix argument being typed
+type prefixArgState struct {
+	keys     []string // Keys typed so far, for the echo area
+	value    int
+	digits   bool // Digits have been typed
+	negative bool
+	typing   bool // Further digits, "-" and C-u extend the argument
+}
+
+// arg returns the argument typed so far
+func (s *prefixArgState) arg() *PrefixArg {
+	switch {
+	case s.digits && s.negative:
+		return &PrefixArg{Value: -s.value}
+	case s.digits:
+		return &PrefixArg{Value: s.value}
+	case s.negative:
+		return &PrefixArg{Value: -1}
+	}
+	return &PrefixArg{Value: s.value, Universal: true}
+}
+
+// PrefixArg returns the prefix argument of the running command, or nil if
+// none was given
+func (e *Editor) PrefixArg() *PrefixArg {
+	return e.prefixArg
+}
+
+// PrefixCount returns the numeric prefix argument of the running command,
+// or 1 if none was given
+func (e *Editor) PrefixCount() int {
+	if e.prefixArg == nil {
+		return 1
+	}
+	return e.prefixArg.Value
+}
+
+// UniversalArgument implements universal-argument (C-u)
+func UniversalArgument(editor *Editor) error {
+	editor.argState = &prefixArgState{keys: []string{"C-u"}, value: 4, typing: true}
+	editor.thisCommand = editor.lastCommand
+	return nil
+}
+
+// DigitArgument implements digit-argument (M-0 to M-9), taking the digit
+// from the key that invoked it
+func DigitArgument(editor *Editor) error {
+	key := editor.lastEvent.Key
+	if len(key) != 1 || key[0] < '0' || key[0] > '9' {
+		return nil
+	}
+	editor.argState = &prefixArgState{
+		keys:   []string{formatKeyPress(KeyPress{Key: key, Meta: true})},
+		value:  int(key[0] - '0'),
+		digits: true,
+		typing: true,
+	}
+	editor.thisCommand = editor.lastCommand
+	return nil
+}
+
+// NegativeArgument implements negative-argument (M--)
+func NegativeArgument(editor *Editor) error {
+	editor.argState = &prefixArgState{keys: []string{"M--"}, negative: true, typing: true}
+	editor.thisCommand = editor.lastCommand
+	return nil
+}
+
+// continuePrefixArg adds a key to the prefix argument being typed and
+// reports whether the key was used. Any other key ends the argument and is
+// dispatched as usual.
+func (e *Editor) continuePrefixArg(event events.KeyEventData) bool {
+	state := e.argState
+	if state == nil || !state.typing {
+		return false
+	}
+
+	key := event.Key
+	switch {
+	case key == "\x1b" || key == "Escape":
+		// Meta prefix of a following M-<digit>
+		return false
+	case !event.Ctrl && len(key) == 1 && key[0] >= '0' && key[0] <= '9':
+		if !state.digits {
+			state.value = 0
+			state.digits = true
+		}
+		state.value = state.value*10 + int(key[0]-'0')
+	case !event.Ctrl && key == "-" && !state.digits && !state.negative:
+		state.negative = true
+	case event.Ctrl && !event.Meta && key == "u":
+		if state.digits || state.negative {
+			// C-u ends a numeric argument, so C-u 8 C-u 1 inserts eight 1s
+			state.typing = false
+		} else {
+			state.value *= 4
+		}
+	default:
+		state.typing = false
+		return false
+	}
+
+	state.keys = append(state.keys, formatKeyPress(KeyPress{Key: key, Ctrl: event.Ctrl, Meta: event.Meta}))
+	return true
+}
+
+// prefixArgInProgress returns the prefix argument being typed for display
+// in the echo area, e.g. "C-u 8-"
+func (e *Editor) prefixArgInProgress() string {
+	if e.argState == nil {
+		return ""
+	}
+	return strings.Join(e.argState.keys, " ") + "-"
+}
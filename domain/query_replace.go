@@ -0,0 +1,263 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/TakahashiShuuhei/gmacs/events"
+	"github.com/TakahashiShuuhei/gmacs/log"
+)
+
+// QueryReplace holds the state of a query-replace run
+type QueryReplace struct {
+	buffer      *Buffer
+	from        string
+	to          string
+	regexp      bool
+	pattern     *regexp.Regexp
+	template    string // Replacement in regexp.Expand syntax, for regexp runs
+	match       []int  // Submatch byte offsets of the current match within its row
+	matchRow    int
+	replaced    int
+	replaceAll  bool
+	lastReplace Position // End of the last replacement, where the cursor ends up
+}
+
+// SearchHighlight describes the matches shown highlighted while searching
+type SearchHighlight interface {
+	Buffer() *Buffer
+	CurrentMatch() (start, end Position, ok bool)
+	LineMatches(line string) [][]int
+}
+
+// SearchHighlight returns the isearch or query-replace in progress, or nil
+func (e *Editor) SearchHighlight() SearchHighlight {
+	if e.isearch != nil {
+		return e.isearch
+	}
+	if e.queryReplace != nil {
+		return e.queryReplace
+	}
+	return nil
+}
+
+// Buffer returns the buffer being edited
+func (q *QueryReplace) Buffer() *Buffer {
+	return q.buffer
+}
+
+// CurrentMatch returns the match waiting for confirmation
+func (q *QueryReplace) CurrentMatch() (start, end Position, ok bool) {
+	if q.match == nil {
+		return Position{}, Position{}, false
+	}
+	return Position{Row: q.matchRow, Col: q.match[0]}, Position{Row: q.matchRow, Col: q.match[1]}, true
+}
+
+// LineMatches returns the byte ranges of all non-empty matches in line
+func (q *QueryReplace) LineMatches(line string) [][]int {
+	var result [][]int
+	for _, m := range q.pattern.FindAllStringIndex(line, -1) {
+		if m[1] > m[0] {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// QueryReplaceCommand implements query-replace (M-%)
+func QueryReplaceCommand(editor *Editor) error {
+	return readReplaceArgs(editor, "Query replace", false)
+}
+
+// ReplaceRegexp implements replace-regexp. The replacement may refer to
+// groups with \1 ... \9 and to the whole match with \&.
+func ReplaceRegexp(editor *Editor) error {
+	return readReplaceArgs(editor, "Replace regexp", true)
+}
+
+// readReplaceArgs prompts for the text to replace and its replacement
+func readReplaceArgs(editor *Editor, prompt string, isRegexp bool) error {
+	if editor.CurrentBuffer() == nil {
+		return nil
+	}
+
+	editor.minibuffer.StartInputWithHistory(prompt+": ", HistorySearch, func(editor *Editor, from string) {
+		if from == "" {
+			editor.SetMinibufferMessage("Nothing to replace")
+			return
+		}
+		editor.minibuffer.StartInputWithHistory(prompt+" "+from+" with: ", HistoryReplace, func(editor *Editor, to string) {
+			startQueryReplace(editor, from, to, isRegexp)
+		})
+	})
+	return nil
+}
+
+// startQueryReplace begins stepping through the matches after the cursor
+func startQueryReplace(editor *Editor, from, to string, isRegexp bool) {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return
+	}
+
+	pattern, err := compileSearchPattern(from, isRegexp)
+	if err != nil {
+		editor.SetMinibufferMessage("Invalid regexp: " + err.Error())
+		return
+	}
+
+	q := &QueryReplace{
+		buffer:      buffer,
+		from:        from,
+		to:          to,
+		regexp:      isRegexp,
+		pattern:     pattern,
+		template:    expandTemplate(to),
+		lastReplace: buffer.Cursor(),
+	}
+	editor.queryReplace = q
+
+	buffer.SetMark(buffer.Cursor())
+	buffer.DeactivateMark()
+	log.Info("Query replace %q with %q (regexp: %v)", from, to, isRegexp)
+
+	q.findNext(editor, buffer.Cursor())
+}
+
+// handleQueryReplaceInput processes the answer to "Replace this match?".
+// Other keys end the run and return false so that they run as ordinary
+// commands. No undo boundaries are set in between, so the whole run is
+// undone at once.
+func (e *Editor) handleQueryReplaceInput(event events.KeyEventData) bool {
+	q := e.queryReplace
+	if q == nil {
+		e.minibuffer.Clear()
+		return false
+	}
+
+	if event.Ctrl || event.Meta {
+		e.finishQueryReplace()
+		return false
+	}
+
+	switch event.Key {
+	case "y", " ":
+		next := q.replaceCurrent()
+		q.findNext(e, next)
+	case "n", "Backspace", "\x7f":
+		start, end, _ := q.CurrentMatch()
+		next := end
+		if start == end {
+			next = nextPosition(q.buffer, next)
+		}
+		q.findNext(e, next)
+	case "!":
+		q.replaceAll = true
+		next := q.replaceCurrent()
+		q.findNext(e, next)
+	case "q", "Enter", "Return", "\x1b", "Escape":
+		e.finishQueryReplace()
+	default:
+		// Unknown answers are ignored so that a stray key does not abort the run
+	}
+	return true
+}
+
+// findNext moves to the next match at or after from, replacing it right
+// away once the user has answered "!"
+func (q *QueryReplace) findNext(e *Editor, from Position) {
+	for {
+		row, loc, ok := findSubmatchForward(q.buffer, q.pattern, from)
+		if !ok {
+			q.match = nil
+			e.finishQueryReplace()
+			return
+		}
+
+		q.matchRow, q.match = row, loc
+		if !q.replaceAll {
+			q.buffer.SetCursor(Position{Row: row, Col: loc[1]})
+			EnsureCursorVisible(e)
+			e.minibuffer.StartQueryReplace(fmt.Sprintf("Query replacing %s with %s: (y, n, !, q) ", q.from, q.to))
+			return
+		}
+		from = q.replaceCurrent()
+	}
+}
+
+// replaceCurrent replaces the current match and returns the position from
+// which to search for the next one
+func (q *QueryReplace) replaceCurrent() Position {
+	line := q.buffer.Line(q.matchRow)
+	start := Position{Row: q.matchRow, Col: q.match[0]}
+	end := Position{Row: q.matchRow, Col: q.match[1]}
+
+	replacement := q.to
+	if q.regexp {
+		replacement = string(q.pattern.ExpandString(nil, q.template, line, q.match))
+	}
+
+	q.buffer.deleteText(start, end)
+	next := q.buffer.insertText(start, replacement)
+	q.replaced++
+	q.lastReplace = next
+
+	if start == end {
+		// Skip past the character after an empty match so that the match
+		// is not found again
+		next = nextPosition(q.buffer, next)
+	}
+	return next
+}
+
+// finishQueryReplace ends the run and reports the number of replacements
+func (e *Editor) finishQueryReplace() {
+	q := e.queryReplace
+	if q == nil {
+		return
+	}
+	e.queryReplace = nil
+
+	if q.replaced > 0 {
+		q.buffer.SetCursor(q.lastReplace)
+		EnsureCursorVisible(e)
+	}
+
+	if q.replaced == 1 {
+		e.minibuffer.SetMessage("Replaced 1 occurrence")
+	} else {
+		e.minibuffer.SetMessage(fmt.Sprintf("Replaced %d occurrences", q.replaced))
+	}
+	log.Info("Query replace finished: %d replacements", q.replaced)
+}
+
+// expandTemplate converts Emacs style group references (\1, \&) in a
+// replacement string to the syntax understood by regexp.Expand
+func expandTemplate(to string) string {
+	var sb strings.Builder
+	for i := 0; i < len(to); i++ {
+		c := to[i]
+		switch {
+		case c == '$':
+			sb.WriteString("$$")
+		case c == '\\' && i+1 < len(to):
+			next := to[i+1]
+			switch {
+			case next >= '0' && next <= '9':
+				sb.WriteString("${" + string(next) + "}")
+			case next == '&':
+				sb.WriteString("${0}")
+			case next == 'n':
+				sb.WriteByte('\n')
+			default:
+				sb.WriteByte(next)
+			}
+			i++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
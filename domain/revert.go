@@ -0,0 +1,348 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TakahashiShuuhei/gmacs/log"
+)
+
+// A buffer remembers the modification time, size and hash of its file as
+// it was last visited, saved or reverted. When the file changes behind the
+// editor's back, as after a git checkout, the change is noticed on
+// switching to the buffer, before saving it and by a background poll of
+// the buffers shown in windows or in auto-revert-mode. For an unmodified
+// buffer the editor offers to revert it to the file, or reverts it right
+// away when the global-auto-revert-mode option is set; a modified one is
+// left alone with a warning, and saving it asks before overwriting the
+// file. auto-revert-mode tails files that grow, such as logs.
+
+// DefaultAutoRevertInterval is the number of seconds between polls of the
+// visited files when the auto-revert-interval option is not set
+const DefaultAutoRevertInterval = 2
+
+// fileStamp identifies the contents of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64 // -1 when the file did not exist
+	hash    [sha256.Size]byte
+}
+
+// missingFile is the stamp of a file that does not exist
+var missingFile = fileStamp{size: -1}
+
+// newFileStamp returns the stamp of a file with the given info and data
+func newFileStamp(info os.FileInfo, data []byte) fileStamp {
+	return fileStamp{modTime: info.ModTime(), size: info.Size(), hash: sha256.Sum256(data)}
+}
+
+// sameContent reports whether two stamps are of the same file contents
+func (s fileStamp) sameContent(other fileStamp) bool {
+	return s.size == other.size && s.hash == other.hash
+}
+
+// readFile returns the lines of a file, as a buffer holds them, how it
+// ends them and its stamp
+func readFile(path string) ([]string, lineFormat, fileStamp, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, lineFormat{}, fileStamp{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, lineFormat{}, fileStamp{}, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, lineFormat{}, fileStamp{}, err
+	}
+
+	lines, format := splitFileLines(string(data))
+	return lines, format, newFileStamp(info, data), nil
+}
+
+// recordWrite remembers data as the contents of the file at path, just
+// written by the buffer
+func (b *Buffer) recordWrite(path string, data []byte) {
+	info, err := os.Stat(path)
+	if err != nil {
+		b.stamp = nil
+		return
+	}
+	stamp := newFileStamp(info, data)
+	b.stamp = &stamp
+}
+
+// diskChange checks whether the file of the buffer changed since it was
+// last visited, saved or reverted, and returns the stamp of the file now.
+// A file that was deleted does not count: there is nothing to load.
+func (b *Buffer) diskChange() (fileStamp, bool) {
+	if b.stamp == nil || b.filepath == "" {
+		return fileStamp{}, false
+	}
+	info, err := os.Stat(b.filepath)
+	if err != nil || info.IsDir() {
+		return fileStamp{}, false
+	}
+	if info.Size() == b.stamp.size && info.ModTime().Equal(b.stamp.modTime) {
+		return *b.stamp, false
+	}
+
+	// Only the hash tells a rewrite from a touch
+	data, err := os.ReadFile(b.filepath)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	stamp := newFileStamp(info, data)
+	if stamp.sameContent(*b.stamp) {
+		b.stamp = &stamp
+		return stamp, false
+	}
+	return stamp, true
+}
+
+// ChangedOnDisk reports whether the file of the buffer was changed by
+// another program since the buffer last read or wrote it
+func (b *Buffer) ChangedOnDisk() bool {
+	_, changed := b.diskChange()
+	return changed
+}
+
+// Revert replaces the text of the buffer with the contents of its file and
+// marks it unmodified. The cursor stays at the same line and column. The
+// revert can be undone.
+func (b *Buffer) Revert() error {
+	if b.filepath == "" {
+		return &FileError{Message: "Buffer " + b.name + " is not visiting a file"}
+	}
+	lines, format, stamp, err := readFile(b.filepath)
+	if err != nil {
+		return err
+	}
+
+	b.format = &format
+	cursor := b.cursor
+	b.ReplaceRegion(Position{Row: 0, Col: 0}, b.endPosition(), strings.Join(lines, "\n"))
+	b.SetCursor(cursor)
+	b.finishRevert(stamp)
+	return nil
+}
+
+// tail brings in text appended to the file of the buffer, keeping a cursor
+// at the end of the buffer at the end. It reverts the whole buffer when
+// the file changed otherwise.
+func (b *Buffer) tail() error {
+	lines, format, stamp, err := readFile(b.filepath)
+	if err != nil {
+		return err
+	}
+	b.format = &format
+	text := strings.Join(lines, "\n")
+	old := strings.Join(b.Content(), "\n")
+	if !strings.HasPrefix(text, old) {
+		return b.Revert()
+	}
+	b.InsertText(b.endPosition(), text[len(old):])
+	b.finishRevert(stamp)
+	return nil
+}
+
+// finishRevert records that the buffer matches the file with stamp
+func (b *Buffer) finishRevert(stamp fileStamp) {
+	b.stamp = &stamp
+	b.warned = nil
+	b.modified = false
+	b.markUndoSavePoint()
+}
+
+// RevertBuffer implements the revert-buffer command: it reloads the current
+// buffer from its file, asking first if the buffer has unsaved changes
+func RevertBuffer(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+	if buffer.Filepath() == "" {
+		editor.SetMinibufferMessage("Buffer " + buffer.Name() + " is not visiting a file")
+		return nil
+	}
+	if _, err := os.Stat(buffer.Filepath()); err != nil {
+		editor.SetMinibufferMessage("File " + buffer.Filepath() + " no longer exists")
+		return nil
+	}
+
+	if !buffer.IsModified() {
+		editor.revertBuffer(buffer)
+		return nil
+	}
+	editor.minibuffer.StartYesOrNo("Discard edits and revert buffer from file "+buffer.Filepath()+"? ", func(editor *Editor, yes bool) {
+		if yes {
+			editor.revertBuffer(buffer)
+		}
+	})
+	return nil
+}
+
+// revertBuffer reverts buffer and reports the outcome
+func (e *Editor) revertBuffer(buffer *Buffer) {
+	if err := buffer.Revert(); err != nil {
+		log.Error("Failed to revert buffer %s: %v", buffer.Name(), err)
+		e.SetMinibufferMessage("Cannot read file: " + buffer.Filepath())
+		return
+	}
+	log.Info("Reverted buffer %s from %s", buffer.Name(), buffer.Filepath())
+	e.SetMinibufferMessage("Reverted buffer " + buffer.Name())
+}
+
+// CheckFileChanges looks for files changed on disk in the buffers shown in
+// windows and those in auto-revert-mode; the others are checked when they
+// are switched to. It is called by the background poll and reports whether
+// any buffer was reverted or warned about. Nothing is checked while the
+// user is answering a prompt or searching, as the text must not change
+// under them; the next poll catches up.
+func (e *Editor) CheckFileChanges() bool {
+	if e.interacting() {
+		return false
+	}
+	shown := make(map[*Buffer]bool)
+	for _, window := range e.layout.GetAllWindows() {
+		shown[window.Buffer()] = true
+	}
+	acted := false
+	for _, buffer := range e.buffers {
+		if !shown[buffer] && !isAutoRevert(buffer) {
+			continue
+		}
+		if e.checkFileChange(buffer, false) {
+			acted = true
+		}
+	}
+	return acted
+}
+
+// checkFileChange offers to revert buffer if its file changed and it has
+// no edits to lose, and otherwise warns that saving would overwrite the
+// change. A change is asked or warned about only once unless always is
+// set.
+func (e *Editor) checkFileChange(buffer *Buffer, always bool) bool {
+	stamp, changed := buffer.diskChange()
+	if !changed {
+		return false
+	}
+
+	if !buffer.IsModified() {
+		if isAutoRevert(buffer) {
+			if err := buffer.tail(); err != nil {
+				log.Warn("Failed to tail %s: %v", buffer.Filepath(), err)
+				return false
+			}
+			if buffer == e.CurrentBuffer() {
+				EnsureCursorVisible(e)
+			}
+			return true
+		}
+		if e.boolOption("global-auto-revert-mode", false) {
+			if err := buffer.Revert(); err != nil {
+				log.Warn("Failed to revert %s: %v", buffer.Filepath(), err)
+				return false
+			}
+			log.Info("Reverted buffer %s: file changed on disk", buffer.Name())
+			e.notify("Reverted buffer " + buffer.Name() + ": file changed on disk")
+			return true
+		}
+	}
+
+	if !always && buffer.warned != nil && buffer.warned.sameContent(stamp) {
+		return false
+	}
+	if !buffer.IsModified() {
+		return e.offerRevert(buffer, stamp)
+	}
+	buffer.warned = &stamp
+	e.notify(buffer.Name() + " changed on disk; saving overwrites it, M-x revert-buffer discards your edits")
+	return true
+}
+
+// offerRevert asks whether to revert buffer to its changed file. The
+// question waits for the minibuffer if it is reading something else.
+func (e *Editor) offerRevert(buffer *Buffer, stamp fileStamp) bool {
+	if e.minibuffer.IsActive() && e.minibuffer.Mode() != MinibufferMessage {
+		return false
+	}
+	buffer.warned = &stamp
+	e.minibuffer.StartYesOrNo(buffer.Name()+" changed on disk; revert? ", func(editor *Editor, yes bool) {
+		if yes {
+			editor.revertBuffer(buffer)
+		}
+	})
+	return true
+}
+
+// interacting reports whether the minibuffer is reading input, an isearch
+// or query-replace is running or a question awaits its answer
+func (e *Editor) interacting() bool {
+	return e.minibuffer.IsEditing() || e.isearch != nil || e.queryReplace != nil ||
+		e.minibuffer.Mode() == MinibufferYesOrNo
+}
+
+// notify shows a message unless the minibuffer is reading something
+func (e *Editor) notify(message string) {
+	if e.minibuffer.IsActive() && e.minibuffer.Mode() != MinibufferMessage {
+		return
+	}
+	e.SetMinibufferMessage(message)
+}
+
+// AutoRevertInterval returns the time between polls of the visited files
+func (e *Editor) AutoRevertInterval() time.Duration {
+	seconds := e.intOption("auto-revert-interval", DefaultAutoRevertInterval)
+	if seconds <= 0 {
+		seconds = DefaultAutoRevertInterval
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// NewAutoRevertMode creates auto-revert-mode, the minor mode that makes an
+// unmodified buffer follow text appended to its file, as tail -f does
+func NewAutoRevertMode() MinorMode {
+	return &CustomMinorMode{
+		name:        "auto-revert-mode",
+		config:      MinorModeConfig{Lighter: "ARev"},
+		keyBindings: NewEmptyKeyBindingMap(),
+	}
+}
+
+// AutoRevertModeCommand toggles auto-revert-mode in the current buffer and
+// brings in what the file gained meanwhile
+func AutoRevertModeCommand(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return &ModeError{Message: "No current buffer"}
+	}
+	if err := editor.ModeManager().ToggleMinorMode(buffer, "auto-revert-mode"); err != nil {
+		return err
+	}
+
+	if !isAutoRevert(buffer) {
+		editor.SetMinibufferMessage("Auto-Revert mode disabled")
+		return nil
+	}
+	editor.SetMinibufferMessage("Auto-Revert mode enabled")
+	editor.checkFileChange(buffer, true)
+	return nil
+}
+
+// isAutoRevert reports whether auto-revert-mode is enabled in buffer
+func isAutoRevert(buffer *Buffer) bool {
+	for _, mode := range buffer.MinorModes() {
+		if mode.Name() == "auto-revert-mode" {
+			return true
+		}
+	}
+	return false
+}
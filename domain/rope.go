@@ -0,0 +1,210 @@
+package domain
+
+// lineRope stores the lines of a buffer in a balanced binary tree (an
+// implicit treap ordered by line number). Looking up, replacing, inserting
+// and deleting lines take O(log n) time, so editing near the top of a large
+// file does not copy every line after the edit point.
+type lineRope struct {
+	root     *ropeNode
+	seed     uint32
+	snapshot []string // Cached result of Lines, dropped on every change
+}
+
+// ropeNode is one line of the rope. Nodes are ordered by their position in
+// the tree and kept balanced by their random heap-ordered priorities.
+type ropeNode struct {
+	line     string
+	priority uint32
+	size     int // Number of lines in this subtree
+	left     *ropeNode
+	right    *ropeNode
+}
+
+// newLineRope creates a rope holding lines
+func newLineRope(lines []string) *lineRope {
+	r := &lineRope{seed: 2463534242}
+	r.root = r.build(lines)
+	return r
+}
+
+func nodeSize(n *ropeNode) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *ropeNode) update() {
+	n.size = 1 + nodeSize(n.left) + nodeSize(n.right)
+}
+
+// random returns the next priority (xorshift32)
+func (r *lineRope) random() uint32 {
+	x := r.seed
+	x ^= x << 13
+	x ^= x >> 17
+	x ^= x << 5
+	r.seed = x
+	return x
+}
+
+// build creates a treap for lines in O(n) using the Cartesian tree
+// construction, keeping the rightmost path on a stack
+func (r *lineRope) build(lines []string) *ropeNode {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	stack := make([]*ropeNode, 0, 64)
+	for _, line := range lines {
+		n := &ropeNode{line: line, priority: r.random(), size: 1}
+		var last *ropeNode
+		for len(stack) > 0 && stack[len(stack)-1].priority < n.priority {
+			last = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		}
+		n.left = last
+		if len(stack) > 0 {
+			stack[len(stack)-1].right = n
+		}
+		stack = append(stack, n)
+	}
+
+	root := stack[0]
+	fixSizes(root)
+	return root
+}
+
+// fixSizes recomputes subtree sizes after build
+func fixSizes(n *ropeNode) int {
+	if n == nil {
+		return 0
+	}
+	n.size = 1 + fixSizes(n.left) + fixSizes(n.right)
+	return n.size
+}
+
+// split divides n into the first k lines and the rest
+func split(n *ropeNode, k int) (*ropeNode, *ropeNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if nodeSize(n.left) >= k {
+		left, right := split(n.left, k)
+		n.left = right
+		n.update()
+		return left, n
+	}
+	left, right := split(n.right, k-nodeSize(n.left)-1)
+	n.right = left
+	n.update()
+	return n, right
+}
+
+// merge joins two trees, all lines of a coming before those of b
+func merge(a, b *ropeNode) *ropeNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.priority > b.priority {
+		a.right = merge(a.right, b)
+		a.update()
+		return a
+	}
+	b.left = merge(a, b.left)
+	b.update()
+	return b
+}
+
+// Len returns the number of lines
+func (r *lineRope) Len() int {
+	return nodeSize(r.root)
+}
+
+// node returns the node holding line i
+func (r *lineRope) node(i int) *ropeNode {
+	n := r.root
+	for n != nil {
+		leftSize := nodeSize(n.left)
+		switch {
+		case i < leftSize:
+			n = n.left
+		case i == leftSize:
+			return n
+		default:
+			i -= leftSize + 1
+			n = n.right
+		}
+	}
+	panic("lineRope: line index out of range")
+}
+
+// Line returns line i
+func (r *lineRope) Line(i int) string {
+	return r.node(i).line
+}
+
+// SetLine replaces line i
+func (r *lineRope) SetLine(i int, line string) {
+	r.node(i).line = line
+	r.snapshot = nil
+}
+
+// Insert inserts lines before line i (at the end if i == Len())
+func (r *lineRope) Insert(i int, lines ...string) {
+	if len(lines) == 0 {
+		return
+	}
+	left, right := split(r.root, i)
+	r.root = merge(merge(left, r.build(lines)), right)
+	r.snapshot = nil
+}
+
+// Delete removes lines i through j-1
+func (r *lineRope) Delete(i, j int) {
+	if j <= i {
+		return
+	}
+	left, rest := split(r.root, i)
+	_, right := split(rest, j-i)
+	r.root = merge(left, right)
+	r.snapshot = nil
+}
+
+// Slice returns lines i through j-1
+func (r *lineRope) Slice(i, j int) []string {
+	if j <= i {
+		return nil
+	}
+	result := make([]string, 0, j-i)
+	var walk func(n *ropeNode, offset int)
+	walk = func(n *ropeNode, offset int) {
+		if n == nil {
+			return
+		}
+		index := offset + nodeSize(n.left)
+		if i < index {
+			walk(n.left, offset)
+		}
+		if index >= i && index < j {
+			result = append(result, n.line)
+		}
+		if index+1 < j {
+			walk(n.right, index+1)
+		}
+	}
+	walk(r.root, 0)
+	return result
+}
+
+// Lines returns all lines. The slice is cached until the next change and
+// must not be modified by the caller.
+func (r *lineRope) Lines() []string {
+	if r.snapshot == nil {
+		r.snapshot = r.Slice(0, r.Len())
+	}
+	return r.snapshot
+}
@@ -90,8 +90,20 @@ func ToggleLineWrap(editor *Editor) error {
 	return nil
 }
 
-// PageUp scrolls up by one screen height
+// PageUp scrolls up by one screen height, or by as many screens as the
+// prefix count
 func PageUp(editor *Editor) error {
+	return repeatCommand(editor, pageUp, pageDown)
+}
+
+// PageDown scrolls down by one screen height, or by as many screens as the
+// prefix count
+func PageDown(editor *Editor) error {
+	return repeatCommand(editor, pageDown, pageUp)
+}
+
+// pageUp scrolls up by one screen height
+func pageUp(editor *Editor) error {
 	window := editor.CurrentWindow()
 	if window == nil {
 		return nil
@@ -116,8 +128,8 @@ func PageUp(editor *Editor) error {
 	return nil
 }
 
-// PageDown scrolls down by one screen height
-func PageDown(editor *Editor) error {
+// pageDown scrolls down by one screen height
+func pageDown(editor *Editor) error {
 	window := editor.CurrentWindow()
 	if window == nil {
 		return nil
@@ -134,7 +146,7 @@ func PageDown(editor *Editor) error {
 			return nil
 		}
 		
-		maxScroll := len(buffer.Content()) - 1
+		maxScroll := buffer.LineCount() - 1
 		if maxScroll < 0 {
 			maxScroll = 0
 		}
@@ -152,6 +164,73 @@ func PageDown(editor *Editor) error {
 	return nil
 }
 
+// RecenterTopBottom implements recenter-top-bottom (C-l). It scrolls the
+// cursor line to the middle of the window, and when repeated to the top and
+// then the bottom. A numeric prefix argument puts the cursor line on that
+// line of the window, counting from the bottom if negative.
+func RecenterTopBottom(editor *Editor) error {
+	window := editor.CurrentWindow()
+	buffer := editor.CurrentBuffer()
+	if window == nil || buffer == nil {
+		return nil
+	}
+	
+	_, windowHeight := window.Size()
+	var offset int
+	if arg := editor.PrefixArg(); arg != nil && !arg.Universal {
+		offset = arg.Value
+		if offset < 0 {
+			offset += windowHeight
+		}
+		editor.recenterCycle = 0
+	} else {
+		if editor.lastCommand == "recenter-top-bottom" {
+			editor.recenterCycle = (editor.recenterCycle + 1) % 3
+		} else {
+			editor.recenterCycle = 0
+		}
+		switch editor.recenterCycle {
+		case 0:
+			offset = windowHeight / 2
+		case 1:
+			offset = 0
+		case 2:
+			offset = windowHeight - 1
+		}
+	}
+	
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > windowHeight-1 {
+		offset = windowHeight - 1
+	}
+	
+	window.SetScrollTop(scrollTopForOffset(window, buffer.Cursor().Row, offset))
+	log.Debug("Recentered line %d at window line %d", buffer.Cursor().Row, offset)
+	return nil
+}
+
+// scrollTopForOffset returns the scroll position that shows row offset
+// screen lines below the top of the window
+func scrollTopForOffset(window *Window, row, offset int) int {
+	if !window.LineWrap() {
+		return row - offset
+	}
+	
+	// Count the wrapped screen lines of the lines above row
+	top := row
+	for top > 0 {
+		screenLines := len(window.wrapLine(window.Buffer().Line(top - 1)))
+		if screenLines > offset {
+			break
+		}
+		offset -= screenLines
+		top--
+	}
+	return top
+}
+
 // EnsureCursorVisible adjusts scrolling to make sure cursor is visible
 func EnsureCursorVisible(editor *Editor) error {
 	window := editor.CurrentWindow()
@@ -189,7 +268,7 @@ func EnsureCursorVisible(editor *Editor) error {
 			
 			// Start from current scroll position and increment until cursor is visible
 			oldScrollTop := window.ScrollTop()
-			maxScrollTop := len(buffer.Content()) - 1
+			maxScrollTop := buffer.LineCount() - 1
 			if maxScrollTop < 0 {
 				maxScrollTop = 0
 			}
@@ -224,8 +303,8 @@ func EnsureCursorVisible(editor *Editor) error {
 		cursorPos = buffer.Cursor()
 		
 		// Calculate the actual display column for the cursor
-		if cursorPos.Row < len(buffer.Content()) {
-			line := buffer.Content()[cursorPos.Row]
+		if cursorPos.Row < buffer.LineCount() {
+			line := buffer.Line(cursorPos.Row)
 			if cursorPos.Col <= len(line) {
 				displayCol := util.StringWidthUpTo(line, cursorPos.Col)
 				
@@ -268,7 +347,7 @@ func ShowDebugInfo(editor *Editor) error {
 	scrollLeft := window.ScrollLeft()
 	lineWrap := window.LineWrap()
 	screenRow, screenCol := window.CursorPosition()
-	bufferLines := len(buffer.Content())
+	bufferLines := buffer.LineCount()
 	
 	debugMsg := fmt.Sprintf("Window: %dx%d, Cursor: buf(%d,%d) scr(%d,%d), Scroll: (%d,%d), Lines: %d, Wrap: %t", 
 		windowWidth, windowHeight, cursor.Row, cursor.Col, screenRow, screenCol, 
@@ -0,0 +1,265 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Highlighting is described by a Grammar: a set of states, each with rules
+// that are regular expressions. Highlighting a line starts in the state the
+// previous line ended in, so a block comment or a string that spans lines
+// keeps its face. Segment styles are face names such as "keyword",
+// "comment" or "string"; the display decides how a face looks.
+
+// LineStateHighlighter is a SyntaxHighlighter whose highlighting of a line
+// depends on the lines before it. The state is opaque to the caller; ""
+// is the state at the start of the buffer.
+type LineStateHighlighter interface {
+	SyntaxHighlighter
+	HighlightLineFrom(line string, state string) ([]HighlightSegment, string)
+}
+
+// SyntaxRule matches text in one state of a Grammar
+type SyntaxRule struct {
+	Pattern *regexp.Regexp // Empty matches are skipped
+	Face    string         // Face of the matched text, "" for the state's face
+	Push    string         // State entered after the match, "" to stay
+	Pop     bool           // Return to the enclosing state after the match
+}
+
+// SyntaxState is a state of a Grammar
+type SyntaxState struct {
+	Face  string // Face of the text no rule matches
+	Rules []SyntaxRule
+}
+
+// RootSyntaxState is the state highlighting starts in
+const RootSyntaxState = "root"
+
+// Grammar describes the syntax of a language as states by name
+type Grammar struct {
+	States map[string]*SyntaxState
+}
+
+// GrammarHighlighter highlights text with a Grammar. At each position the
+// rule of the current state matching earliest wins, the first rule in
+// order among those matching at the same place.
+type GrammarHighlighter struct {
+	grammar Grammar
+}
+
+// NewGrammarHighlighter checks grammar and returns a highlighter for it
+func NewGrammarHighlighter(grammar Grammar) (*GrammarHighlighter, error) {
+	if grammar.States[RootSyntaxState] == nil {
+		return nil, &ModeError{Message: "Grammar has no root state"}
+	}
+	for name, state := range grammar.States {
+		for _, rule := range state.Rules {
+			if rule.Pattern == nil {
+				return nil, &ModeError{Message: "Rule without a pattern in state " + name}
+			}
+			if rule.Push != "" && grammar.States[rule.Push] == nil {
+				return nil, &ModeError{Message: "Unknown state " + rule.Push + " in state " + name}
+			}
+		}
+	}
+	return &GrammarHighlighter{grammar: grammar}, nil
+}
+
+// HighlightLine highlights a line on its own
+func (g *GrammarHighlighter) HighlightLine(line string) []HighlightSegment {
+	segments, _ := g.HighlightLineFrom(line, "")
+	return segments
+}
+
+// GetTokens returns the highlighted parts of content as tokens typed by
+// their face
+func (g *GrammarHighlighter) GetTokens(content []string) []Token {
+	return lineStateTokens(g, content)
+}
+
+// lineStateTokens highlights content line by line with h and returns the
+// highlighted parts as tokens typed by their face
+func lineStateTokens(h LineStateHighlighter, content []string) []Token {
+	var tokens []Token
+	state := ""
+	for row, line := range content {
+		var segments []HighlightSegment
+		segments, state = h.HighlightLineFrom(line, state)
+		for _, s := range segments {
+			tokens = append(tokens, Token{
+				Type:     s.Style,
+				Value:    line[s.Start:s.End],
+				Position: Position{Row: row, Col: s.Start},
+			})
+		}
+	}
+	return tokens
+}
+
+// HighlightLineFrom highlights line starting in state and returns the
+// state at its end. The state is the stack of entered states joined by
+// newlines, "" when only the root state is entered.
+func (g *GrammarHighlighter) HighlightLineFrom(line string, state string) ([]HighlightSegment, string) {
+	stack := []string{RootSyntaxState}
+	if state != "" {
+		stack = append(stack, strings.Split(state, "\n")...)
+	}
+
+	var segments []HighlightSegment
+	add := func(start, end int, face string) {
+		if start >= end || face == "" {
+			return
+		}
+		if n := len(segments); n > 0 && segments[n-1].End == start && segments[n-1].Style == face {
+			segments[n-1].End = end
+			return
+		}
+		segments = append(segments, HighlightSegment{Start: start, End: end, Style: face})
+	}
+
+	pos := 0
+	for pos < len(line) {
+		current := g.grammar.States[stack[len(stack)-1]]
+		if current == nil {
+			// A state left by an older grammar
+			current = g.grammar.States[RootSyntaxState]
+			stack = stack[:1]
+		}
+		rule, start, end := matchRules(current.Rules, line, pos)
+		if rule == nil {
+			add(pos, len(line), current.Face)
+			break
+		}
+		add(pos, start, current.Face)
+		face := rule.Face
+		if face == "" {
+			face = current.Face
+		}
+		add(start, end, face)
+		if rule.Pop && len(stack) > 1 {
+			stack = stack[:len(stack)-1]
+		}
+		if rule.Push != "" {
+			stack = append(stack, rule.Push)
+		}
+		pos = end
+	}
+	return segments, strings.Join(stack[1:], "\n")
+}
+
+// matchRules finds the rule matching earliest in line at or after pos.
+// Matches are found as in the whole line, so ^ only matches at its start
+// and \b sees the text before pos.
+func matchRules(rules []SyntaxRule, line string, pos int) (rule *SyntaxRule, start, end int) {
+	start = -1
+	for i := range rules {
+		s, e, ok := firstMatch(rules[i].Pattern, line, pos)
+		if ok && (start < 0 || s < start) {
+			rule, start, end = &rules[i], s, e
+		}
+	}
+	return rule, start, end
+}
+
+// firstMatch returns the first non-empty match of pattern in line that
+// starts at or after pos
+func firstMatch(pattern *regexp.Regexp, line string, pos int) (int, int, bool) {
+	for pos <= len(line) {
+		loc, ok := findSubmatchFrom(pattern, line, pos)
+		if !ok {
+			break
+		}
+		if loc[1] > loc[0] {
+			return loc[0], loc[1], true
+		}
+		// Skip an empty match
+		_, size := utf8.DecodeRuneInString(line[loc[0]:])
+		if size == 0 {
+			break
+		}
+		pos = loc[0] + size
+	}
+	return 0, 0, false
+}
+
+// syntaxCache keeps the highlighting of each line of a buffer. Lines are
+// highlighted on demand from the top; an edit invalidates the changed lines,
+// and the lines below are highlighted again only if the state they start in
+// has changed.
+type syntaxCache struct {
+	highlighter SyntaxHighlighter
+	lines       []lineSyntax
+	valid       int // Lines before this row are up to date
+}
+
+// lineSyntax is the highlighting of one line
+type lineSyntax struct {
+	ok         bool
+	startState string
+	endState   string
+	segments   []HighlightSegment
+}
+
+// invalidate forgets the lines from start to oldEnd, which now span the
+// rows from start to newEnd
+func (c *syntaxCache) invalidate(start, oldEnd, newEnd int) {
+	if c.valid > start {
+		c.valid = start
+	}
+	if start >= len(c.lines) {
+		return
+	}
+	var tail []lineSyntax
+	if oldEnd+1 < len(c.lines) {
+		tail = c.lines[oldEnd+1:]
+	}
+	fresh := make([]lineSyntax, newEnd-start+1)
+	c.lines = append(append(c.lines[:start:start], fresh...), tail...)
+}
+
+// highlight returns the highlighting of row of buffer by highlighter
+func (c *syntaxCache) highlight(buffer *Buffer, highlighter SyntaxHighlighter, row int) []HighlightSegment {
+	if highlighter != c.highlighter {
+		*c = syntaxCache{highlighter: highlighter}
+	}
+	stateful, _ := highlighter.(LineStateHighlighter)
+	for i := c.valid; i <= row; i++ {
+		state := ""
+		if i > 0 {
+			state = c.lines[i-1].endState
+		}
+		if i < len(c.lines) && c.lines[i].ok && c.lines[i].startState == state {
+			continue
+		}
+		entry := lineSyntax{ok: true, startState: state}
+		if stateful != nil {
+			entry.segments, entry.endState = stateful.HighlightLineFrom(buffer.Line(i), state)
+		} else {
+			entry.segments = highlighter.HighlightLine(buffer.Line(i))
+		}
+		if i < len(c.lines) {
+			c.lines[i] = entry
+		} else {
+			c.lines = append(c.lines, entry)
+		}
+	}
+	if row >= c.valid {
+		c.valid = row + 1
+	}
+	return c.lines[row].segments
+}
+
+// Highlights returns the highlighting of a line by the major mode, nil if
+// the mode has no highlighter
+func (b *Buffer) Highlights(row int) []HighlightSegment {
+	if b.majorMode == nil || row < 0 || row >= b.LineCount() {
+		return nil
+	}
+	highlighter := b.majorMode.SyntaxHighlighting()
+	if highlighter == nil {
+		return nil
+	}
+	return b.syntax.highlight(b, highlighter, row)
+}
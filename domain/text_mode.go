@@ -50,9 +50,10 @@ func (tm *TextMode) Commands() map[string]*Command {
 	return tm.commands
 }
 
-// IndentFunction returns the indentation function for text mode
+// IndentFunction returns the indentation function for text mode, which
+// follows the previous line
 func (tm *TextMode) IndentFunction() IndentFunc {
-	return tm.textIndent
+	return previousLineIndent
 }
 
 // SyntaxHighlighting returns the syntax highlighter (nil for text mode)
@@ -89,9 +90,4 @@ func (tm *TextMode) registerCommands() {
 func (tm *TextMode) setupKeyBindings() {
 	// Text mode specific key bindings can be added here
 	// For now, inherits all global bindings
-}
-
-// textIndent provides basic text indentation (no automatic indentation)
-func (tm *TextMode) textIndent(buffer *Buffer, line int) int {
-	return 0 // No automatic indentation for text mode
 }
\ No newline at end of file
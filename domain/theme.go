@@ -0,0 +1,60 @@
+package domain
+
+import "sort"
+
+// themes are the built-in color themes by name. A theme only lists the
+// faces it changes.
+var themes = map[string]map[string]Face{
+	"dark": {
+		FaceDefault:              {Foreground: "#d3d7cf", Background: "#2e3436"},
+		FaceModeLine:             {Foreground: "#2e3436", Background: "#d3d7cf"},
+		FaceModeLineInactive:     {Foreground: "#eeeeec", Background: "#555753"},
+		FaceRegion:               {Background: "#555753"},
+		FaceISearch:              {Foreground: "#eeeeec", Background: "#ce5c00"},
+		FaceLazyHighlight:        {Foreground: "#2e3436", Background: "#c4a000"},
+		FaceMinibufferPrompt:     {Foreground: "#b4fa70", Bold: true},
+		FaceCompletionSelected:   {Foreground: "#eeeeec", Background: "#204a87"},
+		FaceCompletionAnnotation: {Foreground: "#888a85"},
+		"keyword":                {Foreground: "#b4fa70"},
+		"builtin":                {Foreground: "#e090d7"},
+		"comment":                {Foreground: "#73d216", Italic: true},
+		"string":                 {Foreground: "#e9b96e"},
+		"type":                   {Foreground: "#8cc4ff"},
+		"function-name":          {Foreground: "#fce94f"},
+		"variable-name":          {Foreground: "#fcaf3e"},
+		"constant":               {Foreground: "#e6a8df"},
+		"preprocessor":           {Foreground: "#e090d7"},
+		"warning":                {Foreground: "#ff4b4b", Bold: true},
+	},
+	"light": {
+		FaceDefault:              {Foreground: "#2e3436", Background: "#ffffff"},
+		FaceModeLine:             {Foreground: "#2e3436", Background: "#d3d7cf"},
+		FaceModeLineInactive:     {Foreground: "#555753", Background: "#eeeeec"},
+		FaceRegion:               {Background: "#babdb6"},
+		FaceISearch:              {Foreground: "#ffffff", Background: "#ce5c00"},
+		FaceLazyHighlight:        {Background: "#e9b96e"},
+		FaceMinibufferPrompt:     {Foreground: "#204a87", Bold: true},
+		FaceCompletionSelected:   {Background: "#c5d8ef"},
+		FaceCompletionAnnotation: {Foreground: "#888a85"},
+		"keyword":                {Foreground: "#346604", Bold: true},
+		"builtin":                {Foreground: "#75507b"},
+		"comment":                {Foreground: "#5f615c", Italic: true},
+		"string":                 {Foreground: "#5c3566"},
+		"type":                   {Foreground: "#204a87"},
+		"function-name":          {Foreground: "#a40000"},
+		"variable-name":          {Foreground: "#b35000"},
+		"constant":               {Foreground: "#ce5c00"},
+		"preprocessor":           {Foreground: "#75507b"},
+		"warning":                {Foreground: "#a40000", Bold: true},
+	},
+}
+
+// ThemeNames returns the names of the built-in themes in sorted order
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
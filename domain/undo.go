@@ -0,0 +1,195 @@
+package domain
+
+import (
+	"github.com/TakahashiShuuhei/gmacs/log"
+)
+
+// DefaultUndoLimit is the number of undo groups kept per buffer unless the
+// "undo-limit" option says otherwise
+const DefaultUndoLimit = 1000
+
+// undoKind identifies the kind of primitive change recorded in the undo log
+type undoKind int
+
+const (
+	undoInsert undoKind = iota
+	undoDelete
+)
+
+// undoRecord describes a single primitive change to a buffer
+type undoRecord struct {
+	kind  undoKind
+	start Position
+	end   Position
+	text  string
+}
+
+// undoGroup collects all changes made by one command (or one run of self-inserts)
+type undoGroup struct {
+	records []undoRecord
+	cursor  Position // Cursor position before the first change in the group
+}
+
+// undoLog keeps the undo and redo history of a buffer
+type undoLog struct {
+	undoStack  []*undoGroup
+	redoStack  []*undoGroup
+	open       bool // New changes extend the top group until the next boundary
+	applying   bool // Set while undoing/redoing so that changes are not recorded
+	savedDepth int  // Undo stack depth matching the file on disk, -1 if unreachable
+}
+
+// recordChange adds a primitive change to the current undo group
+func (b *Buffer) recordChange(kind undoKind, start, end Position, text string) {
+	u := &b.undo
+	if u.applying {
+		return
+	}
+
+	if !u.open {
+		// A new edit invalidates everything that could have been redone
+		if len(u.redoStack) > 0 {
+			if u.savedDepth > len(u.undoStack) {
+				u.savedDepth = -1
+			}
+			u.redoStack = nil
+		}
+		u.undoStack = append(u.undoStack, &undoGroup{cursor: b.cursor})
+		u.open = true
+	}
+
+	group := u.undoStack[len(u.undoStack)-1]
+	group.records = append(group.records, undoRecord{kind: kind, start: start, end: end, text: text})
+}
+
+// UndoBoundary closes the current undo group and trims the history to limit groups
+func (b *Buffer) UndoBoundary(limit int) {
+	u := &b.undo
+	u.open = false
+
+	if limit <= 0 || len(u.undoStack) <= limit {
+		return
+	}
+
+	excess := len(u.undoStack) - limit
+	u.undoStack = append([]*undoGroup(nil), u.undoStack[excess:]...)
+	if u.savedDepth >= 0 {
+		u.savedDepth -= excess
+		if u.savedDepth < 0 {
+			u.savedDepth = -1
+		}
+	}
+}
+
+// markUndoSavePoint records that the current state matches the file on disk
+func (b *Buffer) markUndoSavePoint() {
+	b.undo.open = false
+	b.undo.savedDepth = len(b.undo.undoStack)
+}
+
+// CanUndo returns true if there are changes that can be undone
+func (b *Buffer) CanUndo() bool {
+	return len(b.undo.undoStack) > 0
+}
+
+// CanRedo returns true if there are undone changes that can be redone
+func (b *Buffer) CanRedo() bool {
+	return len(b.undo.redoStack) > 0
+}
+
+// Undo reverts the most recent undo group, restoring the cursor and the
+// modified flag. It returns false if there is nothing to undo.
+func (b *Buffer) Undo() bool {
+	u := &b.undo
+	if len(u.undoStack) == 0 {
+		return false
+	}
+
+	group := u.undoStack[len(u.undoStack)-1]
+	u.undoStack = u.undoStack[:len(u.undoStack)-1]
+
+	u.applying = true
+	for i := len(group.records) - 1; i >= 0; i-- {
+		record := group.records[i]
+		switch record.kind {
+		case undoInsert:
+			b.deleteText(record.start, record.end)
+		case undoDelete:
+			b.insertText(record.start, record.text)
+		}
+	}
+	u.applying = false
+
+	u.redoStack = append(u.redoStack, group)
+	u.open = false
+	b.SetCursor(group.cursor)
+	b.modified = len(u.undoStack) != u.savedDepth
+	return true
+}
+
+// Redo re-applies the most recently undone group. It returns false if there
+// is nothing to redo.
+func (b *Buffer) Redo() bool {
+	u := &b.undo
+	if len(u.redoStack) == 0 {
+		return false
+	}
+
+	group := u.redoStack[len(u.redoStack)-1]
+	u.redoStack = u.redoStack[:len(u.redoStack)-1]
+
+	cursor := group.cursor
+	u.applying = true
+	for _, record := range group.records {
+		switch record.kind {
+		case undoInsert:
+			cursor = b.insertText(record.start, record.text)
+		case undoDelete:
+			b.deleteText(record.start, record.end)
+			cursor = record.start
+		}
+	}
+	u.applying = false
+
+	u.undoStack = append(u.undoStack, group)
+	u.open = false
+	b.SetCursor(cursor)
+	b.modified = len(u.undoStack) != u.savedDepth
+	return true
+}
+
+// Undo implements the undo command (C-/, C-x u)
+func Undo(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	if !buffer.Undo() {
+		editor.SetMinibufferMessage("No further undo information")
+		return nil
+	}
+
+	EnsureCursorVisible(editor)
+	editor.SetMinibufferMessage("Undo")
+	log.Debug("Undo in buffer %s", buffer.Name())
+	return nil
+}
+
+// Redo implements the redo command (C-M-/)
+func Redo(editor *Editor) error {
+	buffer := editor.CurrentBuffer()
+	if buffer == nil {
+		return nil
+	}
+
+	if !buffer.Redo() {
+		editor.SetMinibufferMessage("No further redo information")
+		return nil
+	}
+
+	EnsureCursorVisible(editor)
+	editor.SetMinibufferMessage("Redo")
+	log.Debug("Redo in buffer %s", buffer.Name())
+	return nil
+}
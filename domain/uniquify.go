@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Buffers visiting files are named after the file. When files with the
+// same name are visited, or a buffer not visiting a file has the name, the
+// buffers of the files are told apart by the end of their directories, as
+// main.go<cmd> and main.go<pkg>. The names are worked out again whenever a
+// buffer visiting a file is added, renamed or killed.
+
+// canonicalPath returns the absolute path of a file with symlinks
+// resolved, or the absolute path when the file does not exist
+func canonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// FindFileBuffer returns the buffer visiting the file at path, through
+// whatever path or symlink it was visited, or nil if there is none
+func (e *Editor) FindFileBuffer(path string) *Buffer {
+	canonical := canonicalPath(path)
+	for _, buffer := range e.buffers {
+		if buffer.Filepath() != "" && canonicalPath(buffer.Filepath()) == canonical {
+			return buffer
+		}
+	}
+	return nil
+}
+
+// uniquifyBufferNames names the buffers visiting files after their files,
+// adding as many directories as it takes to tell apart those that share a
+// name
+func (e *Editor) uniquifyBufferNames() {
+	groups := make(map[string][]*Buffer)
+	taken := make(map[string]bool) // Names of buffers not visiting files
+	for _, buffer := range e.buffers {
+		if buffer.Filepath() == "" {
+			taken[buffer.Name()] = true
+			continue
+		}
+		base := filepath.Base(buffer.Filepath())
+		groups[base] = append(groups[base], buffer)
+	}
+
+	for base, buffers := range groups {
+		if len(buffers) == 1 && !taken[base] {
+			buffers[0].name = base
+			continue
+		}
+		dirs := make([][]string, len(buffers))
+		longest := 0
+		for i, buffer := range buffers {
+			abs, err := filepath.Abs(buffer.Filepath())
+			if err != nil {
+				abs = buffer.Filepath()
+			}
+			dirs[i] = strings.Split(strings.Trim(filepath.Dir(abs), "/"), "/")
+			if len(dirs[i]) > longest {
+				longest = len(dirs[i])
+			}
+		}
+		for depth := 1; depth <= longest; depth++ {
+			suffixes := make(map[string]bool)
+			for i, buffer := range buffers {
+				suffix := dirSuffix(dirs[i], depth)
+				suffixes[suffix] = true
+				buffer.name = base + "<" + suffix + ">"
+			}
+			if len(suffixes) == len(buffers) {
+				break
+			}
+		}
+	}
+}
+
+// dirSuffix returns the last depth elements of a directory
+func dirSuffix(dir []string, depth int) string {
+	if depth > len(dir) {
+		depth = len(dir)
+	}
+	return strings.Join(dir[len(dir)-depth:], "/")
+}
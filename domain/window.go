@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"unicode/utf8"
+
 	"github.com/TakahashiShuuhei/gmacs/log"
 	"github.com/TakahashiShuuhei/gmacs/util"
 )
@@ -58,13 +60,13 @@ func (w *Window) SetScrollTop(top int) {
 	if w.lineWrap {
 		// In line wrap mode, we need to consider how many screen lines the content takes
 		// For bounds checking, we use a simpler approach: can't scroll past the last buffer line
-		maxScroll = len(w.buffer.content) - 1
+		maxScroll = w.buffer.LineCount() - 1
 		if maxScroll < 0 {
 			maxScroll = 0
 		}
 	} else {
 		// In no-wrap mode, use the traditional calculation
-		maxScroll = len(w.buffer.content) - w.height
+		maxScroll = w.buffer.LineCount() - w.height
 		if maxScroll < 0 {
 			maxScroll = 0
 		}
@@ -95,30 +97,57 @@ func (w *Window) SetLineWrap(wrap bool) {
 	w.lineWrap = wrap
 }
 
+// DisplayLine describes one screen line of a window and the part of the
+// buffer line it shows
+type DisplayLine struct {
+	Row            int    // Buffer row shown on this screen line
+	StartCol       int    // Byte offset of the first displayed character
+	EndCol         int    // Byte offset just after the last displayed character
+	LeftIndicator  bool   // Line is scrolled horizontally ("\\" at the left edge)
+	RightIndicator bool   // Line continues past the right edge ("\\" at the right edge)
+	Text           string // Text shown on screen, including indicators
+}
+
 func (w *Window) VisibleLines() []string {
-	content := w.buffer.Content()
+	displayLines := w.DisplayLines()
+	result := make([]string, len(displayLines))
+	for i, line := range displayLines {
+		result[i] = line.Text
+	}
+	return result
+}
+
+// DisplayLines returns the screen lines of the window, taking line wrapping
+// and horizontal scrolling into account
+func (w *Window) DisplayLines() []DisplayLine {
 	start := w.scrollTop
 	end := start + w.height
+	lineCount := w.buffer.LineCount()
 	
-	if start >= len(content) {
-		return []string{}
+	if start >= lineCount {
+		return []DisplayLine{}
 	}
-	if end > len(content) {
-		end = len(content)
+	if end > lineCount {
+		end = lineCount
 	}
 	
-	lines := content[start:end]
-	result := make([]string, 0, len(lines))
+	result := make([]DisplayLine, 0, end-start)
 	
-	for _, line := range lines {
+	for i, line := range w.buffer.Lines(start, end) {
+		row := start + i
 		if w.lineWrap {
 			// Line wrapping: split long lines into multiple display lines
-			wrappedLines := w.wrapLine(line)
-			result = append(result, wrappedLines...)
+			for _, span := range w.wrapLineSpans(line) {
+				result = append(result, DisplayLine{
+					Row:      row,
+					StartCol: span[0],
+					EndCol:   span[1],
+					Text:     line[span[0]:span[1]],
+				})
+			}
 		} else {
 			// No wrapping: apply horizontal scrolling
-			scrolledLine := w.applyHorizontalScroll(line)
-			result = append(result, scrolledLine)
+			result = append(result, w.horizontalScrollLine(row, line))
 		}
 	}
 	
@@ -131,17 +160,29 @@ func (w *Window) VisibleLines() []string {
 }
 
 func (w *Window) wrapLine(line string) []string {
+	spans := w.wrapLineSpans(line)
+	result := make([]string, len(spans))
+	for i, span := range spans {
+		result[i] = line[span[0]:span[1]]
+	}
+	return result
+}
+
+// wrapLineSpans splits a line into byte ranges that each fit the window width
+func (w *Window) wrapLineSpans(line string) [][2]int {
 	if util.StringWidth(line) <= w.width {
-		return []string{line}
+		return [][2]int{{0, len(line)}}
 	}
 	
-	var result []string
+	var result [][2]int
 	runes := []rune(line)
 	start := 0
+	startByte := 0
 	
 	for start < len(runes) {
 		width := 0
 		end := start
+		endByte := startByte
 		
 		// Find how many characters fit in one line
 		for end < len(runes) {
@@ -150,51 +191,68 @@ func (w *Window) wrapLine(line string) []string {
 				break
 			}
 			width += charWidth
+			endByte += utf8.RuneLen(runes[end])
 			end++
 		}
 		
 		// If we couldn't fit even one character, take at least one
 		if end == start && start < len(runes) {
+			endByte += utf8.RuneLen(runes[end])
 			end = start + 1
 		}
 		
-		result = append(result, string(runes[start:end]))
+		result = append(result, [2]int{startByte, endByte})
 		start = end
+		startByte = endByte
 	}
 	
 	return result
 }
 
 func (w *Window) applyHorizontalScroll(line string) string {
+	return w.horizontalScrollLine(0, line).Text
+}
+
+// horizontalScrollLine computes the visible part of a line in no-wrap mode
+func (w *Window) horizontalScrollLine(row int, line string) DisplayLine {
 	lineWidth := util.StringWidth(line)
 	
 	if w.scrollLeft == 0 {
 		// No horizontal scrolling
 		if lineWidth <= w.width {
-			return line
+			return DisplayLine{Row: row, StartCol: 0, EndCol: len(line), Text: line}
 		}
 		// Line continues beyond window width - show continuation indicator
 		truncated := w.truncateToWidth(line, w.width-1)
-		return truncated + "\\"
+		return DisplayLine{
+			Row:            row,
+			StartCol:       0,
+			EndCol:         len(truncated),
+			RightIndicator: true,
+			Text:           truncated + "\\",
+		}
 	}
 	
 	// Apply horizontal scrolling
 	runes := []rune(line)
 	width := 0
 	start := 0
+	startByte := 0
 	
 	// Skip characters until we reach scrollLeft position
 	for start < len(runes) && width < w.scrollLeft {
 		width += util.RuneWidth(runes[start])
+		startByte += utf8.RuneLen(runes[start])
 		start++
 	}
 	
 	// Now get characters that fit in the window width
 	if start >= len(runes) {
-		return ""
+		return DisplayLine{Row: row, StartCol: len(line), EndCol: len(line), Text: ""}
 	}
 	
 	end := start
+	endByte := startByte
 	displayWidth := 0
 	availableWidth := w.width
 	
@@ -230,10 +288,11 @@ func (w *Window) applyHorizontalScroll(line string) string {
 			break
 		}
 		displayWidth += charWidth
+		endByte += utf8.RuneLen(runes[end])
 		end++
 	}
 	
-	result := string(runes[start:end])
+	result := line[startByte:endByte]
 	
 	// Add continuation indicators
 	if showLeftIndicator {
@@ -243,7 +302,14 @@ func (w *Window) applyHorizontalScroll(line string) string {
 		result = result + "\\"
 	}
 	
-	return result
+	return DisplayLine{
+		Row:            row,
+		StartCol:       startByte,
+		EndCol:         endByte,
+		LeftIndicator:  showLeftIndicator,
+		RightIndicator: hasContentAfter,
+		Text:           result,
+	}
 }
 
 func (w *Window) truncateToWidth(s string, maxWidth int) string {
@@ -269,8 +335,8 @@ func (w *Window) CursorPosition() (int, int) {
 	bufferPos := w.buffer.Cursor()
 	log.Info("SCROLL_TIMING: CursorPosition calculation - buffer cursor at (%d,%d), scrollTop=%d", bufferPos.Row, bufferPos.Col, w.scrollTop)
 	
-	if bufferPos.Row < len(w.buffer.content) {
-		line := w.buffer.content[bufferPos.Row]
+	if bufferPos.Row < w.buffer.LineCount() {
+		line := w.buffer.Line(bufferPos.Row)
 		if bufferPos.Col <= len(line) {
 			// Calculate display width up to cursor position
 			displayCol := util.StringWidthUpTo(line, bufferPos.Col)
@@ -299,7 +365,7 @@ func (w *Window) CursorPosition() (int, int) {
 
 // calculateWrappedCursorPosition calculates the screen position when line wrapping is enabled
 func (w *Window) calculateWrappedCursorPosition(bufferRow int, cursorDisplayCol int) (int, int) {
-	content := w.buffer.Content()
+	lineCount := w.buffer.LineCount()
 	
 	// If cursor is above scroll area, return negative screen row
 	if bufferRow < w.scrollTop {
@@ -311,17 +377,17 @@ func (w *Window) calculateWrappedCursorPosition(bufferRow int, cursorDisplayCol
 	screenRow := 0
 	
 	// Count wrapped lines from scroll top to cursor row
-	for row := w.scrollTop; row < bufferRow && row < len(content); row++ {
+	for row := w.scrollTop; row < bufferRow && row < lineCount; row++ {
 		if row >= 0 {
-			line := content[row]
+			line := w.buffer.Line(row)
 			wrappedLines := w.wrapLine(line)
 			screenRow += len(wrappedLines)
 		}
 	}
 	
 	// Now handle the cursor's line - find which wrapped segment it's in
-	if bufferRow >= 0 && bufferRow < len(content) {
-		line := content[bufferRow]
+	if bufferRow >= 0 && bufferRow < lineCount {
+		line := w.buffer.Line(bufferRow)
 		wrappedLines := w.wrapLine(line)
 		
 		// Find which wrapped line contains our cursor
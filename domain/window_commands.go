@@ -11,7 +11,7 @@ func SplitWindowRight(editor *Editor) error {
 		return nil
 	}
 	
-	newWindow := editor.layout.SplitWindowRight()
+	newWindow := editor.SplitWindow(editor.CurrentWindow(), "right")
 	if newWindow == nil {
 		log.Warn("Failed to split window right")
 		return nil
@@ -33,7 +33,7 @@ func SplitWindowBelow(editor *Editor) error {
 		return nil
 	}
 	
-	newWindow := editor.layout.SplitWindowBelow()
+	newWindow := editor.SplitWindow(editor.CurrentWindow(), "below")
 	if newWindow == nil {
 		log.Warn("Failed to split window below")
 		return nil
@@ -48,6 +48,32 @@ func SplitWindowBelow(editor *Editor) error {
 	return nil
 }
 
+// SplitWindow splits window to its "right" or "below" it and returns the
+// new window, which shares the buffer and becomes the selected window.
+// It returns nil if the window could not be split.
+func (e *Editor) SplitWindow(window *Window, direction string) *Window {
+	if e.layout == nil || window == nil {
+		return nil
+	}
+	
+	e.layout.SetActiveWindow(window)
+	var newWindow *Window
+	switch direction {
+	case "right":
+		newWindow = e.layout.SplitWindowRight()
+	case "below":
+		newWindow = e.layout.SplitWindowBelow()
+	default:
+		return nil
+	}
+	if newWindow == nil {
+		return nil
+	}
+	
+	e.TriggerHook(HookWindowSplit, newWindow, direction)
+	return newWindow
+}
+
 // OtherWindow implements the other-window command (C-x o)
 func OtherWindow(editor *Editor) error {
 	if editor.layout == nil {
@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// A word is a run of word-constituent runes of the same class. Letters and
+// digits of alphabetic scripts form one class, while kanji, hiragana,
+// katakana and hangul each form their own, so Japanese text such as
+// "日本語のテキスト" breaks into "日本語", "の" and "テキスト" as it does in
+// Emacs. Whitespace, punctuation and symbols, including the CJK 、 and 。,
+// separate words.
+
+// wordClass classifies a rune for word motion
+type wordClass int
+
+const (
+	wordNone wordClass = iota // Not part of a word
+	wordAlnum
+	wordHan
+	wordHiragana
+	wordKatakana
+	wordHangul
+)
+
+// classOfRune returns the word class of r
+func classOfRune(r rune) wordClass {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return wordHan
+	case unicode.Is(unicode.Hiragana, r):
+		return wordHiragana
+	case unicode.Is(unicode.Katakana, r) || r == 'ー' || r == 'ｰ':
+		// The prolonged sound mark belongs to the Common script but is
+		// written almost only in katakana words
+		return wordKatakana
+	case unicode.Is(unicode.Hangul, r):
+		return wordHangul
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+		return wordAlnum
+	}
+	return wordNone
+}
+
+// runeAfter returns the rune just after pos and the position following it.
+// The end of a line reads as '\n'; ok is false at the end of the buffer.
+func runeAfter(buffer *Buffer, pos Position) (r rune, next Position, ok bool) {
+	line := buffer.Line(pos.Row)
+	if pos.Col < len(line) {
+		r, size := utf8.DecodeRuneInString(line[pos.Col:])
+		return r, Position{Row: pos.Row, Col: pos.Col + size}, true
+	}
+	if pos.Row < buffer.LineCount()-1 {
+		return '\n', Position{Row: pos.Row + 1, Col: 0}, true
+	}
+	return 0, pos, false
+}
+
+// runeBefore returns the rune just before pos and the position preceding
+// it. The start of a line reads as '\n'; ok is false at the buffer start.
+func runeBefore(buffer *Buffer, pos Position) (r rune, prev Position, ok bool) {
+	if pos.Col > 0 {
+		r, size := utf8.DecodeLastRuneInString(buffer.Line(pos.Row)[:pos.Col])
+		return r, Position{Row: pos.Row, Col: pos.Col - size}, true
+	}
+	if pos.Row > 0 {
+		return '\n', Position{Row: pos.Row - 1, Col: len(buffer.Line(pos.Row - 1))}, true
+	}
+	return 0, pos, false
+}
+
+// wordEnd returns the end of the word at or after pos
+func wordEnd(buffer *Buffer, pos Position) Position {
+	class := wordNone
+	for {
+		r, next, ok := runeAfter(buffer, pos)
+		if !ok {
+			return pos
+		}
+		c := classOfRune(r)
+		if class == wordNone {
+			class = c
+		} else if c != class {
+			return pos
+		}
+		pos = next
+	}
+}
+
+// wordStart returns the start of the word at or before pos
+func wordStart(buffer *Buffer, pos Position) Position {
+	class := wordNone
+	for {
+		r, prev, ok := runeBefore(buffer, pos)
+		if !ok {
+			return pos
+		}
+		c := classOfRune(r)
+		if class == wordNone {
+			class = c
+		} else if c != class {
+			return pos
+		}
+		pos = prev
+	}
+}
+
+// isCJK returns true for Chinese and Japanese characters and punctuation,
+// which are written without spaces between words
+func isCJK(r rune) bool {
+	switch classOfRune(r) {
+	case wordHan, wordHiragana, wordKatakana:
+		return true
+	}
+	// CJK symbols and punctuation, and fullwidth forms
+	return (r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
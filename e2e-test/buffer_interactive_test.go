@@ -505,7 +505,7 @@ func TestBufferTabCompletionSingle(t *testing.T) {
  * @description Tabキーによるバッファ名の自動補完機能（複数マッチ）
  * @given C-x bでバッファ切り替えモード開始し、複数にマッチする部分文字列を入力済み
  * @when Tabキーを押下
- * @then 共通部分まで補完され、マッチした候補一覧が表示される
+ * @then 入力はそのまま残り、マッチした候補の一覧がミニバッファの上に表示される
  * @implementation domain/buffer_interactive.go, 補完機能
  */
 func TestBufferTabCompletionMultiple(t *testing.T) {
@@ -536,27 +536,18 @@ func TestBufferTabCompletionMultiple(t *testing.T) {
 	tabEvent := events.KeyEventData{Key: "Tab", Rune: '\t'}
 	editor.HandleEvent(tabEvent)
 	
-	// Then: Should complete to common prefix and show matches message
+	// Then: The prompt stays and the candidates are listed above it
 	display.Render(editor)
 	minibuffer := editor.Minibuffer()
-	
-	// When there are multiple matches with no further common prefix, 
-	// it should show the matches message instead of completing
-	if minibuffer.Mode() != domain.MinibufferMessage {
-		t.Errorf("Expected MinibufferMessage mode after multiple matches, got %v", minibuffer.Mode())
-	}
-	
-	// Check that matches message is shown
-	minibufferContent := display.GetMinibuffer()
-	if !strings.Contains(minibufferContent, "Matches:") {
-		t.Errorf("Expected matches message, got %q", minibufferContent)
+	if minibuffer.Mode() != domain.MinibufferBufferSelection || minibuffer.Content() != "test-" {
+		t.Errorf("Expected the prompt to stay with %q, got mode %v and %q", "test-", minibuffer.Mode(), minibuffer.Content())
 	}
 	
-	// Should show the matching buffers
+	lines := display.TerminalLines()
 	expectedMatches := []string{"test-buffer-1", "test-buffer-2", "test-file"}
-	for _, match := range expectedMatches {
-		if !strings.Contains(minibufferContent, match) {
-			t.Errorf("Expected match %q in message, got %q", match, minibufferContent)
+	for i, match := range expectedMatches {
+		if line := lines[1+i]; !strings.HasPrefix(line, match) {
+			t.Errorf("Expected candidate %q on line %d, got %q", match, 1+i, line)
 		}
 	}
 }
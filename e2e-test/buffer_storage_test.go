@@ -0,0 +1,199 @@
+package test
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+// sliceLines is the previous []string based line storage, kept as a
+// reference model and as the baseline for the benchmarks below
+type sliceLines struct {
+	content []string
+	cursor  domain.Position
+}
+
+func (s *sliceLines) insertNewline() {
+	line := s.content[s.cursor.Row]
+	newContent := make([]string, 0, len(s.content)+1)
+	newContent = append(newContent, s.content[:s.cursor.Row]...)
+	newContent = append(newContent, line[:s.cursor.Col], line[s.cursor.Col:])
+	newContent = append(newContent, s.content[s.cursor.Row+1:]...)
+	s.content = newContent
+	s.cursor = domain.Position{Row: s.cursor.Row + 1, Col: 0}
+}
+
+func (s *sliceLines) insertChar(ch rune) {
+	line := s.content[s.cursor.Row]
+	s.content[s.cursor.Row] = line[:s.cursor.Col] + string(ch) + line[s.cursor.Col:]
+	s.cursor.Col += utf8.RuneLen(ch)
+}
+
+func (s *sliceLines) deleteBackward() {
+	if s.cursor.Col > 0 {
+		line := s.content[s.cursor.Row]
+		_, size := utf8.DecodeLastRuneInString(line[:s.cursor.Col])
+		s.content[s.cursor.Row] = line[:s.cursor.Col-size] + line[s.cursor.Col:]
+		s.cursor.Col -= size
+		return
+	}
+	if s.cursor.Row == 0 {
+		return
+	}
+	prev := s.content[s.cursor.Row-1]
+	s.content[s.cursor.Row-1] = prev + s.content[s.cursor.Row]
+	newContent := make([]string, 0, len(s.content)-1)
+	newContent = append(newContent, s.content[:s.cursor.Row]...)
+	newContent = append(newContent, s.content[s.cursor.Row+1:]...)
+	s.content = newContent
+	s.cursor = domain.Position{Row: s.cursor.Row - 1, Col: len(prev)}
+}
+
+// largeText returns n numbered lines joined by newlines
+func largeText(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d: the quick brown fox jumps over the lazy dog", i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func largeBuffer(n int) *domain.Buffer {
+	buffer := domain.NewBuffer("large")
+	buffer.InsertString(largeText(n))
+	return buffer
+}
+
+/**
+ * @spec buffer/line_storage_model
+ * @scenario 大量のランダム編集後の内容の一致
+ * @description 行ストレージに対するランダムな挿入・改行・削除が、単純な []string 実装と同じ結果になる
+ * @given 200行のバッファと同じ内容の []string モデル
+ * @when ランダムな位置で文字挿入、改行、後退削除を2000回行う
+ * @then Content()、LineCount()、Line() がモデルと一致する
+ * @implementation domain/rope.go, domain/buffer.go
+ */
+func TestBufferLineStorageMatchesSliceModel(t *testing.T) {
+	buffer := largeBuffer(200)
+	model := &sliceLines{content: strings.Split(largeText(200), "\n")}
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		row := rng.Intn(len(model.content))
+		line := model.content[row]
+		col := rng.Intn(len(line) + 1)
+		for col < len(line) && !utf8.RuneStart(line[col]) {
+			col--
+		}
+		pos := domain.Position{Row: row, Col: col}
+		model.cursor = pos
+		buffer.SetCursor(pos)
+
+		switch rng.Intn(3) {
+		case 0:
+			model.insertChar('あ')
+			buffer.InsertChar('あ')
+		case 1:
+			model.insertNewline()
+			buffer.InsertChar('\n')
+		case 2:
+			// 行頭での後退削除は行の結合になる
+			model.deleteBackward()
+			buffer.DeleteBackward()
+		}
+
+		if buffer.Cursor() != model.cursor {
+			t.Fatalf("Step %d: cursor mismatch, got %v want %v", i, buffer.Cursor(), model.cursor)
+		}
+	}
+
+	if buffer.LineCount() != len(model.content) {
+		t.Fatalf("Expected %d lines, got %d", len(model.content), buffer.LineCount())
+	}
+	content := buffer.Content()
+	for row, line := range model.content {
+		if content[row] != line || buffer.Line(row) != line {
+			t.Fatalf("Line %d mismatch: got %q want %q", row, buffer.Line(row), line)
+		}
+	}
+	if lines := buffer.Lines(10, 20); len(lines) != 10 || lines[0] != model.content[10] || lines[9] != model.content[19] {
+		t.Errorf("Lines(10, 20) does not match the model")
+	}
+}
+
+// Benchmarks: editing near the top of a 100k line buffer
+
+const benchmarkLines = 100000
+
+func BenchmarkInsertNewlineTop(b *testing.B) {
+	b.Run("rope", func(b *testing.B) {
+		buffer := largeBuffer(benchmarkLines)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			buffer.SetCursor(domain.Position{Row: 10, Col: 4})
+			buffer.InsertChar('\n')
+			buffer.DeleteBackward()
+		}
+	})
+	b.Run("slice", func(b *testing.B) {
+		model := &sliceLines{content: strings.Split(largeText(benchmarkLines), "\n")}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			model.cursor = domain.Position{Row: 10, Col: 4}
+			model.insertNewline()
+			model.deleteBackward()
+		}
+	})
+}
+
+func BenchmarkInsertCharTop(b *testing.B) {
+	b.Run("rope", func(b *testing.B) {
+		buffer := largeBuffer(benchmarkLines)
+		buffer.SetCursor(domain.Position{Row: 10, Col: 0})
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			buffer.InsertChar('x')
+			buffer.DeleteBackward()
+		}
+	})
+	b.Run("slice", func(b *testing.B) {
+		model := &sliceLines{content: strings.Split(largeText(benchmarkLines), "\n")}
+		model.cursor = domain.Position{Row: 10, Col: 0}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			model.insertChar('x')
+			model.deleteBackward()
+		}
+	})
+}
+
+func BenchmarkLineLookup(b *testing.B) {
+	b.Run("rope", func(b *testing.B) {
+		buffer := largeBuffer(benchmarkLines)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = buffer.Line(i % benchmarkLines)
+		}
+	})
+	b.Run("slice", func(b *testing.B) {
+		model := &sliceLines{content: strings.Split(largeText(benchmarkLines), "\n")}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = model.content[i%benchmarkLines]
+		}
+	})
+}
+
+func BenchmarkContentAfterEdit(b *testing.B) {
+	buffer := largeBuffer(benchmarkLines)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buffer.SetCursor(domain.Position{Row: 10, Col: 0})
+		buffer.InsertChar('x')
+		_ = buffer.Content()
+	}
+}
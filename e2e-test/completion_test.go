@@ -0,0 +1,282 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+// completionTexts returns the candidates shown in the completion list
+func completionTexts(editor *domain.Editor) []string {
+	list, _, _ := editor.Minibuffer().CompletionList(100)
+	texts := make([]string, len(list))
+	for i, candidate := range list {
+		texts[i] = candidate.Text
+	}
+	return texts
+}
+
+/**
+ * @spec completion/styles
+ * @scenario M-x のコマンド名の補完とマッチの方式
+ * @description M-x で TAB を押すと、候補が共通する部分まで入力が補完され、候補が複数あればミニバッファの上に一覧が表示される。一覧は入力に合わせて絞り込まれる。マッチの方式は completion-styles に並べた順に試され、前方一致 (prefix)、部分一致 (substring)、文字が順に現れるもの (flex) を選べる
+ * @given 既定の設定のエディタ
+ * @when M-x で "forward" を入力して TAB を押して "w" を入力し、"buf" で TAB を押し、completion-styles を flex にして "wch" で TAB を押す
+ * @then "forward-" まで補完されて forward- で始まるコマンドが並び、"w" の入力で forward-word に絞られる。"buf" は buffer を含むコマンドに、"wch" は w、c、h が近くに現れるもの (switch-to-buffer) から順に並ぶ
+ * @implementation domain/completion.go, domain/minibuffer.go
+ */
+func TestCompletionStyles(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	minibuffer := editor.Minibuffer()
+
+	pressKey(editor, "x", false, true)
+	typeString(editor, "forward")
+	pressTab(editor)
+	if got := minibuffer.Content(); got != "forward-" {
+		t.Errorf("Expected completion to the common prefix, got %q", got)
+	}
+	if got := strings.Join(completionTexts(editor), " "); got != "forward-char forward-paragraph forward-word" {
+		t.Errorf("Expected the forward- commands, got %q", got)
+	}
+	// 一覧は入力に合わせて絞り込まれる
+	typeString(editor, "w")
+	if got := strings.Join(completionTexts(editor), " "); got != "forward-word" {
+		t.Errorf("Expected the list to follow the input, got %q", got)
+	}
+	pressKey(editor, "g", true, false)
+
+	// 前方一致がなければ部分一致
+	pressKey(editor, "x", false, true)
+	typeString(editor, "buf")
+	pressTab(editor)
+	if got := minibuffer.Content(); got != "buf" {
+		t.Errorf("Expected substring matches to leave the input, got %q", got)
+	}
+	for _, name := range completionTexts(editor) {
+		if !strings.Contains(name, "buf") {
+			t.Errorf("Expected only commands containing buf, got %q", name)
+		}
+	}
+	if texts := completionTexts(editor); len(texts) < 2 {
+		t.Errorf("Expected several buffer commands, got %q", texts)
+	}
+	pressKey(editor, "g", true, false)
+
+	// flex は文字が近くに現れるものから並ぶ
+	editor.SetOption("completion-styles", "flex")
+	pressKey(editor, "x", false, true)
+	typeString(editor, "wch")
+	pressTab(editor)
+	want := "switch-to-buffer backward-char delete-backward-char forward-char"
+	if got := strings.Join(completionTexts(editor), " "); got != want {
+		t.Errorf("Expected the flex matches %q, got %q", want, got)
+	}
+}
+
+/**
+ * @spec completion/selection
+ * @scenario 候補の選択とキーバインドの注釈
+ * @description 候補の一覧では C-n と C-p で候補を選べる。TAB は選んだ候補を入力し、Enter は選んだ候補で確定する。コマンド名の候補には、そのコマンドを実行するキーが注釈として並ぶ
+ * @given 既定の設定のエディタとテキストのあるバッファ
+ * @when M-x で "forward-" を入力して C-n、C-n、C-p、C-p を押し、描画してから C-n で選んで Enter を押す
+ * @then 選択は先頭から進み、先頭の前では末尾へ回り、一覧には forward-char の横に C-f が表示され、Enter で選んだ forward-char が実行される
+ * @implementation domain/completion.go, cli/display.go
+ */
+func TestCompletionSelection(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "hello")
+	buffer.SetCursor(domain.Position{Row: 0, Col: 0})
+	minibuffer := editor.Minibuffer()
+
+	pressKey(editor, "x", false, true)
+	typeString(editor, "forward-")
+	steps := []struct {
+		key  string
+		want string
+	}{
+		{"n", "forward-char"},
+		{"n", "forward-paragraph"},
+		{"p", "forward-char"},
+		{"p", "forward-word"}, // 先頭の前は末尾
+	}
+	for i, step := range steps {
+		pressKey(editor, step.key, true, false)
+		list, selected, ok := minibuffer.CompletionList(10)
+		if !ok || selected < 0 || list[selected].Text != step.want {
+			t.Errorf("Step %d (C-%s): expected %q to be selected, got %v at %d", i, step.key, step.want, list, selected)
+		}
+	}
+
+	// 注釈にキーバインドが表示される
+	display := NewMockDisplay(40, 10)
+	display.Render(editor)
+	lines := display.TerminalLines()
+	if line := lines[6]; !strings.HasPrefix(line, "forward-char") || !strings.Contains(line, "C-f") {
+		t.Errorf("Expected forward-char annotated with C-f, got %q", line)
+	}
+	if line := lines[9]; !strings.HasPrefix(line, "M-x forward-") {
+		t.Errorf("Expected the prompt below the list, got %q", line)
+	}
+
+	// TAB は選んだ候補を入力する
+	pressKey(editor, "n", true, false)
+	pressTab(editor)
+	if got := minibuffer.Content(); got != "forward-char" {
+		t.Errorf("Expected TAB to insert the selection, got %q", got)
+	}
+	pressEnter(editor)
+	if cursor := buffer.Cursor(); cursor != (domain.Position{Row: 0, Col: 1}) {
+		t.Errorf("Expected forward-char to run, got cursor %+v", cursor)
+	}
+
+	// Enter は選んだ候補で確定する
+	pressKey(editor, "x", false, true)
+	typeString(editor, "end-of-l")
+	pressKey(editor, "n", true, false)
+	pressEnter(editor)
+	if cursor := buffer.Cursor(); cursor != (domain.Position{Row: 0, Col: 5}) {
+		t.Errorf("Expected end-of-line to run, got cursor %+v", cursor)
+	}
+}
+
+/**
+ * @spec completion/find_file
+ * @scenario ファイル名と書き込み先の補完
+ * @description C-x C-f と C-x C-w では、入力したディレクトリの中のファイル名を補完する。ディレクトリの候補は / で終わる
+ * @given main.go、make.sh、src ディレクトリのある一時ディレクトリ
+ * @when C-x C-f でディレクトリと "mai" を入力して TAB を押して開き、C-x C-w で "s"、C-x C-f で "m" を入力して TAB を押す
+ * @then "main.go" と "src/" まで補完され、"m" では main.go と make.sh が一覧に並ぶ
+ * @implementation domain/completion.go, domain/command.go
+ */
+func TestFindFileCompletion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "make.sh"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	minibuffer := editor.Minibuffer()
+
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "f", true, false)
+	typeString(editor, dir+"/mai")
+	pressTab(editor)
+	if got := minibuffer.Content(); got != dir+"/main.go" {
+		t.Errorf("Expected main.go, got %q", got)
+	}
+	pressEnter(editor)
+	if got := editor.CurrentBuffer().Filepath(); got != dir+"/main.go" {
+		t.Errorf("Expected main.go to be visited, got %q", got)
+	}
+
+	// C-x C-w でも補完する (プロンプトは main.go のディレクトリから始まる)
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "w", true, false)
+	typeString(editor, "s")
+	pressTab(editor)
+	if got := minibuffer.Content(); got != dir+"/src/" {
+		t.Errorf("Expected the directory with a slash, got %q", got)
+	}
+	pressKey(editor, "g", true, false)
+
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "f", true, false)
+	typeString(editor, dir+"/m")
+	pressTab(editor)
+	if got := strings.Join(completionTexts(editor), " "); got != "main.go make.sh" {
+		t.Errorf("Expected both m files, got %q", got)
+	}
+}
+
+/**
+ * @spec completion/common_prefix_multibyte
+ * @scenario 日本語の候補の共通部分までの補完
+ * @description TAB で補完する共通部分は文字単位で求める。先頭のバイトが同じでも違う文字なら、その文字の前で止める
+ * @given "日本.txt" と "日曜.txt" のバッファ
+ * @when C-x b で "日" を入力して TAB を押す
+ * @then 入力は "日" のままで、壊れた文字が入らない
+ * @implementation domain/completion.go
+ */
+func TestCompletionCommonPrefixMultibyte(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	editor.GetOrCreateBuffer("日本.txt")
+	editor.GetOrCreateBuffer("日曜.txt")
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "b")
+	typeString(editor, "日")
+	pressTab(editor)
+	if got := editor.Minibuffer().Content(); got != "日" {
+		t.Errorf("Expected the input to stay at a character boundary, got %q", got)
+	}
+}
+
+/**
+ * @spec completion/lua_completing_read
+ * @scenario Lua の gmacs.completing_read
+ * @description gmacs.completing_read(prompt, collection, callback, options) は collection の候補で補完しながら文字列を読み、callback に渡す。collection は文字列の配列か、入力を受け取って配列を返す関数で、options.annotate で注釈を付けられる
+ * @given 果物の名前を補完して読み、結果をオプションに入れるコマンドを定義した設定
+ * @when コマンドを実行して "b" で TAB を押し、選んで Enter を押す
+ * @then 候補に注釈が付き、選んだ名前が callback に渡される
+ * @implementation lua-config/completion.go, domain/completion.go
+ */
+func TestLuaCompletingRead(t *testing.T) {
+	editor := NewEditorWithLua(`
+local colors = { apple = "red", banana = "yellow", blueberry = "blue" }
+gmacs.defun("pick-fruit", function()
+    gmacs.completing_read("Fruit: ", { "apple", "banana", "blueberry" }, function(fruit)
+        gmacs.set_option("fruit", fruit)
+    end, { annotate = function(fruit) return colors[fruit] end })
+end)
+gmacs.defun("pick-number", function()
+    gmacs.completing_read("Number: ", function(input)
+        return { input .. "1", input .. "2" }
+    end, function(n)
+        gmacs.set_option("number", n)
+    end)
+end)
+`)
+	defer editor.Cleanup()
+
+	runCommand(editor, "pick-fruit")
+	if got := editor.Minibuffer().Prompt(); got != "Fruit: " {
+		t.Fatalf("Expected the Lua prompt, got %q", got)
+	}
+	typeString(editor, "b")
+	pressTab(editor)
+	list, _, _ := editor.Minibuffer().CompletionList(10)
+	if len(list) != 2 || list[0].Text != "banana" || list[0].Annotation != "yellow" || list[1].Annotation != "blue" {
+		t.Errorf("Expected annotated banana and blueberry, got %v", list)
+	}
+	pressKey(editor, "p", true, false)
+	pressEnter(editor)
+	if got := optionString(t, editor, "fruit"); got != "blueberry" {
+		t.Errorf("Expected blueberry, got %q", got)
+	}
+
+	// 関数の collection は入力から候補を作る
+	runCommand(editor, "pick-number")
+	typeString(editor, "4")
+	pressTab(editor)
+	if got := editor.Minibuffer().Content(); got != "4" {
+		t.Errorf("Expected the input to stay, got %q", got)
+	}
+	pressKey(editor, "n", true, false)
+	pressKey(editor, "n", true, false)
+	pressEnter(editor)
+	if got := optionString(t, editor, "number"); got != "42" {
+		t.Errorf("Expected 42, got %q", got)
+	}
+}
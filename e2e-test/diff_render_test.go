@@ -0,0 +1,133 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// freshTerminalLines renders editor on a new display, as a full repaint
+func freshTerminalLines(editor *domain.Editor, width, height int) []string {
+	display := NewMockDisplay(width, height)
+	display.Render(editor)
+	return display.TerminalLines()
+}
+
+// assertSameScreen checks that the incrementally updated terminal shows the
+// same as a full repaint
+func assertSameScreen(t *testing.T, step string, display *MockDisplay, editor *domain.Editor) {
+	t.Helper()
+	width, height := display.Size()
+	want := freshTerminalLines(editor, width, height)
+	got := display.TerminalLines()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: line %d differs from a full repaint\n got: %q\nwant: %q", step, i, got[i], want[i])
+		}
+	}
+}
+
+/**
+ * @spec display/diff_rendering
+ * @scenario 差分描画
+ * @description 最初の描画では画面を消去して全体を描き、以降は前回のフレームから変化したセルだけを送る。描画中はカーソルを隠す
+ * @given 20x6 のディスプレイと "hello" を入力したバッファ
+ * @when 1文字入力して再描画し、何も変えずに再描画する
+ * @then 2回目以降は画面消去を含まず、変化した部分だけを送る。端末の表示は全体を描き直した場合と一致する
+ * @implementation cli/display.go, cli/screen.go
+ */
+func TestDiffRendering(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	editor.HandleEvent(events.ResizeEventData{Width: 20, Height: 6})
+	display := NewMockDisplay(20, 6)
+
+	typeString(editor, "hello")
+	display.Render(editor)
+	first := display.EscapeStream()
+	if !strings.HasPrefix(first, "\033[?25l") || !strings.HasSuffix(first, "\033[?25h") {
+		t.Errorf("Expected the cursor to be hidden while drawing, got %q", first)
+	}
+	if !strings.Contains(first, "\033[2J") {
+		t.Errorf("Expected the first frame to clear the screen, got %q", first)
+	}
+	if line := strings.TrimRight(display.TerminalLines()[0], " "); line != "hello" {
+		t.Errorf("Expected 'hello' on the terminal, got %q", line)
+	}
+
+	typeString(editor, "!")
+	display.Render(editor)
+	second := display.EscapeStream()
+	if strings.Contains(second, "\033[2J") {
+		t.Errorf("Expected no screen clear after the first frame, got %q", second)
+	}
+	if !strings.Contains(second, "\033[1;6H!") {
+		t.Errorf("Expected only the new character to be written, got %q", second)
+	}
+	if len(second) >= len(first)/2 {
+		t.Errorf("Expected a small update, got %d bytes after a %d byte frame: %q", len(second), len(first), second)
+	}
+	assertSameScreen(t, "after typing", display, editor)
+	if row, col := display.TerminalCursor(); row != 0 || col != 6 {
+		t.Errorf("Expected the terminal cursor at (0, 6), got (%d, %d)", row, col)
+	}
+
+	// 何も変わらなければカーソルの移動だけ
+	display.Render(editor)
+	if got := display.EscapeStream(); got != "\033[?25l\033[1;7H\033[?25h" {
+		t.Errorf("Expected only the cursor to be positioned, got %q", got)
+	}
+}
+
+/**
+ * @spec display/diff_rendering_wide
+ * @scenario 全角文字と強調表示を含む差分描画
+ * @description 全角文字を半角文字で置き換えたり、リージョンの強調表示が変わったりしても、差分描画の結果は全体の描き直しと一致する
+ * @given 20x6 のディスプレイ
+ * @when 日本語の行を英字に書き換え、リージョンを設定・解除し、ウィンドウを分割し、サイズを変更する
+ * @then 各段階で端末の表示が全体を描き直した場合と一致し、サイズ変更後は画面を消去して描き直す
+ * @implementation cli/display.go, cli/screen.go
+ */
+func TestDiffRenderingWideAndStyled(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	editor.HandleEvent(events.ResizeEventData{Width: 20, Height: 6})
+	display := NewMockDisplay(20, 6)
+	buffer := editor.CurrentBuffer()
+
+	typeString(editor, "あいうえお")
+	display.Render(editor)
+
+	// 全角文字を半角文字で書き換える
+	buffer.Clear()
+	typeString(editor, "abcあxyz")
+	display.Render(editor)
+	assertSameScreen(t, "after replacing wide characters", display, editor)
+
+	// リージョンの強調表示はスタイル付きで送られる
+	pressKey(editor, "a", true, false)
+	pressKey(editor, "SPC", true, false)
+	pressKey(editor, "e", true, false)
+	display.Render(editor)
+	if !strings.Contains(display.EscapeStream(), "\033[7m") {
+		t.Errorf("Expected the region to be drawn in reverse video, got %q", display.EscapeStream())
+	}
+	pressKey(editor, "g", true, false)
+	display.Render(editor)
+	assertSameScreen(t, "after deactivating the region", display, editor)
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "3")
+	display.Render(editor)
+	assertSameScreen(t, "after splitting the window", display, editor)
+
+	editor.HandleEvent(events.ResizeEventData{Width: 30, Height: 8})
+	display.Resize(30, 8)
+	display.Render(editor)
+	if !strings.Contains(display.EscapeStream(), "\033[2J") {
+		t.Errorf("Expected a full repaint after resizing, got %q", display.EscapeStream())
+	}
+	assertSameScreen(t, "after resizing", display, editor)
+}
@@ -0,0 +1,206 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+/**
+ * @spec edit/kill_word
+ * @scenario 単語単位の切り取り
+ * @description M-d は単語の末尾まで、M-Backspace は単語の先頭までを切り取る。連続した切り取りはキルリングの1つの項目にまとめられる
+ * @given "hello world 日本語です" と入力したバッファ
+ * @when 行頭で M-d を2回、行末で M-Backspace を2回押し、C-y で貼り付ける
+ * @then 前方の切り取りは後ろに、後方の切り取りは前に連結され、日本語は文字種の境界で区切られる
+ * @implementation domain/edit_commands.go, domain/kill_ring.go
+ */
+func TestKillWord(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "hello world 日本語です")
+
+	pressKey(editor, "d", false, true)
+	pressKey(editor, "d", false, true)
+	if got := buffer.Line(0); got != " 日本語です" {
+		t.Errorf("M-d M-d: expected %q, got %q", " 日本語です", got)
+	}
+	if text, _ := editor.KillRing().Current(); text != "hello world" {
+		t.Errorf("Expected the kills to be merged into %q, got %q", "hello world", text)
+	}
+
+	pressKey(editor, "e", true, false)
+	pressKey(editor, "Backspace", false, true)
+	pressKey(editor, "Backspace", false, true)
+	if got := buffer.Line(0); got != " " {
+		t.Errorf("M-Backspace twice: expected %q, got %q", " ", got)
+	}
+	if text, _ := editor.KillRing().Current(); text != "日本語です" {
+		t.Errorf("Expected backward kills to be prepended into %q, got %q", "日本語です", text)
+	}
+
+	pressKey(editor, "y", true, false)
+	if got := buffer.Line(0); got != " 日本語です" {
+		t.Errorf("C-y: expected %q, got %q", " 日本語です", got)
+	}
+}
+
+/**
+ * @spec edit/transpose
+ * @scenario 文字・単語・行の入れ替え
+ * @description C-t は前後の文字を、M-t は前後の単語を、C-x C-t は前後の行を入れ替えて先へ進む。行末の C-t は直前の2文字を入れ替える
+ * @given 英語と日本語の文字・単語・行
+ * @when C-t、M-t、C-x C-t を実行する
+ * @then マルチバイト文字も壊さずに入れ替えられ、繰り返すと文字や行を先へ運ぶ
+ * @implementation domain/edit_commands.go, domain/buffer.go
+ */
+func TestTranspose(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "abc", "あいう", "hello world", "one", "two", "three")
+
+	// 文字の入れ替えを繰り返すと "a" が後ろへ運ばれる
+	buffer.SetCursor(domain.Position{Row: 0, Col: 1})
+	pressKey(editor, "t", true, false)
+	pressKey(editor, "t", true, false)
+	if got, col := buffer.Line(0), buffer.Cursor().Col; got != "bca" || col != 3 {
+		t.Errorf("C-t C-t: expected \"bca\" at column 3, got %q at column %d", got, col)
+	}
+
+	// 行末では直前の2文字を入れ替える
+	pressKey(editor, "n", true, false)
+	pressKey(editor, "e", true, false)
+	pressKey(editor, "t", true, false)
+	if got := buffer.Line(1); got != "あうい" {
+		t.Errorf("C-t at end of line: expected %q, got %q", "あうい", got)
+	}
+
+	// 単語の途中で M-t
+	buffer.SetCursor(domain.Position{Row: 2, Col: 2})
+	pressKey(editor, "t", false, true)
+	if got, col := buffer.Line(2), buffer.Cursor().Col; got != "world hello" || col != 11 {
+		t.Errorf("M-t: expected \"world hello\" at column 11, got %q at column %d", got, col)
+	}
+
+	// C-x C-t を繰り返すと "one" が下へ運ばれ、最終行では改行が追加される
+	buffer.SetCursor(domain.Position{Row: 4, Col: 0})
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "t", true, false)
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "t", true, false)
+	want := []string{"bca", "あうい", "world hello", "two", "three", "one", ""}
+	if got := buffer.Content(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("C-x C-t twice: expected %q, got %q", want, got)
+	}
+	if row := buffer.Cursor().Row; row != 6 {
+		t.Errorf("Expected the cursor on row 6 after transposing, got %d", row)
+	}
+}
+
+/**
+ * @spec edit/case
+ * @scenario 単語・リージョンの大文字小文字変換
+ * @description M-u / M-l / M-c はカーソルから単語の末尾までを変換して先へ進む。負の引数では直前の単語を変換し、カーソルは動かない。C-x C-u / C-x C-l はリージョンを変換する
+ * @given "hello WORLD foo-bar baz" と入力したバッファ
+ * @when M-u、M-l、C-u 2 M-c、M-- M-u、リージョンを選択して C-x C-l を実行する
+ * @then 各単語が変換され、リージョンの両端のマークとカーソルの位置が保たれる
+ * @implementation domain/edit_commands.go
+ */
+func TestCaseConversion(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "hello WORLD foo-bar baz")
+
+	pressKey(editor, "u", false, true)
+	pressKey(editor, "l", false, true)
+	pressKey(editor, "u", true, false)
+	typeString(editor, "2")
+	pressKey(editor, "c", false, true)
+	if got, col := buffer.Line(0), buffer.Cursor().Col; got != "HELLO world Foo-Bar baz" || col != 19 {
+		t.Errorf("Expected \"HELLO world Foo-Bar baz\" at column 19, got %q at column %d", got, col)
+	}
+
+	// 負の引数ではカーソルは動かない
+	pressKey(editor, "-", false, true)
+	pressKey(editor, "u", false, true)
+	if got, col := buffer.Line(0), buffer.Cursor().Col; got != "HELLO world Foo-BAR baz" || col != 19 {
+		t.Errorf("M-- M-u: expected \"HELLO world Foo-BAR baz\" at column 19, got %q at column %d", got, col)
+	}
+
+	// リージョン
+	pressKey(editor, "a", true, false)
+	pressKey(editor, "SPC", true, false)
+	pressKey(editor, "e", true, false)
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "l", true, false)
+	if got := buffer.Line(0); got != "hello world foo-bar baz" {
+		t.Errorf("C-x C-l: expected the region downcased, got %q", got)
+	}
+	if mark, _ := buffer.Mark(); mark.Col != 0 || buffer.Cursor().Col != 23 {
+		t.Errorf("Expected the mark at column 0 and the cursor at 23, got %d and %d", mark.Col, buffer.Cursor().Col)
+	}
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "u", true, false)
+	if got := buffer.Line(0); got != "HELLO WORLD FOO-BAR BAZ" {
+		t.Errorf("C-x C-u: expected the region upcased, got %q", got)
+	}
+}
+
+/**
+ * @spec edit/lines
+ * @scenario 行の結合・挿入・空行の削除
+ * @description M-^ は前の行と空白1つで結合し、日本語どうしでは空白を入れない。C-o はカーソルの後に改行を挿入する。C-x C-o は連続する空行を1行に、単独の空行は削除し、空行でない行では後続の空行を削除する
+ * @given インデントされた行、日本語の行、空行を含むバッファ
+ * @when M-^、C-u M-^、C-o、C-x C-o を実行する
+ * @then 行が結合・挿入・削除される
+ * @implementation domain/edit_commands.go
+ */
+func TestLineEditing(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "func(  ", "    arg)", "日本語の", "　文章です", "x", "", "", "", "y", "", "z")
+
+	// M-^ : "(" の後と ")" の前には空白を入れない
+	buffer.SetCursor(domain.Position{Row: 1, Col: 6})
+	pressKey(editor, "^", false, true)
+	if got, cursor := buffer.Line(0), buffer.Cursor(); got != "func(arg)" || cursor.Row != 0 || cursor.Col != 5 {
+		t.Errorf("M-^: expected \"func(arg)\" with the cursor at (0, 5), got %q at (%d, %d)", got, cursor.Row, cursor.Col)
+	}
+
+	// C-u M-^ は次の行を結合する。日本語どうしは空白なし
+	buffer.SetCursor(domain.Position{Row: 1, Col: 0})
+	pressKey(editor, "u", true, false)
+	pressKey(editor, "^", false, true)
+	if got := buffer.Line(1); got != "日本語の文章です" {
+		t.Errorf("C-u M-^: expected %q, got %q", "日本語の文章です", got)
+	}
+
+	// C-o
+	buffer.SetCursor(domain.Position{Row: 1, Col: len("日本語の")})
+	pressKey(editor, "o", true, false)
+	if got, cursor := buffer.Line(1), buffer.Cursor(); got != "日本語の" || buffer.Line(2) != "文章です" || cursor.Row != 1 {
+		t.Errorf("C-o: expected the line split after the cursor, got %q / %q with the cursor on row %d", got, buffer.Line(2), cursor.Row)
+	}
+
+	// C-x C-o: 連続する空行は1行に、単独の空行は削除
+	// 行: func(arg) / 日本語の / 文章です / x / "" / "" / "" / y / "" / z
+	buffer.SetCursor(domain.Position{Row: 5, Col: 0})
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "o", true, false)
+	buffer.SetCursor(domain.Position{Row: 6, Col: 0})
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "o", true, false)
+	want := []string{"func(arg)", "日本語の", "文章です", "x", "", "y", "z"}
+	if got := buffer.Content(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("C-x C-o: expected %q, got %q", want, got)
+	}
+
+	// 空行でない行では後続の空行を削除
+	buffer.SetCursor(domain.Position{Row: 3, Col: 0})
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "o", true, false)
+	if got := buffer.Line(4); got != "y" {
+		t.Errorf("C-x C-o on a non-blank line: expected the blank line deleted, got %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/cli"
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+/**
+ * @spec display/color_profile
+ * @scenario 端末の色数の判定
+ * @description COLORTERM と TERM から、端末が表示できる色数 (なし、16色、256色、24ビット) を判定する
+ * @given さまざまな COLORTERM と TERM の組み合わせ
+ * @when DetectColorProfile を呼ぶ
+ * @then COLORTERM=truecolor / 24bit や *-direct は24ビット、*256color は256色、dumb は色なし、それ以外は16色になる
+ * @implementation cli/face.go
+ */
+func TestColorProfileDetection(t *testing.T) {
+	cases := []struct {
+		colorterm string
+		term      string
+		want      cli.ColorProfile
+	}{
+		{"truecolor", "xterm-256color", cli.ColorTrueColor},
+		{"24bit", "screen", cli.ColorTrueColor},
+		{"", "xterm-direct", cli.ColorTrueColor},
+		{"", "xterm-256color", cli.Color256},
+		{"", "screen-256color", cli.Color256},
+		{"", "xterm", cli.Color16},
+		{"", "", cli.Color16},
+		{"", "dumb", cli.ColorNone},
+	}
+	for _, c := range cases {
+		env := map[string]string{"COLORTERM": c.colorterm, "TERM": c.term}
+		if got := cli.DetectColorProfile(func(key string) string { return env[key] }); got != c.want {
+			t.Errorf("COLORTERM=%q TERM=%q: expected profile %d, got %d", c.colorterm, c.term, c.want, got)
+		}
+	}
+}
+
+// renderWithProfile renders editor on a new 30x10 display with the given
+// colors and returns the escape sequences of the full frame
+func renderWithProfile(editor *domain.Editor, profile cli.ColorProfile) string {
+	display := NewMockDisplay(30, 10)
+	display.SetColorProfile(profile)
+	display.Render(editor)
+	return display.EscapeStream()
+}
+
+/**
+ * @spec display/faces
+ * @scenario face の設定と色数に応じた出力
+ * @description gmacs.set_face で設定した face の色と属性で描画される。RGB の色は端末の色数に応じて 24ビット、256色、16色の近い色に変換され、色を表示できない端末では属性だけが送られる
+ * @given キーワードを強調表示するモードのバッファと、キーワードを太字の #ff0000 にする設定
+ * @when 各色数のディスプレイで描画する
+ * @then 24ビットでは RGB、256色では 196 番、16色では明るい赤、色なしでは太字だけで描画される。不正な色はエラーになる
+ * @implementation domain/face.go, cli/face.go, lua-config/faces.go
+ */
+func TestSetFace(t *testing.T) {
+	editor := NewEditorWithLua(cLikeMode + `
+gmacs.set_face("keyword", { fg = "#ff0000", bold = true })
+local ok, err = pcall(gmacs.set_face, "comment", { fg = "reddish" })
+gmacs.set_option("face-error", tostring(err))
+`)
+	defer editor.Cleanup()
+	editor.HandleEvent(events.ResizeEventData{Width: 30, Height: 10})
+	if err := editor.ModeManager().SetMajorMode(editor.CurrentBuffer(), "clike-mode"); err != nil {
+		t.Fatal(err)
+	}
+	typeString(editor, "if x")
+
+	cases := []struct {
+		profile cli.ColorProfile
+		want    string
+	}{
+		{cli.ColorTrueColor, "\033[1;38;2;255;0;0mif"},
+		{cli.Color256, "\033[1;38;5;196mif"},
+		{cli.Color16, "\033[1;91mif"},
+		{cli.ColorNone, "\033[1mif"},
+	}
+	for _, c := range cases {
+		if got := renderWithProfile(editor, c.profile); !strings.Contains(got, c.want) {
+			t.Errorf("Profile %d: expected %q in the output, got %q", c.profile, c.want, got)
+		}
+	}
+
+	if got := optionString(t, editor, "face-error"); !strings.Contains(got, "Invalid color: reddish") {
+		t.Errorf("Expected an invalid color error, got %q", got)
+	}
+}
+
+/**
+ * @spec display/themes
+ * @scenario テーマの読み込み
+ * @description gmacs.load_theme で同梱の dark / light テーマを読み込むと、既定の face が置き換わり、画面全体が default face の背景色で描画される。選択中のウィンドウとそれ以外のモードライン、ミニバッファのプロンプトにはそれぞれの face が使われる
+ * @given dark テーマを読み込み、ウィンドウを分割したエディタ
+ * @when 24ビットカラーで描画し、M-x のプロンプトを表示し、存在しないテーマを読み込む
+ * @then 背景色、モードライン、非選択のモードライン、プロンプトがテーマの色で描画され、存在しないテーマはエラーになる
+ * @implementation domain/face.go, domain/theme.go, cli/face.go, cli/display.go
+ */
+func TestLoadTheme(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.load_theme("dark")
+local ok, err = pcall(gmacs.load_theme, "solarized")
+gmacs.set_option("theme-error", tostring(err))
+`)
+	defer editor.Cleanup()
+	editor.HandleEvent(events.ResizeEventData{Width: 30, Height: 10})
+	if theme := editor.Faces().Theme(); theme != "dark" {
+		t.Fatalf("Expected the dark theme to be loaded, got %q", theme)
+	}
+	if got := optionString(t, editor, "theme-error"); !strings.Contains(got, "Unknown theme: solarized") {
+		t.Errorf("Expected an unknown theme error, got %q", got)
+	}
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "2")
+	pressKey(editor, "x", false, true)
+
+	stream := renderWithProfile(editor, cli.ColorTrueColor)
+	expected := map[string]string{
+		"default":            "\033[38;2;211;215;207;48;2;46;52;54m",
+		"mode-line":          "\033[38;2;46;52;54;48;2;211;215;207m *scratch*",
+		"mode-line-inactive": "\033[38;2;238;238;236;48;2;85;87;83m *scratch*",
+		"minibuffer-prompt":  "\033[1;38;2;180;250;112;48;2;46;52;54mM-x ",
+	}
+	for face, want := range expected {
+		if !strings.Contains(stream, want) {
+			t.Errorf("Expected the %s face %q in the output, got %q", face, want, stream)
+		}
+	}
+
+	// 別のテーマを読み込むと face が置き換わる
+	if err := editor.Faces().LoadTheme("light"); err != nil {
+		t.Fatal(err)
+	}
+	if face, _ := editor.Faces().Get(domain.FaceDefault); face.Background != "#ffffff" {
+		t.Errorf("Expected the light theme background, got %q", face.Background)
+	}
+}
@@ -0,0 +1,232 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+// openGoFile writes content to a .go file and visits it
+func openGoFile(t *testing.T, editor *domain.Editor, content string) *domain.Buffer {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	buffer := openFile(t, editor, path)
+	if mode := buffer.MajorMode(); mode == nil || mode.Name() != "go-mode" {
+		t.Fatalf("Expected go-mode for %s, got %v", path, mode)
+	}
+	return buffer
+}
+
+// faceOf returns the face of the text at row, col, "" for none
+func faceOf(buffer *domain.Buffer, row, col int) string {
+	for _, s := range buffer.Highlights(row) {
+		if col >= s.Start && col < s.End {
+			return s.Style
+		}
+	}
+	return ""
+}
+
+/**
+ * @spec modes/go_mode_highlighting
+ * @scenario go-mode の構文強調表示
+ * @description .go ファイルは go-mode で開かれ、go/scanner のトークンに基づいてキーワード、型、組み込み関数、関数名、文字列、コメントが強調表示される。行をまたぐブロックコメントと raw 文字列も正しく扱われる
+ * @given メソッド、組み込み関数、複数行のコメントと raw 文字列を含む Go のソース
+ * @when ファイルを開いて各行の強調表示を調べる
+ * @then 各トークンに対応する face が付き、コメントや文字列の中のキーワードは強調されない
+ * @implementation domain/go_mode.go, domain/syntax.go
+ */
+func TestGoModeHighlighting(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := openGoFile(t, editor, strings.Join([]string{
+		"package main",
+		"/* func in",
+		"   a comment */ var x int",
+		"func (s *Server) Run(n int) error {",
+		"\tq := `select",
+		"func` + \"s\"",
+		"\treturn len(q) == nil",
+		"}",
+	}, "\n")+"\n")
+
+	cases := []struct {
+		row, col int
+		face     string
+	}{
+		{0, 0, "keyword"},        // package
+		{1, 3, "comment"},        // func (コメント内)
+		{2, 5, "comment"},        // コメントの続き
+		{2, 16, "keyword"},       // var
+		{2, 22, "type"},          // int
+		{3, 0, "keyword"},        // func
+		{3, 9, ""},               // Server (レシーバの型名は強調しない)
+		{3, 17, "function-name"}, // Run
+		{3, 28, "type"},          // error
+		{4, 6, "string"},         // raw 文字列の開始
+		{5, 0, "string"},         // raw 文字列の続き (func はキーワードではない)
+		{5, 8, "string"},         // "s"
+		{6, 1, "keyword"},        // return
+		{6, 8, "builtin"},        // len
+		{6, 18, "constant"},      // nil
+	}
+	for _, c := range cases {
+		if got := faceOf(buffer, c.row, c.col); got != c.face {
+			t.Errorf("Line %d column %d (%q): expected face %q, got %q", c.row, c.col, buffer.Line(c.row), c.face, got)
+		}
+	}
+}
+
+/**
+ * @spec modes/go_mode_indent
+ * @scenario go-mode のインデント計算
+ * @description go-mode のインデント関数は、括弧の深さに応じてタブ幅単位のインデントを返す。閉じ括弧と case / default の行は1段浅くなり、raw 文字列の中の行は現在のインデントを保つ
+ * @given switch 文、複数行の引数、raw 文字列を含む Go のソース
+ * @when 各行についてメジャーモードの IndentFunc を呼ぶ
+ * @then 括弧の深さに応じたインデントが返る
+ * @implementation domain/go_mode.go
+ */
+func TestGoModeIndent(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := openGoFile(t, editor, strings.Join([]string{
+		"func f(x int) {",
+		"switch x {",
+		"case 1:",
+		"call(a,",
+		"b)",
+		"default:",
+		"s := `{",
+		"  raw`",
+		"}",
+		"}",
+	}, "\n")+"\n")
+
+	indent := buffer.MajorMode().IndentFunction()
+	want := []int{0, 8, 8, 16, 24, 8, 16, 2, 8, 0}
+	for row, w := range want {
+		if got := indent(buffer, row); got != w {
+			t.Errorf("Line %d (%q): expected indentation %d, got %d", row, buffer.Line(row), w, got)
+		}
+	}
+}
+
+/**
+ * @spec modes/go_mode_tab
+ * @scenario go-mode の TAB はタブでインデントする
+ * @description indent-tabs-mode の既定は true なので、既定の設定の go-mode で TAB を押すと gofmt と同じくタブでインデントされる
+ * @given 既定の設定で開いた関数本体の行がインデントされていない Go のソース
+ * @when 本体の行で TAB を押す
+ * @then 行はタブ1つでインデントされる
+ * @implementation domain/indent.go, domain/go_mode.go
+ */
+func TestGoModeTabIndent(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := openGoFile(t, editor, "func f() {\nx := 1\n}\n")
+
+	buffer.SetCursor(domain.Position{Row: 1, Col: 0})
+	pressTab(editor)
+	assertLines(t, buffer, "func f() {", "\tx := 1", "}")
+}
+
+/**
+ * @spec modes/gofmt
+ * @scenario gofmt-buffer と保存前の整形
+ * @description M-x gofmt-buffer (go-mode では C-c C-f) は go/format でバッファを整形する。構文エラーは行番号付きでミニバッファに表示される。gofmt-before-save オプションを有効にすると保存前に整形され、構文エラーがあっても保存は行われる
+ * @given 整形されていない Go のソース
+ * @when gofmt-buffer を2回実行し、構文エラーを入れて実行し、gofmt-before-save を有効にして保存する
+ * @then バッファが整形され、2回目は整形済みと表示され、構文エラーの行番号が表示され、保存したファイルは整形されている
+ * @implementation domain/go_mode.go, domain/file_commands.go
+ */
+func TestGofmtBuffer(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := openGoFile(t, editor, "package main\nfunc main(){\nx:=1\n_ = x\n}\n")
+	buffer.SetCursor(domain.Position{Row: 2, Col: 1})
+
+	runCommand(editor, "gofmt-buffer")
+	want := []string{"package main", "", "func main() {", "\tx := 1", "\t_ = x", "}"}
+	if got := buffer.Content(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected the buffer to be formatted as %q, got %q", want, got)
+	}
+	if got := editor.Minibuffer().Message(); got != "Applied gofmt" {
+		t.Errorf("Expected 'Applied gofmt', got %q", got)
+	}
+
+	// 整形済みなら何も変えない (C-c C-f)
+	pressKey(editor, "c", true, false)
+	pressKey(editor, "f", true, false)
+	if got := editor.Minibuffer().Message(); got != "Buffer is already gofmted" {
+		t.Errorf("Expected 'Buffer is already gofmted', got %q", got)
+	}
+
+	// 構文エラーは行番号付きで表示される
+	buffer.InsertText(domain.Position{Row: 4, Col: 6}, " +")
+	runCommand(editor, "gofmt-buffer")
+	if got := editor.Minibuffer().Message(); !strings.HasPrefix(got, "Syntax error at line 6:") {
+		t.Errorf("Expected a syntax error at line 6, got %q", got)
+	}
+
+	// 構文エラーがあっても保存はされる
+	editor.SetOption("gofmt-before-save", true)
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "s", true, false)
+	if got := editor.Minibuffer().Message(); !strings.HasPrefix(got, "Wrote ") || !strings.Contains(got, "Syntax error at line 6") {
+		t.Errorf("Expected the save to report the syntax error, got %q", got)
+	}
+	if buffer.IsModified() {
+		t.Error("Expected the buffer to be saved despite the syntax error")
+	}
+
+	// 保存前に整形される
+	buffer.DeleteRegion(domain.Position{Row: 4, Col: 6}, domain.Position{Row: 4, Col: 8})
+	buffer.InsertText(domain.Position{Row: 3, Col: 0}, "  ")
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "s", true, false)
+	data, err := os.ReadFile(buffer.Filepath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != strings.Join(want, "\n")+"\n" {
+		t.Errorf("Expected the saved file to be formatted, got %q", got)
+	}
+}
+
+/**
+ * @spec modes/gofmt_write_file
+ * @scenario C-x C-w での保存前の整形
+ * @description C-x C-w (write-file) も C-x C-s と同じ手順で保存するので、gofmt-before-save が有効なら新しいファイルにも整形して書き込む
+ * @given gofmt-before-save を有効にし、整形されていない Go のソースを開いたエディタ
+ * @when C-x C-w で別の .go ファイルに書き込む
+ * @then 書き込んだファイルとバッファが整形されている
+ * @implementation domain/file_commands.go, domain/minibuffer.go
+ */
+func TestGofmtBeforeWriteFile(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	editor.SetOption("gofmt-before-save", true)
+	buffer := openGoFile(t, editor, "package main\nfunc main(){\n}\n")
+
+	path := filepath.Join(t.TempDir(), "copy.go")
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "w", true, false)
+	typeString(editor, path)
+	pressEnter(editor)
+
+	want := "package main\n\nfunc main() {\n}\n"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("Expected the written file to be formatted, got %q", data)
+	}
+	assertLines(t, buffer, "package main", "", "func main() {", "}")
+}
@@ -0,0 +1,241 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+// optionString returns a string option set by a Lua hook, or "" if unset
+func optionString(t *testing.T, editor *domain.Editor, name string) string {
+	t.Helper()
+	value, err := editor.GetOption(name)
+	if err != nil {
+		return ""
+	}
+	s, _ := value.(string)
+	return s
+}
+
+/**
+ * @spec hooks/after_change
+ * @scenario after-change フックに範囲とテキストが渡される
+ * @description バッファの変更後に after-change フックがバッファオブジェクト、範囲、テキスト、種類を受け取る
+ * @given 変更内容を記録する after-change フックを Lua で登録する
+ * @when "ab" と入力し、C-h で1文字削除する
+ * @then 挿入と削除それぞれについてバッファ名、1始まりの範囲、テキストが記録される
+ * @implementation domain/hooks.go, domain/buffer.go, lua-config/objects.go
+ */
+func TestAfterChangeHook(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.add_hook("after-change", function(buf, s, e, text, kind)
+    gmacs.set_option("change", string.format("%s %s %d:%d-%d:%d %q",
+        buf:name(), kind, s.line, s.col, e.line, e.col, text))
+end)
+`)
+	defer editor.Cleanup()
+
+	typeString(editor, "ab")
+	if got := optionString(t, editor, "change"); got != `*scratch* insert 1:2-1:3 "b"` {
+		t.Errorf("Unexpected after-change for insertion: %s", got)
+	}
+
+	pressKey(editor, "h", true, false)
+	if got := optionString(t, editor, "change"); got != `*scratch* delete 1:2-1:3 "b"` {
+		t.Errorf("Unexpected after-change for deletion: %s", got)
+	}
+}
+
+/**
+ * @spec hooks/no_recursion
+ * @scenario フック内の変更で同じフックが再帰しない
+ * @description after-change フックがバッファを変更しても、そのフックは再度呼ばれない
+ * @given 変更のたびに "!" を挿入する after-change フック
+ * @when "x" と入力する
+ * @then "x!" となり、無限ループにならない
+ * @implementation domain/editor.go
+ */
+func TestAfterChangeHookDoesNotRecurse(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+
+	// フック内でバッファを変更する
+	editor.AddHook("after-change", func(args ...interface{}) error {
+		editor.CurrentBuffer().InsertString("!")
+		return nil
+	})
+
+	typeString(editor, "x")
+	if got := editor.CurrentBuffer().Content()[0]; got != "x!" {
+		t.Errorf("Expected 'x!', got %q", got)
+	}
+}
+
+/**
+ * @spec hooks/save
+ * @scenario 保存前後のフック
+ * @description before-save と after-save がバッファオブジェクトを受け取り、保存の前後で呼ばれる
+ * @given ファイルを開いて編集し、保存状態を記録するフックを登録する
+ * @when C-x C-s で保存する
+ * @then before-save では変更あり、after-save では変更なしとして同じファイルパスが渡される
+ * @implementation domain/file_commands.go, domain/hooks.go
+ */
+func TestSaveHooks(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.add_hook("before-save", function(buf)
+    gmacs.set_option("before-save", buf:filepath() .. " " .. tostring(buf:is_modified()))
+end)
+gmacs.add_hook("after-save", function(buf)
+    gmacs.set_option("after-save", buf:filepath() .. " " .. tostring(buf:is_modified()))
+end)
+`)
+	defer editor.Cleanup()
+
+	path := filepath.Join(t.TempDir(), "hook.txt")
+	if err := os.WriteFile(path, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	openFile(t, editor, path)
+	typeString(editor, "x")
+
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "s", true, false)
+
+	if got := optionString(t, editor, "before-save"); got != path+" true" {
+		t.Errorf("Unexpected before-save argument: %q", got)
+	}
+	if got := optionString(t, editor, "after-save"); got != path+" false" {
+		t.Errorf("Unexpected after-save argument: %q", got)
+	}
+}
+
+/**
+ * @spec hooks/find_file
+ * @scenario ファイルを開いたときのフック
+ * @description find-file、buffer-switch、major-mode-change がファイルを開いたバッファで呼ばれる
+ * @given 各フックで引数を記録する設定
+ * @when C-x C-f で .txt ファイルを開く
+ * @then find-file は新しいバッファ、buffer-switch は切り替え前の *scratch*、major-mode-change は text-mode を受け取る
+ * @implementation domain/minibuffer.go, domain/editor.go, domain/mode.go
+ */
+func TestFindFileHooks(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.add_hook("find-file", function(buf)
+    gmacs.set_option("find-file", buf:name())
+end)
+gmacs.add_hook("buffer-switch", function(buf, previous)
+    gmacs.set_option("buffer-switch", buf:name() .. " from " .. previous:name())
+end)
+gmacs.add_hook("major-mode-change", function(buf, mode, previous)
+    gmacs.set_option("major-mode-change", buf:name() .. " " .. mode .. " " .. tostring(previous))
+end)
+`)
+	defer editor.Cleanup()
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	openFile(t, editor, path)
+
+	if got := optionString(t, editor, "find-file"); got != "notes.txt" {
+		t.Errorf("Unexpected find-file argument: %q", got)
+	}
+	if got := optionString(t, editor, "buffer-switch"); got != "notes.txt from *scratch*" {
+		t.Errorf("Unexpected buffer-switch arguments: %q", got)
+	}
+	if got := optionString(t, editor, "major-mode-change"); got != "notes.txt text-mode nil" {
+		t.Errorf("Unexpected major-mode-change arguments: %q", got)
+	}
+}
+
+/**
+ * @spec hooks/command
+ * @scenario コマンド実行前後のフック
+ * @description pre-command と post-command がキーに割り当てられたコマンドの名前を受け取る
+ * @given コマンド名を記録するフック
+ * @when C-f と "a" を入力する
+ * @then forward-char と self-insert-command の名前が前後のフックに渡される
+ * @implementation domain/editor.go, domain/keybinding.go
+ */
+func TestCommandHooks(t *testing.T) {
+	editor := NewEditorWithLua(`
+local log = {}
+gmacs.add_hook("pre-command", function(name)
+    table.insert(log, "pre:" .. name)
+end)
+gmacs.add_hook("post-command", function(name)
+    table.insert(log, "post:" .. name)
+    gmacs.set_option("commands", table.concat(log, ","))
+end)
+`)
+	defer editor.Cleanup()
+
+	pressKey(editor, "f", true, false)
+	typeString(editor, "a")
+
+	expected := "pre:forward-char,post:forward-char,pre:self-insert-command,post:self-insert-command"
+	if got := optionString(t, editor, "commands"); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+/**
+ * @spec hooks/window_split
+ * @scenario ウィンドウ分割のフック
+ * @description window-split が新しいウィンドウのオブジェクトと分割方向を受け取る
+ * @given 引数を記録する window-split フック
+ * @when C-x 2 を押す
+ * @then 新しいウィンドウが表示しているバッファ名と "below" が渡される
+ * @implementation domain/window_commands.go, lua-config/objects.go
+ */
+func TestWindowSplitHook(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.add_hook("window-split", function(win, direction)
+    gmacs.set_option("window-split", win:buffer():name() .. " " .. direction)
+end)
+`)
+	defer editor.Cleanup()
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "2")
+
+	if got := optionString(t, editor, "window-split"); got != "*scratch* below" {
+		t.Errorf("Unexpected window-split arguments: %q", got)
+	}
+}
+
+/**
+ * @spec hooks/startup_exit
+ * @scenario 起動時と終了時のフック
+ * @description editor-startup は設定の読み込み後に、editor-exit は Cleanup 時に一度だけ呼ばれる
+ * @given 呼び出し回数を数える editor-startup と editor-exit のフック
+ * @when エディタを作成し、Cleanup を2回呼ぶ
+ * @then startup と exit がそれぞれ1回ずつ呼ばれ、current_buffer() がバッファオブジェクトを返す
+ * @implementation domain/hooks.go, domain/editor.go
+ */
+func TestStartupAndExitHooks(t *testing.T) {
+	var exits int
+	editor := NewEditorWithLua(`
+gmacs.add_hook("editor-startup", function()
+    local buf = gmacs.current_buffer()
+    gmacs.set_option("startup", buf:name() .. " " .. buf:line_count())
+end)
+`)
+
+	if got := optionString(t, editor, "startup"); got != "*scratch* 1" {
+		t.Errorf("Unexpected editor-startup result: %q", got)
+	}
+
+	editor.AddHook("editor-exit", func(args ...interface{}) error {
+		exits++
+		return nil
+	})
+	editor.Cleanup()
+	editor.Cleanup()
+	if exits != 1 {
+		t.Errorf("Expected editor-exit to run once, ran %d times", exits)
+	}
+}
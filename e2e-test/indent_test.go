@@ -0,0 +1,236 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+// pressTab sends TAB as a terminal does, as C-i
+func pressTab(editor *domain.Editor) {
+	pressKey(editor, "i", true, false)
+}
+
+// assertLines checks the whole content of buffer
+func assertLines(t *testing.T, buffer *domain.Buffer, want ...string) {
+	t.Helper()
+	if got := buffer.Content(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected lines %q, got %q", want, got)
+	}
+}
+
+/**
+ * @spec indent/previous_line
+ * @scenario 前の行に合わせたインデント
+ * @description fundamental-mode と text-mode では、TAB (indent-for-tab-command) は直前の空でない行と同じ幅に行をインデントする。行頭の空白の中にあるカーソルはインデントの後ろへ移り、本文の中にあるカーソルは同じ文字の位置に留まる
+ * @given 4桁インデントされた行と、空行をはさんだインデントのない行
+ * @when インデントのない行で TAB を押し、インデントの多すぎる行の本文中で TAB を押す
+ * @then どちらの行も4桁のインデントになり、カーソルは元の文字の位置に留まる
+ * @implementation domain/indent.go, domain/fundamental_mode.go, domain/text_mode.go
+ */
+func TestIndentLikePreviousLine(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "    foo", "", "bar", "          baz")
+
+	// 行頭のカーソルはインデントの後ろへ移る
+	buffer.SetCursor(domain.Position{Row: 2, Col: 0})
+	pressTab(editor)
+	assertLines(t, buffer, "    foo", "", "    bar", "          baz")
+	if cursor := buffer.Cursor(); cursor != (domain.Position{Row: 2, Col: 4}) {
+		t.Errorf("Expected the cursor after the indentation, got %+v", cursor)
+	}
+
+	// 本文中のカーソルは同じ文字に留まる (z の位置)
+	buffer.SetCursor(domain.Position{Row: 3, Col: 12})
+	pressTab(editor)
+	assertLines(t, buffer, "    foo", "", "    bar", "    baz")
+	if cursor := buffer.Cursor(); cursor != (domain.Position{Row: 3, Col: 6}) {
+		t.Errorf("Expected the cursor to stay on z, got %+v", cursor)
+	}
+
+	// text-mode でも同じ
+	if err := editor.ModeManager().SetMajorMode(buffer, "text-mode"); err != nil {
+		t.Fatal(err)
+	}
+	buffer.InsertText(domain.Position{Row: 3, Col: 7}, "\nqux")
+	buffer.SetCursor(domain.Position{Row: 4, Col: 0})
+	pressTab(editor)
+	if got := buffer.Line(4); got != "    qux" {
+		t.Errorf("Expected text-mode to indent like the previous line, got %q", got)
+	}
+}
+
+/**
+ * @spec indent/tabs
+ * @scenario indent-tabs-mode と tab-width
+ * @description インデントの幅はタブを tab-width 桁として数える。indent-tabs-mode が true (既定) ならタブとスペースで、false ならスペースだけでインデントする
+ * @given tab-width を 4 にし、タブ2つでインデントされた行のあるバッファ
+ * @when indent-tabs-mode を切り替えて次の行で TAB を押す
+ * @then true ではタブ2つ、false では8個のスペースでインデントされる
+ * @implementation domain/indent.go, domain/editor.go
+ */
+func TestIndentTabsMode(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.set_option("tab-width", 4)
+gmacs.set_option("indent-tabs-mode", true)
+`)
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "\t\tfoo", "  bar")
+
+	buffer.SetCursor(domain.Position{Row: 1, Col: 0})
+	pressTab(editor)
+	if got := buffer.Line(1); got != "\t\tbar" {
+		t.Errorf("Expected two tabs, got %q", got)
+	}
+
+	editor.SetOption("indent-tabs-mode", false)
+	pressTab(editor)
+	if got := buffer.Line(1); got != "        bar" {
+		t.Errorf("Expected eight spaces, got %q", got)
+	}
+}
+
+/**
+ * @spec indent/newline_and_indent
+ * @scenario 改行してインデント
+ * @description C-j (newline-and-indent) はカーソルの前の空白を取り除いて改行し、新しい行をメジャーモードのインデント関数でインデントする
+ * @given indent-tabs-mode を有効にした go-mode のバッファ
+ * @when 閉じ括弧の前で C-j を押し、開き括弧の後で C-j を押して入力する
+ * @then 閉じ括弧の行はインデントされず、関数本体の行はタブ1つでインデントされる
+ * @implementation domain/indent.go, domain/go_mode.go
+ */
+func TestNewlineAndIndent(t *testing.T) {
+	editor := NewEditorWithLua(`gmacs.set_option("indent-tabs-mode", true)`)
+	defer editor.Cleanup()
+	buffer := openGoFile(t, editor, "func f() {   }\n")
+
+	// 閉じ括弧の前の空白は取り除かれる
+	buffer.SetCursor(domain.Position{Row: 0, Col: 13})
+	pressKey(editor, "j", true, false)
+	assertLines(t, buffer, "func f() {", "}")
+
+	pressKey(editor, "b", true, false)
+	pressKey(editor, "j", true, false)
+	typeString(editor, "return")
+	assertLines(t, buffer, "func f() {", "\treturn", "}")
+	if cursor := buffer.Cursor(); cursor != (domain.Position{Row: 1, Col: 7}) {
+		t.Errorf("Expected the cursor after return, got %+v", cursor)
+	}
+}
+
+/**
+ * @spec indent/lua_indent_function
+ * @scenario Lua によるインデント関数と indent-region
+ * @description gmacs.major_mode の indent か gmacs.set_indent_function で、任意のモードのインデント関数を Lua で与えられる。関数はバッファと1始まりの行番号を受け取り、インデントする桁を返す。nil を返すと行はそのままになる。C-M-\ (indent-region) はリージョンの空でない各行をインデントし、数値の前置引数があればその桁にそろえる
+ * @given begin / end の深さでインデントする Lua のモードと、text-mode のインデント関数を置き換える設定
+ * @when リージョンを選んで C-M-\ を押し、text-mode でも C-M-\ を押し、存在しないモードを指定する
+ * @then 各モードの Lua 関数に従ってインデントされ、nil の行は変わらず、存在しないモードはエラーになる
+ * @implementation domain/indent.go, domain/custom_mode.go, lua-config/api_bindings.go, lua-config/objects.go
+ */
+func TestLuaIndentFunction(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.major_mode("block-mode", {
+    indent = function(buffer, line)
+        local text = buffer:get_line(line)
+        if text:match("^%s*#") then
+            return nil
+        end
+        local depth = 0
+        for i = 1, line - 1 do
+            local l = buffer:get_line(i)
+            if l:match("begin$") then depth = depth + 1 end
+            if l:match("^%s*end") then depth = depth - 1 end
+        end
+        if text:match("^%s*end") then depth = depth - 1 end
+        return depth * 2
+    end,
+})
+gmacs.set_indent_function("text-mode", function(buffer, line)
+    return buffer:indentation(line) + 1
+end)
+local ok, err = pcall(gmacs.set_indent_function, "no-such-mode", function() return 0 end)
+gmacs.set_option("indent-error", tostring(err))
+`)
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "begin", "x", "begin", "y", "  # keep", "end", "end")
+	if err := editor.ModeManager().SetMajorMode(buffer, "block-mode"); err != nil {
+		t.Fatal(err)
+	}
+
+	// リージョン全体をインデントする
+	pressKey(editor, "SPC", true, false)
+	pressKey(editor, ">", false, true)
+	pressKey(editor, "\\", true, true)
+	assertLines(t, buffer, "begin", "  x", "  begin", "    y", "  # keep", "  end", "end")
+
+	// 数値の前置引数でその桁にそろえる (M-1 C-M-\)
+	buffer.SetMark(domain.Position{Row: 1, Col: 0})
+	buffer.SetCursor(domain.Position{Row: 3, Col: 0})
+	pressKey(editor, "1", false, true)
+	pressKey(editor, "\\", true, true)
+	assertLines(t, buffer, "begin", " x", " begin", "    y", "  # keep", "  end", "end")
+
+	// 組み込みのモードも置き換えられる
+	if err := editor.ModeManager().SetMajorMode(buffer, "text-mode"); err != nil {
+		t.Fatal(err)
+	}
+	buffer.SetCursor(domain.Position{Row: 0, Col: 0})
+	pressTab(editor)
+	if got := buffer.Line(0); got != " begin" {
+		t.Errorf("Expected the Lua indent function of text-mode, got %q", got)
+	}
+
+	if got := optionString(t, editor, "indent-error"); !strings.Contains(got, "Unknown major mode: no-such-mode") {
+		t.Errorf("Expected an unknown mode error, got %q", got)
+	}
+}
+
+/**
+ * @spec indent/indent_rigidly
+ * @scenario リージョンをまとめてずらす
+ * @description C-x TAB (indent-rigidly) はリージョンの各行のインデントを前置引数の桁数だけ増やし、負の引数なら減らす。空白だけの行は空になる。リージョンが行頭で終わるとき、その行は含まれない
+ * @given 3行とそれに続く行のバッファ
+ * @when 最初の3行を選んで C-u C-x TAB、M-- C-x TAB、C-u -8 C-x TAB を順に実行する
+ * @then 4桁右へずれ、1桁左へ戻り、左端で止まる。空白だけの行は空になり、リージョン外の行は変わらない
+ * @implementation domain/indent.go
+ */
+func TestIndentRigidly(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "a", "   ", "  b", "c")
+
+	selectRows := func() {
+		buffer.SetMark(domain.Position{Row: 0, Col: 0})
+		buffer.SetCursor(domain.Position{Row: 3, Col: 0})
+	}
+
+	selectRows()
+	pressKey(editor, "u", true, false)
+	pressKey(editor, "x", true, false)
+	pressTab(editor)
+	assertLines(t, buffer, "    a", "", "      b", "c")
+
+	selectRows()
+	pressKey(editor, "-", false, true)
+	pressKey(editor, "x", true, false)
+	pressTab(editor)
+	assertLines(t, buffer, "   a", "", "     b", "c")
+
+	selectRows()
+	pressKey(editor, "u", true, false)
+	typeString(editor, "-8")
+	pressKey(editor, "x", true, false)
+	pressTab(editor)
+	assertLines(t, buffer, "a", "", "b", "c")
+
+	// リージョンがなければ何もしない
+	editor2 := NewEditorWithDefaults()
+	defer editor2.Cleanup()
+	setupLines(editor2, "x")
+	runCommand(editor2, "indent-rigidly")
+	if got := editor2.Minibuffer().Message(); got != "The mark is not set now, so there is no region" {
+		t.Errorf("Expected a no region message, got %q", got)
+	}
+}
@@ -0,0 +1,274 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// setupSearchBuffer creates an editor whose buffer holds the given lines,
+// with the cursor at the beginning of the buffer
+func setupSearchBuffer(lines ...string) (*domain.Editor, *domain.Buffer) {
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+	for i, line := range lines {
+		if i > 0 {
+			pressEnter(editor)
+		}
+		typeString(editor, line)
+	}
+	buffer.SetCursor(domain.Position{Row: 0, Col: 0})
+	return editor, buffer
+}
+
+/**
+ * @spec search/isearch_forward
+ * @scenario C-s によるインクリメンタルサーチ
+ * @description 入力ごとに前方検索し、C-s で次のマッチへ移動する
+ * @given "foo bar" "bar foo" "baz" の3行があるバッファ
+ * @when C-s で "bar" を入力し、さらに C-s を押す
+ * @then カーソルが各マッチの末尾へ移動し、現在のマッチが取得できる
+ * @implementation domain/isearch.go, domain/minibuffer.go
+ */
+func TestISearchForward(t *testing.T) {
+	editor, buffer := setupSearchBuffer("foo bar", "bar foo", "baz")
+
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	if editor.Minibuffer().Mode() != domain.MinibufferISearch {
+		t.Fatalf("Expected MinibufferISearch mode, got %v", editor.Minibuffer().Mode())
+	}
+
+	// "ba" の時点で最初の "ba" にマッチする
+	typeString(editor, "ba")
+	if cursor := buffer.Cursor(); cursor.Row != 0 || cursor.Col != 6 {
+		t.Errorf("Expected cursor at (0,6) after 'ba', got (%d,%d)", cursor.Row, cursor.Col)
+	}
+
+	typeString(editor, "r")
+	if cursor := buffer.Cursor(); cursor.Row != 0 || cursor.Col != 7 {
+		t.Errorf("Expected cursor at (0,7) after 'bar', got (%d,%d)", cursor.Row, cursor.Col)
+	}
+	if editor.Minibuffer().GetDisplayText() != "I-search: bar" {
+		t.Errorf("Expected 'I-search: bar', got %q", editor.Minibuffer().GetDisplayText())
+	}
+
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	start, end, ok := editor.ISearch().CurrentMatch()
+	if !ok || start != (domain.Position{Row: 1, Col: 0}) || end != (domain.Position{Row: 1, Col: 3}) {
+		t.Errorf("Expected current match (1,0)-(1,3), got %v-%v", start, end)
+	}
+
+	// Enter でその場に留まる
+	pressEnter(editor)
+	if editor.ISearch() != nil {
+		t.Error("Search should end after Enter")
+	}
+	if cursor := buffer.Cursor(); cursor.Row != 1 || cursor.Col != 3 {
+		t.Errorf("Expected cursor to stay at (1,3), got (%d,%d)", cursor.Row, cursor.Col)
+	}
+	if mark, ok := buffer.Mark(); !ok || mark != (domain.Position{}) {
+		t.Errorf("Expected mark at the search start, got %v", mark)
+	}
+}
+
+/**
+ * @spec search/isearch_regexp_anchors
+ * @scenario 行の途中からの正規表現検索での ^ と \b
+ * @description 行の途中から検索しても、正規表現は行全体の中でマッチする。^ は行頭だけに、\b は直前の文字を見てマッチする。前回の検索文字列を C-M-s C-s で呼び出したときも同じ
+ * @given "aaa" "aa b" "x aab" の3行があるバッファ
+ * @when C-M-s で "^aa" を検索して Enter で終え、カーソルを (0,1) に置いて C-M-s C-s を押し、さらに C-s を押す
+ * @then 行の途中の (0,1) ではマッチせず次の行頭 (1,0) にマッチし、その次は失敗する
+ * @implementation domain/isearch.go
+ */
+func TestISearchRegexpRepeatLineStart(t *testing.T) {
+	editor, buffer := setupSearchBuffer("aaa", "aa b", "x aab")
+	assertRepeatFromInside(t, editor, buffer, "^aa", domain.Position{Row: 1, Col: 0}, domain.Position{Row: 1, Col: 2})
+}
+
+/**
+ * @spec search/isearch_regexp_word_boundary
+ * @scenario 行の途中からの正規表現検索での \b
+ * @description \b は検索を始めた位置の直前の文字を見るので、単語の途中ではマッチしない
+ * @given "aaa b" "ab" の2行があるバッファ
+ * @when C-M-s で "\ba\w*" を検索して Enter で終え、カーソルを (0,1) に置いて C-M-s C-s を押し、さらに C-s を押す
+ * @then 単語の途中の (0,1) ではマッチせず次の行の (1,0) にマッチし、その次は失敗する
+ * @implementation domain/isearch.go
+ */
+func TestISearchRegexpRepeatWordBoundary(t *testing.T) {
+	editor, buffer := setupSearchBuffer("aaa b", "ab")
+	assertRepeatFromInside(t, editor, buffer, `\ba\w*`, domain.Position{Row: 1, Col: 0}, domain.Position{Row: 1, Col: 2})
+}
+
+// assertRepeatFromInside searches for pattern with C-M-s, then searches for
+// it again from inside its first match and checks where it is found
+func assertRepeatFromInside(t *testing.T, editor *domain.Editor, buffer *domain.Buffer, pattern string, wantStart, wantEnd domain.Position) {
+	t.Helper()
+	defer editor.Cleanup()
+	regexpSearch := func() {
+		editor.HandleEvent(events.KeyEventData{Key: "\x1b"})
+		editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	}
+
+	regexpSearch()
+	typeString(editor, pattern)
+	if start, _, ok := editor.ISearch().CurrentMatch(); !ok || start != (domain.Position{}) {
+		t.Fatalf("Expected %q to match at the buffer start, got %v", pattern, start)
+	}
+	pressEnter(editor)
+
+	// 前回の検索文字列で、最初のマッチの途中から検索し直す
+	buffer.SetCursor(domain.Position{Row: 0, Col: 1})
+	regexpSearch()
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	start, end, ok := editor.ISearch().CurrentMatch()
+	if !ok || start != wantStart || end != wantEnd {
+		t.Errorf("Expected %q to match %v-%v, got %v-%v (found: %v)", pattern, wantStart, wantEnd, start, end, ok)
+	}
+
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	if _, _, ok := editor.ISearch().CurrentMatch(); ok {
+		t.Errorf("Expected no match of %q after %v", pattern, wantEnd)
+	}
+}
+
+/**
+ * @spec search/isearch_wrap
+ * @scenario 検索の折り返し
+ * @description マッチが見つからない場合は Failing と表示し、次の C-s で先頭から検索する
+ * @given "abc" "xyz abc" の2行があるバッファ
+ * @when C-s "abc" の後、C-s を2回押す
+ * @then 1回目は失敗し、2回目で先頭のマッチに折り返す
+ * @implementation domain/isearch.go
+ */
+func TestISearchWrapAround(t *testing.T) {
+	editor, buffer := setupSearchBuffer("abc", "xyz abc")
+
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	typeString(editor, "abc")
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	if cursor := buffer.Cursor(); cursor.Row != 1 || cursor.Col != 7 {
+		t.Errorf("Expected second match at (1,7), got (%d,%d)", cursor.Row, cursor.Col)
+	}
+
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	if editor.Minibuffer().Prompt() != "Failing I-search: " {
+		t.Errorf("Expected failing prompt, got %q", editor.Minibuffer().Prompt())
+	}
+	// 失敗時はカーソルが動かない
+	if cursor := buffer.Cursor(); cursor.Row != 1 || cursor.Col != 7 {
+		t.Errorf("Cursor should not move on failure, got (%d,%d)", cursor.Row, cursor.Col)
+	}
+
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	if editor.Minibuffer().Prompt() != "Wrapped I-search: " {
+		t.Errorf("Expected wrapped prompt, got %q", editor.Minibuffer().Prompt())
+	}
+	if cursor := buffer.Cursor(); cursor.Row != 0 || cursor.Col != 3 {
+		t.Errorf("Expected wrap to first match at (0,3), got (%d,%d)", cursor.Row, cursor.Col)
+	}
+}
+
+/**
+ * @spec search/isearch_backward
+ * @scenario C-r による後方検索
+ * @description 後方検索ではカーソルがマッチの先頭へ移動する
+ * @given "one two" "two one" の2行があり、カーソルがバッファ末尾にある
+ * @when C-r で "one" を入力し、さらに C-r を押す
+ * @then カーソルが後ろから順にマッチの先頭へ移動する
+ * @implementation domain/isearch.go
+ */
+func TestISearchBackward(t *testing.T) {
+	editor, buffer := setupSearchBuffer("one two", "two one")
+	buffer.SetCursor(domain.Position{Row: 1, Col: 7})
+
+	editor.HandleEvent(events.KeyEventData{Key: "r", Ctrl: true})
+	typeString(editor, "one")
+	if cursor := buffer.Cursor(); cursor.Row != 1 || cursor.Col != 4 {
+		t.Errorf("Expected cursor at (1,4), got (%d,%d)", cursor.Row, cursor.Col)
+	}
+	if editor.Minibuffer().Prompt() != "I-search backward: " {
+		t.Errorf("Expected backward prompt, got %q", editor.Minibuffer().Prompt())
+	}
+
+	editor.HandleEvent(events.KeyEventData{Key: "r", Ctrl: true})
+	if cursor := buffer.Cursor(); cursor.Row != 0 || cursor.Col != 0 {
+		t.Errorf("Expected cursor at (0,0), got (%d,%d)", cursor.Row, cursor.Col)
+	}
+}
+
+/**
+ * @spec search/isearch_abort
+ * @scenario C-g による検索の中止
+ * @description C-g で検索を中止すると検索開始位置に戻る
+ * @given "hello world" のバッファで検索を開始
+ * @when "world" を検索した後 C-g を押す
+ * @then カーソルが検索開始位置に戻り、ミニバッファが閉じる
+ * @implementation domain/isearch.go, domain/command.go
+ */
+func TestISearchAbort(t *testing.T) {
+	editor, buffer := setupSearchBuffer("hello world")
+	buffer.SetCursor(domain.Position{Row: 0, Col: 2})
+
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	typeString(editor, "world")
+	if buffer.Cursor().Col != 11 {
+		t.Fatalf("Expected cursor at col 11, got %d", buffer.Cursor().Col)
+	}
+
+	editor.HandleEvent(events.KeyEventData{Key: "g", Ctrl: true})
+	if buffer.Cursor().Col != 2 {
+		t.Errorf("Expected cursor to return to col 2, got %d", buffer.Cursor().Col)
+	}
+	if editor.ISearch() != nil {
+		t.Error("Search should end after C-g")
+	}
+	if editor.Minibuffer().IsEditing() || editor.Minibuffer().Mode() == domain.MinibufferISearch {
+		t.Error("Minibuffer should leave isearch mode after C-g")
+	}
+}
+
+/**
+ * @spec search/isearch_regexp
+ * @scenario C-M-s による正規表現検索
+ * @description 正規表現で検索し、Backspace で一つ前の状態に戻る
+ * @given "id: 42" "id: 7" の2行があるバッファ
+ * @when C-M-s で "[0-9]+" を入力する
+ * @then 最初の数字列にマッチし、表示中の全マッチが取得できる
+ * @implementation domain/isearch.go
+ */
+func TestISearchRegexp(t *testing.T) {
+	editor, buffer := setupSearchBuffer("id: 42", "id: 7")
+
+	// ESC C-s (C-M-s)
+	editor.HandleEvent(events.KeyEventData{Key: "\x1b"})
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	typeString(editor, "[0-9]+")
+
+	if editor.Minibuffer().Prompt() != "Regexp I-search: " {
+		t.Errorf("Expected regexp prompt, got %q", editor.Minibuffer().Prompt())
+	}
+	start, end, ok := editor.ISearch().CurrentMatch()
+	if !ok || start != (domain.Position{Row: 0, Col: 4}) || end != (domain.Position{Row: 0, Col: 6}) {
+		t.Errorf("Expected match (0,4)-(0,6), got %v-%v", start, end)
+	}
+	if matches := editor.ISearch().LineMatches(buffer.Content()[1]); len(matches) != 1 || matches[0][0] != 4 {
+		t.Errorf("Expected one match on second line, got %v", matches)
+	}
+
+	// Backspace で "+" を取り消すと1文字のマッチになる
+	editor.HandleEvent(events.KeyEventData{Key: "Backspace"})
+	start, end, _ = editor.ISearch().CurrentMatch()
+	if end.Col-start.Col != 1 {
+		t.Errorf("Expected single digit match after backspace, got %v-%v", start, end)
+	}
+
+	// 他のコマンドキーで検索を終了し、そのコマンドを実行する
+	editor.HandleEvent(events.KeyEventData{Key: "a", Ctrl: true})
+	if editor.ISearch() != nil {
+		t.Error("Search should end on a command key")
+	}
+	if buffer.Cursor().Col != 0 {
+		t.Errorf("Expected C-a to run after leaving isearch, got col %d", buffer.Cursor().Col)
+	}
+}
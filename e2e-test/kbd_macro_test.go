@@ -0,0 +1,234 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+// setupLines fills the buffer with one line per entry and moves to the top
+func setupLines(editor *domain.Editor, lines ...string) *domain.Buffer {
+	buffer := editor.CurrentBuffer()
+	buffer.InsertString(strings.Join(lines, "\n"))
+	buffer.SetCursor(domain.Position{Row: 0, Col: 0})
+	return buffer
+}
+
+/**
+ * @spec kbd_macro/record_and_call
+ * @scenario キーボードマクロの記録と再生
+ * @description C-x ( から C-x ) までのキー入力を記録し、C-x e で再生する。続く e で繰り返し、前置引数で回数を指定できる
+ * @given 6行のバッファ
+ * @when 行頭に "> " を挿入して次の行へ移るマクロを記録し、C-x e、e、C-u 2 C-x e を実行する
+ * @then 先頭5行に "> " が挿入され、6行目は変わらない。C-x ) 自体はマクロに含まれない
+ * @implementation domain/kbd_macro.go, domain/editor.go
+ */
+func TestKbdMacroRecordAndCall(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "a", "b", "c", "d", "e", "f")
+
+	// C-x ( C-a "> " C-n C-x )
+	pressKey(editor, "x", true, false)
+	typeString(editor, "(")
+	if !editor.DefiningKbdMacro() {
+		t.Fatal("Expected to be defining a keyboard macro after C-x (")
+	}
+	pressKey(editor, "a", true, false)
+	typeString(editor, "> ")
+	pressKey(editor, "n", true, false)
+	pressKey(editor, "x", true, false)
+	typeString(editor, ")")
+
+	if editor.DefiningKbdMacro() {
+		t.Fatal("Expected the macro definition to end after C-x )")
+	}
+	if n := len(editor.LastKbdMacro()); n != 4 {
+		t.Errorf("Expected 4 keys in the macro (C-a, >, space, C-n), got %d", n)
+	}
+
+	// C-x e の後の e で繰り返す
+	pressKey(editor, "x", true, false)
+	typeString(editor, "e")
+	typeString(editor, "e")
+
+	// C-u 2 C-x e
+	pressKey(editor, "u", true, false)
+	typeString(editor, "2")
+	pressKey(editor, "x", true, false)
+	typeString(editor, "e")
+
+	want := []string{"> a", "> b", "> c", "> d", "> e", "f"}
+	for i, line := range want {
+		if got := buffer.Line(i); got != line {
+			t.Errorf("Line %d: expected %q, got %q", i, line, got)
+		}
+	}
+
+	// e 以外のキーで繰り返しは終わる
+	pressKey(editor, "f", true, false)
+	typeString(editor, "e")
+	if got := buffer.Line(5); got != "fe" {
+		t.Errorf("Expected 'e' to self-insert after another command, got %q", got)
+	}
+}
+
+/**
+ * @spec kbd_macro/cancel
+ * @scenario C-g によるマクロ定義の中止
+ * @description 定義中に C-g を押すとマクロ定義は破棄され、以前のマクロが残る
+ * @given "x" を挿入するマクロを定義済み
+ * @when C-x ( で新しい定義を始めて "y" を入力し、C-g を押してから C-x e を実行する
+ * @then 新しい定義は破棄され、C-x e は以前のマクロ ("x" の挿入) を実行する
+ * @implementation domain/kbd_macro.go, domain/command.go
+ */
+func TestKbdMacroCancel(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := editor.CurrentBuffer()
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "(x")
+	pressKey(editor, "x", true, false)
+	typeString(editor, ")")
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "(y")
+	pressKey(editor, "g", true, false)
+	if editor.DefiningKbdMacro() {
+		t.Fatal("Expected C-g to cancel the macro definition")
+	}
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "e")
+	if got := buffer.Line(0); got != "xyx" {
+		t.Errorf("Expected the previous macro to insert 'x', got %q", got)
+	}
+}
+
+/**
+ * @spec kbd_macro/empty_after_macro
+ * @scenario マクロの後に空のマクロを定義する
+ * @description マクロを定義した後で C-x ( C-x ) と何も入力せずに定義を終えると、空のマクロになる
+ * @given "abc" を挿入するマクロを定義済み
+ * @when C-x ( C-x ) を押す
+ * @then エラーにならず、最後のマクロは空になる
+ * @implementation domain/kbd_macro.go
+ */
+func TestKbdMacroEmptyAfterMacro(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "(abc")
+	pressKey(editor, "x", true, false)
+	typeString(editor, ")")
+	if n := len(editor.LastKbdMacro()); n != 3 {
+		t.Fatalf("Expected 3 keys in the first macro, got %d", n)
+	}
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "(")
+	pressKey(editor, "x", true, false)
+	typeString(editor, ")")
+	if n := len(editor.LastKbdMacro()); n != 0 {
+		t.Errorf("Expected an empty macro, got %d keys", n)
+	}
+}
+
+/**
+ * @spec kbd_macro/keys_after_message
+ * @scenario メッセージが表示されている間のキーの記録
+ * @description ミニバッファにメッセージが表示されている間に押したキーも、入力中でなければコマンドのキーとして記録される。"Mark set" を表示する C-SPC の後の C-x ) も正しくマクロから外れる
+ * @given 2行のバッファ
+ * @when C-x ( a C-SPC C-x ) で記録し、次の行の先頭で C-x e を実行する
+ * @then マクロは a と C-SPC の2キーで、再生すると "a" を挿入してマークを設定する
+ * @implementation domain/kbd_macro.go
+ */
+func TestKbdMacroKeysAfterMessage(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "one", "two")
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "(a")
+	pressKey(editor, "SPC", true, false)
+	pressKey(editor, "x", true, false)
+	typeString(editor, ")")
+	if n := len(editor.LastKbdMacro()); n != 2 {
+		t.Fatalf("Expected 2 keys (a, C-SPC), got %d", n)
+	}
+
+	buffer.SetCursor(domain.Position{Row: 1, Col: 0})
+	buffer.DeactivateMark()
+	pressKey(editor, "x", true, false)
+	typeString(editor, "e")
+	if got := buffer.Line(1); got != "atwo" {
+		t.Errorf("Expected the macro to insert 'a', got %q", got)
+	}
+	if mark, ok := buffer.Mark(); !ok || !buffer.MarkActive() || mark != (domain.Position{Row: 1, Col: 1}) {
+		t.Errorf("Expected the macro to set the mark after 'a', got %+v (set %v)", mark, ok)
+	}
+}
+
+/**
+ * @spec kbd_macro/name_and_save
+ * @scenario マクロへの名前付けと Lua 設定への保存
+ * @description name-last-kbd-macro でマクロをコマンドにし、save-kbd-macro で gmacs.kbd_macro の呼び出しとして設定ファイルに追記する
+ * @given 行末に "!" を追加して次の行へ移るマクロ
+ * @when M-x name-last-kbd-macro で "add-bang" と名付け、M-x save-kbd-macro で user-init-file に保存し、保存したファイルを新しいエディタで読み込む
+ * @then M-x add-bang がマクロを実行し、保存された Lua から定義したコマンドも同じ動作をする
+ * @implementation domain/kbd_macro.go, lua-config/kbd_macro.go
+ */
+func TestKbdMacroNameAndSave(t *testing.T) {
+	initFile := filepath.Join(t.TempDir(), "gmacs", "init.lua")
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	editor.SetOption("user-init-file", initFile)
+	buffer := setupLines(editor, "one", "two", "three")
+
+	pressKey(editor, "x", true, false)
+	typeString(editor, "(")
+	pressKey(editor, "e", true, false)
+	typeString(editor, "!")
+	pressKey(editor, "n", true, false)
+	pressKey(editor, "x", true, false)
+	typeString(editor, ")")
+
+	runCommand(editor, "name-last-kbd-macro")
+	typeString(editor, "add-bang")
+	pressEnter(editor)
+
+	runCommand(editor, "add-bang")
+	if got := buffer.Line(1); got != "two!" {
+		t.Errorf("Expected M-x add-bang to run the macro, got %q", got)
+	}
+
+	runCommand(editor, "save-kbd-macro")
+	typeString(editor, "add-bang")
+	pressEnter(editor)
+
+	data, err := os.ReadFile(initFile)
+	if err != nil {
+		t.Fatalf("Failed to read the saved init file: %v", err)
+	}
+	if !strings.Contains(string(data), `gmacs.kbd_macro("add-bang", {`) {
+		t.Errorf("Expected a gmacs.kbd_macro call in the init file, got:\n%s", data)
+	}
+
+	// 保存した設定を読み込んだエディタでも同じコマンドが使える
+	loaded := NewEditorWithLua(string(data))
+	defer loaded.Cleanup()
+	loadedBuffer := setupLines(loaded, "x", "y")
+	runCommand(loaded, "add-bang")
+	if got := loadedBuffer.Line(0); got != "x!" {
+		t.Errorf("Expected the saved macro to append '!', got %q", got)
+	}
+	if cursor := loadedBuffer.Cursor(); cursor.Row != 1 {
+		t.Errorf("Expected the saved macro to move to the next line, got row %d", cursor.Row)
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+/**
+ * @spec keybinding/mode_layers
+ * @scenario マイナーモード・メジャーモード・グローバルの順でキーを引く
+ * @description モードのキーマップがグローバルより優先され、マイナーモードはメジャーモードより優先される
+ * @given fundamental-mode で C-f を backward-char に、auto-a-mode で C-f を end-of-line に割り当てる
+ * @when "abc" と入力し、auto-a-mode の有効・無効を切り替えながら C-f を押す
+ * @then 有効なマイナーモードがあればその割り当て、なければメジャーモードの割り当てが使われる
+ * @implementation domain/keymap_layers.go, domain/mode.go
+ */
+func TestModeKeymapLayers(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.local_bind_key("fundamental-mode", "C-f", "backward-char")
+gmacs.local_bind_key("auto-a-mode", "C-f", "end-of-line")
+`)
+	defer editor.Cleanup()
+	buffer := editor.CurrentBuffer()
+
+	typeString(editor, "abc")
+
+	// メジャーモードの割り当てがグローバルの forward-char より優先される
+	pressKey(editor, "f", true, false)
+	if col := buffer.Cursor().Col; col != 2 {
+		t.Errorf("Expected major mode C-f (backward-char) to move to column 2, got %d", col)
+	}
+
+	// マイナーモードはメジャーモードより優先される
+	if err := editor.ModeManager().ToggleMinorMode(buffer, "auto-a-mode"); err != nil {
+		t.Fatal(err)
+	}
+	pressKey(editor, "f", true, false)
+	if col := buffer.Cursor().Col; col != 3 {
+		t.Errorf("Expected minor mode C-f (end-of-line) to move to column 3, got %d", col)
+	}
+
+	// マイナーモードを無効にするとメジャーモードの割り当てに戻る
+	editor.ModeManager().ToggleMinorMode(buffer, "auto-a-mode")
+	pressKey(editor, "f", true, false)
+	if col := buffer.Cursor().Col; col != 2 {
+		t.Errorf("Expected major mode C-f after disabling the minor mode, got column %d", col)
+	}
+}
+
+/**
+ * @spec keybinding/prefix_across_layers
+ * @scenario 複数レイヤーにまたがるプレフィックスキー
+ * @description モードで C-x をプレフィックスとして使っても、グローバルの C-x シーケンスは隠されない
+ * @given fundamental-mode で "C-x t" を beginning-of-line に割り当てる
+ * @when "C-x t" と "C-x C-f" を入力する
+ * @then "C-x t" はモードのコマンドを、"C-x C-f" はグローバルの find-file を実行する
+ * @implementation domain/keymap_layers.go
+ */
+func TestPrefixKeyAcrossLayers(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.local_bind_key("fundamental-mode", "C-x t", "beginning-of-line")
+`)
+	defer editor.Cleanup()
+	buffer := editor.CurrentBuffer()
+
+	typeString(editor, "hello")
+	pressKey(editor, "x", true, false)
+	if seq := editor.GetKeySequenceInProgress(); seq != "C-x -" {
+		t.Errorf("Expected 'C-x -' in progress, got %q", seq)
+	}
+	typeString(editor, "t")
+	if col := buffer.Cursor().Col; col != 0 {
+		t.Errorf("Expected C-x t to move to column 0, got %d", col)
+	}
+
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "f", true, false)
+	if editor.Minibuffer().Mode() != domain.MinibufferFile {
+		t.Errorf("Expected global C-x C-f to start find-file, minibuffer mode is %v", editor.Minibuffer().Mode())
+	}
+}
+
+/**
+ * @spec keybinding/per_buffer_sequence
+ * @scenario バッファごとのキーシーケンス状態
+ * @description 入力途中のキーシーケンスはバッファごとに保持され、他のバッファの入力に影響しない
+ * @given *scratch* で C-x を押した状態
+ * @when 別のバッファに切り替えて "b" を入力し、*scratch* に戻って C-f を押す
+ * @then 別のバッファには "b" が挿入され、*scratch* では C-x C-f として find-file が始まる
+ * @implementation domain/keymap_layers.go, domain/editor.go
+ */
+func TestKeySequenceStatePerBuffer(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	scratch := editor.CurrentBuffer()
+	other := editor.GetOrCreateBuffer("other")
+
+	pressKey(editor, "x", true, false)
+
+	editor.SwitchToBuffer(other)
+	if seq := editor.GetKeySequenceInProgress(); seq != "" {
+		t.Errorf("Expected no key sequence in the other buffer, got %q", seq)
+	}
+	typeString(editor, "b")
+	if other.Content()[0] != "b" {
+		t.Errorf("Expected 'b' to be inserted in the other buffer, got %q", other.Content()[0])
+	}
+
+	editor.SwitchToBuffer(scratch)
+	if seq := editor.GetKeySequenceInProgress(); seq != "C-x -" {
+		t.Errorf("Expected 'C-x -' to still be in progress in *scratch*, got %q", seq)
+	}
+	pressKey(editor, "f", true, false)
+	if editor.Minibuffer().Mode() != domain.MinibufferFile {
+		t.Errorf("Expected C-x C-f to start find-file, minibuffer mode is %v", editor.Minibuffer().Mode())
+	}
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// pressKey sends a single key event with the given modifiers
+func pressKey(editor *domain.Editor, key string, ctrl, meta bool) {
+	editor.HandleEvent(events.KeyEventData{Key: key, Ctrl: ctrl, Meta: meta})
+}
+
+/**
+ * @spec killring/kill_region_yank
+ * @scenario リージョンの切り取りと貼り付け
+ * @description C-SPC でマークを設定し、C-w で切り取ったテキストを C-y で貼り付ける
+ * @given "hello world" と入力したバッファ
+ * @when 行頭で C-SPC、5文字進めて C-w、行末で C-y を押す
+ * @then "hello" が切り取られ、行末に貼り付けられる
+ * @implementation domain/kill_ring.go, domain/buffer.go
+ */
+func TestKillRegionAndYank(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+	typeString(editor, "hello world")
+
+	pressKey(editor, "a", true, false)
+	pressKey(editor, "SPC", true, false)
+	if editor.Minibuffer().Message() != "Mark set" {
+		t.Errorf("Expected 'Mark set' message, got %q", editor.Minibuffer().Message())
+	}
+	for i := 0; i < 5; i++ {
+		pressKey(editor, "f", true, false)
+	}
+
+	// マーク設定中はリージョンがアクティブ
+	if !buffer.MarkActive() {
+		t.Fatal("Mark should be active after C-SPC")
+	}
+	start, end, ok := buffer.Region()
+	if !ok || start.Col != 0 || end.Col != 5 {
+		t.Errorf("Expected region (0,0)-(0,5), got %v-%v", start, end)
+	}
+
+	pressKey(editor, "w", true, false)
+	if buffer.Content()[0] != " world" {
+		t.Errorf("Expected ' world' after kill-region, got %q", buffer.Content()[0])
+	}
+	if buffer.MarkActive() {
+		t.Error("Mark should be deactivated after kill-region")
+	}
+
+	pressKey(editor, "e", true, false)
+	pressKey(editor, "y", true, false)
+	if buffer.Content()[0] != " worldhello" {
+		t.Errorf("Expected ' worldhello' after yank, got %q", buffer.Content()[0])
+	}
+	if buffer.Cursor().Col != 11 {
+		t.Errorf("Expected cursor after yanked text, got col %d", buffer.Cursor().Col)
+	}
+}
+
+/**
+ * @spec killring/kill_line_append
+ * @scenario 連続した C-k の結合
+ * @description 連続した C-k で切り取ったテキストは1つのエントリにまとめられる
+ * @given "first" "second" の2行があるバッファ
+ * @when 先頭で C-k を3回押し、C-y で貼り付ける
+ * @then 1行目と改行、2行目がまとめて1回の C-y で復元される
+ * @implementation domain/kill_ring.go
+ */
+func TestKillLineConsecutiveAppend(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+	typeString(editor, "first")
+	pressEnter(editor)
+	typeString(editor, "second")
+
+	// バッファ先頭へ移動
+	pressKey(editor, "p", true, false)
+	pressKey(editor, "a", true, false)
+
+	pressKey(editor, "k", true, false) // "first"
+	pressKey(editor, "k", true, false) // 改行
+	pressKey(editor, "k", true, false) // "second"
+
+	if len(buffer.Content()) != 1 || buffer.Content()[0] != "" {
+		t.Fatalf("Expected empty buffer after kills, got %q", buffer.Content())
+	}
+	if editor.KillRing().Len() != 1 {
+		t.Errorf("Expected consecutive kills to share one entry, got %d entries", editor.KillRing().Len())
+	}
+
+	pressKey(editor, "y", true, false)
+	content := buffer.Content()
+	if len(content) != 2 || content[0] != "first" || content[1] != "second" {
+		t.Errorf("Expected yank to restore both lines, got %q", content)
+	}
+}
+
+/**
+ * @spec killring/kill_ring_save
+ * @scenario M-w によるコピー
+ * @description M-w はリージョンを削除せずにキルリングへ保存する
+ * @given "copy me" と入力し、全体をリージョンとして選択
+ * @when M-w を押した後 C-y を押す
+ * @then 元のテキストは残り、末尾にコピーが貼り付けられる
+ * @implementation domain/kill_ring.go
+ */
+func TestKillRingSave(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+	typeString(editor, "copy me")
+
+	pressKey(editor, "SPC", true, false)
+	pressKey(editor, "a", true, false)
+
+	// ESC w (M-w)
+	pressKey(editor, "\x1b", false, false)
+	pressKey(editor, "w", false, false)
+
+	if buffer.Content()[0] != "copy me" {
+		t.Errorf("M-w should not modify the buffer, got %q", buffer.Content()[0])
+	}
+	if buffer.MarkActive() {
+		t.Error("Mark should be deactivated after M-w")
+	}
+
+	pressKey(editor, "e", true, false)
+	pressKey(editor, "y", true, false)
+	if buffer.Content()[0] != "copy mecopy me" {
+		t.Errorf("Expected 'copy mecopy me', got %q", buffer.Content()[0])
+	}
+}
+
+/**
+ * @spec killring/yank_pop
+ * @scenario M-y による過去のキルの貼り付け
+ * @description C-y の直後の M-y は貼り付けたテキストを一つ前のキルに置き換える
+ * @given "aaa" と "bbb" を順にキルした状態
+ * @when C-y の後に M-y を押す
+ * @then "bbb" が "aaa" に置き換わる。C-y 直後でなければエラーメッセージが表示される
+ * @implementation domain/kill_ring.go
+ */
+func TestYankPop(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+
+	typeString(editor, "aaa")
+	pressKey(editor, "a", true, false)
+	pressKey(editor, "k", true, false)
+	typeString(editor, "bbb")
+	pressKey(editor, "a", true, false)
+	pressKey(editor, "k", true, false)
+
+	if editor.KillRing().Len() != 2 {
+		t.Fatalf("Expected 2 kill ring entries, got %d", editor.KillRing().Len())
+	}
+
+	pressKey(editor, "y", true, false)
+	if buffer.Content()[0] != "bbb" {
+		t.Fatalf("Expected most recent kill 'bbb', got %q", buffer.Content()[0])
+	}
+
+	pressKey(editor, "y", false, true)
+	if buffer.Content()[0] != "aaa" {
+		t.Errorf("Expected 'aaa' after yank-pop, got %q", buffer.Content()[0])
+	}
+
+	// C-y 以外のコマンドの後では M-y は使えない
+	pressKey(editor, "a", true, false)
+	pressKey(editor, "y", false, true)
+	if editor.Minibuffer().Message() != "Previous command was not a yank" {
+		t.Errorf("Expected yank-pop error message, got %q", editor.Minibuffer().Message())
+	}
+	if buffer.Content()[0] != "aaa" {
+		t.Errorf("Buffer should be unchanged, got %q", buffer.Content()[0])
+	}
+}
+
+/**
+ * @spec killring/no_region
+ * @scenario マーク未設定時の C-w
+ * @description マークが設定されていない場合はエラーメッセージを表示する
+ * @given 新しいバッファにテキストを入力
+ * @when C-w を押す
+ * @then バッファは変更されずメッセージが表示される
+ * @implementation domain/kill_ring.go
+ */
+func TestKillRegionWithoutMark(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+	typeString(editor, "text")
+
+	pressKey(editor, "w", true, false)
+
+	if buffer.Content()[0] != "text" {
+		t.Errorf("Buffer should be unchanged, got %q", buffer.Content()[0])
+	}
+	if editor.Minibuffer().Message() != "The mark is not set now, so there is no region" {
+		t.Errorf("Expected no-region message, got %q", editor.Minibuffer().Message())
+	}
+}
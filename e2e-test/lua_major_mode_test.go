@@ -0,0 +1,90 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/**
+ * @spec lua/major_mode
+ * @scenario Lua で定義したメジャーモードの自動選択とキーマップ
+ * @description gmacs.major_mode で定義したモードが file_patterns に一致するファイルで有効になり、キーマップとフックが動作する
+ * @given "%.foo$" に一致する foo-mode をキーマップ (コマンド名と Lua 関数) とフック付きで定義する
+ * @when .foo ファイルを開き、モードのキーを押し、別のモードに切り替える
+ * @then foo-mode が選択され、キーマップのコマンドと関数が実行され (関数はフックに "lambda" という名前で渡される)、on_activate / on_deactivate が呼ばれる
+ * @implementation lua-config/api_bindings.go, lua-config/lua_pattern.go, domain/custom_mode.go
+ */
+func TestLuaMajorMode(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "sample.foo")
+	if err := os.WriteFile(testFile, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	editor := NewEditorWithLua(`
+gmacs.major_mode("foo-mode", {
+  file_patterns = {"%.foo$"},
+  keymap = {
+    ["C-c e"] = "end-of-line",
+    ["C-c m"] = function()
+      gmacs.set_option("foo-key", "pressed")
+    end,
+  },
+  hooks = {
+    on_activate = function(buf)
+      gmacs.set_option("foo-activated", buf:name())
+    end,
+    on_deactivate = function(buf)
+      gmacs.set_option("foo-deactivated", buf:name())
+    end,
+  },
+})
+gmacs.add_hook("post-command", function(name)
+  gmacs.set_option("foo-command", name)
+end)
+`)
+	defer editor.Cleanup()
+
+	buffer := openFile(t, editor, testFile)
+	if mode := buffer.MajorMode(); mode == nil || mode.Name() != "foo-mode" {
+		t.Fatalf("Expected foo-mode for %s, got %v", testFile, mode)
+	}
+	if got := optionString(t, editor, "foo-activated"); got != buffer.Name() {
+		t.Errorf("Expected on_activate to receive %q, got %q", buffer.Name(), got)
+	}
+
+	// コマンド名で割り当てたキー
+	pressKey(editor, "c", true, false)
+	typeString(editor, "e")
+	if col := buffer.Cursor().Col; col != 5 {
+		t.Errorf("Expected C-c e (end-of-line) to move to column 5, got %d", col)
+	}
+
+	// Lua 関数で割り当てたキー
+	pressKey(editor, "c", true, false)
+	typeString(editor, "m")
+	if got := optionString(t, editor, "foo-key"); got != "pressed" {
+		t.Errorf("Expected C-c m to call the Lua function, foo-key is %q", got)
+	}
+	if got := optionString(t, editor, "foo-command"); got != "lambda" {
+		t.Errorf("Expected hooks to see the Lua function as lambda, got %q", got)
+	}
+
+	// 別のモードに切り替えると on_deactivate が呼ばれる
+	if err := editor.ModeManager().SetMajorMode(buffer, "text-mode"); err != nil {
+		t.Fatal(err)
+	}
+	if got := optionString(t, editor, "foo-deactivated"); got != buffer.Name() {
+		t.Errorf("Expected on_deactivate to receive %q, got %q", buffer.Name(), got)
+	}
+
+	// 他の拡張子のファイルには影響しない
+	other := filepath.Join(tempDir, "sample.txt")
+	if err := os.WriteFile(other, []byte("text\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if mode := openFile(t, editor, other).MajorMode(); mode != nil && mode.Name() == "foo-mode" {
+		t.Errorf("Expected %s not to use foo-mode", other)
+	}
+}
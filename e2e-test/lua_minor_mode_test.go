@@ -0,0 +1,136 @@
+package test
+
+import (
+	"strings"
+	"testing"
+)
+
+/**
+ * @spec lua/minor_mode
+ * @scenario Lua で定義したマイナーモードのトグルとキーマップ
+ * @description gmacs.minor_mode で定義したモードに同名のトグルコマンドが作られ、有効な間だけキーマップが使われる
+ * @given C-f を end-of-line に割り当て、lighter と on_enable / on_disable を持つ shout-mode を定義する
+ * @when M-x shout-mode で有効にして C-f を押し、もう一度 M-x shout-mode で無効にする
+ * @then 有効な間はモードの割り当てが使われ、lighter が返され、on_enable / on_disable が呼ばれる
+ * @implementation lua-config/api_bindings.go, domain/custom_mode.go
+ */
+func TestLuaMinorModeToggle(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.minor_mode("shout-mode", {
+  priority = 20,
+  lighter = "Shout",
+  keymap = { ["C-f"] = "end-of-line" },
+  on_enable = function(buf)
+    gmacs.set_option("shout-enabled", buf:name())
+  end,
+  on_disable = function(buf)
+    gmacs.set_option("shout-disabled", buf:name())
+  end,
+})
+`)
+	defer editor.Cleanup()
+	buffer := editor.CurrentBuffer()
+
+	typeString(editor, "abc")
+	pressKey(editor, "a", true, false)
+
+	// M-x shout-mode で有効にする
+	runCommand(editor, "shout-mode")
+	mode, exists := editor.ModeManager().GetMinorModeByName("shout-mode")
+	if !exists {
+		t.Fatal("shout-mode should be registered")
+	}
+	if !mode.IsEnabled(buffer) {
+		t.Fatal("shout-mode should be enabled after M-x shout-mode")
+	}
+	if mode.Lighter() != "Shout" || mode.Priority() != 20 {
+		t.Errorf("Expected lighter 'Shout' and priority 20, got %q and %d", mode.Lighter(), mode.Priority())
+	}
+	if got := optionString(t, editor, "shout-enabled"); got != "*scratch*" {
+		t.Errorf("Expected on_enable to receive *scratch*, got %q", got)
+	}
+
+	pressKey(editor, "f", true, false)
+	if col := buffer.Cursor().Col; col != 3 {
+		t.Errorf("Expected mode C-f (end-of-line) to move to column 3, got %d", col)
+	}
+
+	// もう一度実行すると無効になり、グローバルの forward-char に戻る
+	runCommand(editor, "shout-mode")
+	if mode.IsEnabled(buffer) {
+		t.Error("shout-mode should be disabled after the second M-x shout-mode")
+	}
+	if got := optionString(t, editor, "shout-disabled"); got != "*scratch*" {
+		t.Errorf("Expected on_disable to receive *scratch*, got %q", got)
+	}
+	pressKey(editor, "a", true, false)
+	pressKey(editor, "f", true, false)
+	if col := buffer.Cursor().Col; col != 1 {
+		t.Errorf("Expected global C-f (forward-char) to move to column 1, got %d", col)
+	}
+}
+
+/**
+ * @spec lua/global_minor_mode
+ * @scenario predicate を持つグローバルマイナーモード
+ * @description predicate を指定したマイナーモードは、条件を満たすバッファで自動的に有効になる
+ * @given バッファ名が "notes" で始まる場合に有効になる notes-mode を定義する
+ * @when "notes-today" と "other" のバッファを作る
+ * @then notes-mode は "notes-today" でのみ有効になり、lighter を省略するとモード名が使われる
+ * @implementation lua-config/api_bindings.go, domain/custom_mode.go, domain/mode.go
+ */
+func TestLuaGlobalMinorMode(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.minor_mode("notes-mode", {
+  predicate = function(buf)
+    return buf:name():find("^notes") ~= nil
+  end,
+})
+`)
+	defer editor.Cleanup()
+
+	mode, exists := editor.ModeManager().GetMinorModeByName("notes-mode")
+	if !exists {
+		t.Fatal("notes-mode should be registered")
+	}
+	if mode.Lighter() != "notes-mode" {
+		t.Errorf("Expected the lighter to default to the mode name, got %q", mode.Lighter())
+	}
+
+	notes := editor.GetOrCreateBuffer("notes-today")
+	other := editor.GetOrCreateBuffer("other")
+	if !mode.IsEnabled(notes) {
+		t.Error("notes-mode should be enabled in notes-today")
+	}
+	if mode.IsEnabled(other) || mode.IsEnabled(editor.CurrentBuffer()) {
+		t.Error("notes-mode should only be enabled in buffers its predicate accepts")
+	}
+}
+
+/**
+ * @spec lua/global_minor_mode_order
+ * @scenario グローバルマイナーモードを有効にする順序
+ * @description 同じバッファに当てはまるグローバルマイナーモードは、定義した順に有効になる。モード行の表示やキーマップの優先順位が起動ごとに変わらない
+ * @given すべてのバッファで有効になる8つのマイナーモードを順に定義する
+ * @when 新しいバッファを作る
+ * @then バッファのマイナーモードが定義した順に並ぶ
+ * @implementation domain/mode.go
+ */
+func TestLuaGlobalMinorModeOrder(t *testing.T) {
+	names := []string{"a-mode", "b-mode", "c-mode", "d-mode", "e-mode", "f-mode", "g-mode", "h-mode"}
+	config := ""
+	for _, name := range names {
+		config += `gmacs.minor_mode("` + name + `", { predicate = function(buf) return true end })` + "\n"
+	}
+	editor := NewEditorWithLua(config)
+	defer editor.Cleanup()
+
+	buffer := editor.GetOrCreateBuffer("notes")
+	var enabled []string
+	for _, mode := range buffer.MinorModes() {
+		enabled = append(enabled, mode.Name())
+	}
+	if strings.Join(enabled, " ") != strings.Join(names, " ") {
+		t.Errorf("Expected the modes in definition order %v, got %v", names, enabled)
+	}
+}
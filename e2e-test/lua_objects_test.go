@@ -0,0 +1,144 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/**
+ * @spec lua/buffer_object
+ * @scenario Lua からバッファのテキストを読み書きする
+ * @description バッファオブジェクトのメソッドで行の取得、挿入、範囲削除、カーソル移動ができる
+ * @given "hello world" と入力したバッファと、バッファを編集する Lua コマンド
+ * @when M-x でコマンドを実行する
+ * @then "world" が削除されて先頭に "> " が挿入され、カーソルと各メソッドの戻り値が期待どおりになる
+ * @implementation lua-config/objects.go, domain/buffer.go
+ */
+func TestLuaBufferObject(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.defun("quote-line", function()
+  local buf = gmacs.current_buffer()
+  local line = buf:get_line(1)
+  local start = line:find("world")
+  local deleted = buf:delete_region({line = 1, col = start}, {line = 1, col = #line + 1})
+  local after = buf:insert("> ", {line = 1, col = 1})
+  buf:set_cursor({line = 1, col = 3})
+  local cursor = buf:cursor()
+  gmacs.set_option("quote-result", string.format("%s|%d:%d|%d:%d|%d|%s|%s",
+    deleted, after.line, after.col, cursor.line, cursor.col,
+    buf:line_count(), tostring(buf:is_modified()), buf:major_mode()))
+end)
+`)
+	defer editor.Cleanup()
+	buffer := editor.CurrentBuffer()
+
+	typeString(editor, "hello world")
+	runCommand(editor, "quote-line")
+
+	if line := buffer.Content()[0]; line != "> hello " {
+		t.Errorf("Expected '> hello ', got %q", line)
+	}
+	if got := optionString(t, editor, "quote-result"); got != "world|1:3|1:3|1|true|fundamental-mode" {
+		t.Errorf("Unexpected results from the buffer methods: %q", got)
+	}
+	if cursor := buffer.Cursor(); cursor.Row != 0 || cursor.Col != 2 {
+		t.Errorf("Expected cursor at (0, 2), got (%d, %d)", cursor.Row, cursor.Col)
+	}
+}
+
+/**
+ * @spec lua/buffer_save
+ * @scenario Lua からバッファを保存する
+ * @description buf:save() はファイルに書き込み、ファイルのないバッファでは nil とエラーメッセージを返す
+ * @given ファイルを開いて編集したバッファと、gmacs.buffers() の全バッファを保存する Lua コマンド
+ * @when M-x でコマンドを実行する
+ * @then ファイルを開いたバッファは保存され、*scratch* の保存はエラーになる
+ * @implementation lua-config/objects.go, domain/file_commands.go
+ */
+func TestLuaBufferSave(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "lua_save.txt")
+	if err := os.WriteFile(testFile, []byte("one\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	editor := NewEditorWithLua(`
+gmacs.defun("save-all", function()
+  local results = {}
+  for _, buf in ipairs(gmacs.buffers()) do
+    local ok, err = buf:save()
+    table.insert(results, buf:name() .. "=" .. (ok and "saved" or "error"))
+  end
+  gmacs.set_option("save-result", table.concat(results, ","))
+end)
+`)
+	defer editor.Cleanup()
+
+	buffer := openFile(t, editor, testFile)
+	typeString(editor, "zero ")
+	runCommand(editor, "save-all")
+
+	if got := optionString(t, editor, "save-result"); got != "*scratch*=error,lua_save.txt=saved" {
+		t.Errorf("Unexpected save results: %q", got)
+	}
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to read saved file: %v", err)
+	}
+	if string(data) != "zero one\n" {
+		t.Errorf("Expected saved content %q, got %q", "zero one\n", string(data))
+	}
+	if buffer.IsModified() {
+		t.Error("Buffer should not be modified after buf:save()")
+	}
+}
+
+/**
+ * @spec lua/window_object
+ * @scenario Lua からウィンドウを分割してバッファを表示する
+ * @description win:split() で分割したウィンドウに win:set_buffer() で別のバッファを表示し、win:scroll_top() でスクロールできる
+ * @given 30行のバッファ "other" と、ウィンドウを操作する Lua コマンド
+ * @when M-x でコマンドを実行する
+ * @then 右に分割されたウィンドウが選択されて "other" を表示し、元のウィンドウは *scratch* のまま 10 行目から表示される
+ * @implementation lua-config/objects.go, domain/window_commands.go, domain/editor.go
+ */
+func TestLuaWindowObject(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.defun("show-other", function()
+  local win = gmacs.current_window()
+  local other
+  for _, buf in ipairs(gmacs.buffers()) do
+    if buf:name() == "other" then other = buf end
+  end
+  local new = win:split("right")
+  new:set_buffer(other)
+  new:scroll_top(10)
+  gmacs.set_option("window-result", string.format("%s|%s|%d|%s",
+    tostring(new == gmacs.current_window()), new:buffer():name(),
+    new:scroll_top(), win:buffer():name()))
+end)
+`)
+	defer editor.Cleanup()
+	scratch := editor.CurrentBuffer()
+
+	other := editor.GetOrCreateBuffer("other")
+	for i := 0; i < 29; i++ {
+		other.InsertString("line\n")
+	}
+	runCommand(editor, "show-other")
+
+	if got := optionString(t, editor, "window-result"); got != "true|other|10|*scratch*" {
+		t.Errorf("Unexpected window results: %q", got)
+	}
+	windows := editor.Layout().GetAllWindows()
+	if len(windows) != 2 {
+		t.Fatalf("Expected 2 windows, got %d", len(windows))
+	}
+	if windows[0].Buffer() != scratch || windows[1].Buffer() != other {
+		t.Error("Expected *scratch* on the left and other on the right")
+	}
+	if top := windows[1].ScrollTop(); top != 9 {
+		t.Errorf("Expected the new window to start at row 9, got %d", top)
+	}
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+/**
+ * @spec minibuffer/history_navigation
+ * @scenario ミニバッファの履歴の呼び出し
+ * @description 入力したコマンド名は M-x の履歴に残り、M-p で古いもの、M-n で新しいものを呼び出せる。M-n で最後まで戻ると入力途中の文字列に戻る。M-r は入力した文字列を含む古い履歴を探す。履歴はプロンプトの種類ごとに別になっている
+ * @given M-x で forward-char、end-of-line、forward-word、end-of-line を順に実行したエディタ
+ * @when M-x で "x" を入力してから M-p と M-n を押し、"forward" を入力して M-r を2回押し、C-x b で M-p を押す
+ * @then 重複のない新しい順にコマンド名が表示され、入力途中の "x" に戻り、M-r で forward-word、forward-char の順に見つかり、バッファ名の履歴にはコマンド名が出てこない
+ * @implementation domain/history.go, domain/minibuffer.go
+ */
+func TestMinibufferHistoryNavigation(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	minibuffer := editor.Minibuffer()
+
+	runCommand(editor, "forward-char")
+	runCommand(editor, "end-of-line")
+	runCommand(editor, "forward-word")
+	runCommand(editor, "end-of-line") // 重複は先頭へ移る
+
+	pressKey(editor, "x", false, true)
+	typeString(editor, "x")
+	steps := []struct {
+		key  string
+		want string
+	}{
+		{"p", "end-of-line"},
+		{"p", "forward-word"},
+		{"p", "forward-char"},
+		{"p", "forward-char"}, // 最も古い履歴で止まる
+		{"n", "forward-word"},
+		{"n", "end-of-line"},
+		{"n", "x"}, // 入力途中の文字列に戻る
+		{"n", "x"},
+	}
+	for i, step := range steps {
+		pressKey(editor, step.key, false, true)
+		if got := minibuffer.Content(); got != step.want {
+			t.Errorf("Step %d (M-%s): expected %q, got %q", i, step.key, step.want, got)
+		}
+	}
+
+	// M-r は入力した文字列を含む履歴を古い方へ探す
+	pressKey(editor, "g", true, false)
+	pressKey(editor, "x", false, true)
+	typeString(editor, "forward")
+	pressKey(editor, "r", false, true)
+	if got := minibuffer.Content(); got != "forward-word" {
+		t.Errorf("Expected M-r to find forward-word, got %q", got)
+	}
+	pressKey(editor, "r", false, true)
+	if got := minibuffer.Content(); got != "forward-char" {
+		t.Errorf("Expected a second M-r to find forward-char, got %q", got)
+	}
+	pressEnter(editor)
+
+	// バッファ名の履歴は別になっている
+	pressKey(editor, "x", true, false)
+	typeString(editor, "b")
+	pressKey(editor, "p", false, true)
+	if got := minibuffer.Content(); got != "" {
+		t.Errorf("Expected an empty buffer name history, got %q", got)
+	}
+	typeString(editor, "notes")
+	pressEnter(editor)
+	pressKey(editor, "x", true, false)
+	typeString(editor, "b")
+	pressKey(editor, "p", false, true)
+	if got := minibuffer.Content(); got != "notes" {
+		t.Errorf("Expected the buffer name history, got %q", got)
+	}
+}
+
+/**
+ * @spec minibuffer/search_history
+ * @scenario 検索文字列と置換文字列の履歴
+ * @description isearch の検索文字列と query-replace の置換前の文字列は検索の履歴に、置換後の文字列は置換の履歴に残る。isearch 中の M-p は履歴の文字列で検索し直す
+ * @given "foo bar foo" のバッファで "foo" を isearch し、"bar" を "baz" に置換したエディタ
+ * @when query-replace のそれぞれのプロンプトで M-p を押し、isearch 中に M-p を押す
+ * @then 置換前のプロンプトには "bar"、"foo" の順に、置換後のプロンプトには "baz" が出て、isearch は最後に使った "foo" を検索する
+ * @implementation domain/history.go, domain/isearch.go, domain/query_replace.go
+ */
+func TestSearchAndReplaceHistory(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "foo bar foo")
+	minibuffer := editor.Minibuffer()
+
+	pressKey(editor, "s", true, false)
+	typeString(editor, "foo")
+	pressEnter(editor)
+
+	buffer.SetCursor(domain.Position{Row: 0, Col: 0})
+	pressKey(editor, "%", false, true)
+	typeString(editor, "bar")
+	pressEnter(editor)
+	typeString(editor, "baz")
+	pressEnter(editor)
+	typeString(editor, "!")
+	if got := buffer.Line(0); got != "foo baz foo" {
+		t.Fatalf("Expected the replacement, got %q", got)
+	}
+
+	buffer.SetCursor(domain.Position{Row: 0, Col: 0})
+	pressKey(editor, "%", false, true)
+	pressKey(editor, "p", false, true)
+	if got := minibuffer.Content(); got != "bar" {
+		t.Errorf("Expected the last search string, got %q", got)
+	}
+	pressKey(editor, "p", false, true)
+	if got := minibuffer.Content(); got != "foo" {
+		t.Errorf("Expected the isearch string, got %q", got)
+	}
+	pressEnter(editor)
+	pressKey(editor, "p", false, true)
+	if got := minibuffer.Content(); got != "baz" {
+		t.Errorf("Expected the last replacement, got %q", got)
+	}
+	pressKey(editor, "g", true, false)
+
+	// isearch 中の M-p は履歴の文字列で検索し直す
+	buffer.SetCursor(domain.Position{Row: 0, Col: 0})
+	pressKey(editor, "s", true, false)
+	pressKey(editor, "p", false, true)
+	if got := minibuffer.Content(); got != "foo" {
+		t.Errorf("Expected isearch to recall foo, got %q", got)
+	}
+	if cursor := buffer.Cursor(); cursor != (domain.Position{Row: 0, Col: 3}) {
+		t.Errorf("Expected the cursor after the first foo, got %+v", cursor)
+	}
+	pressEnter(editor)
+}
+
+/**
+ * @spec minibuffer/history_file
+ * @scenario 履歴の保存と読み込み
+ * @description 履歴はプロンプトの種類ごとにファイルへ保存され、次の起動時に読み込まれる。ファイルがなければ履歴は空のまま、壊れたファイルはエラーになる
+ * @given ファイル名とコマンド名の履歴を持つエディタ
+ * @when 履歴を保存し、別のエディタで読み込む
+ * @then 同じ履歴が復元され、M-p で呼び出せる
+ * @implementation domain/history.go, main.go
+ */
+func TestHistorySaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".gmacs", "history")
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	history := editor.Minibuffer().History()
+	history.Add(domain.HistoryFile, "/tmp/a.txt")
+	history.Add(domain.HistoryFile, "/tmp/b.txt")
+	history.Add(domain.HistoryCommand, "forward-char")
+	if err := history.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewEditorWithDefaults()
+	defer restored.Cleanup()
+	if err := restored.Minibuffer().History().Load(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []domain.HistoryKind{domain.HistoryFile, domain.HistoryCommand, domain.HistorySearch} {
+		if got, want := restored.Minibuffer().History().Entries(kind), history.Entries(kind); !reflect.DeepEqual(got, want) {
+			t.Errorf("History %s: expected %q, got %q", kind, want, got)
+		}
+	}
+	pressKey(restored, "x", true, false)
+	pressKey(restored, "f", true, false)
+	pressKey(restored, "p", false, true)
+	if got := restored.Minibuffer().Content(); got != "/tmp/b.txt" {
+		t.Errorf("Expected the restored file history, got %q", got)
+	}
+
+	// ファイルがなければ空、壊れていればエラー
+	if err := NewEditorWithDefaults().Minibuffer().History().Load(filepath.Join(t.TempDir(), "none")); err != nil {
+		t.Errorf("Expected no error for a missing file, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Minibuffer().History().Load(path); err == nil || !strings.Contains(err.Error(), "Invalid history file") {
+		t.Errorf("Expected an invalid history error, got %v", err)
+	}
+}
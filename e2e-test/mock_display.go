@@ -1,10 +1,12 @@
 package test
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 	"unicode/utf8"
 
+	"github.com/TakahashiShuuhei/gmacs/cli"
 	"github.com/TakahashiShuuhei/gmacs/domain"
 	"github.com/TakahashiShuuhei/gmacs/util"
 )
@@ -18,22 +20,33 @@ type MockDisplay struct {
 	modeLine     string
 	minibuffer   string
 	renderCount  int
+	terminal     *cli.Display  // The real display, writing to output
+	output       bytes.Buffer  // Escape sequences written by the last Render
+	emulator     *terminalEmulator
 }
 
 func NewMockDisplay(width, height int) *MockDisplay {
 	// MockDisplay should match actual Display behavior
 	// The content area size should match what the window reports
 	contentHeight := height - 2  // Reserve 2 lines for mode line and minibuffer
-	return &MockDisplay{
-		width:   width,
-		height:  height,
-		content: make([]string, contentHeight),
+	d := &MockDisplay{
+		width:    width,
+		height:   height,
+		content:  make([]string, contentHeight),
+		emulator: newTerminalEmulator(width, height),
 	}
+	d.terminal = cli.NewDisplayWithWriter(&d.output, width, height)
+	return d
 }
 
 func (d *MockDisplay) Render(editor *domain.Editor) {
 	d.renderCount++
 	
+	// Run the real display too, so that tests can check what it sends
+	d.output.Reset()
+	d.terminal.Render(editor)
+	d.emulator.write(d.output.String())
+	
 	layout := editor.Layout()
 	if layout == nil {
 		return
@@ -150,6 +163,30 @@ func (d *MockDisplay) Resize(width, height int) {
 	d.width = width
 	d.height = height
 	d.content = make([]string, height-2) // Update content array size
+	d.terminal.Resize(width, height)
+	d.emulator = newTerminalEmulator(width, height)
+}
+
+// SetColorProfile sets the colors the real display draws faces with
+func (d *MockDisplay) SetColorProfile(profile cli.ColorProfile) {
+	d.terminal.SetColorProfile(profile)
+}
+
+// EscapeStream returns what the real display wrote to the terminal in the
+// last Render
+func (d *MockDisplay) EscapeStream() string {
+	return d.output.String()
+}
+
+// TerminalLines returns the lines a terminal shows after receiving all the
+// output of the real display
+func (d *MockDisplay) TerminalLines() []string {
+	return d.emulator.lines()
+}
+
+// TerminalCursor returns where the terminal cursor is after the last Render
+func (d *MockDisplay) TerminalCursor() (int, int) {
+	return d.emulator.row, d.emulator.col
 }
 
 // Get full screen representation as string
@@ -391,4 +428,85 @@ func (d *MockDisplay) insertCharAt(row, col int, char rune) {
 	
 	existingRunes[col] = char
 	d.content[row] = string(existingRunes)
-}
\ No newline at end of file
+}
+
+// terminalEmulator applies the escape sequences used by cli.Display to a
+// grid of characters
+type terminalEmulator struct {
+	width, height int
+	cells         [][]rune // 0 marks the second column of a wide character
+	row, col      int
+}
+
+func newTerminalEmulator(width, height int) *terminalEmulator {
+	t := &terminalEmulator{width: width, height: height}
+	t.clear()
+	return t
+}
+
+func (t *terminalEmulator) clear() {
+	t.cells = make([][]rune, t.height)
+	for i := range t.cells {
+		t.cells[i] = []rune(strings.Repeat(" ", t.width))
+	}
+}
+
+// write interprets cursor positioning, screen clearing and text; other
+// sequences such as styles are skipped
+func (t *terminalEmulator) write(s string) {
+	for len(s) > 0 {
+		if strings.HasPrefix(s, "\033[") {
+			end := strings.IndexFunc(s[2:], func(r rune) bool { return r >= '@' && r <= '~' }) + 2
+			params, command := s[2:end], s[end]
+			switch {
+			case command == 'H':
+				t.row, t.col = 0, 0
+				fmt.Sscanf(params, "%d;%d", &t.row, &t.col)
+				if params != "" {
+					t.row--
+					t.col--
+				}
+			case command == 'J' && params == "2":
+				t.clear()
+			}
+			s = s[end+1:]
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		width := util.RuneWidth(r)
+		if t.row < 0 || t.row >= t.height || t.col+width > t.width || width == 0 {
+			continue
+		}
+		// Overwriting half of a wide character erases the other half
+		line := t.cells[t.row]
+		for c := t.col; c < t.col+width; c++ {
+			if line[c] == 0 && c > 0 {
+				line[c-1] = ' '
+			}
+			if c+1 < t.width && line[c+1] == 0 {
+				line[c+1] = ' '
+			}
+		}
+		line[t.col] = r
+		if width == 2 {
+			line[t.col+1] = 0
+		}
+		t.col += width
+	}
+}
+
+func (t *terminalEmulator) lines() []string {
+	lines := make([]string, t.height)
+	for i, row := range t.cells {
+		var sb strings.Builder
+		for _, r := range row {
+			if r != 0 {
+				sb.WriteRune(r)
+			}
+		}
+		lines[i] = sb.String()
+	}
+	return lines
+}
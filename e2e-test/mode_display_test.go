@@ -54,7 +54,7 @@ func TestMajorModeDisplay(t *testing.T) {
  * @given エディタとモードマネージャーが存在する
  * @when 様々な拡張子のファイルを処理する
  * @then 各ファイルに適切なメジャーモードが設定される
- * @implementation domain/mode.go, domain/text_mode.go
+ * @implementation domain/mode.go, domain/text_mode.go, domain/go_mode.go
  */
 func TestFileExtensionModeMapping(t *testing.T) {
 	editor := NewEditorWithDefaults()
@@ -70,7 +70,8 @@ func TestFileExtensionModeMapping(t *testing.T) {
 		{"notes.text", "text-mode"},
 		{"article.markdown", "text-mode"},
 		{"todo.org", "text-mode"},
-		{"script.go", "fundamental-mode"}, // Goファイル用モードがないので fundamental-mode
+		{"script.go", "go-mode"},
+		{"script.py", "fundamental-mode"}, // Python 用モードがないので fundamental-mode
 		{"unknown", "fundamental-mode"},    // 拡張子なし
 	}
 	
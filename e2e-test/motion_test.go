@@ -0,0 +1,204 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+/**
+ * @spec motion/word
+ * @scenario 単語単位の移動 (英語と日本語)
+ * @description M-f / M-b は単語の末尾・先頭へ移動する。日本語は漢字・ひらがな・カタカナの境界を単語の区切りとし、句読点や空白は単語に含めない
+ * @given "hello, world foo_bar" と "日本語のテキストを編集する。" と "next" の3行
+ * @when 各行で M-f / M-b を繰り返し、C-u 2 M-b を実行する
+ * @then カーソルが単語境界ごとに止まり、行末の句読点と改行を越えて次の行の単語へ移動する
+ * @implementation domain/word.go, domain/cursor.go
+ */
+func TestWordMotion(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "hello, world foo_bar", "日本語のテキストを編集する。", "next")
+
+	// 英語: "_" は単語の区切り
+	for _, col := range []int{5, 12, 16, 20} {
+		pressKey(editor, "f", false, true)
+		if cursor := buffer.Cursor(); cursor.Row != 0 || cursor.Col != col {
+			t.Errorf("M-f: expected (0, %d), got (%d, %d)", col, cursor.Row, cursor.Col)
+		}
+	}
+	pressKey(editor, "b", false, true)
+	if col := buffer.Cursor().Col; col != 17 {
+		t.Errorf("M-b: expected column 17, got %d", col)
+	}
+
+	// 日本語: 日本語|の|テキスト|を|編集|する|。
+	buffer.SetCursor(domain.Position{Row: 1, Col: 0})
+	line := buffer.Line(1)
+	for _, word := range []string{"日本語", "日本語の", "日本語のテキスト", "日本語のテキストを", "日本語のテキストを編集", "日本語のテキストを編集する"} {
+		pressKey(editor, "f", false, true)
+		if cursor := buffer.Cursor(); cursor.Row != 1 || line[:cursor.Col] != word {
+			t.Errorf("M-f: expected to stop after %q, got (%d, %d)", word, cursor.Row, cursor.Col)
+		}
+	}
+
+	// 句読点と改行を越えて次の行の単語の末尾へ
+	pressKey(editor, "f", false, true)
+	if cursor := buffer.Cursor(); cursor.Row != 2 || cursor.Col != 4 {
+		t.Errorf("M-f: expected (2, 4), got (%d, %d)", cursor.Row, cursor.Col)
+	}
+
+	// C-u 2 M-b で "next" の先頭を経て前の行の "する" の先頭へ
+	pressKey(editor, "u", true, false)
+	typeString(editor, "2")
+	pressKey(editor, "b", false, true)
+	if cursor := buffer.Cursor(); cursor.Row != 1 || line[:cursor.Col] != "日本語のテキストを編集" {
+		t.Errorf("C-u 2 M-b: expected the start of %q, got (%d, %d)", "する", cursor.Row, cursor.Col)
+	}
+}
+
+/**
+ * @spec motion/buffer_paragraph
+ * @scenario バッファ・段落単位の移動
+ * @description M-< / M-> はバッファの先頭・末尾へ移動して元の位置にマークを残す。M-} / M-{ は空行で区切られた段落の後・前の空行へ移動する
+ * @given 空行で区切られた3つの段落
+ * @when M->、M-<、M-} と M-{ をそれぞれ3回実行する
+ * @then カーソルが末尾・先頭・各段落の境界へ移動し、M-> の前の位置にマークが設定される
+ * @implementation domain/cursor.go
+ */
+func TestBufferAndParagraphMotion(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "one", "two", "", "three", "", "", "four", "five")
+	buffer.SetCursor(domain.Position{Row: 1, Col: 1})
+
+	pressKey(editor, ">", false, true)
+	if cursor := buffer.Cursor(); cursor.Row != 7 || cursor.Col != 4 {
+		t.Errorf("M->: expected (7, 4), got (%d, %d)", cursor.Row, cursor.Col)
+	}
+	if mark, ok := buffer.Mark(); !ok || mark.Row != 1 || mark.Col != 1 || buffer.MarkActive() {
+		t.Errorf("M->: expected an inactive mark at (1, 1), got %v (set %t)", mark, ok)
+	}
+
+	pressKey(editor, "<", false, true)
+	if cursor := buffer.Cursor(); cursor.Row != 0 || cursor.Col != 0 {
+		t.Errorf("M-<: expected (0, 0), got (%d, %d)", cursor.Row, cursor.Col)
+	}
+
+	// M-} は段落の後の空行、最後の段落ではバッファ末尾
+	for _, want := range []domain.Position{{Row: 2, Col: 0}, {Row: 4, Col: 0}, {Row: 7, Col: 4}} {
+		pressKey(editor, "}", false, true)
+		if cursor := buffer.Cursor(); cursor != want {
+			t.Errorf("M-}: expected (%d, %d), got (%d, %d)", want.Row, want.Col, cursor.Row, cursor.Col)
+		}
+	}
+
+	// M-{ は段落の前の空行、最初の段落ではバッファ先頭
+	for _, want := range []domain.Position{{Row: 5, Col: 0}, {Row: 2, Col: 0}, {Row: 0, Col: 0}} {
+		pressKey(editor, "{", false, true)
+		if cursor := buffer.Cursor(); cursor != want {
+			t.Errorf("M-{: expected (%d, %d), got (%d, %d)", want.Row, want.Col, cursor.Row, cursor.Col)
+		}
+	}
+}
+
+/**
+ * @spec motion/goto_line_indentation
+ * @scenario 指定行への移動とインデント位置への移動
+ * @description M-g g はミニバッファで行番号を尋ね、数値の前置引数があればそれを使う。M-m は行の最初の空白以外の文字へ移動する
+ * @given 全角空白を含むインデントされた行を含む5行のバッファ
+ * @when M-g g で 3 を入力、M-4 M-g M-g、範囲外の 99 と数字以外の入力、M-m を実行する
+ * @then 指定行の先頭へ移動し、範囲外は最終行に丸められ、数字以外ではメッセージを表示する。M-m でインデントの後へ移動する
+ * @implementation domain/cursor.go
+ */
+func TestGotoLineAndBackToIndentation(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := setupLines(editor, "first", "second", "\t  third", "　全角インデント", "last")
+
+	pressKey(editor, "g", false, true)
+	typeString(editor, "g")
+	if prompt := editor.Minibuffer().Prompt(); prompt != "Goto line: " {
+		t.Errorf("Expected 'Goto line: ' prompt, got %q", prompt)
+	}
+	typeString(editor, "3")
+	pressEnter(editor)
+	if cursor := buffer.Cursor(); cursor.Row != 2 || cursor.Col != 0 {
+		t.Errorf("goto-line 3: expected (2, 0), got (%d, %d)", cursor.Row, cursor.Col)
+	}
+
+	pressKey(editor, "m", false, true)
+	if col := buffer.Cursor().Col; col != 3 {
+		t.Errorf("M-m: expected column 3, got %d", col)
+	}
+
+	// 数値の前置引数では尋ねない
+	pressKey(editor, "4", false, true)
+	pressKey(editor, "g", false, true)
+	pressKey(editor, "g", false, true)
+	if cursor := buffer.Cursor(); cursor.Row != 3 {
+		t.Errorf("M-4 M-g M-g: expected row 3, got %d", cursor.Row)
+	}
+	if prompt := editor.Minibuffer().Prompt(); prompt != "" {
+		t.Errorf("Expected no prompt with a numeric prefix argument, got %q", prompt)
+	}
+
+	// 全角空白もインデントとして扱う
+	pressKey(editor, "e", true, false)
+	pressKey(editor, "m", false, true)
+	if col := buffer.Cursor().Col; col != len("　") {
+		t.Errorf("M-m: expected to skip the full-width space, got column %d", col)
+	}
+
+	runCommand(editor, "goto-line")
+	typeString(editor, "99")
+	pressEnter(editor)
+	if row := buffer.Cursor().Row; row != 4 {
+		t.Errorf("goto-line 99: expected the last line, got row %d", row)
+	}
+
+	runCommand(editor, "goto-line")
+	typeString(editor, "abc")
+	pressEnter(editor)
+	if msg := editor.Minibuffer().Message(); msg != "Please enter a number" {
+		t.Errorf("Expected 'Please enter a number', got %q", msg)
+	}
+}
+
+/**
+ * @spec motion/recenter
+ * @scenario C-l によるカーソル行の中央・上端・下端への表示
+ * @description C-l を続けて押すと、カーソル行がウィンドウの中央、上端、下端の順に表示される。数値の前置引数では指定したウィンドウの行に表示する
+ * @given 100行のバッファで50行目にカーソルがある
+ * @when C-l を3回押し、C-u 3 C-l を実行する
+ * @then ScrollTop がそれぞれ中央・上端・下端・3行目に合わせた値になる
+ * @implementation domain/scroll.go
+ */
+func TestRecenterTopBottom(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	buffer := setupLines(editor, lines...)
+	buffer.SetCursor(domain.Position{Row: 49, Col: 0})
+
+	window := editor.CurrentWindow()
+	_, height := window.Size()
+
+	for _, want := range []int{49 - height/2, 49, 49 - height + 1} {
+		pressKey(editor, "l", true, false)
+		if top := window.ScrollTop(); top != want {
+			t.Errorf("C-l: expected scroll top %d, got %d", want, top)
+		}
+	}
+
+	pressKey(editor, "u", true, false)
+	typeString(editor, "3")
+	pressKey(editor, "l", true, false)
+	if top := window.ScrollTop(); top != 46 {
+		t.Errorf("C-u 3 C-l: expected scroll top 46, got %d", top)
+	}
+}
@@ -0,0 +1,174 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+/**
+ * @spec prefix_arg/self_insert
+ * @scenario 前置引数による文字の繰り返し入力
+ * @description C-u は 4、C-u C-u は 16、C-u に続く数字や M-<数字> はその値を繰り返し回数にする
+ * @given 空のバッファ
+ * @when C-u a、C-u C-u b、C-u 1 2 c、M-3 d、C-u 3 C-u 1 を入力する
+ * @then それぞれ 4、16、12、3 回挿入され、数字の後の C-u で引数が確定して "1" が 3 回挿入される
+ * @implementation domain/prefix_arg.go, domain/editor.go
+ */
+func TestPrefixArgSelfInsert(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := editor.CurrentBuffer()
+
+	cases := []struct {
+		name string
+		keys func()
+		want string
+	}{
+		{"C-u a", func() {
+			pressKey(editor, "u", true, false)
+			typeString(editor, "a")
+		}, strings.Repeat("a", 4)},
+		{"C-u C-u b", func() {
+			pressKey(editor, "u", true, false)
+			pressKey(editor, "u", true, false)
+			typeString(editor, "b")
+		}, strings.Repeat("b", 16)},
+		{"C-u 1 2 c", func() {
+			pressKey(editor, "u", true, false)
+			typeString(editor, "12c")
+		}, strings.Repeat("c", 12)},
+		{"M-3 d", func() {
+			pressKey(editor, "3", false, true)
+			typeString(editor, "d")
+		}, strings.Repeat("d", 3)},
+		{"C-u 3 C-u 1", func() {
+			pressKey(editor, "u", true, false)
+			typeString(editor, "3")
+			pressKey(editor, "u", true, false)
+			typeString(editor, "1")
+		}, strings.Repeat("1", 3)},
+	}
+
+	for _, c := range cases {
+		buffer.Clear()
+		c.keys()
+		if got := buffer.Content()[0]; got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, got)
+		}
+	}
+
+	// 前置引数は次のコマンドだけに適用される
+	buffer.Clear()
+	pressKey(editor, "u", true, false)
+	typeString(editor, "xy")
+	if got := buffer.Content()[0]; got != "xxxxy" {
+		t.Errorf("Expected the argument to apply to one command only, got %q", got)
+	}
+}
+
+/**
+ * @spec prefix_arg/motion
+ * @scenario 前置引数による移動・削除・スクロール
+ * @description forward-char、next-line、delete-char、page-down が前置引数の回数だけ動作し、負の引数では逆方向に動作する
+ * @given 複数行のテキストがあるバッファ
+ * @when C-u 3 C-f、M-- C-f、C-u 2 C-n、C-u 3 C-d、C-u 2 C-v を実行する
+ * @then カーソル・テキスト・スクロール位置が前置引数に応じて変化する
+ * @implementation domain/cursor.go, domain/command.go, domain/scroll.go
+ */
+func TestPrefixArgMotion(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := editor.CurrentBuffer()
+
+	for i := 0; i < 99; i++ {
+		buffer.InsertString("0123456789\n")
+	}
+	buffer.SetCursor(domain.Position{Row: 0, Col: 0})
+
+	// C-u 3 C-f
+	pressKey(editor, "u", true, false)
+	typeString(editor, "3")
+	pressKey(editor, "f", true, false)
+	if col := buffer.Cursor().Col; col != 3 {
+		t.Errorf("Expected C-u 3 C-f to move to column 3, got %d", col)
+	}
+
+	// M-- C-f は後方へ1文字
+	pressKey(editor, "-", false, true)
+	pressKey(editor, "f", true, false)
+	if col := buffer.Cursor().Col; col != 2 {
+		t.Errorf("Expected M-- C-f to move back to column 2, got %d", col)
+	}
+
+	// C-u 2 C-n
+	pressKey(editor, "u", true, false)
+	typeString(editor, "2")
+	pressKey(editor, "n", true, false)
+	if cursor := buffer.Cursor(); cursor.Row != 2 || cursor.Col != 2 {
+		t.Errorf("Expected C-u 2 C-n to move to (2, 2), got (%d, %d)", cursor.Row, cursor.Col)
+	}
+
+	// C-u 3 C-d
+	pressKey(editor, "u", true, false)
+	typeString(editor, "3")
+	pressKey(editor, "d", true, false)
+	if line := buffer.Content()[2]; line != "0156789" {
+		t.Errorf("Expected C-u 3 C-d to delete 3 characters, got %q", line)
+	}
+
+	// C-u 2 C-v は2画面分スクロールする
+	window := editor.CurrentWindow()
+	_, height := window.Size()
+	window.SetScrollTop(0)
+	pressKey(editor, "u", true, false)
+	typeString(editor, "2")
+	pressKey(editor, "v", true, false)
+	if top := window.ScrollTop(); top != 2*height {
+		t.Errorf("Expected C-u 2 C-v to scroll to %d, got %d", 2*height, top)
+	}
+}
+
+/**
+ * @spec prefix_arg/lua_defun
+ * @scenario Lua コマンドへの前置引数の受け渡し
+ * @description gmacs.defun で定義した関数は前置引数の数値を受け取り、引数がなければ nil を受け取る
+ * @given 受け取った引数を記録する Lua コマンドを C-c a に割り当てる
+ * @when C-c a、C-u C-c a、C-u 7 C-c a を実行する
+ * @then nil、4、7 が記録され、入力途中の前置引数がエコー領域に表示される
+ * @implementation lua-config/api_bindings.go, domain/prefix_arg.go
+ */
+func TestPrefixArgLuaDefun(t *testing.T) {
+	editor := NewEditorWithLua(`
+gmacs.defun("record-arg", function(arg)
+  gmacs.set_option("recorded-arg", tostring(arg))
+end)
+gmacs.bind_key("C-c a", "record-arg")
+`)
+	defer editor.Cleanup()
+
+	pressKey(editor, "c", true, false)
+	typeString(editor, "a")
+	if got := optionString(t, editor, "recorded-arg"); got != "nil" {
+		t.Errorf("Expected nil without a prefix argument, got %q", got)
+	}
+
+	pressKey(editor, "u", true, false)
+	pressKey(editor, "c", true, false)
+	typeString(editor, "a")
+	if got := optionString(t, editor, "recorded-arg"); got != "4" {
+		t.Errorf("Expected 4 after C-u, got %q", got)
+	}
+
+	pressKey(editor, "u", true, false)
+	typeString(editor, "7")
+	if seq := editor.GetKeySequenceInProgress(); seq != "C-u 7-" {
+		t.Errorf("Expected 'C-u 7-' in the echo area, got %q", seq)
+	}
+	pressKey(editor, "c", true, false)
+	typeString(editor, "a")
+	if got := optionString(t, editor, "recorded-arg"); got != "7" {
+		t.Errorf("Expected 7 after C-u 7, got %q", got)
+	}
+}
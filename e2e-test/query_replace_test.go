@@ -0,0 +1,193 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// startReplace runs M-% (or M-x replace-regexp) and answers both prompts
+func startReplace(editor *domain.Editor, regexp bool, from, to string) {
+	if regexp {
+		editor.HandleEvent(events.KeyEventData{Key: "x", Meta: true})
+		typeString(editor, "replace-regexp")
+		pressEnter(editor)
+	} else {
+		editor.HandleEvent(events.KeyEventData{Key: "\x1b"})
+		editor.HandleEvent(events.KeyEventData{Key: "%", Rune: '%'})
+	}
+	typeString(editor, from)
+	pressEnter(editor)
+	typeString(editor, to)
+	pressEnter(editor)
+}
+
+/**
+ * @spec replace/query_replace
+ * @scenario M-% による対話的置換
+ * @description y/n で各マッチを置換するかどうかを選び、最後に置換数を表示する
+ * @given "cat dog cat" "cat" の2行があり、カーソルが先頭にある
+ * @when M-% で "cat" を "cow" に置換し、y, n, y と答える
+ * @then 1つ目と3つ目だけが置換され、"Replaced 2 occurrences" が表示される
+ * @implementation domain/query_replace.go, domain/minibuffer.go
+ */
+func TestQueryReplace(t *testing.T) {
+	editor, buffer := setupSearchBuffer("cat dog cat", "cat")
+
+	editor.HandleEvent(events.KeyEventData{Key: "\x1b"})
+	editor.HandleEvent(events.KeyEventData{Key: "%", Rune: '%'})
+	if editor.Minibuffer().Prompt() != "Query replace: " {
+		t.Fatalf("Expected 'Query replace: ' prompt, got %q", editor.Minibuffer().Prompt())
+	}
+	typeString(editor, "cat")
+	pressEnter(editor)
+	if editor.Minibuffer().Prompt() != "Query replace cat with: " {
+		t.Fatalf("Expected second prompt, got %q", editor.Minibuffer().Prompt())
+	}
+	typeString(editor, "cow")
+	pressEnter(editor)
+
+	if editor.Minibuffer().Mode() != domain.MinibufferQueryReplace {
+		t.Fatalf("Expected MinibufferQueryReplace mode, got %v", editor.Minibuffer().Mode())
+	}
+
+	typeString(editor, "y")
+	typeString(editor, "n")
+	typeString(editor, "y")
+
+	content := buffer.Content()
+	if content[0] != "cow dog cat" || content[1] != "cow" {
+		t.Errorf("Unexpected content after query-replace: %q", content)
+	}
+	if editor.Minibuffer().Message() != "Replaced 2 occurrences" {
+		t.Errorf("Expected 'Replaced 2 occurrences', got %q", editor.Minibuffer().Message())
+	}
+}
+
+/**
+ * @spec replace/replace_all_undo
+ * @scenario "!" による一括置換とアンドゥ
+ * @description "!" で残りのマッチをすべて置換し、置換全体を1回のアンドゥで取り消せる
+ * @given "a-a-a" と入力したバッファ
+ * @when M-% で "a" を "bb" に置換し "!" と答えた後、C-/ を押す
+ * @then 3つとも置換され、アンドゥ1回で元に戻る
+ * @implementation domain/query_replace.go, domain/undo.go
+ */
+func TestQueryReplaceAllUndo(t *testing.T) {
+	editor, buffer := setupSearchBuffer("a-a-a")
+
+	startReplace(editor, false, "a", "bb")
+	typeString(editor, "!")
+
+	if buffer.Content()[0] != "bb-bb-bb" {
+		t.Errorf("Expected 'bb-bb-bb', got %q", buffer.Content()[0])
+	}
+	if editor.Minibuffer().Message() != "Replaced 3 occurrences" {
+		t.Errorf("Expected 'Replaced 3 occurrences', got %q", editor.Minibuffer().Message())
+	}
+
+	pressUndo(editor)
+	if buffer.Content()[0] != "a-a-a" {
+		t.Errorf("Expected a single undo to restore 'a-a-a', got %q", buffer.Content()[0])
+	}
+}
+
+/**
+ * @spec replace/replace_regexp
+ * @scenario 正規表現置換とグループ参照
+ * @description replace-regexp は \1 形式のグループ参照を置換文字列で使える
+ * @given "key=value" "name=gmacs" の2行があるバッファ
+ * @when M-x replace-regexp で "(\w+)=(\w+)" を "\2: \1" に置換し "!" と答える
+ * @then 各行の左右が入れ替わる
+ * @implementation domain/query_replace.go
+ */
+func TestReplaceRegexpGroups(t *testing.T) {
+	editor, buffer := setupSearchBuffer("key=value", "name=gmacs")
+
+	startReplace(editor, true, `(\w+)=(\w+)`, `\2: \1`)
+	typeString(editor, "!")
+
+	content := buffer.Content()
+	if content[0] != "value: key" || content[1] != "gmacs: name" {
+		t.Errorf("Unexpected content after replace-regexp: %q", content)
+	}
+	if editor.Minibuffer().Message() != "Replaced 2 occurrences" {
+		t.Errorf("Expected 'Replaced 2 occurrences', got %q", editor.Minibuffer().Message())
+	}
+}
+
+/**
+ * @spec replace/replace_regexp_dollar
+ * @scenario 置換文字列中の "$"
+ * @description 置換文字列の "$" はそのまま挿入される。グループ参照は \1 形式だけで、"$1" は参照にならない
+ * @given "cost 5" "total 12" の2行があるバッファ
+ * @when M-x replace-regexp で "[0-9]+" を "$\&" に、"\$([0-9])" を "$1\1" に置換し、それぞれ "!" と答える
+ * @then 数字の前に "$" が付き、2回目の置換では "$1" がそのまま残る
+ * @implementation domain/query_replace.go
+ */
+func TestReplaceRegexpDollar(t *testing.T) {
+	editor, buffer := setupSearchBuffer("cost 5", "total 12")
+
+	startReplace(editor, true, `[0-9]+`, `$\&`)
+	typeString(editor, "!")
+	assertLines(t, buffer, "cost $5", "total $12")
+
+	buffer.SetCursor(domain.Position{Row: 0, Col: 0})
+	startReplace(editor, true, `\$([0-9])`, `$1\1`)
+	typeString(editor, "!")
+	assertLines(t, buffer, "cost $15", "total $112")
+}
+
+/**
+ * @spec replace/empty_match_multibyte
+ * @scenario 日本語の行での空のマッチの置換
+ * @description 空のマッチを置換したときと "n" で飛ばしたときは、次の検索を1文字 (UTF-8 の1文字分) 先から始め、行末では次の行へ進む
+ * @given "日本" "語" の2行があるバッファ
+ * @when M-x replace-regexp で "x*" を "-" に置換し、n, y, ! と答える
+ * @then 文字の間と行の両端に "-" が入り、最初の空のマッチだけが残る
+ * @implementation domain/query_replace.go, domain/isearch.go
+ */
+func TestReplaceRegexpEmptyMatchMultibyte(t *testing.T) {
+	editor, buffer := setupSearchBuffer("日本", "語")
+
+	startReplace(editor, true, "x*", "-")
+	typeString(editor, "n")
+	if cursor := buffer.Cursor(); cursor != (domain.Position{Row: 0, Col: len("日")}) {
+		t.Errorf("Expected the next match after 日, got %v", cursor)
+	}
+	typeString(editor, "y")
+	typeString(editor, "!")
+
+	assertLines(t, buffer, "日-本-", "-語-")
+	if editor.Minibuffer().Message() != "Replaced 4 occurrences" {
+		t.Errorf("Expected 'Replaced 4 occurrences', got %q", editor.Minibuffer().Message())
+	}
+}
+
+/**
+ * @spec replace/quit
+ * @scenario "q" による置換の終了
+ * @description "q" で置換を途中終了し、それまでの置換数を表示する
+ * @given "x x x" と入力したバッファ
+ * @when M-% で "x" を "y" に置換し、y, q と答える
+ * @then 最初のマッチだけが置換され、"Replaced 1 occurrence" が表示される
+ * @implementation domain/query_replace.go
+ */
+func TestQueryReplaceQuit(t *testing.T) {
+	editor, buffer := setupSearchBuffer("x x x")
+
+	startReplace(editor, false, "x", "y")
+	typeString(editor, "y")
+	typeString(editor, "q")
+
+	if buffer.Content()[0] != "y x x" {
+		t.Errorf("Expected 'y x x', got %q", buffer.Content()[0])
+	}
+	if editor.Minibuffer().Message() != "Replaced 1 occurrence" {
+		t.Errorf("Expected 'Replaced 1 occurrence', got %q", editor.Minibuffer().Message())
+	}
+	if editor.SearchHighlight() != nil {
+		t.Error("No search highlight should remain after quitting")
+	}
+}
@@ -0,0 +1,309 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+// rewriteFile replaces the contents of a file as another program would
+func rewriteFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/**
+ * @spec file/revert_unmodified
+ * @scenario 外部で書き換えられたファイルの読み直し
+ * @description バッファはファイルを開いたときと保存したときの更新時刻、サイズ、ハッシュを覚えている。バッファへ切り替えたときと定期的な確認 (CheckFileChanges) で、ファイルが他のプログラムに書き換えられていて、バッファに未保存の変更がなければ、読み直すか y か n で尋ねる。同じ変更について尋ねるのは一度だけ。オプションの global-auto-revert-mode を有効にすると、尋ねずに読み直す (Emacs の global-auto-revert-mode と同じ)。定期的な確認はウィンドウに表示中のバッファだけを対象にする。更新時刻だけが変わって内容が同じなら何もしない
+ * @given ファイルを開いたエディタ
+ * @when 別のバッファにいる間にファイルを書き換えて確認してから C-x b で戻って y を押し、さらに書き換えて確認して n を押してもう一度確認し、global-auto-revert-mode を有効にして書き換えて確認し、更新時刻だけを変えて確認する
+ * @then 表示されていない間の確認では何もしない。戻ったときに尋ねられ、y で新しい内容になって変更フラグは立たない。n では内容が変わらず、次の確認では尋ねない。global-auto-revert-mode では尋ねずに読み直してメッセージが表示される。更新時刻だけの変更では何もしない
+ * @implementation domain/revert.go, domain/editor.go
+ */
+func TestRevertUnmodifiedBuffer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	rewriteFile(t, path, "first\n")
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := openFile(t, editor, path)
+	editor.SwitchToBuffer(editor.FindBuffer("*scratch*"))
+	question := "notes.txt changed on disk; revert? (y or n) "
+
+	// 表示されていないバッファは定期的な確認では扱わない
+	rewriteFile(t, path, "first\nsecond\n")
+	if editor.CheckFileChanges() {
+		t.Error("Expected the poll to leave hidden buffers alone")
+	}
+	assertLines(t, buffer, "first")
+
+	// 切り替えたときに尋ね、y で読み直す
+	pressKey(editor, "x", true, false)
+	typeString(editor, "b")
+	typeString(editor, "notes.txt")
+	pressEnter(editor)
+	if got := editor.Minibuffer().Prompt(); got != question {
+		t.Fatalf("Expected the question, got %q", got)
+	}
+	typeString(editor, "y")
+	assertLines(t, buffer, "first", "second")
+	if buffer.IsModified() {
+		t.Error("Expected the reverted buffer to be unmodified")
+	}
+	if got := editor.Minibuffer().Message(); got != "Reverted buffer notes.txt" {
+		t.Errorf("Expected the revert to be reported, got %q", got)
+	}
+
+	// 定期的な確認でも尋ね、n なら読み直さない。同じ変更は一度だけ尋ねる
+	rewriteFile(t, path, "gofmt'd\n")
+	if !editor.CheckFileChanges() {
+		t.Error("Expected the poll to notice the change")
+	}
+	if got := editor.Minibuffer().Prompt(); got != question {
+		t.Fatalf("Expected the question, got %q", got)
+	}
+	typeString(editor, "n")
+	assertLines(t, buffer, "first", "second")
+	if editor.CheckFileChanges() {
+		t.Error("Expected the change to be asked about once")
+	}
+
+	// global-auto-revert-mode では尋ねずに読み直す
+	editor.SetOption("global-auto-revert-mode", true)
+	rewriteFile(t, path, "third\n")
+	if !editor.CheckFileChanges() {
+		t.Error("Expected the poll to revert the buffer")
+	}
+	assertLines(t, buffer, "third")
+	if got := editor.Minibuffer().Message(); got != "Reverted buffer notes.txt: file changed on disk" {
+		t.Errorf("Expected the revert to be reported, got %q", got)
+	}
+
+	// 更新時刻だけが変わっても読み直さない
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if editor.CheckFileChanges() {
+		t.Error("Expected a touched file not to count as changed")
+	}
+}
+
+/**
+ * @spec file/save_changed_on_disk
+ * @scenario 編集中に外部で書き換えられたファイルの保存
+ * @description 未保存の変更があるバッファのファイルが書き換えられると、読み直さずに警告する。同じ変更について警告するのは一度だけ。C-x C-s は上書きしてよいか y か n で尋ね、n なら保存しない
+ * @given ファイルを開いて編集したエディタ
+ * @when ファイルを書き換えて2回確認し、C-x C-s で n、もう一度 C-x C-s で y を押す
+ * @then 1回目の確認で警告され、2回目は何もしない。n ではファイルは書き換えられたまま、y で編集した内容が書き込まれる
+ * @implementation domain/revert.go, domain/file_commands.go, domain/minibuffer.go
+ */
+func TestSaveFileChangedOnDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	rewriteFile(t, path, "package main\n")
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := openFile(t, editor, path)
+	pressKey(editor, "e", true, false)
+	typeString(editor, " // mine")
+
+	rewriteFile(t, path, "package main // theirs\n")
+	if !editor.CheckFileChanges() {
+		t.Error("Expected the poll to warn about the change")
+	}
+	if got := editor.Minibuffer().Message(); !strings.HasPrefix(got, "main.go changed on disk") {
+		t.Errorf("Expected a warning, got %q", got)
+	}
+	assertLines(t, buffer, "package main // mine")
+	// 同じ変更は一度だけ警告する
+	if editor.CheckFileChanges() {
+		t.Error("Expected the change to be warned about once")
+	}
+
+	// n なら保存しない
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "s", true, false)
+	if got := editor.Minibuffer().Prompt(); got != "main.go changed on disk; really save? (y or n) " {
+		t.Fatalf("Expected the question, got %q", got)
+	}
+	typeString(editor, "n")
+	if data, _ := os.ReadFile(path); string(data) != "package main // theirs\n" {
+		t.Errorf("Expected the file to be kept, got %q", data)
+	}
+
+	// y なら上書きする
+	pressKey(editor, "x", true, false)
+	pressKey(editor, "s", true, false)
+	typeString(editor, "y")
+	if data, _ := os.ReadFile(path); string(data) != "package main // mine\n" {
+		t.Errorf("Expected the edits to be written, got %q", data)
+	}
+	if buffer.IsModified() || buffer.ChangedOnDisk() {
+		t.Error("Expected the buffer to match the file after saving")
+	}
+}
+
+/**
+ * @spec file/write_file_changed_on_disk
+ * @scenario 外部で書き換えられたファイルへの C-x C-w
+ * @description C-x C-w で開いているファイルと同じパスに書き込むときも、C-x C-s と同じくファイルが外部で書き換えられていれば上書きしてよいか尋ねる
+ * @given ファイルを開いて編集し、外部でファイルを書き換えたエディタ
+ * @when C-x C-w で同じファイル名を入力して n を押し、もう一度 C-x C-w で y を押す
+ * @then n ではファイルは書き換えられたまま、y で編集した内容が書き込まれる
+ * @implementation domain/file_commands.go, domain/minibuffer.go
+ */
+func TestWriteFileChangedOnDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	rewriteFile(t, path, "first\n")
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	openFile(t, editor, path)
+	typeString(editor, "mine ")
+	rewriteFile(t, path, "theirs\n")
+
+	writeFile := func(answer string) {
+		pressKey(editor, "x", true, false)
+		pressKey(editor, "w", true, false)
+		typeString(editor, "notes.txt")
+		pressEnter(editor)
+		if got := editor.Minibuffer().Prompt(); got != "notes.txt changed on disk; really save? (y or n) " {
+			t.Fatalf("Expected the question, got %q", got)
+		}
+		typeString(editor, answer)
+	}
+
+	writeFile("n")
+	if data, _ := os.ReadFile(path); string(data) != "theirs\n" {
+		t.Errorf("Expected the file to be kept, got %q", data)
+	}
+
+	writeFile("y")
+	if data, _ := os.ReadFile(path); string(data) != "mine first\n" {
+		t.Errorf("Expected the edits to be written, got %q", data)
+	}
+}
+
+/**
+ * @spec file/revert_buffer
+ * @scenario M-x revert-buffer
+ * @description revert-buffer はバッファをファイルの内容に戻す。未保存の変更があれば捨ててよいか y か n で尋ねる。読み直しは undo で取り消せる
+ * @given ファイルを開いて編集したエディタ
+ * @when M-x revert-buffer で n、もう一度 M-x revert-buffer で y を押し、undo する
+ * @then n では編集が残り、y でファイルの内容に戻って変更フラグが消え、undo で編集した内容に戻る
+ * @implementation domain/revert.go, domain/minibuffer.go
+ */
+func TestRevertBufferCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	rewriteFile(t, path, "saved\n")
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := openFile(t, editor, path)
+	typeString(editor, "edited ")
+
+	runCommand(editor, "revert-buffer")
+	if got := editor.Minibuffer().Prompt(); !strings.HasSuffix(got, "(y or n) ") {
+		t.Fatalf("Expected a question, got %q", got)
+	}
+	typeString(editor, "n")
+	assertLines(t, buffer, "edited saved")
+
+	runCommand(editor, "revert-buffer")
+	typeString(editor, "y")
+	assertLines(t, buffer, "saved")
+	if buffer.IsModified() {
+		t.Error("Expected the reverted buffer to be unmodified")
+	}
+
+	runCommand(editor, "undo")
+	assertLines(t, buffer, "edited saved")
+}
+
+/**
+ * @spec file/auto_revert_mode
+ * @scenario auto-revert-mode によるログの追従
+ * @description auto-revert-mode を有効にしたバッファは、ファイルの末尾に追加された内容だけを末尾に足す。カーソルがバッファの末尾にあれば新しい末尾へ進む。追加ではない書き換えならバッファ全体を読み直す
+ * @given ログファイルを開いて末尾へ移動したエディタ
+ * @when auto-revert-mode を有効にし、ファイルに行を追加して確認し、ファイルを短く書き換えて確認する
+ * @then 追加した行がバッファの末尾に足されてカーソルが末尾へ進み、書き換えでは新しい内容になる
+ * @implementation domain/revert.go
+ */
+func TestAutoRevertModeTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	rewriteFile(t, path, "started\n")
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := openFile(t, editor, path)
+	runCommand(editor, "auto-revert-mode")
+	if got := editor.Minibuffer().Message(); got != "Auto-Revert mode enabled" {
+		t.Errorf("Expected the mode to be enabled, got %q", got)
+	}
+	runCommand(editor, "end-of-buffer")
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("request 1\nrequest 2\n")
+	file.Close()
+
+	if !editor.CheckFileChanges() {
+		t.Error("Expected the appended lines to be noticed")
+	}
+	assertLines(t, buffer, "started", "request 1", "request 2")
+	if cursor := buffer.Cursor(); cursor != (domain.Position{Row: 2, Col: 9}) {
+		t.Errorf("Expected the cursor to follow the end, got %+v", cursor)
+	}
+	if buffer.IsModified() {
+		t.Error("Expected the tailed buffer to be unmodified")
+	}
+
+	// 追加ではない書き換えは全体を読み直す
+	rewriteFile(t, path, "rotated\n")
+	editor.CheckFileChanges()
+	assertLines(t, buffer, "rotated")
+}
+
+/**
+ * @spec file/revert_during_query_replace
+ * @scenario query-replace 中のファイルの書き換え
+ * @description ミニバッファで入力中、isearch や query-replace の途中では、定期的な確認はファイルの変更を扱わずに次の確認に回す。扱うとマッチの位置が古くなり、置換が壊れる
+ * @given "foo foo foo" のファイルを開いたエディタ
+ * @when M-% で foo を barbaz に置換し始め、ファイルを "x" に書き換えて確認し、y と q を押してもう一度確認する
+ * @then 置換中の確認は何もせず、y で1つ目が置換される。置換を終えた後の確認で、編集されたバッファについて警告される
+ * @implementation domain/revert.go
+ */
+func TestRevertDuringQueryReplace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	rewriteFile(t, path, "foo foo foo\n")
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := openFile(t, editor, path)
+	startReplace(editor, false, "foo", "barbaz")
+
+	rewriteFile(t, path, "x\n")
+	if editor.CheckFileChanges() {
+		t.Error("Expected the poll to wait for query-replace")
+	}
+	typeString(editor, "y")
+	typeString(editor, "q")
+	assertLines(t, buffer, "barbaz foo foo")
+
+	if !editor.CheckFileChanges() {
+		t.Error("Expected the poll to catch up after query-replace")
+	}
+	if got := editor.Minibuffer().Message(); !strings.HasPrefix(got, "words.txt changed on disk") {
+		t.Errorf("Expected a warning about the edited buffer, got %q", got)
+	}
+}
@@ -0,0 +1,240 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// openFile opens path in the editor via C-x C-f
+func openFile(t *testing.T, editor *domain.Editor, path string) *domain.Buffer {
+	editor.HandleEvent(events.KeyEventData{Key: "x", Ctrl: true})
+	editor.HandleEvent(events.KeyEventData{Key: "f", Ctrl: true})
+	typeString(editor, path)
+	pressEnter(editor)
+
+	buffer := editor.CurrentBuffer()
+	if buffer == nil || buffer.Filepath() != path {
+		t.Fatalf("Failed to open %s", path)
+	}
+	return buffer
+}
+
+/**
+ * @spec file/save_buffer
+ * @scenario C-x C-s によるバッファの保存
+ * @description 編集したバッファの内容をファイルに書き戻す
+ * @given パーミッション 0600 のファイルを開いて編集する
+ * @when C-x C-s を押す
+ * @then 内容がファイルに書き込まれ、パーミッションが維持され、変更フラグがクリアされる
+ * @implementation domain/file_commands.go, domain/buffer.go
+ */
+func TestSaveBuffer(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "save.txt")
+	if err := os.WriteFile(testFile, []byte("first\nsecond\n"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	editor := NewEditorWithDefaults()
+	buffer := openFile(t, editor, testFile)
+
+	// 1行目の末尾に文字を追加
+	editor.HandleEvent(events.KeyEventData{Key: "e", Ctrl: true})
+	typeString(editor, "!")
+	if !buffer.IsModified() {
+		t.Fatal("Buffer should be modified after editing")
+	}
+
+	// C-x C-s
+	editor.HandleEvent(events.KeyEventData{Key: "x", Ctrl: true})
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to read saved file: %v", err)
+	}
+	if string(data) != "first!\nsecond\n" {
+		t.Errorf("Expected saved content %q, got %q", "first!\nsecond\n", string(data))
+	}
+
+	info, err := os.Stat(testFile)
+	if err != nil {
+		t.Fatalf("Failed to stat saved file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected file mode 0600 to be preserved, got %o", info.Mode().Perm())
+	}
+
+	if buffer.IsModified() {
+		t.Error("Buffer should not be modified after saving")
+	}
+
+	expected := "Wrote " + testFile
+	if editor.Minibuffer().Message() != expected {
+		t.Errorf("Expected message %q, got %q", expected, editor.Minibuffer().Message())
+	}
+
+	// 一時ファイルが残っていないことを確認
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the saved file in directory, found %d entries", len(entries))
+	}
+}
+
+/**
+ * @spec file/save_buffer_unmodified
+ * @scenario 変更のないバッファの保存
+ * @description 変更がない場合は書き込みを行わずメッセージを表示する
+ * @given ファイルを開いた直後の未変更バッファ
+ * @when C-x C-s を押す
+ * @then "(No changes need to be saved)" が表示される
+ * @implementation domain/file_commands.go
+ */
+func TestSaveBufferUnmodified(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "unchanged.txt")
+	if err := os.WriteFile(testFile, []byte("content\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	editor := NewEditorWithDefaults()
+	openFile(t, editor, testFile)
+
+	editor.HandleEvent(events.KeyEventData{Key: "x", Ctrl: true})
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+
+	if editor.Minibuffer().Message() != "(No changes need to be saved)" {
+		t.Errorf("Expected no-changes message, got %q", editor.Minibuffer().Message())
+	}
+}
+
+/**
+ * @spec file/save_keeps_line_endings
+ * @scenario 改行コードと末尾の改行を保ったままの保存
+ * @description ファイルを開くと改行コード (LF か CRLF) と最後の行が改行で終わるかを覚え、保存するときは同じ形で書き戻す。バッファの行には "\r" を含まない
+ * @given CRLF のファイル、末尾に改行のないファイル、CRLF で末尾に改行のないファイル、改行だけのファイル
+ * @when それぞれを開き、先頭に "x" を入力して C-x C-s を押す
+ * @then "x" 以外はファイルの形が変わらない
+ * @implementation domain/buffer.go, domain/revert.go
+ */
+func TestSaveBufferKeepsLineEndings(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		lines   []string
+		want    string
+	}{
+		{"crlf.txt", "one\r\ntwo\r\n", []string{"one", "two"}, "xone\r\ntwo\r\n"},
+		{"no-newline.txt", "one\ntwo", []string{"one", "two"}, "xone\ntwo"},
+		{"crlf-no-newline.txt", "one\r\ntwo", []string{"one", "two"}, "xone\r\ntwo"},
+		{"newline.txt", "\n", []string{""}, "x\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			editor := NewEditorWithDefaults()
+			defer editor.Cleanup()
+			buffer := openFile(t, editor, path)
+			assertLines(t, buffer, tt.lines...)
+
+			typeString(editor, "x")
+			editor.HandleEvent(events.KeyEventData{Key: "x", Ctrl: true})
+			editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+			if data, _ := os.ReadFile(path); string(data) != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, data)
+			}
+		})
+	}
+}
+
+/**
+ * @spec file/write_file
+ * @scenario C-x C-w による別名保存
+ * @description 新しいパスにバッファを書き込み、バッファ名とファイルパスを更新する
+ * @given テキストを入力した *scratch* バッファ
+ * @when C-x C-w で新しいファイルパスを入力して Enter を押す
+ * @then ファイルが作成され、バッファ名がファイル名に変わり、変更フラグがクリアされる
+ * @implementation domain/file_commands.go, domain/minibuffer.go
+ */
+func TestWriteFile(t *testing.T) {
+	tempDir := t.TempDir()
+	newFile := filepath.Join(tempDir, "notes.txt")
+
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+	typeString(editor, "hello")
+	pressEnter(editor)
+	typeString(editor, "world")
+
+	// C-x C-w
+	editor.HandleEvent(events.KeyEventData{Key: "x", Ctrl: true})
+	editor.HandleEvent(events.KeyEventData{Key: "w", Ctrl: true})
+
+	minibuffer := editor.Minibuffer()
+	if minibuffer.Mode() != domain.MinibufferWriteFile {
+		t.Fatalf("Expected MinibufferWriteFile mode, got %v", minibuffer.Mode())
+	}
+	if minibuffer.Prompt() != "Write file: " {
+		t.Errorf("Expected 'Write file: ' prompt, got %q", minibuffer.Prompt())
+	}
+
+	typeString(editor, newFile)
+	pressEnter(editor)
+
+	data, err := os.ReadFile(newFile)
+	if err != nil {
+		t.Fatalf("Failed to read written file: %v", err)
+	}
+	if string(data) != "hello\nworld\n" {
+		t.Errorf("Expected written content %q, got %q", "hello\nworld\n", string(data))
+	}
+
+	if buffer.Name() != "notes.txt" {
+		t.Errorf("Expected buffer to be renamed to 'notes.txt', got %q", buffer.Name())
+	}
+	if buffer.Filepath() != newFile {
+		t.Errorf("Expected filepath %q, got %q", newFile, buffer.Filepath())
+	}
+	if buffer.IsModified() {
+		t.Error("Buffer should not be modified after write-file")
+	}
+	if buffer.MajorMode() == nil || buffer.MajorMode().Name() != "text-mode" {
+		t.Error("Major mode should be re-detected from the new file name")
+	}
+	if minibuffer.Message() != "Wrote "+newFile {
+		t.Errorf("Expected message %q, got %q", "Wrote "+newFile, minibuffer.Message())
+	}
+}
+
+/**
+ * @spec file/save_buffer_no_file
+ * @scenario ファイルに紐づかないバッファの保存
+ * @description ファイルパスのないバッファで save-buffer を実行すると write-file のプロンプトになる
+ * @given *scratch* バッファ
+ * @when C-x C-s を押す
+ * @then "Write file: " プロンプトが表示される
+ * @implementation domain/file_commands.go
+ */
+func TestSaveBufferWithoutFile(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	typeString(editor, "text")
+
+	editor.HandleEvent(events.KeyEventData{Key: "x", Ctrl: true})
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+
+	minibuffer := editor.Minibuffer()
+	if minibuffer.Mode() != domain.MinibufferWriteFile {
+		t.Errorf("Expected MinibufferWriteFile mode, got %v", minibuffer.Mode())
+	}
+}
@@ -0,0 +1,204 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// cLikeMode defines a mode with keywords, block comments and strings that
+// may span lines
+const cLikeMode = `
+gmacs.major_mode("clike-mode", {
+  file_patterns = {"%.clike$"},
+  syntax = {
+    { keywords = {"if", "return", "end"}, face = "keyword" },
+    { pattern = "/%*", face = "comment", push = "comment" },
+    { pattern = "//.*", face = "comment" },
+    { pattern = '"', face = "string", push = "string" },
+    states = {
+      comment = { face = "comment", { pattern = "%*/", pop = true } },
+      string = { face = "string", { regex = [[\\.]] }, { pattern = '"', pop = true } },
+    },
+  },
+})
+`
+
+/**
+ * @spec display/syntax_highlighting
+ * @scenario Lua で定義した文法による構文強調表示
+ * @description gmacs.major_mode の syntax で定義した文法で行が強調表示される。複数行のコメントや文字列の状態は次の行に引き継がれ、編集すると変更された行以降が再計算される。描画では face に応じた色で表示される
+ * @given キーワード、ブロックコメント、文字列の規則を持つ clike-mode と、コメントと文字列が行をまたぐファイル
+ * @when ファイルを開いて各行の強調表示を取得し、コメントの開始を削除してから再び取得し、描画する
+ * @then 行をまたいだコメントと文字列に face が付き、編集後は後続の行の強調表示も更新され、端末にはキーワードとコメントの色が送られる
+ * @implementation domain/syntax.go, lua-config/syntax.go, cli/display.go
+ */
+func TestSyntaxHighlighting(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "sample.clike")
+	content := "if x /* start\nstill */ return \"a\nb\\\"\" end\n"
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	editor := NewEditorWithLua(cLikeMode)
+	defer editor.Cleanup()
+	editor.HandleEvent(events.ResizeEventData{Width: 40, Height: 8})
+	buffer := openFile(t, editor, testFile)
+
+	seg := func(start, end int, face string) domain.HighlightSegment {
+		return domain.HighlightSegment{Start: start, End: end, Style: face}
+	}
+	want := [][]domain.HighlightSegment{
+		{seg(0, 2, "keyword"), seg(5, 13, "comment")},
+		{seg(0, 8, "comment"), seg(9, 15, "keyword"), seg(16, 18, "string")},
+		{seg(0, 4, "string"), seg(5, 8, "keyword")},
+	}
+	for row, segments := range want {
+		if got := buffer.Highlights(row); !reflect.DeepEqual(got, segments) {
+			t.Errorf("Line %d: expected %v, got %v", row, segments, got)
+		}
+	}
+
+	// コメントの開始を削除すると、次の行はコメントではなくなる
+	buffer.DeleteRegion(domain.Position{Row: 0, Col: 5}, domain.Position{Row: 0, Col: 7})
+	if got := buffer.Highlights(0); !reflect.DeepEqual(got, []domain.HighlightSegment{seg(0, 2, "keyword")}) {
+		t.Errorf("Line 0 after the edit: expected only the keyword, got %v", got)
+	}
+	if got := buffer.Highlights(1); !reflect.DeepEqual(got, []domain.HighlightSegment{seg(9, 15, "keyword"), seg(16, 18, "string")}) {
+		t.Errorf("Line 1 after the edit: expected the comment to be gone, got %v", got)
+	}
+
+	// 行を挿入しても後続の行の状態は保たれる
+	buffer.InsertText(domain.Position{Row: 0, Col: 0}, "// note\n")
+	if got := buffer.Highlights(3); !reflect.DeepEqual(got, want[2]) {
+		t.Errorf("Line 3 after inserting a line: expected %v, got %v", want[2], got)
+	}
+
+	display := NewMockDisplay(40, 8)
+	display.Render(editor)
+	stream := display.EscapeStream()
+	if !strings.Contains(stream, "\033[90m// note") {
+		t.Errorf("Expected the comment in the comment face, got %q", stream)
+	}
+	if !strings.Contains(stream, "\033[35mif") {
+		t.Errorf("Expected the keyword in the keyword face, got %q", stream)
+	}
+}
+
+/**
+ * @spec display/syntax_grammar_errors
+ * @scenario 不正な文法の定義
+ * @description 存在しない状態への push や規則のない指定はモードの定義時にエラーになる
+ * @given 存在しない状態に push する規則を持つ文法
+ * @when gmacs.major_mode で定義する
+ * @then エラーになり、メッセージに状態名が含まれる
+ * @implementation domain/syntax.go, lua-config/syntax.go
+ */
+func TestSyntaxGrammarErrors(t *testing.T) {
+	editor := NewEditorWithLua(`
+local ok, err = pcall(gmacs.major_mode, "broken-mode", {
+  syntax = { { pattern = "/%*", push = "missing" } },
+})
+gmacs.set_option("syntax-error", tostring(err))
+ok, err = pcall(gmacs.major_mode, "empty-rule-mode", {
+  syntax = { { face = "keyword" } },
+})
+gmacs.set_option("rule-error", tostring(err))
+`)
+	defer editor.Cleanup()
+
+	if got := optionString(t, editor, "syntax-error"); !strings.Contains(got, "Unknown state missing") {
+		t.Errorf("Expected an error about the unknown state, got %q", got)
+	}
+	if got := optionString(t, editor, "rule-error"); !strings.Contains(got, "needs keywords, pattern or regex") {
+		t.Errorf("Expected an error about the rule, got %q", got)
+	}
+}
+
+/**
+ * @spec display/syntax_line_context
+ * @scenario 行頭と単語境界を使う規則
+ * @description 規則は行の途中から探すときも行全体に対して照合されるので、^ は行頭だけに、\b は本当の単語境界だけに一致する
+ * @given 行頭の # をコメントにする規則、キーワード if、識別子 x の規則を持つモード
+ * @when "#if"、"x#if"、"xif" の行の強調表示を取得する
+ * @then 行頭の # だけがコメントになり、x の直後の if はキーワードにならない
+ * @implementation domain/syntax.go
+ */
+func TestSyntaxLineContext(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "sample.hash")
+	if err := os.WriteFile(testFile, []byte("#if\nx#if\nxif\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	editor := NewEditorWithLua(`
+gmacs.major_mode("hash-mode", {
+  file_patterns = {"%.hash$"},
+  syntax = {
+    { pattern = "^#.*", face = "comment" },
+    { keywords = {"if"}, face = "keyword" },
+    { pattern = "x", face = "string" },
+  },
+})
+`)
+	defer editor.Cleanup()
+	buffer := openFile(t, editor, testFile)
+
+	seg := func(start, end int, face string) domain.HighlightSegment {
+		return domain.HighlightSegment{Start: start, End: end, Style: face}
+	}
+	want := [][]domain.HighlightSegment{
+		{seg(0, 3, "comment")},
+		{seg(0, 1, "string"), seg(2, 4, "keyword")},
+		{seg(0, 1, "string")},
+	}
+	for row, segments := range want {
+		if got := buffer.Highlights(row); !reflect.DeepEqual(got, segments) {
+			t.Errorf("Line %d (%q): expected %v, got %v", row, buffer.Line(row), segments, got)
+		}
+	}
+}
+
+/**
+ * @spec display/syntax_overlapping_match
+ * @scenario 前のトークンと重なる規則のマッチ
+ * @description 前のトークンの途中から始まるマッチがある規則でも、行全体に対して照合した結果から探すので、^ や \b を使う規則が行の途中で一致することはない
+ * @given 行頭の # の並び、"b#"、"-i"、単語の先頭の "i+f" (正規表現) の規則を持つモード
+ * @when "#b##" と "-iif" の行の強調表示を取得する
+ * @then "b#" の後ろの # は行頭の規則に、"-i" の後ろの "if" は単語の先頭の規則に一致しない
+ * @implementation domain/syntax.go, domain/isearch.go
+ */
+func TestSyntaxOverlappingMatch(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "sample.hash")
+	if err := os.WriteFile(testFile, []byte("#b##\n-iif\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	editor := NewEditorWithLua(`
+gmacs.major_mode("hash-mode", {
+  file_patterns = {"%.hash$"},
+  syntax = {
+    { pattern = "^#+", face = "comment" },
+    { pattern = "b#", face = "string" },
+    { pattern = "-i", face = "string" },
+    { regex = [[\bi+f]], face = "keyword" },
+  },
+})
+`)
+	defer editor.Cleanup()
+	buffer := openFile(t, editor, testFile)
+
+	seg := func(start, end int, face string) domain.HighlightSegment {
+		return domain.HighlightSegment{Start: start, End: end, Style: face}
+	}
+	want := [][]domain.HighlightSegment{
+		{seg(0, 1, "comment"), seg(1, 3, "string")},
+		{seg(0, 2, "string")},
+	}
+	for row, segments := range want {
+		if got := buffer.Highlights(row); !reflect.DeepEqual(got, segments) {
+			t.Errorf("Line %d (%q): expected %v, got %v", row, buffer.Line(row), segments, got)
+		}
+	}
+}
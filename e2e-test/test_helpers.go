@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/events"
 	"github.com/TakahashiShuuhei/gmacs/lua-config"
 )
 
@@ -19,6 +20,12 @@ func getDefaultConfig() string {
 // NewEditorWithDefaults creates an editor with the EXACT same initialization as main.go
 // This ensures tests use the same configuration as the main application
 func NewEditorWithDefaults() *domain.Editor {
+	return NewEditorWithLua("")
+}
+
+// NewEditorWithLua creates an editor like NewEditorWithDefaults and runs
+// script as if it were the user's init.lua
+func NewEditorWithLua(script string) *domain.Editor {
 	// Step 1: Create editor with Lua configuration support (same as main.go)
 	configLoader := luaconfig.NewConfigLoader()
 	hookManager := luaconfig.NewHookManager()
@@ -36,5 +43,32 @@ func NewEditorWithDefaults() *domain.Editor {
 		panic("Failed to load default config in test: " + err.Error())
 	}
 	
+	// Step 4: Load the user configuration, then run the startup hooks (same as main.go)
+	if script != "" {
+		if err := configLoader.GetVM().ExecuteString(script); err != nil {
+			panic("Failed to load user config in test: " + err.Error())
+		}
+	}
+	editor.RunStartupHooks()
+	
 	return editor
-}
\ No newline at end of file
+}
+
+// typeString sends each rune of text to the editor as a plain key event
+func typeString(editor *domain.Editor, text string) {
+	for _, ch := range text {
+		editor.HandleEvent(events.KeyEventData{Rune: ch, Key: string(ch)})
+	}
+}
+
+// pressEnter sends an Enter key event to the editor
+func pressEnter(editor *domain.Editor) {
+	editor.HandleEvent(events.KeyEventData{Key: "Enter", Rune: '\n'})
+}
+
+// runCommand runs a command by name with M-x
+func runCommand(editor *domain.Editor, name string) {
+	editor.HandleEvent(events.KeyEventData{Key: "x", Meta: true})
+	typeString(editor, name)
+	pressEnter(editor)
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/events"
+)
+
+// pressUndo sends C-/ (undo) to the editor
+func pressUndo(editor *domain.Editor) {
+	editor.HandleEvent(events.KeyEventData{Key: "/", Ctrl: true})
+}
+
+/**
+ * @spec undo/self_insert_group
+ * @scenario 連続した文字入力のアンドゥ
+ * @description 連続した self-insert は1つのアンドゥ単位にまとめられる
+ * @given 空のバッファに "hello" を入力
+ * @when C-/ を1回押す
+ * @then "hello" 全体が取り消され、カーソルと変更フラグが元に戻る
+ * @implementation domain/undo.go, domain/editor.go
+ */
+func TestUndoSelfInsertRun(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+
+	typeString(editor, "hello")
+	if buffer.Content()[0] != "hello" {
+		t.Fatalf("Expected 'hello', got %q", buffer.Content()[0])
+	}
+
+	pressUndo(editor)
+
+	if buffer.Content()[0] != "" {
+		t.Errorf("Expected empty line after undo, got %q", buffer.Content()[0])
+	}
+	cursor := buffer.Cursor()
+	if cursor.Row != 0 || cursor.Col != 0 {
+		t.Errorf("Expected cursor at (0,0), got (%d,%d)", cursor.Row, cursor.Col)
+	}
+	if buffer.IsModified() {
+		t.Error("Buffer should not be modified after undoing all changes")
+	}
+	if editor.Minibuffer().Message() != "Undo" {
+		t.Errorf("Expected 'Undo' message, got %q", editor.Minibuffer().Message())
+	}
+}
+
+/**
+ * @spec undo/command_groups
+ * @scenario コマンド単位のアンドゥ
+ * @description 改行や削除などのコマンドはそれぞれ別のアンドゥ単位になる
+ * @given "abc" Enter "def" と入力し、C-h で1文字削除
+ * @when C-/ と C-x u を順に押す
+ * @then 削除、"de"、改行の順に取り消される
+ * @implementation domain/undo.go
+ */
+func TestUndoCommandGroups(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+
+	typeString(editor, "abc")
+	pressEnter(editor)
+	typeString(editor, "def")
+	editor.HandleEvent(events.KeyEventData{Key: "h", Ctrl: true})
+
+	content := buffer.Content()
+	if len(content) != 2 || content[1] != "de" {
+		t.Fatalf("Unexpected content before undo: %q", content)
+	}
+
+	// 削除を取り消す
+	pressUndo(editor)
+	if buffer.Content()[1] != "def" {
+		t.Errorf("Expected 'def' after undoing deletion, got %q", buffer.Content()[1])
+	}
+	cursor := buffer.Cursor()
+	if cursor.Row != 1 || cursor.Col != 3 {
+		t.Errorf("Expected cursor at (1,3), got (%d,%d)", cursor.Row, cursor.Col)
+	}
+
+	// "def" の入力を取り消す (C-x u)
+	editor.HandleEvent(events.KeyEventData{Key: "x", Ctrl: true})
+	editor.HandleEvent(events.KeyEventData{Key: "u"})
+	if len(buffer.Content()) != 2 || buffer.Content()[1] != "" {
+		t.Errorf("Expected empty second line, got %q", buffer.Content())
+	}
+
+	// 改行を取り消す
+	pressUndo(editor)
+	if len(buffer.Content()) != 1 || buffer.Content()[0] != "abc" {
+		t.Errorf("Expected single line 'abc', got %q", buffer.Content())
+	}
+}
+
+/**
+ * @spec undo/redo
+ * @scenario アンドゥ後のリドゥ
+ * @description redo コマンドで取り消した変更を再適用する
+ * @given "foo" と入力してアンドゥした状態
+ * @when ESC C-/ (redo) を押す
+ * @then "foo" が復元され、カーソルが変更箇所の後ろに移動する
+ * @implementation domain/undo.go
+ */
+func TestRedo(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+
+	typeString(editor, "foo")
+	pressUndo(editor)
+
+	editor.HandleEvent(events.KeyEventData{Key: "\x1b"})
+	editor.HandleEvent(events.KeyEventData{Key: "/", Ctrl: true})
+
+	if buffer.Content()[0] != "foo" {
+		t.Errorf("Expected 'foo' after redo, got %q", buffer.Content()[0])
+	}
+	if buffer.Cursor().Col != 3 {
+		t.Errorf("Expected cursor at col 3, got %d", buffer.Cursor().Col)
+	}
+	if !buffer.IsModified() {
+		t.Error("Buffer should be modified after redo")
+	}
+
+	// 新しい編集でリドゥ履歴は破棄される
+	pressUndo(editor)
+	typeString(editor, "x")
+	editor.HandleEvent(events.KeyEventData{Key: "\x1b"})
+	editor.HandleEvent(events.KeyEventData{Key: "/", Ctrl: true})
+	if editor.Minibuffer().Message() != "No further redo information" {
+		t.Errorf("Expected redo history to be cleared, got message %q", editor.Minibuffer().Message())
+	}
+}
+
+/**
+ * @spec undo/limit
+ * @scenario アンドゥ履歴の上限
+ * @description undo-limit オプションでアンドゥ履歴の数を制限する
+ * @given gmacs.set_option("undo-limit", 2) を設定
+ * @when 3つの別々のコマンドで編集し、3回アンドゥする
+ * @then 最後のアンドゥは "No further undo information" になる
+ * @implementation domain/undo.go, domain/editor.go
+ */
+func TestUndoLimit(t *testing.T) {
+	editor := NewEditorWithDefaults()
+	buffer := editor.CurrentBuffer()
+	editor.SetOption("undo-limit", float64(2))
+
+	typeString(editor, "a")
+	pressEnter(editor)
+	typeString(editor, "b")
+	pressEnter(editor)
+
+	pressUndo(editor)
+	pressUndo(editor)
+	pressUndo(editor)
+
+	if editor.Minibuffer().Message() != "No further undo information" {
+		t.Errorf("Expected undo history to be exhausted, got message %q", editor.Minibuffer().Message())
+	}
+	if buffer.Content()[0] != "a" {
+		t.Errorf("Expected oldest change to be kept, got %q", buffer.Content())
+	}
+}
+
+/**
+ * @spec undo/modified_after_save
+ * @scenario 保存後のアンドゥと変更フラグ
+ * @description 保存した時点に戻ると変更フラグがクリアされる
+ * @given ファイルを開いて編集し保存した後、さらに編集する
+ * @when アンドゥで保存時点まで戻し、さらにもう一度アンドゥする
+ * @then 保存時点では未変更、それより前では変更ありと判定される
+ * @implementation domain/undo.go
+ */
+func TestUndoModifiedFlagAfterSave(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "undo.txt")
+	if err := os.WriteFile(testFile, []byte("base\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	editor := NewEditorWithDefaults()
+	buffer := openFile(t, editor, testFile)
+
+	typeString(editor, "1")
+	editor.HandleEvent(events.KeyEventData{Key: "x", Ctrl: true})
+	editor.HandleEvent(events.KeyEventData{Key: "s", Ctrl: true})
+	typeString(editor, "2")
+
+	pressUndo(editor)
+	if buffer.IsModified() {
+		t.Error("Buffer should be unmodified when undone back to the saved state")
+	}
+
+	pressUndo(editor)
+	if !buffer.IsModified() {
+		t.Error("Buffer should be modified when undone past the saved state")
+	}
+	if buffer.Content()[0] != "base" {
+		t.Errorf("Expected 'base', got %q", buffer.Content()[0])
+	}
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+)
+
+// writeFiles creates files under dir with their names as content
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+/**
+ * @spec file/find_file_existing_buffer
+ * @scenario 訪問済みのファイルを開き直す
+ * @description C-x C-f で既に訪問しているファイルを開くと、ファイルを読み直さずにそのバッファへ切り替わる。ファイルは絶対パスにしてシンボリックリンクを解決したパスで比べるので、別の書き方やリンク経由で開いても同じバッファになる
+ * @given main.go とそれを指すシンボリックリンクのあるディレクトリ
+ * @when main.go を開いて編集し、同じパス、".." を含むパス、シンボリックリンクで開き直す
+ * @then バッファは増えず、編集した内容のバッファに切り替わる
+ * @implementation domain/uniquify.go, domain/minibuffer.go
+ */
+func TestFindFileExistingBuffer(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "main.go", "sub/x.txt")
+	link := filepath.Join(dir, "link.go")
+	if err := os.Symlink(filepath.Join(dir, "main.go"), link); err != nil {
+		t.Skipf("Symlinks are not available: %v", err)
+	}
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	buffer := openFile(t, editor, filepath.Join(dir, "main.go"))
+	buffer.InsertText(domain.Position{Row: 0, Col: 0}, "// edited\n")
+	count := len(editor.Buffers())
+
+	for _, path := range []string{
+		filepath.Join(dir, "main.go"),
+		dir + "/sub/../main.go",
+		link,
+	} {
+		editor.SwitchToBuffer(editor.Buffers()[0])
+		pressKey(editor, "x", true, false)
+		pressKey(editor, "f", true, false)
+		typeString(editor, path)
+		pressEnter(editor)
+		if editor.CurrentBuffer() != buffer {
+			t.Errorf("Expected %s to switch to the existing buffer, got %q", path, editor.CurrentBuffer().Name())
+		}
+		if got := len(editor.Buffers()); got != count {
+			t.Errorf("Expected %s not to add a buffer, got %d buffers", path, got)
+		}
+	}
+	if got := buffer.Line(0); got != "// edited" {
+		t.Errorf("Expected the edits to be kept, got %q", got)
+	}
+}
+
+/**
+ * @spec file/uniquify_buffer_names
+ * @scenario 同じ名前のファイルのバッファ名
+ * @description 同じ名前のファイルを訪問すると、バッファ名はディレクトリの末尾を付けて main.go<cmd>、main.go<pkg> のように区別される。末尾のディレクトリも同じなら区別できるまでさかのぼる。区別する必要がなくなると元の名前に戻る。FindBuffer と C-x b は区別された名前で探す
+ * @given a/cmd/main.go、a/pkg/main.go、b/cmd/main.go のあるディレクトリ
+ * @when 順に開き、区別された名前で C-x b し、バッファを kill する
+ * @then 名前は main.go<cmd> と main.go<pkg>、3つ目で a/cmd、a/pkg、b/cmd になり、C-x b は既存のバッファへ切り替わり、kill して1つだけになると main.go に戻る
+ * @implementation domain/uniquify.go, domain/editor.go, domain/buffer_interactive.go
+ */
+func TestUniquifyBufferNames(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a/cmd/main.go", "a/pkg/main.go", "b/cmd/main.go")
+
+	editor := NewEditorWithDefaults()
+	defer editor.Cleanup()
+	cmd := openFile(t, editor, filepath.Join(dir, "a", "cmd", "main.go"))
+	if cmd.Name() != "main.go" {
+		t.Errorf("Expected a single main.go to keep its name, got %q", cmd.Name())
+	}
+	pkg := openFile(t, editor, filepath.Join(dir, "a", "pkg", "main.go"))
+	if cmd.Name() != "main.go<cmd>" || pkg.Name() != "main.go<pkg>" {
+		t.Errorf("Expected main.go<cmd> and main.go<pkg>, got %q and %q", cmd.Name(), pkg.Name())
+	}
+	if editor.FindBuffer("main.go<cmd>") != cmd || editor.FindBuffer("main.go") != nil {
+		t.Error("Expected FindBuffer to use the unique names")
+	}
+
+	// 末尾のディレクトリが同じならさかのぼる
+	other := openFile(t, editor, filepath.Join(dir, "b", "cmd", "main.go"))
+	for buffer, want := range map[*domain.Buffer]string{cmd: "main.go<a/cmd>", pkg: "main.go<a/pkg>", other: "main.go<b/cmd>"} {
+		if buffer.Name() != want {
+			t.Errorf("Expected %s, got %q", want, buffer.Name())
+		}
+	}
+
+	// C-x b は区別された名前の既存のバッファへ切り替わる
+	count := len(editor.Buffers())
+	pressKey(editor, "x", true, false)
+	typeString(editor, "b")
+	typeString(editor, "main.go<a/pkg>")
+	pressEnter(editor)
+	if editor.CurrentBuffer() != pkg || len(editor.Buffers()) != count {
+		t.Errorf("Expected C-x b to switch to main.go<a/pkg>, got %q", editor.CurrentBuffer().Name())
+	}
+
+	// kill すると区別に必要なだけの名前になる
+	runCommand(editor, "kill-buffer")
+	if cmd.Name() != "main.go<a/cmd>" || other.Name() != "main.go<b/cmd>" {
+		t.Errorf("Expected the cmd buffers to stay apart, got %q and %q", cmd.Name(), other.Name())
+	}
+	editor.SwitchToBuffer(other)
+	runCommand(editor, "kill-buffer")
+	if cmd.Name() != "main.go" {
+		t.Errorf("Expected main.go once it is alone, got %q", cmd.Name())
+	}
+}
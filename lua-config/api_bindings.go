@@ -45,11 +45,19 @@ func (api *APIBindings) RegisterGmacsAPI() error {
 	L.SetField(gmacsTable, "get_option", L.NewFunction(api.luaGetOption))
 	L.SetField(gmacsTable, "major_mode", L.NewFunction(api.luaMajorMode))
 	L.SetField(gmacsTable, "minor_mode", L.NewFunction(api.luaMinorMode))
+	L.SetField(gmacsTable, "set_indent_function", L.NewFunction(api.luaSetIndentFunction))
 	L.SetField(gmacsTable, "add_hook", L.NewFunction(api.luaAddHook))
+	L.SetField(gmacsTable, "kbd_macro", L.NewFunction(api.luaKbdMacro))
+	L.SetField(gmacsTable, "set_face", L.NewFunction(api.luaSetFace))
+	L.SetField(gmacsTable, "load_theme", L.NewFunction(api.luaLoadTheme))
 	
 	// Buffer and UI functions
+	api.registerObjectTypes(L)
 	L.SetField(gmacsTable, "current_buffer", L.NewFunction(api.luaCurrentBuffer))
+	L.SetField(gmacsTable, "buffers", L.NewFunction(api.luaBuffers))
+	L.SetField(gmacsTable, "current_window", L.NewFunction(api.luaCurrentWindow))
 	L.SetField(gmacsTable, "message", L.NewFunction(api.luaMessage))
+	L.SetField(gmacsTable, "completing_read", L.NewFunction(api.luaCompletingRead))
 	L.SetField(gmacsTable, "toggle_minor_mode", L.NewFunction(api.luaToggleMinorMode))
 	
 	// Register all built-in commands
@@ -96,13 +104,14 @@ func (api *APIBindings) luaDefun(L *lua.LState) int {
 	name := L.CheckString(1)
 	fn := L.CheckFunction(2)
 	
-	// Create a wrapper function that calls the Lua function
+	// Create a wrapper function that calls the Lua function with the
+	// numeric prefix argument, or nil if none was given
 	wrapper := func() error {
 		err := L.CallByParam(lua.P{
 			Fn:      fn,
 			NRet:    0,
 			Protect: true,
-		})
+		}, prefixArgToLua(api.editor))
 		if err != nil {
 			return &ConfigError{Message: "Lua function error: " + err.Error()}
 		}
@@ -174,46 +183,88 @@ func (api *APIBindings) luaGetOption(L *lua.LState) int {
 	return 1
 }
 
-// luaMajorMode implements gmacs.major_mode(name, config)
+// luaMajorMode implements gmacs.major_mode(name, config). config may hold
+// file_patterns (Lua patterns matched against the file path), keymap
+// (key sequence to command name or function), syntax (a grammar for
+// highlighting, see luaGrammar), indent (see luaIndentFunction) and
+// hooks.on_activate / hooks.on_deactivate, which are called with the buffer.
 func (api *APIBindings) luaMajorMode(L *lua.LState) int {
 	name := L.CheckString(1)
 	configTable := L.CheckTable(2)
 	
-	// Convert Lua table to Go map
-	config := make(map[string]interface{})
-	configTable.ForEach(func(key, value lua.LValue) {
-		if keyStr, ok := key.(lua.LString); ok {
-			config[string(keyStr)] = luaValueToGo(value)
+	config := domain.MajorModeConfig{}
+	
+	if patterns := luaStringList(configTable.RawGetString("file_patterns")); len(patterns) > 0 {
+		pattern, err := luaPatternsToRegexp(patterns)
+		if err != nil {
+			L.RaiseError("Invalid file_patterns for %s: %s", name, err.Error())
+			return 0
 		}
-	})
+		config.FilePattern = pattern
+	}
 	
-	err := api.editor.RegisterMajorMode(name, config)
-	if err != nil {
-		L.Push(lua.LString("Error: " + err.Error()))
-		return 1
+	config.Keymap = api.luaKeymap(L, configTable.RawGetString("keymap"))
+	
+	if syntax, ok := configTable.RawGetString("syntax").(*lua.LTable); ok {
+		highlighter, err := luaGrammar(syntax)
+		if err != nil {
+			L.RaiseError("Invalid syntax for %s: %s", name, err.Error())
+			return 0
+		}
+		config.Highlighter = highlighter
+	}
+	
+	config.Indent = api.luaIndentFunction(L, configTable.RawGetString("indent"))
+	
+	if hooks, ok := configTable.RawGetString("hooks").(*lua.LTable); ok {
+		config.OnActivate = api.luaBufferHook(L, hooks.RawGetString("on_activate"))
+		config.OnDeactivate = api.luaBufferHook(L, hooks.RawGetString("on_deactivate"))
+	}
+	
+	if err := api.editor.RegisterMajorMode(name, config); err != nil {
+		L.RaiseError("Failed to define major mode %s: %s", name, err.Error())
+		return 0
 	}
 	
 	log.Info("Lua: Registered major mode %s", name)
 	return 0
 }
 
-// luaMinorMode implements gmacs.minor_mode(name, config)
+// luaSetIndentFunction implements gmacs.set_indent_function(mode, fn),
+// replacing the indentation of any major mode, built-in ones included
+func (api *APIBindings) luaSetIndentFunction(L *lua.LState) int {
+	name := L.CheckString(1)
+	fn := api.luaIndentFunction(L, L.CheckFunction(2))
+	if err := api.editor.ModeManager().SetIndentFunction(name, fn); err != nil {
+		L.RaiseError("%s", err.Error())
+	}
+	return 0
+}
+
+// luaMinorMode implements gmacs.minor_mode(name, config). config may hold
+// priority, keymap, lighter (defaults to the name), on_enable / on_disable
+// called with the buffer, and a predicate that makes the mode global.
 func (api *APIBindings) luaMinorMode(L *lua.LState) int {
 	name := L.CheckString(1)
 	configTable := L.CheckTable(2)
 	
-	// Convert Lua table to Go map
-	config := make(map[string]interface{})
-	configTable.ForEach(func(key, value lua.LValue) {
-		if keyStr, ok := key.(lua.LString); ok {
-			config[string(keyStr)] = luaValueToGo(value)
-		}
-	})
+	config := domain.MinorModeConfig{
+		Lighter: name,
+		Keymap:  api.luaKeymap(L, configTable.RawGetString("keymap")),
+	}
+	if priority, ok := configTable.RawGetString("priority").(lua.LNumber); ok {
+		config.Priority = int(priority)
+	}
+	if lighter, ok := configTable.RawGetString("lighter").(lua.LString); ok {
+		config.Lighter = string(lighter)
+	}
+	config.OnEnable = api.luaBufferHook(L, configTable.RawGetString("on_enable"))
+	config.OnDisable = api.luaBufferHook(L, configTable.RawGetString("on_disable"))
+	config.Predicate = api.luaBufferPredicate(L, configTable.RawGetString("predicate"))
 	
-	err := api.editor.RegisterMinorMode(name, config)
-	if err != nil {
-		L.Push(lua.LString("Error: " + err.Error()))
-		return 1
+	if err := api.editor.RegisterMinorMode(name, config); err != nil {
+		L.RaiseError("Failed to define minor mode %s: %s", name, err.Error())
+		return 0
 	}
 	
 	log.Info("Lua: Registered minor mode %s", name)
@@ -257,18 +308,23 @@ func (api *APIBindings) luaAddHook(L *lua.LState) int {
 
 // luaCurrentBuffer implements gmacs.current_buffer()
 func (api *APIBindings) luaCurrentBuffer(L *lua.LState) int {
-	buffer := api.editor.CurrentBuffer()
-	if buffer == nil {
-		L.Push(lua.LNil)
-		return 1
+	L.Push(newBufferObject(L, api.editor.CurrentBuffer()))
+	return 1
+}
+
+// luaBuffers implements gmacs.buffers(), returning an array of all buffers
+func (api *APIBindings) luaBuffers(L *lua.LState) int {
+	table := L.NewTable()
+	for _, buffer := range api.editor.Buffers() {
+		table.Append(newBufferObject(L, buffer))
 	}
-	
-	// Return a table representing the buffer
-	bufferTable := L.NewTable()
-	L.SetField(bufferTable, "name", lua.LString(buffer.Name()))
-	// Add more buffer properties as needed
-	
-	L.Push(bufferTable)
+	L.Push(table)
+	return 1
+}
+
+// luaCurrentWindow implements gmacs.current_window()
+func (api *APIBindings) luaCurrentWindow(L *lua.LState) int {
+	L.Push(newWindowObject(L, api.editor.CurrentWindow()))
 	return 1
 }
 
@@ -318,6 +374,58 @@ func (api *APIBindings) registerBuiltinCommands() {
 	api.editor.RegisterCommand("find-file", func() error { return domain.FindFile(api.editor) })
 	api.editor.RegisterCommand("delete-backward-char", func() error { return domain.DeleteBackwardChar(api.editor) })
 	api.editor.RegisterCommand("delete-char", func() error { return domain.DeleteChar(api.editor) })
+	api.editor.RegisterCommand("universal-argument", func() error { return domain.UniversalArgument(api.editor) })
+	api.editor.RegisterCommand("digit-argument", func() error { return domain.DigitArgument(api.editor) })
+	api.editor.RegisterCommand("negative-argument", func() error { return domain.NegativeArgument(api.editor) })
+	
+	// Register keyboard macro commands
+	api.editor.RegisterCommand("start-kbd-macro", func() error { return domain.StartKbdMacro(api.editor) })
+	api.editor.RegisterCommand("end-kbd-macro", func() error { return domain.EndKbdMacro(api.editor) })
+	api.editor.RegisterCommand("call-last-kbd-macro", func() error { return domain.CallLastKbdMacro(api.editor) })
+	api.editor.RegisterCommand("name-last-kbd-macro", func() error { return domain.NameLastKbdMacro(api.editor) })
+	api.editor.RegisterCommand("save-kbd-macro", api.saveKbdMacro)
+	api.editor.RegisterCommand("load-theme", func() error { return domain.LoadTheme(api.editor) })
+	
+	// Register file commands
+	api.editor.RegisterCommand("save-buffer", func() error { return domain.SaveBuffer(api.editor) })
+	api.editor.RegisterCommand("write-file", func() error { return domain.WriteFile(api.editor) })
+	api.editor.RegisterCommand("revert-buffer", func() error { return domain.RevertBuffer(api.editor) })
+	api.editor.RegisterCommand("gofmt-buffer", func() error { return domain.GofmtBuffer(api.editor) })
+	
+	// Register editing commands
+	api.editor.RegisterCommand("undo", func() error { return domain.Undo(api.editor) })
+	api.editor.RegisterCommand("redo", func() error { return domain.Redo(api.editor) })
+	api.editor.RegisterCommand("set-mark-command", func() error { return domain.SetMarkCommand(api.editor) })
+	api.editor.RegisterCommand("kill-region", func() error { return domain.KillRegion(api.editor) })
+	api.editor.RegisterCommand("kill-ring-save", func() error { return domain.KillRingSave(api.editor) })
+	api.editor.RegisterCommand("kill-line", func() error { return domain.KillLine(api.editor) })
+	api.editor.RegisterCommand("yank", func() error { return domain.Yank(api.editor) })
+	api.editor.RegisterCommand("yank-pop", func() error { return domain.YankPop(api.editor) })
+	api.editor.RegisterCommand("kill-word", func() error { return domain.KillWord(api.editor) })
+	api.editor.RegisterCommand("backward-kill-word", func() error { return domain.BackwardKillWord(api.editor) })
+	api.editor.RegisterCommand("transpose-chars", func() error { return domain.TransposeChars(api.editor) })
+	api.editor.RegisterCommand("transpose-words", func() error { return domain.TransposeWords(api.editor) })
+	api.editor.RegisterCommand("transpose-lines", func() error { return domain.TransposeLines(api.editor) })
+	api.editor.RegisterCommand("upcase-word", func() error { return domain.UpcaseWord(api.editor) })
+	api.editor.RegisterCommand("downcase-word", func() error { return domain.DowncaseWord(api.editor) })
+	api.editor.RegisterCommand("capitalize-word", func() error { return domain.CapitalizeWord(api.editor) })
+	api.editor.RegisterCommand("upcase-region", func() error { return domain.UpcaseRegion(api.editor) })
+	api.editor.RegisterCommand("downcase-region", func() error { return domain.DowncaseRegion(api.editor) })
+	api.editor.RegisterCommand("join-line", func() error { return domain.JoinLine(api.editor) })
+	api.editor.RegisterCommand("open-line", func() error { return domain.OpenLine(api.editor) })
+	api.editor.RegisterCommand("delete-blank-lines", func() error { return domain.DeleteBlankLines(api.editor) })
+	api.editor.RegisterCommand("indent-for-tab-command", func() error { return domain.IndentForTabCommand(api.editor) })
+	api.editor.RegisterCommand("newline-and-indent", func() error { return domain.NewlineAndIndent(api.editor) })
+	api.editor.RegisterCommand("indent-region", func() error { return domain.IndentRegion(api.editor) })
+	api.editor.RegisterCommand("indent-rigidly", func() error { return domain.IndentRigidly(api.editor) })
+	
+	// Register search commands
+	api.editor.RegisterCommand("isearch-forward", func() error { return domain.ISearchForward(api.editor) })
+	api.editor.RegisterCommand("isearch-backward", func() error { return domain.ISearchBackward(api.editor) })
+	api.editor.RegisterCommand("isearch-forward-regexp", func() error { return domain.ISearchForwardRegexp(api.editor) })
+	api.editor.RegisterCommand("isearch-backward-regexp", func() error { return domain.ISearchBackwardRegexp(api.editor) })
+	api.editor.RegisterCommand("query-replace", func() error { return domain.QueryReplaceCommand(api.editor) })
+	api.editor.RegisterCommand("replace-regexp", func() error { return domain.ReplaceRegexp(api.editor) })
 	
 	// Register cursor movement commands
 	api.editor.RegisterCommand("forward-char", func() error { return domain.ForwardChar(api.editor) })
@@ -326,12 +434,21 @@ func (api *APIBindings) registerBuiltinCommands() {
 	api.editor.RegisterCommand("previous-line", func() error { return domain.PreviousLine(api.editor) })
 	api.editor.RegisterCommand("beginning-of-line", func() error { return domain.BeginningOfLine(api.editor) })
 	api.editor.RegisterCommand("end-of-line", func() error { return domain.EndOfLine(api.editor) })
+	api.editor.RegisterCommand("forward-word", func() error { return domain.ForwardWord(api.editor) })
+	api.editor.RegisterCommand("backward-word", func() error { return domain.BackwardWord(api.editor) })
+	api.editor.RegisterCommand("beginning-of-buffer", func() error { return domain.BeginningOfBuffer(api.editor) })
+	api.editor.RegisterCommand("end-of-buffer", func() error { return domain.EndOfBuffer(api.editor) })
+	api.editor.RegisterCommand("forward-paragraph", func() error { return domain.ForwardParagraph(api.editor) })
+	api.editor.RegisterCommand("backward-paragraph", func() error { return domain.BackwardParagraph(api.editor) })
+	api.editor.RegisterCommand("goto-line", func() error { return domain.GotoLine(api.editor) })
+	api.editor.RegisterCommand("back-to-indentation", func() error { return domain.BackToIndentation(api.editor) })
 	
 	// Register scrolling commands
 	api.editor.RegisterCommand("scroll-up", func() error { return domain.ScrollUp(api.editor) })
 	api.editor.RegisterCommand("scroll-down", func() error { return domain.ScrollDown(api.editor) })
 	api.editor.RegisterCommand("page-up", func() error { return domain.PageUp(api.editor) })
 	api.editor.RegisterCommand("page-down", func() error { return domain.PageDown(api.editor) })
+	api.editor.RegisterCommand("recenter-top-bottom", func() error { return domain.RecenterTopBottom(api.editor) })
 	api.editor.RegisterCommand("toggle-truncate-lines", func() error { return domain.ToggleLineWrap(api.editor) })
 	api.editor.RegisterCommand("debug-info", func() error { return domain.ShowDebugInfo(api.editor) })
 	
@@ -356,11 +473,146 @@ func (api *APIBindings) registerBuiltinCommands() {
 // registerMinorModeCommands registers minor mode commands
 func (api *APIBindings) registerMinorModeCommands() {
 	// auto-a-mode is now defined in default.lua
-	// Other minor mode commands can be added here if needed
+	api.editor.RegisterCommand("auto-revert-mode", func() error { return domain.AutoRevertModeCommand(api.editor) })
+}
+
+// luaKeymap converts a keymap table whose values are command names or functions
+func (api *APIBindings) luaKeymap(L *lua.LState, value lua.LValue) []domain.ModeKey {
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return nil
+	}
+	
+	var keys []domain.ModeKey
+	table.ForEach(func(key, val lua.LValue) {
+		sequence, ok := key.(lua.LString)
+		if !ok {
+			return
+		}
+		switch v := val.(type) {
+		case lua.LString:
+			keys = append(keys, domain.ModeKey{Sequence: string(sequence), Command: string(v)})
+		case *lua.LFunction:
+			keys = append(keys, domain.ModeKey{Sequence: string(sequence), Func: api.luaCommand(L, v)})
+		}
+	})
+	return keys
+}
+
+// luaCommand wraps a Lua function as a command that receives the prefix
+// argument like gmacs.defun functions
+func (api *APIBindings) luaCommand(L *lua.LState, fn *lua.LFunction) domain.CommandFunc {
+	return func(editor *domain.Editor) error {
+		err := L.CallByParam(lua.P{
+			Fn:      fn,
+			NRet:    0,
+			Protect: true,
+		}, prefixArgToLua(editor))
+		if err != nil {
+			return &ConfigError{Message: "Lua function error: " + err.Error()}
+		}
+		return nil
+	}
+}
+
+// luaBufferHook wraps a Lua function taking a buffer, or returns nil if
+// value is not a function
+func (api *APIBindings) luaBufferHook(L *lua.LState, value lua.LValue) func(*domain.Buffer) error {
+	fn, ok := value.(*lua.LFunction)
+	if !ok {
+		return nil
+	}
+	return func(buffer *domain.Buffer) error {
+		err := L.CallByParam(lua.P{
+			Fn:      fn,
+			NRet:    0,
+			Protect: true,
+		}, newBufferObject(L, buffer))
+		if err != nil {
+			return &ConfigError{Message: "Lua hook error: " + err.Error()}
+		}
+		return nil
+	}
+}
+
+// luaIndentFunction wraps a Lua function called with a buffer and a
+// 1-based line number, returning the column to indent the line to or nil
+// to leave it alone. It returns nil if value is not a function.
+func (api *APIBindings) luaIndentFunction(L *lua.LState, value lua.LValue) domain.IndentFunc {
+	fn, ok := value.(*lua.LFunction)
+	if !ok {
+		return nil
+	}
+	return func(buffer *domain.Buffer, line int) int {
+		err := L.CallByParam(lua.P{
+			Fn:      fn,
+			NRet:    1,
+			Protect: true,
+		}, newBufferObject(L, buffer), lua.LNumber(line+1))
+		if err != nil {
+			log.Warn("Lua indent function error: %v", err)
+			return -1
+		}
+		result := L.Get(-1)
+		L.Pop(1)
+		if column, ok := result.(lua.LNumber); ok {
+			return int(column)
+		}
+		return -1
+	}
+}
+
+// luaBufferPredicate wraps a Lua function taking a buffer and returning a
+// boolean, or returns nil if value is not a function
+func (api *APIBindings) luaBufferPredicate(L *lua.LState, value lua.LValue) func(*domain.Buffer) bool {
+	fn, ok := value.(*lua.LFunction)
+	if !ok {
+		return nil
+	}
+	return func(buffer *domain.Buffer) bool {
+		err := L.CallByParam(lua.P{
+			Fn:      fn,
+			NRet:    1,
+			Protect: true,
+		}, newBufferObject(L, buffer))
+		if err != nil {
+			log.Warn("Lua predicate error: %v", err)
+			return false
+		}
+		result := L.Get(-1)
+		L.Pop(1)
+		return lua.LVAsBool(result)
+	}
 }
 
 // Helper functions for type conversion
 
+// prefixArgToLua returns the numeric prefix argument of the running command,
+// or nil if none was given
+func prefixArgToLua(editor *domain.Editor) lua.LValue {
+	if arg := editor.PrefixArg(); arg != nil {
+		return lua.LNumber(arg.Value)
+	}
+	return lua.LNil
+}
+
+// luaStringList converts a string or an array of strings to a slice
+func luaStringList(value lua.LValue) []string {
+	switch v := value.(type) {
+	case lua.LString:
+		return []string{string(v)}
+	case *lua.LTable:
+		var list []string
+		for i := 1; i <= v.Len(); i++ {
+			if s, ok := v.RawGetInt(i).(lua.LString); ok {
+				list = append(list, string(s))
+			}
+		}
+		return list
+	}
+	return nil
+}
+
 func luaValueToGo(value lua.LValue) interface{} {
 	switch value.Type() {
 	case lua.LTString:
@@ -393,6 +645,12 @@ func goValueToLua(L *lua.LState, value interface{}) lua.LValue {
 		return lua.LNumber(v)
 	case bool:
 		return lua.LBool(v)
+	case *domain.Buffer:
+		return newBufferObject(L, v)
+	case *domain.Window:
+		return newWindowObject(L, v)
+	case domain.Position:
+		return positionToLua(L, v)
 	default:
 		return lua.LNil
 	}
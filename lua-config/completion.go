@@ -0,0 +1,91 @@
+package luaconfig
+
+import (
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/log"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaCompletingRead implements gmacs.completing_read(prompt, collection,
+// callback, options). collection is an array of strings or a function
+// called with the input that returns one. callback receives the string
+// read. options may hold annotate, a function returning the text shown
+// after a candidate.
+func (api *APIBindings) luaCompletingRead(L *lua.LState) int {
+	prompt := L.CheckString(1)
+	collection := L.Get(2)
+	if _, ok := collection.(*lua.LFunction); !ok && collection.Type() != lua.LTTable {
+		L.ArgError(2, "array of strings or function expected")
+		return 0
+	}
+	callback := L.CheckFunction(3)
+	table := &luaCompletionTable{L: L, collection: collection}
+	if options, ok := L.Get(4).(*lua.LTable); ok {
+		table.annotate, _ = options.RawGetString("annotate").(*lua.LFunction)
+	}
+
+	minibuffer := api.editor.Minibuffer()
+	minibuffer.StartInput(prompt, func(editor *domain.Editor, input string) {
+		err := L.CallByParam(lua.P{
+			Fn:      callback,
+			NRet:    0,
+			Protect: true,
+		}, lua.LString(input))
+		if err != nil {
+			log.Error("Lua completing_read callback error: %v", err)
+			editor.SetMinibufferMessage("Lua function error: " + err.Error())
+		}
+	})
+	minibuffer.SetCompletionTable(table)
+	return 0
+}
+
+// luaCompletionTable is the completion table of gmacs.completing_read
+type luaCompletionTable struct {
+	L          *lua.LState
+	collection lua.LValue
+	annotate   *lua.LFunction // nil for no annotations
+}
+
+func (t *luaCompletionTable) Candidates(input string) (string, []string) {
+	fn, ok := t.collection.(*lua.LFunction)
+	if !ok {
+		return "", luaStringList(t.collection)
+	}
+	result, err := t.call(fn, input)
+	if err != nil {
+		log.Warn("Lua completion function error: %v", err)
+		return "", nil
+	}
+	return "", luaStringList(result)
+}
+
+func (t *luaCompletionTable) Annotation(candidate string) string {
+	if t.annotate == nil {
+		return ""
+	}
+	result, err := t.call(t.annotate, candidate)
+	if err != nil {
+		log.Warn("Lua annotation function error: %v", err)
+		return ""
+	}
+	if s, ok := result.(lua.LString); ok {
+		return string(s)
+	}
+	return ""
+}
+
+// call calls fn with a string and returns its result
+func (t *luaCompletionTable) call(fn *lua.LFunction, arg string) (lua.LValue, error) {
+	err := t.L.CallByParam(lua.P{
+		Fn:      fn,
+		NRet:    1,
+		Protect: true,
+	}, lua.LString(arg))
+	if err != nil {
+		return lua.LNil, err
+	}
+	result := t.L.Get(-1)
+	t.L.Pop(1)
+	return result, nil
+}
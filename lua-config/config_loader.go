@@ -59,19 +59,7 @@ func (cl *ConfigLoader) LoadConfig(configPath string) error {
 
 // FindConfigFile searches for a configuration file in standard locations
 func (cl *ConfigLoader) FindConfigFile() (string, error) {
-	// Check for ~/.gmacs/init.lua
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		log.Warn("Could not get user home directory: %v", err)
-		return "", nil
-	}
-	
-	configPaths := []string{
-		filepath.Join(homeDir, ".gmacs", "init.lua"),
-		filepath.Join(homeDir, ".gmacs.lua"),
-	}
-	
-	for _, path := range configPaths {
+	for _, path := range userConfigPaths() {
 		if _, err := os.Stat(path); err == nil {
 			log.Info("Found config file: %s", path)
 			return path, nil
@@ -82,6 +70,21 @@ func (cl *ConfigLoader) FindConfigFile() (string, error) {
 	return "", nil
 }
 
+// userConfigPaths returns the standard config file locations in the order
+// they are searched, starting with ~/.gmacs/init.lua
+func userConfigPaths() []string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		log.Warn("Could not get user home directory: %v", err)
+		return nil
+	}
+	
+	return []string{
+		filepath.Join(homeDir, ".gmacs", "init.lua"),
+		filepath.Join(homeDir, ".gmacs.lua"),
+	}
+}
+
 // ReloadConfig reloads the current configuration file
 func (cl *ConfigLoader) ReloadConfig() error {
 	if cl.configPath == "" {
@@ -11,13 +11,77 @@ gmacs.bind_key("C-n", "next-line")
 gmacs.bind_key("C-p", "previous-line")
 gmacs.bind_key("C-a", "beginning-of-line")
 gmacs.bind_key("C-e", "end-of-line")
+gmacs.bind_key("M-f", "forward-word")
+gmacs.bind_key("M-b", "backward-word")
+gmacs.bind_key("M-<", "beginning-of-buffer")
+gmacs.bind_key("M->", "end-of-buffer")
+gmacs.bind_key("M-}", "forward-paragraph")
+gmacs.bind_key("M-{", "backward-paragraph")
+gmacs.bind_key("M-g g", "goto-line")
+gmacs.bind_key("M-g M-g", "goto-line")
+gmacs.bind_key("M-m", "back-to-indentation")
 
 -- Scrolling commands
 gmacs.bind_key("C-v", "page-down")
 gmacs.bind_key("M-v", "page-up")
-gmacs.bind_key("C-u", "scroll-up")
+gmacs.bind_key("C-l", "recenter-top-bottom")
 gmacs.bind_key("C-d", "scroll-down")
 
+-- Prefix arguments (C-u, C-u C-u, C-u 8, M-5, M--)
+gmacs.bind_key("C-u", "universal-argument")
+for digit = 0, 9 do
+    gmacs.bind_key("M-" .. digit, "digit-argument")
+end
+gmacs.bind_key("M--", "negative-argument")
+
+-- Keyboard macros
+gmacs.bind_key("C-x (", "start-kbd-macro")
+gmacs.bind_key("C-x )", "end-kbd-macro")
+gmacs.bind_key("C-x e", "call-last-kbd-macro")
+
+-- Undo/redo (C-/ and C-_ send the same byte in a terminal)
+gmacs.bind_key("C-/", "undo")
+gmacs.bind_key("C-x u", "undo")
+gmacs.bind_key("C-M-/", "redo")
+
+-- Mark, region and kill ring
+gmacs.bind_key("C-SPC", "set-mark-command")
+gmacs.bind_key("C-w", "kill-region")
+gmacs.bind_key("M-w", "kill-ring-save")
+gmacs.bind_key("C-k", "kill-line")
+gmacs.bind_key("C-y", "yank")
+gmacs.bind_key("M-y", "yank-pop")
+gmacs.bind_key("M-d", "kill-word")
+gmacs.bind_key("M-Backspace", "backward-kill-word")
+
+-- Word and line editing
+gmacs.bind_key("C-t", "transpose-chars")
+gmacs.bind_key("M-t", "transpose-words")
+gmacs.bind_key("C-x C-t", "transpose-lines")
+gmacs.bind_key("M-u", "upcase-word")
+gmacs.bind_key("M-l", "downcase-word")
+gmacs.bind_key("M-c", "capitalize-word")
+gmacs.bind_key("C-x C-u", "upcase-region")
+gmacs.bind_key("C-x C-l", "downcase-region")
+gmacs.bind_key("M-^", "join-line")
+gmacs.bind_key("C-o", "open-line")
+gmacs.bind_key("C-x C-o", "delete-blank-lines")
+
+-- Indentation
+gmacs.bind_key("TAB", "indent-for-tab-command")
+gmacs.bind_key("C-j", "newline-and-indent")
+gmacs.bind_key("C-M-\\", "indent-region")
+gmacs.bind_key("C-x TAB", "indent-rigidly")
+
+-- Incremental search
+gmacs.bind_key("C-s", "isearch-forward")
+gmacs.bind_key("C-r", "isearch-backward")
+gmacs.bind_key("C-M-s", "isearch-forward-regexp")
+gmacs.bind_key("C-M-r", "isearch-backward-regexp")
+
+-- Replace
+gmacs.bind_key("M-%", "query-replace")
+
 -- Buffer management
 gmacs.bind_key("C-x b", "switch-to-buffer")
 gmacs.bind_key("C-x C-b", "list-buffers") 
@@ -42,6 +106,8 @@ gmacs.bind_key("C-x C-c", "quit")
 
 -- File operations  
 gmacs.bind_key("C-x C-f", "find-file")
+gmacs.bind_key("C-x C-s", "save-buffer")
+gmacs.bind_key("C-x C-w", "write-file")
 
 -- Define auto-a-mode command in Lua
 gmacs.defun("auto-a-mode", function()
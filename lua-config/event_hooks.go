@@ -3,6 +3,7 @@ package luaconfig
 import (
 	"sync"
 	lua "github.com/yuin/gopher-lua"
+	"github.com/TakahashiShuuhei/gmacs/domain"
 	"github.com/TakahashiShuuhei/gmacs/log"
 )
 
@@ -86,22 +87,9 @@ func (hm *HookManager) ListEvents() []string {
 	return events
 }
 
-// StandardEvents defines the standard hook events available in gmacs
-var StandardEvents = []string{
-	"before-save",        // Before saving a buffer
-	"after-save",         // After saving a buffer
-	"after-change",       // After text is changed in a buffer
-	"before-change",      // Before text is changed in a buffer
-	"key-press",          // When a key is pressed
-	"buffer-create",      // When a new buffer is created
-	"buffer-switch",      // When switching between buffers
-	"mode-activate",      // When a mode is activated
-	"mode-deactivate",    // When a mode is deactivated
-	"window-create",      // When a new window is created
-	"window-resize",      // When a window is resized
-	"editor-startup",     // When the editor starts up
-	"editor-shutdown",    // When the editor shuts down
-}
+// StandardEvents lists the hook events fired by the editor. See
+// domain/hooks.go for the arguments passed with each event.
+var StandardEvents = domain.HookEvents
 
 // LuaHookWrapper creates a hook function that calls a Lua function
 func LuaHookWrapper(L *lua.LState, fn lua.LValue) func(...interface{}) error {
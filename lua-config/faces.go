@@ -0,0 +1,50 @@
+package luaconfig
+
+import (
+	"github.com/TakahashiShuuhei/gmacs/log"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaSetFace implements gmacs.set_face(name, attributes). attributes may
+// hold fg and bg colors and the bold, italic, underline and reverse flags;
+// the attributes left out keep their current value.
+func (api *APIBindings) luaSetFace(L *lua.LState) int {
+	name := L.CheckString(1)
+	table := L.CheckTable(2)
+
+	face, _ := api.editor.Faces().Get(name)
+	if fg, ok := table.RawGetString("fg").(lua.LString); ok {
+		face.Foreground = string(fg)
+	}
+	if bg, ok := table.RawGetString("bg").(lua.LString); ok {
+		face.Background = string(bg)
+	}
+	flags := map[string]*bool{
+		"bold":      &face.Bold,
+		"italic":    &face.Italic,
+		"underline": &face.Underline,
+		"reverse":   &face.Reverse,
+	}
+	for key, flag := range flags {
+		if value := table.RawGetString(key); value != lua.LNil {
+			*flag = lua.LVAsBool(value)
+		}
+	}
+
+	if err := api.editor.Faces().Set(name, face); err != nil {
+		L.RaiseError("Failed to set face %s: %s", name, err.Error())
+		return 0
+	}
+	return 0
+}
+
+// luaLoadTheme implements gmacs.load_theme(name)
+func (api *APIBindings) luaLoadTheme(L *lua.LState) int {
+	name := L.CheckString(1)
+	if err := api.editor.Faces().LoadTheme(name); err != nil {
+		L.RaiseError("%s", err.Error())
+		return 0
+	}
+	log.Info("Lua: Loaded theme %s", name)
+	return 0
+}
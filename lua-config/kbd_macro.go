@@ -0,0 +1,163 @@
+package luaconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	"github.com/TakahashiShuuhei/gmacs/events"
+	"github.com/TakahashiShuuhei/gmacs/log"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Keyboard macros are saved to the user's init file as calls to
+// gmacs.kbd_macro(name, keys), where keys is an array of key tables such as
+// {key = "a", rune = "a"} or {key = "e", ctrl = true}.
+
+// luaKbdMacro implements gmacs.kbd_macro(name, keys), defining a keyboard
+// macro as a command
+func (api *APIBindings) luaKbdMacro(L *lua.LState) int {
+	name := L.CheckString(1)
+	table := L.CheckTable(2)
+
+	keys := make([]events.KeyEventData, 0, table.Len())
+	for i := 1; i <= table.Len(); i++ {
+		key, ok := table.RawGetInt(i).(*lua.LTable)
+		if !ok {
+			L.ArgError(2, "array of key tables expected")
+			return 0
+		}
+		keys = append(keys, luaToKeyEvent(key))
+	}
+
+	api.editor.DefineKbdMacro(name, keys)
+	log.Info("Lua: Defined keyboard macro %s", name)
+	return 0
+}
+
+// saveKbdMacro implements save-kbd-macro. It reads a name and appends the
+// macro of that name to the user's init file; if there is none, the last
+// keyboard macro is first defined under the name.
+func (api *APIBindings) saveKbdMacro() error {
+	api.editor.Minibuffer().StartInput("Save kbd macro as: ", func(editor *domain.Editor, name string) {
+		if name == "" {
+			return
+		}
+
+		keys, exists := editor.KbdMacro(name)
+		if !exists {
+			keys = editor.LastKbdMacro()
+			if len(keys) == 0 {
+				editor.SetMinibufferMessage("No kbd macro has been defined")
+				return
+			}
+			editor.DefineKbdMacro(name, keys)
+		}
+
+		path := userInitFile(editor)
+		if err := appendToFile(path, formatKbdMacro(name, keys)); err != nil {
+			log.Error("Failed to save keyboard macro %s: %v", name, err)
+			editor.SetMinibufferMessage("Cannot write file: " + path)
+			return
+		}
+		editor.SetMinibufferMessage("Saved kbd macro " + name + " to " + path)
+	})
+	return nil
+}
+
+// userInitFile returns the file macros are saved to: the user-init-file
+// option if set, else the existing config file, else ~/.gmacs/init.lua
+func userInitFile(editor *domain.Editor) string {
+	if value, err := editor.GetOption("user-init-file"); err == nil {
+		if path, ok := value.(string); ok && path != "" {
+			return path
+		}
+	}
+
+	paths := userConfigPaths()
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	if len(paths) == 0 {
+		return "init.lua"
+	}
+	return paths[0]
+}
+
+// appendToFile appends text to path, creating the file and its directory
+func appendToFile(path, text string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(text); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// formatKbdMacro returns the Lua code that defines a keyboard macro
+func formatKbdMacro(name string, keys []events.KeyEventData) string {
+	var sb strings.Builder
+	sb.WriteString("\n-- Keyboard macro saved with save-kbd-macro\n")
+	fmt.Fprintf(&sb, "gmacs.kbd_macro(%s, {\n", luaQuote(name))
+	for _, key := range keys {
+		fields := []string{"key = " + luaQuote(key.Key)}
+		if key.Rune != 0 {
+			fields = append(fields, "rune = "+luaQuote(string(key.Rune)))
+		}
+		if key.Ctrl {
+			fields = append(fields, "ctrl = true")
+		}
+		if key.Meta {
+			fields = append(fields, "meta = true")
+		}
+		fmt.Fprintf(&sb, "    {%s},\n", strings.Join(fields, ", "))
+	}
+	sb.WriteString("})\n")
+	return sb.String()
+}
+
+// luaToKeyEvent converts a key table written by formatKbdMacro
+func luaToKeyEvent(table *lua.LTable) events.KeyEventData {
+	event := events.KeyEventData{
+		Key:  lua.LVAsString(table.RawGetString("key")),
+		Ctrl: lua.LVAsBool(table.RawGetString("ctrl")),
+		Meta: lua.LVAsBool(table.RawGetString("meta")),
+	}
+	if r := []rune(lua.LVAsString(table.RawGetString("rune"))); len(r) > 0 {
+		event.Rune = r[0]
+	}
+	return event
+}
+
+// luaQuote returns s as a Lua string literal. Control characters use
+// decimal escapes, which every Lua version understands.
+func luaQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c == '\n':
+			sb.WriteString(`\n`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&sb, "\\%03d", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
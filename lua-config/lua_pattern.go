@@ -0,0 +1,176 @@
+package luaconfig
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// luaClasses maps Lua pattern classes (%a, %d, ...) to POSIX class names.
+// The upper case forms (%A, %D, ...) are their complements.
+var luaClasses = map[byte]string{
+	'a': "alpha",
+	'c': "cntrl",
+	'd': "digit",
+	'g': "graph",
+	'l': "lower",
+	'p': "punct",
+	's': "space",
+	'u': "upper",
+	'w': "alnum",
+	'x': "xdigit",
+}
+
+// luaPatternsToRegexp translates Lua patterns, as used by string.find, into
+// one regular expression matching whatever any of them matches
+func luaPatternsToRegexp(patterns []string) (*regexp.Regexp, error) {
+	parts := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		expr, err := translateLuaPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = "(?:" + expr + ")"
+	}
+	return regexp.Compile("(?s)" + strings.Join(parts, "|"))
+}
+
+// translateLuaPattern converts a single Lua pattern to regexp syntax.
+// The balance (%b) and frontier (%f) items and back-references (%1) are
+// not supported.
+func translateLuaPattern(pattern string) (string, error) {
+	var sb strings.Builder
+	i := 0
+	if strings.HasPrefix(pattern, "^") {
+		sb.WriteByte('^')
+		i++
+	}
+
+	for i < len(pattern) {
+		c := pattern[i]
+		var item string
+		switch {
+		case c == '%':
+			if i+1 >= len(pattern) {
+				return "", &ConfigError{Message: "malformed pattern (ends with '%'): " + pattern}
+			}
+			next := pattern[i+1]
+			if next == 'b' || next == 'f' {
+				return "", &ConfigError{Message: "unsupported pattern item %" + string(next) + ": " + pattern}
+			}
+			if next >= '0' && next <= '9' {
+				return "", &ConfigError{Message: "back-references are not supported (%" + string(next) + "): " + pattern}
+			}
+			item = classItem(next, false)
+			i += 2
+		case c == '[':
+			set, end, err := translateLuaSet(pattern, i)
+			if err != nil {
+				return "", err
+			}
+			item = set
+			i = end
+		case c == '$' && i == len(pattern)-1:
+			sb.WriteByte('$')
+			i++
+			continue
+		case c == '(' || c == ')':
+			// Captures become groups; they do not take quantifiers
+			sb.WriteByte(c)
+			i++
+			continue
+		case c == '.':
+			item = "."
+			i++
+		default:
+			r, size := utf8.DecodeRuneInString(pattern[i:])
+			item = regexp.QuoteMeta(string(r))
+			i += size
+		}
+
+		sb.WriteString(item)
+		if i < len(pattern) {
+			switch pattern[i] {
+			case '*', '+', '?':
+				sb.WriteByte(pattern[i])
+				i++
+			case '-':
+				sb.WriteString("*?")
+				i++
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+// classItem returns the regexp for the Lua escape %c, either standalone or
+// for use inside a bracketed set
+func classItem(c byte, inSet bool) string {
+	name, ok := luaClasses[c|0x20]
+	if !ok {
+		// %x for a non-alphanumeric x is the character itself
+		if inSet {
+			return escapeSetChar(c)
+		}
+		return regexp.QuoteMeta(string(c))
+	}
+	class := "[:" + name + ":]"
+	if c >= 'A' && c <= 'Z' {
+		class = "[:^" + name + ":]"
+	}
+	if inSet {
+		return class
+	}
+	return "[" + class + "]"
+}
+
+// translateLuaSet converts the set starting at pattern[start] == '[' and
+// returns it with the index just past its closing ']'
+func translateLuaSet(pattern string, start int) (string, int, error) {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		sb.WriteByte('^')
+		i++
+	}
+
+	first := true
+	for {
+		if i >= len(pattern) {
+			return "", 0, &ConfigError{Message: "malformed pattern (missing ']'): " + pattern}
+		}
+		c := pattern[i]
+		switch {
+		case c == ']' && !first:
+			sb.WriteByte(']')
+			return sb.String(), i + 1, nil
+		case c == '%':
+			if i+1 >= len(pattern) {
+				return "", 0, &ConfigError{Message: "malformed pattern (ends with '%'): " + pattern}
+			}
+			sb.WriteString(classItem(pattern[i+1], true))
+			i += 2
+		case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+			sb.WriteString(escapeSetChar(c) + "-" + escapeSetChar(pattern[i+2]))
+			i += 3
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRuneInString(pattern[i:])
+			sb.WriteRune(r)
+			i += size
+		default:
+			sb.WriteString(escapeSetChar(c))
+			i++
+		}
+		first = false
+	}
+}
+
+// escapeSetChar escapes a character for use inside a regexp set
+func escapeSetChar(c byte) string {
+	switch c {
+	case '\\', ']', '[', '^', '-':
+		return `\` + string(c)
+	}
+	return string(c)
+}
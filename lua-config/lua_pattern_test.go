@@ -0,0 +1,62 @@
+package luaconfig
+
+import (
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// TestLuaPatternsMatchLikeStringFind checks the translated regexps against
+// string.find in the Lua VM itself
+func TestLuaPatternsMatchLikeStringFind(t *testing.T) {
+	patterns := []string{
+		`%.go$`,
+		`^Makefile$`,
+		`%.[ch]pp$`,
+		`%.md$`,
+		`[^/]+%.txt$`,
+		`/%.?config/`,
+		`%d%d%-%d+`,
+		`^%u%l+%.lua$`,
+		`a.-b`,
+		`%.tar%.[gx]z$`,
+		`[%w_]+_test%.go$`,
+		`%$HOME`,
+	}
+	subjects := []string{
+		"main.go", "main.go.bak", "Makefile", "src/Makefile", "a.cpp", "a.hpp", "a.pp",
+		"README.md", "dir/notes.txt", "/home/u/.config/x", "/etc/config/y",
+		"12-345", "1-2", "Init.lua", "init.lua", "aXXb", "ab", "x.tar.gz", "x.tar.bz",
+		"foo_bar_test.go", "_test.go", "$HOME/x", "日本語.txt",
+	}
+
+	L := lua.NewState()
+	defer L.Close()
+	find := L.GetField(L.GetGlobal("string"), "find")
+
+	for _, pattern := range patterns {
+		re, err := luaPatternsToRegexp([]string{pattern})
+		if err != nil {
+			t.Errorf("Failed to translate %q: %v", pattern, err)
+			continue
+		}
+		for _, subject := range subjects {
+			if err := L.CallByParam(lua.P{Fn: find, NRet: 1, Protect: true}, lua.LString(subject), lua.LString(pattern)); err != nil {
+				t.Fatalf("string.find(%q, %q) failed: %v", subject, pattern, err)
+			}
+			want := L.Get(-1) != lua.LNil
+			L.Pop(1)
+			if got := re.MatchString(subject); got != want {
+				t.Errorf("Pattern %q on %q: regexp match %v, string.find %v", pattern, subject, got, want)
+			}
+		}
+	}
+}
+
+func TestLuaPatternErrors(t *testing.T) {
+	for _, pattern := range []string{`abc%`, `[abc`, `%b()`, `%f[%w]`, `(a)%1`} {
+		if _, err := luaPatternsToRegexp([]string{pattern}); err == nil {
+			t.Errorf("Expected an error for %q", pattern)
+		}
+	}
+}
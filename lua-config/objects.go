@@ -0,0 +1,256 @@
+package luaconfig
+
+import (
+	"errors"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Buffers and windows are passed to Lua as userdata objects with methods,
+// e.g. buf:name() or win:buffer(). Two objects wrapping the same buffer
+// compare equal. Positions are tables {line = n, col = n}, both 1-based,
+// where col is a byte index into the line as with string.sub.
+
+const (
+	bufferTypeName = "gmacs.buffer"
+	windowTypeName = "gmacs.window"
+)
+
+// registerObjectTypes creates the metatables of buffer and window objects.
+// Methods that act through the editor are bound to api.
+func (api *APIBindings) registerObjectTypes(L *lua.LState) {
+	registerObjectType(L, bufferTypeName, map[string]lua.LGFunction{
+		"name":          bufferName,
+		"filepath":      bufferFilepath,
+		"is_modified":   bufferIsModified,
+		"line_count":    bufferLineCount,
+		"get_line":      bufferGetLine,
+		"indentation":   bufferIndentation,
+		"insert":        bufferInsert,
+		"delete_region": bufferDeleteRegion,
+		"cursor":        bufferCursor,
+		"set_cursor":    bufferSetCursor,
+		"major_mode":    bufferMajorMode,
+		"save":          api.bufferSave,
+	})
+	registerObjectType(L, windowTypeName, map[string]lua.LGFunction{
+		"buffer":     windowBuffer,
+		"split":      api.windowSplit,
+		"set_buffer": api.windowSetBuffer,
+		"scroll_top": windowScrollTop,
+	})
+}
+
+// registerObjectType creates the metatable for typeName
+func registerObjectType(L *lua.LState, typeName string, methods map[string]lua.LGFunction) {
+	mt := L.NewTypeMetatable(typeName)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), methods))
+	L.SetField(mt, "__eq", L.NewFunction(objectEqual))
+	L.SetField(mt, "__tostring", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString(typeName))
+		return 1
+	}))
+}
+
+// objectEqual compares two objects by the Go value they wrap
+func objectEqual(L *lua.LState) int {
+	a, b := L.CheckUserData(1), L.CheckUserData(2)
+	L.Push(lua.LBool(a.Value == b.Value))
+	return 1
+}
+
+// newBufferObject wraps buffer for Lua, returning nil for a nil buffer
+func newBufferObject(L *lua.LState, buffer *domain.Buffer) lua.LValue {
+	if buffer == nil {
+		return lua.LNil
+	}
+	ud := L.NewUserData()
+	ud.Value = buffer
+	L.SetMetatable(ud, L.GetTypeMetatable(bufferTypeName))
+	return ud
+}
+
+// newWindowObject wraps window for Lua, returning nil for a nil window
+func newWindowObject(L *lua.LState, window *domain.Window) lua.LValue {
+	if window == nil {
+		return lua.LNil
+	}
+	ud := L.NewUserData()
+	ud.Value = window
+	L.SetMetatable(ud, L.GetTypeMetatable(windowTypeName))
+	return ud
+}
+
+// checkBuffer returns the buffer wrapped by argument n
+func checkBuffer(L *lua.LState, n int) *domain.Buffer {
+	if buffer, ok := L.CheckUserData(n).Value.(*domain.Buffer); ok {
+		return buffer
+	}
+	L.ArgError(n, "buffer expected")
+	return nil
+}
+
+// checkWindow returns the window wrapped by argument n
+func checkWindow(L *lua.LState, n int) *domain.Window {
+	if window, ok := L.CheckUserData(n).Value.(*domain.Window); ok {
+		return window
+	}
+	L.ArgError(n, "window expected")
+	return nil
+}
+
+// positionToLua converts a buffer position to a {line, col} table
+func positionToLua(L *lua.LState, pos domain.Position) *lua.LTable {
+	table := L.NewTable()
+	L.SetField(table, "line", lua.LNumber(pos.Row+1))
+	L.SetField(table, "col", lua.LNumber(pos.Col+1))
+	return table
+}
+
+// checkPosition converts argument n, a {line, col} table, to a buffer position
+func checkPosition(L *lua.LState, n int) domain.Position {
+	table := L.CheckTable(n)
+	line, lineOk := table.RawGetString("line").(lua.LNumber)
+	col, colOk := table.RawGetString("col").(lua.LNumber)
+	if !lineOk || !colOk {
+		L.ArgError(n, "position {line = n, col = n} expected")
+	}
+	return domain.Position{Row: int(line) - 1, Col: int(col) - 1}
+}
+
+func bufferName(L *lua.LState) int {
+	L.Push(lua.LString(checkBuffer(L, 1).Name()))
+	return 1
+}
+
+func bufferFilepath(L *lua.LState) int {
+	path := checkBuffer(L, 1).Filepath()
+	if path == "" {
+		L.Push(lua.LNil)
+	} else {
+		L.Push(lua.LString(path))
+	}
+	return 1
+}
+
+func bufferIsModified(L *lua.LState) int {
+	L.Push(lua.LBool(checkBuffer(L, 1).IsModified()))
+	return 1
+}
+
+func bufferLineCount(L *lua.LState) int {
+	L.Push(lua.LNumber(checkBuffer(L, 1).LineCount()))
+	return 1
+}
+
+// bufferGetLine implements buf:get_line(n), returning nil past the last line
+func bufferGetLine(L *lua.LState) int {
+	buffer := checkBuffer(L, 1)
+	line := L.CheckInt(2)
+	if line < 1 || line > buffer.LineCount() {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LString(buffer.Line(line - 1)))
+	return 1
+}
+
+// bufferIndentation implements buf:indentation(line), the indentation of
+// a line in columns
+func bufferIndentation(L *lua.LState) int {
+	buffer := checkBuffer(L, 1)
+	line := L.CheckInt(2)
+	if line < 1 || line > buffer.LineCount() {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LNumber(buffer.Indentation(line - 1)))
+	return 1
+}
+
+func windowBuffer(L *lua.LState) int {
+	L.Push(newBufferObject(L, checkWindow(L, 1).Buffer()))
+	return 1
+}
+
+// bufferInsert implements buf:insert(text [, pos]), inserting at the cursor
+// by default, and returns the position after the inserted text
+func bufferInsert(L *lua.LState) int {
+	buffer := checkBuffer(L, 1)
+	text := L.CheckString(2)
+	pos := buffer.Cursor()
+	if L.GetTop() >= 3 {
+		pos = checkPosition(L, 3)
+	}
+	L.Push(positionToLua(L, buffer.InsertText(pos, text)))
+	return 1
+}
+
+// bufferDeleteRegion implements buf:delete_region(start, end) and returns
+// the deleted text
+func bufferDeleteRegion(L *lua.LState) int {
+	buffer := checkBuffer(L, 1)
+	start, end := checkPosition(L, 2), checkPosition(L, 3)
+	L.Push(lua.LString(buffer.DeleteRegion(start, end)))
+	return 1
+}
+
+func bufferCursor(L *lua.LState) int {
+	L.Push(positionToLua(L, checkBuffer(L, 1).Cursor()))
+	return 1
+}
+
+func bufferSetCursor(L *lua.LState) int {
+	checkBuffer(L, 1).SetCursor(checkPosition(L, 2))
+	return 0
+}
+
+func bufferMajorMode(L *lua.LState) int {
+	if mode := checkBuffer(L, 1).MajorMode(); mode != nil {
+		L.Push(lua.LString(mode.Name()))
+	} else {
+		L.Push(lua.LNil)
+	}
+	return 1
+}
+
+// bufferSave implements buf:save(), returning true or nil and an error message
+func (api *APIBindings) bufferSave(L *lua.LState) int {
+	var warning *domain.SaveWarning
+	if err := api.editor.WriteBuffer(checkBuffer(L, 1)); err != nil && !errors.As(err, &warning) {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// windowSplit implements win:split([direction]), where direction is "below"
+// (the default) or "right", and returns the new window
+func (api *APIBindings) windowSplit(L *lua.LState) int {
+	window := checkWindow(L, 1)
+	direction := L.OptString(2, "below")
+	if direction != "below" && direction != "right" {
+		L.ArgError(2, "\"below\" or \"right\" expected")
+	}
+	L.Push(newWindowObject(L, api.editor.SplitWindow(window, direction)))
+	return 1
+}
+
+func (api *APIBindings) windowSetBuffer(L *lua.LState) int {
+	api.editor.SetWindowBuffer(checkWindow(L, 1), checkBuffer(L, 2))
+	return 0
+}
+
+// windowScrollTop implements win:scroll_top([line]), returning the first
+// line shown in the window after scrolling it to line if given
+func windowScrollTop(L *lua.LState) int {
+	window := checkWindow(L, 1)
+	if L.GetTop() >= 2 {
+		window.SetScrollTop(L.CheckInt(2) - 1)
+	}
+	L.Push(lua.LNumber(window.ScrollTop() + 1))
+	return 1
+}
@@ -0,0 +1,102 @@
+package luaconfig
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/TakahashiShuuhei/gmacs/domain"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaGrammar builds a highlighter from the syntax table of gmacs.major_mode.
+// The array part of the table holds the rules of the root state, and its
+// states field the other states by name, each a table of rules with an
+// optional face for the text no rule matches.
+func luaGrammar(table *lua.LTable) (*domain.GrammarHighlighter, error) {
+	root, err := luaSyntaxState(table)
+	if err != nil {
+		return nil, err
+	}
+	grammar := domain.Grammar{States: map[string]*domain.SyntaxState{
+		domain.RootSyntaxState: root,
+	}}
+
+	if states, ok := table.RawGetString("states").(*lua.LTable); ok {
+		states.ForEach(func(key, value lua.LValue) {
+			stateTable, ok := value.(*lua.LTable)
+			if err != nil || !ok {
+				return
+			}
+			var state *domain.SyntaxState
+			state, err = luaSyntaxState(stateTable)
+			if err != nil {
+				err = &ConfigError{Message: "state " + key.String() + ": " + err.Error()}
+				return
+			}
+			grammar.States[key.String()] = state
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return domain.NewGrammarHighlighter(grammar)
+}
+
+// luaSyntaxState reads the face and the rules of a state
+func luaSyntaxState(table *lua.LTable) (*domain.SyntaxState, error) {
+	state := &domain.SyntaxState{}
+	if face, ok := table.RawGetString("face").(lua.LString); ok {
+		state.Face = string(face)
+	}
+	for i := 1; i <= table.Len(); i++ {
+		ruleTable, ok := table.RawGetInt(i).(*lua.LTable)
+		if !ok {
+			return nil, &ConfigError{Message: "rule " + strconv.Itoa(i) + " is not a table"}
+		}
+		rule, err := luaSyntaxRule(ruleTable)
+		if err != nil {
+			return nil, &ConfigError{Message: "rule " + strconv.Itoa(i) + ": " + err.Error()}
+		}
+		state.Rules = append(state.Rules, rule)
+	}
+	return state, nil
+}
+
+// luaSyntaxRule reads a rule. It matches either a list of keywords as whole
+// words, a Lua pattern or a regular expression in Go syntax.
+func luaSyntaxRule(table *lua.LTable) (domain.SyntaxRule, error) {
+	var expr string
+	if keywords := luaStringList(table.RawGetString("keywords")); len(keywords) > 0 {
+		for i, keyword := range keywords {
+			keywords[i] = regexp.QuoteMeta(keyword)
+		}
+		expr = `\b(?:` + strings.Join(keywords, "|") + `)\b`
+	} else if pattern, ok := table.RawGetString("pattern").(lua.LString); ok {
+		translated, err := translateLuaPattern(string(pattern))
+		if err != nil {
+			return domain.SyntaxRule{}, err
+		}
+		expr = translated
+	} else if regex, ok := table.RawGetString("regex").(lua.LString); ok {
+		expr = string(regex)
+	} else {
+		return domain.SyntaxRule{}, &ConfigError{Message: "needs keywords, pattern or regex"}
+	}
+
+	compiled, err := regexp.Compile(expr)
+	if err != nil {
+		return domain.SyntaxRule{}, err
+	}
+	rule := domain.SyntaxRule{
+		Pattern: compiled,
+		Pop:     lua.LVAsBool(table.RawGetString("pop")),
+	}
+	if face, ok := table.RawGetString("face").(lua.LString); ok {
+		rule.Face = string(face)
+	}
+	if push, ok := table.RawGetString("push").(lua.LString); ok {
+		rule.Push = string(push)
+	}
+	return rule, nil
+}
@@ -65,8 +65,17 @@ func main() {
 		gmacslog.Info("No user config file found, using defaults only")
 	}
 	
-	// Ensure cleanup on exit
+	// Restore the minibuffer history of the last session
+	historyPath := findHistoryFile(editor)
+	if historyPath != "" {
+		if err := editor.Minibuffer().History().Load(historyPath); err != nil {
+			gmacslog.Warn("Failed to load history: %v", err)
+		}
+	}
+	
+	// Ensure cleanup on exit (this also runs the editor-exit hooks)
 	defer editor.Cleanup()
+	editor.RunStartupHooks()
 	
 	width, height := display.Size()
 	resizeEvent := events.ResizeEventData{
@@ -80,6 +89,10 @@ func main() {
 
 	ticker := time.NewTicker(16 * time.Millisecond) // ~60 FPS
 	defer ticker.Stop()
+	
+	// Poll the visited files for changes made by other programs
+	revertTicker := time.NewTicker(editor.AutoRevertInterval())
+	defer revertTicker.Stop()
 
 	gmacslog.Info("Entering main loop")
 	needsRender := false
@@ -111,9 +124,19 @@ func main() {
 				display.Render(editor)
 				needsRender = false
 			}
+		case <-revertTicker.C:
+			if editor.CheckFileChanges() {
+				display.Render(editor)
+			}
 		}
 	}
 
+	if historyPath != "" {
+		if err := editor.Minibuffer().History().Save(historyPath); err != nil {
+			gmacslog.Error("Failed to save history: %v", err)
+		}
+	}
+	
 	// Clear screen and reset terminal state before exiting
 	gmacslog.Debug("Clearing screen on exit")
 	display.ClearAndExit()
@@ -145,4 +168,21 @@ func findConfigFile() string {
 	
 	gmacslog.Info("No config file found in standard locations")
 	return ""
-}
\ No newline at end of file
+}
+
+// findHistoryFile returns the file the minibuffer history is kept in: the
+// history-file option if set, else ~/.gmacs/history
+func findHistoryFile(editor *domain.Editor) string {
+	if value, err := editor.GetOption("history-file"); err == nil {
+		if path, ok := value.(string); ok && path != "" {
+			return path
+		}
+	}
+	
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		gmacslog.Warn("Could not get user home directory: %v", err)
+		return ""
+	}
+	return filepath.Join(homeDir, ".gmacs", "history")
+}